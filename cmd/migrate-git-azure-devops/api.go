@@ -10,6 +10,8 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -22,9 +24,300 @@ var httpClient = &http.Client{
 }
 
 // getRepos calls the Azure DevOps API to get the list of repositories.
-// Errors are returned to the caller for centralized handling.
+// Follows the continuation token (x-ms-continuationtoken) so that no repository is
+// missing on large projects. Errors are returned to the caller for centralized handling.
 func getRepos(ctx context.Context, org, project, pat string, trace bool) ([]Repo, error) {
-	path := fmt.Sprintf("_apis/git/repositories?api-version=%s", apiVersion)
+	var repos []Repo
+	err := paginate(ctx, org, project, fmt.Sprintf("_apis/git/repositories?api-version=%s", apiVersion), pat, trace, func(body []byte) error {
+		var resp listReposResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return fmt.Errorf("invalid response: %w", err)
+		}
+		repos = append(repos, resp.Value...)
+		return nil
+	})
+	return repos, err
+}
+
+// continuationHeader is the response header carrying the token of the next page.
+const continuationHeader = "X-Ms-Continuationtoken"
+
+// paginate GETs path page by page, passing every page body to handle, until the
+// response carries no continuation token.
+func paginate(ctx context.Context, org, project, path, pat string, trace bool, handle func([]byte) error) error {
+	token := ""
+	for {
+		pagePath := path
+		if token != "" {
+			pagePath += "&continuationToken=" + url.QueryEscape(token)
+		}
+		body, code, header, err := httpReqHeaders(ctx, "GET", org, project, pagePath, pat, nil, trace)
+		if err != nil {
+			return err
+		}
+		if code < 200 || code >= 300 {
+			return fmt.Errorf("API error (HTTP %d): %s", code, string(body))
+		}
+		if err := handle(body); err != nil {
+			return err
+		}
+		next := header.Get(continuationHeader)
+		if next == "" || next == token {
+			return nil
+		}
+		token = next
+	}
+}
+
+// PolicyConfiguration is a branch policy configured on a repository.
+// Raw keeps the full JSON document, needed to update the configuration.
+type PolicyConfiguration struct {
+	ID         int  `json:"id"`
+	IsEnabled  bool `json:"isEnabled"`
+	IsBlocking bool `json:"isBlocking"`
+	Type       struct {
+		DisplayName string `json:"displayName"`
+	} `json:"type"`
+	Settings struct {
+		Scope []PolicyScope `json:"scope"`
+	} `json:"settings"`
+	Raw json.RawMessage `json:"-"`
+}
+
+// PolicyScope is an entry of the scope of a policy: RepositoryID is empty for the
+// entries applying to every repository of the project.
+type PolicyScope struct {
+	RepositoryID string `json:"repositoryId"`
+	RefName      string `json:"refName"`
+	MatchKind    string `json:"matchKind"`
+}
+
+// getPolicies returns the policy configurations applying to the given repository
+// (both repository-scoped and project-wide ones).
+func getPolicies(ctx context.Context, org, project, pat, repoID string, trace bool) ([]PolicyConfiguration, error) {
+	path := fmt.Sprintf("_apis/git/policy/configurations?repositoryId=%s&$top=100&api-version=%s", url.QueryEscape(repoID), apiVersion)
+	return listPolicies(ctx, org, project, path, pat, trace)
+}
+
+// getProjectPolicies returns every policy configuration of the project.
+func getProjectPolicies(ctx context.Context, org, project, pat string, trace bool) ([]PolicyConfiguration, error) {
+	path := fmt.Sprintf("_apis/policy/configurations?$top=100&api-version=%s", apiVersion)
+	return listPolicies(ctx, org, project, path, pat, trace)
+}
+
+// listPolicies reads the pages of policy configurations of path.
+func listPolicies(ctx context.Context, org, project, path, pat string, trace bool) ([]PolicyConfiguration, error) {
+	var policies []PolicyConfiguration
+	err := paginate(ctx, org, project, path, pat, trace, func(body []byte) error {
+		var resp struct {
+			Value []json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return fmt.Errorf("invalid response: %w", err)
+		}
+		for _, raw := range resp.Value {
+			var p PolicyConfiguration
+			if err := json.Unmarshal(raw, &p); err != nil {
+				return fmt.Errorf("invalid response: %w", err)
+			}
+			p.Raw = raw
+			policies = append(policies, p)
+		}
+		return nil
+	})
+	return policies, err
+}
+
+// getPolicy returns a policy configuration by ID.
+func getPolicy(ctx context.Context, org, project, pat string, id int, trace bool) (PolicyConfiguration, error) {
+	path := fmt.Sprintf("_apis/policy/configurations/%d?api-version=%s", id, apiVersion)
+	body, code, err := httpReq(ctx, "GET", org, project, path, pat, nil, trace)
+	if err != nil {
+		return PolicyConfiguration{}, err
+	}
+	if code < 200 || code >= 300 {
+		return PolicyConfiguration{}, fmt.Errorf("API error reading policy %d (HTTP %d): %s", id, code, string(body))
+	}
+	var p PolicyConfiguration
+	if err := json.Unmarshal(body, &p); err != nil {
+		return PolicyConfiguration{}, fmt.Errorf("invalid response: %w", err)
+	}
+	p.Raw = body
+	return p, nil
+}
+
+// setPolicyEnabled enables or disables a policy configuration, sending back its full document.
+func setPolicyEnabled(ctx context.Context, org, project, pat string, policy PolicyConfiguration, enabled bool, trace bool) error {
+	var doc map[string]any
+	if err := json.Unmarshal(policy.Raw, &doc); err != nil {
+		return fmt.Errorf("invalid policy document: %w", err)
+	}
+	doc["isEnabled"] = enabled
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("error encoding payload: %w", err)
+	}
+	path := fmt.Sprintf("_apis/policy/configurations/%d?api-version=%s", policy.ID, apiVersion)
+	body, code, err := httpReq(ctx, "PUT", org, project, path, pat, payload, trace)
+	if err != nil {
+		return err
+	}
+	if code < 200 || code >= 300 {
+		return fmt.Errorf("API error updating policy %d (HTTP %d): %s", policy.ID, code, string(body))
+	}
+	return nil
+}
+
+// getRepo returns a single repository by name or ID.
+func getRepo(ctx context.Context, org, project, pat, name string, trace bool) (Repo, error) {
+	path := fmt.Sprintf("_apis/git/repositories/%s?api-version=%s", url.PathEscape(name), apiVersion)
+	body, code, err := httpReq(ctx, "GET", org, project, path, pat, nil, trace)
+	if err != nil {
+		return Repo{}, err
+	}
+	if code < 200 || code >= 300 {
+		return Repo{}, fmt.Errorf("API error (HTTP %d): %s", code, string(body))
+	}
+	var repo Repo
+	if err := json.Unmarshal(body, &repo); err != nil {
+		return Repo{}, fmt.Errorf("invalid response: %w", err)
+	}
+	return repo, nil
+}
+
+// getProjectID returns the ID of a project by name.
+func getProjectID(ctx context.Context, org, project, pat string, trace bool) (string, error) {
+	path := fmt.Sprintf("_apis/projects/%s?api-version=%s", url.PathEscape(project), apiVersion)
+	body, code, err := httpReq(ctx, "GET", org, "", path, pat, nil, trace)
+	if err != nil {
+		return "", err
+	}
+	if code < 200 || code >= 300 {
+		return "", fmt.Errorf("API error reading project %s (HTTP %d): %s", project, code, string(body))
+	}
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("invalid response: %w", err)
+	}
+	return resp.ID, nil
+}
+
+// setRepoDisabled disables or enables a repository by ID. Disabled repositories can't be
+// read nor written, not even by git.
+func setRepoDisabled(ctx context.Context, org, project, pat, repoID string, disabled bool, trace bool) error {
+	path := fmt.Sprintf("_apis/git/repositories/%s?api-version=%s", url.PathEscape(repoID), apiVersion)
+	payload, err := json.Marshal(map[string]bool{"isDisabled": disabled})
+	if err != nil {
+		return fmt.Errorf("error encoding payload: %w", err)
+	}
+	body, code, err := httpReq(ctx, "PATCH", org, project, path, pat, payload, trace)
+	if err != nil {
+		return err
+	}
+	if code < 200 || code >= 300 {
+		return fmt.Errorf("API error updating repo (HTTP %d): %s", code, string(body))
+	}
+	return nil
+}
+
+// deleteRepo deletes a repository by ID (Azure DevOps keeps it in the recycle bin).
+func deleteRepo(ctx context.Context, org, project, pat, repoID string, trace bool) error {
+	path := fmt.Sprintf("_apis/git/repositories/%s?api-version=%s", url.PathEscape(repoID), apiVersion)
+	body, code, err := httpReq(ctx, "DELETE", org, project, path, pat, nil, trace)
+	if err != nil {
+		return err
+	}
+	if code < 200 || code >= 300 {
+		return fmt.Errorf("API error deleting repo (HTTP %d): %s", code, string(body))
+	}
+	return nil
+}
+
+// getItemContent downloads a file from the default branch of a repository.
+// Returns found=false when the repository or the file does not exist.
+func getItemContent(ctx context.Context, org, project, pat, repo, filePath string, trace bool) ([]byte, bool, error) {
+	path := fmt.Sprintf("_apis/git/repositories/%s/items?path=%s&download=true&api-version=%s", url.PathEscape(repo), url.QueryEscape(filePath), apiVersion)
+	body, code, err := httpReq(ctx, "GET", org, project, path, pat, nil, trace)
+	if err != nil {
+		return nil, false, err
+	}
+	if code == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if code < 200 || code >= 300 {
+		return nil, false, fmt.Errorf("API error (HTTP %d): %s", code, string(body))
+	}
+	return body, true, nil
+}
+
+// getRepoDetails returns the date of the last push and the number of branches of a repository.
+func getRepoDetails(ctx context.Context, org, project, pat, repoID string, trace bool) (RepoDetails, error) {
+	var d RepoDetails
+	path := fmt.Sprintf("_apis/git/repositories/%s/pushes?$top=1&api-version=%s", url.PathEscape(repoID), apiVersion)
+	body, code, err := httpReq(ctx, "GET", org, project, path, pat, nil, trace)
+	if err != nil {
+		return d, err
+	}
+	if code < 200 || code >= 300 {
+		return d, fmt.Errorf("API error (HTTP %d): %s", code, string(body))
+	}
+	var pushes struct {
+		Value []struct {
+			Date time.Time `json:"date"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(body, &pushes); err != nil {
+		return d, fmt.Errorf("invalid response: %w", err)
+	}
+	if len(pushes.Value) > 0 {
+		d.LastPush = pushes.Value[0].Date
+	}
+
+	refsPath := fmt.Sprintf("_apis/git/repositories/%s/refs?filter=heads/&api-version=%s", url.PathEscape(repoID), apiVersion)
+	err = paginate(ctx, org, project, refsPath, pat, trace, func(body []byte) error {
+		var refs struct {
+			Count int `json:"count"`
+		}
+		if err := json.Unmarshal(body, &refs); err != nil {
+			return fmt.Errorf("invalid response: %w", err)
+		}
+		d.NumBranches += refs.Count
+		return nil
+	})
+	return d, err
+}
+
+// getRefCount returns the number of refs of a repository under a prefix (heads/, tags/).
+func getRefCount(ctx context.Context, org, project, pat, repoID, prefix string, trace bool) (int, error) {
+	path := fmt.Sprintf("_apis/git/repositories/%s/refs?filter=%s&api-version=%s", url.PathEscape(repoID), url.QueryEscape(prefix), apiVersion)
+	n := 0
+	err := paginate(ctx, org, project, path, pat, trace, func(body []byte) error {
+		var refs struct {
+			Count int `json:"count"`
+		}
+		if err := json.Unmarshal(body, &refs); err != nil {
+			return fmt.Errorf("invalid response: %w", err)
+		}
+		n += refs.Count
+		return nil
+	})
+	return n, err
+}
+
+// CommitAuthor is the author of a commit returned by the commits API.
+type CommitAuthor struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+// getCommitAuthors returns the authors of the most recent commits (newest first) of the
+// default branch, up to top commits not older than since.
+func getCommitAuthors(ctx context.Context, org, project, pat, repoID string, since time.Time, top int, trace bool) ([]CommitAuthor, error) {
+	path := fmt.Sprintf("_apis/git/repositories/%s/commits?searchCriteria.fromDate=%s&searchCriteria.$top=%d&api-version=%s",
+		url.PathEscape(repoID), url.QueryEscape(since.UTC().Format(time.RFC3339)), top, apiVersion)
 	body, code, err := httpReq(ctx, "GET", org, project, path, pat, nil, trace)
 	if err != nil {
 		return nil, err
@@ -32,58 +325,153 @@ func getRepos(ctx context.Context, org, project, pat string, trace bool) ([]Repo
 	if code < 200 || code >= 300 {
 		return nil, fmt.Errorf("API error (HTTP %d): %s", code, string(body))
 	}
-	var resp listReposResponse
+	var resp struct {
+		Value []struct {
+			Author CommitAuthor `json:"author"`
+		} `json:"value"`
+	}
 	if err := json.Unmarshal(body, &resp); err != nil {
 		return nil, fmt.Errorf("invalid response: %w", err)
 	}
-	return resp.Value, nil
+	authors := make([]CommitAuthor, 0, len(resp.Value))
+	for _, c := range resp.Value {
+		authors = append(authors, c.Author)
+	}
+	return authors, nil
+}
+
+// setRefLocked locks or unlocks a branch (refName like "refs/heads/main") of a repository.
+func setRefLocked(ctx context.Context, org, project, pat, repoID, refName string, locked bool, trace bool) error {
+	filter := strings.TrimPrefix(refName, "refs/")
+	path := fmt.Sprintf("_apis/git/repositories/%s/refs?filter=%s&api-version=%s", url.PathEscape(repoID), url.QueryEscape(filter), apiVersion)
+	payload, err := json.Marshal(map[string]bool{"isLocked": locked})
+	if err != nil {
+		return fmt.Errorf("error encoding payload: %w", err)
+	}
+	body, code, err := httpReq(ctx, "PATCH", org, project, path, pat, payload, trace)
+	if err != nil {
+		return err
+	}
+	if code < 200 || code >= 300 {
+		return fmt.Errorf("API error locking %s (HTTP %d): %s", refName, code, string(body))
+	}
+	return nil
+}
+
+// GitRef is a branch of a repository with its lock state.
+type GitRef struct {
+	Name     string `json:"name"` // e.g. refs/heads/main
+	ObjectID string `json:"objectId"`
+	IsLocked bool   `json:"isLocked"`
+}
+
+// getBranchRefs returns the branches of a repository.
+func getBranchRefs(ctx context.Context, org, project, pat, repoID string, trace bool) ([]GitRef, error) {
+	path := fmt.Sprintf("_apis/git/repositories/%s/refs?filter=heads/&api-version=%s", url.PathEscape(repoID), apiVersion)
+	var refs []GitRef
+	err := paginate(ctx, org, project, path, pat, trace, func(body []byte) error {
+		var resp struct {
+			Value []GitRef `json:"value"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return fmt.Errorf("invalid response: %w", err)
+		}
+		refs = append(refs, resp.Value...)
+		return nil
+	})
+	return refs, err
 }
 
-// createRepo creates a destination repository via Azure DevOps API.
+// createRepo creates a destination repository via Azure DevOps API and returns it (with its ID).
 // Errors are returned to the caller for centralized handling.
-func createRepo(ctx context.Context, org, project, pat, name string, trace bool) error {
+func createRepo(ctx context.Context, org, project, pat, name string, trace bool) (Repo, error) {
 	path := fmt.Sprintf("_apis/git/repositories?api-version=%s", apiVersion)
 	payload := map[string]string{"name": name}
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(payload); err != nil {
-		return fmt.Errorf("error encoding payload: %w", err)
+		return Repo{}, fmt.Errorf("error encoding payload: %w", err)
 	}
 	body, code, err := httpReq(ctx, "POST", org, project, path, pat, buf.Bytes(), trace)
 	if err != nil {
-		return err
+		return Repo{}, err
 	}
 	if code != 200 && code != 201 {
-		return fmt.Errorf("API error creating repo (HTTP %d): %s", code, string(body))
+		return Repo{}, fmt.Errorf("API error creating repo (HTTP %d): %s", code, string(body))
 	}
-	return nil
+	var repo Repo
+	if err := json.Unmarshal(body, &repo); err != nil {
+		return Repo{}, fmt.Errorf("invalid response: %w", err)
+	}
+	return repo, nil
 }
 
-// httpReq performs an authenticated HTTP request using Basic (with PAT) to Azure DevOps.
+// maxThrottleRetries is the number of times a throttled request (429/503) is retried.
+const maxThrottleRetries = 5
+
+// throttleRetryable reports whether a response asks to retry the request. A 429 rejects
+// the request before it is processed and is always retried. A 503 may come after the
+// server acted on it, so POST and PATCH, which are not idempotent (a repository or a pull
+// request would be created twice), are retried on 503 only when it carries Retry-After.
+func throttleRetryable(method string, code int, h http.Header) bool {
+	switch code {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable:
+		return (method != "POST" && method != "PATCH") || h.Get("Retry-After") != ""
+	}
+	return false
+}
+
+// httpReq performs an authenticated HTTP request to Azure DevOps (Basic with PAT, Bearer with Entra ID token).
 // - Does not follow redirects (CheckRedirect -> ErrUseLastResponse) to intercept 3xx.
+// - Retries throttled requests (HTTP 429/503, see throttleRetryable) honoring Retry-After / X-RateLimit-Reset.
 // - Returns body, status code, and any network/IO error.
 func httpReq(ctx context.Context, method, org, project, path, pat string, body []byte, trace bool) ([]byte, int, error) {
+	data, code, _, err := httpReqHeaders(ctx, method, org, project, path, pat, body, trace)
+	return data, code, err
+}
+
+// httpReqHeaders is httpReq also returning the response headers (e.g. continuation tokens).
+func httpReqHeaders(ctx context.Context, method, org, project, path, pat string, body []byte, trace bool) ([]byte, int, http.Header, error) {
 	var urlStr string
 	if project == "" || project == "-" {
 		urlStr = fmt.Sprintf("https://dev.azure.com/%s/%s", org, path)
 	} else {
 		urlStr = fmt.Sprintf("https://dev.azure.com/%s/%s/%s", org, url.PathEscape(project), path)
 	}
-	if trace {
-		fmt.Fprintln(os.Stderr, "[TRACE]", method, urlStr)
+
+	for attempt := 1; ; attempt++ {
+		if trace {
+			fmt.Fprintln(os.Stderr, "[TRACE]", method, urlStr)
+		}
+		data, code, header, err := doHTTPReq(ctx, method, urlStr, pat, body)
+		if err != nil || !throttleRetryable(method, code, header) || attempt > maxThrottleRetries {
+			return data, code, header, err
+		}
+		wait := throttleDelay(header, attempt)
+		fmt.Fprintf(os.Stderr, "[THROTTLED] HTTP %d from Azure DevOps, retrying in %s (attempt %d/%d)\n", code, wait, attempt, maxThrottleRetries)
+		select {
+		case <-ctx.Done():
+			return data, code, header, ctx.Err()
+		case <-time.After(wait):
+		}
 	}
+}
 
+// doHTTPReq performs a single request attempt and returns body, status code and headers.
+func doHTTPReq(ctx context.Context, method, urlStr, pat string, body []byte) ([]byte, int, http.Header, error) {
 	req, err := http.NewRequestWithContext(ctx, method, urlStr, bytes.NewReader(body))
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
-	req.Header.Set("Authorization", basicAuth(pat))
-	if method == "POST" {
+	req.Header.Set("Authorization", authHeader(pat))
+	if method == "POST" || method == "PUT" || method == "PATCH" {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -93,16 +481,43 @@ func httpReq(ctx context.Context, method, org, project, path, pat string, body [
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, resp.StatusCode, fmt.Errorf("error reading response: %w", err)
+		return nil, resp.StatusCode, resp.Header, fmt.Errorf("error reading response: %w", err)
 	}
 
 	// Azure DevOps responds with 302 to a login page instead of 401 if the PAT is invalid.
 	// We intercept this case to provide a clearer error.
 	if resp.StatusCode == http.StatusFound { // 302
-		return data, http.StatusUnauthorized, fmt.Errorf("authentication failed (received HTTP 302, likely invalid or expired PAT)")
+		return data, http.StatusUnauthorized, resp.Header, fmt.Errorf("authentication failed (received HTTP 302, likely invalid or expired PAT)")
 	}
 
-	return data, resp.StatusCode, nil
+	return data, resp.StatusCode, resp.Header, nil
+}
+
+// throttleDelay computes how long to wait before retrying a throttled request:
+// Retry-After (seconds or HTTP date), then X-RateLimit-Reset (Unix time), then an
+// exponential backoff. The wait is capped to 5 minutes.
+func throttleDelay(h http.Header, attempt int) time.Duration {
+	const maxWait = 5 * time.Minute
+	wait := time.Duration(0)
+	if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			wait = time.Duration(secs) * time.Second
+		} else if t, err := http.ParseTime(v); err == nil {
+			wait = time.Until(t)
+		}
+	}
+	if wait <= 0 {
+		if v := h.Get("X-RateLimit-Reset"); v != "" {
+			if epoch, err := strconv.ParseInt(v, 10, 64); err == nil {
+				wait = time.Until(time.Unix(epoch, 0))
+			}
+		}
+	}
+	if wait <= 0 {
+		wait = backoffDelay(2*time.Second, attempt)
+	}
+	wait = min(max(wait, time.Second), maxWait)
+	return wait.Round(time.Second)
 }
 
 // basicAuth builds the Authorization Basic header from the provided PAT.
@@ -110,20 +525,3 @@ func basicAuth(pat string) string {
 	token := ":" + pat
 	return "Basic " + base64.StdEncoding.EncodeToString([]byte(token))
 }
-
-// redactToken masks any credentials present in a URL, useful for safe log/trace.
-func redactToken(s string) string {
-	if s == "" {
-		return ""
-	}
-	u, err := url.Parse(s)
-	if err != nil {
-		// If parsing fails, return the original string to not block logging
-		return s
-	}
-	if u.User != nil {
-		u.User = url.UserPassword("user", "***")
-		return u.String()
-	}
-	return s
-}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestThrottleRetryable(t *testing.T) {
+	retryAfter := http.Header{"Retry-After": {"10"}}
+	tests := []struct {
+		method string
+		code   int
+		header http.Header
+		want   bool
+	}{
+		{"GET", http.StatusTooManyRequests, nil, true},
+		{"POST", http.StatusTooManyRequests, nil, true},
+		{"GET", http.StatusServiceUnavailable, nil, true},
+		{"PUT", http.StatusServiceUnavailable, nil, true},
+		{"DELETE", http.StatusServiceUnavailable, nil, true},
+		{"POST", http.StatusServiceUnavailable, nil, false},
+		{"PATCH", http.StatusServiceUnavailable, nil, false},
+		{"POST", http.StatusServiceUnavailable, retryAfter, true},
+		{"GET", http.StatusOK, nil, false},
+		{"POST", http.StatusInternalServerError, retryAfter, false},
+	}
+	for _, tt := range tests {
+		if got := throttleRetryable(tt.method, tt.code, tt.header); got != tt.want {
+			t.Errorf("throttleRetryable(%s, %d, %v) = %v, want %v", tt.method, tt.code, tt.header, got, tt.want)
+		}
+	}
+}
+
+// servePages answers the GETs with the page of their continuation token: pages maps the
+// token ("" for the first page) to the repositories and the token of the next page.
+func servePages(t *testing.T, pages map[string][2]string) *[]string {
+	t.Helper()
+	var queries []string
+	prev := httpClient.Transport
+	httpClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		queries = append(queries, req.URL.RawQuery)
+		page, ok := pages[req.URL.Query().Get("continuationToken")]
+		if !ok {
+			return &http.Response{StatusCode: http.StatusBadRequest, Header: http.Header{},
+				Body: io.NopCloser(strings.NewReader("unknown token")), Request: req}, nil
+		}
+		var value []string
+		for _, name := range strings.Fields(page[0]) {
+			value = append(value, fmt.Sprintf(`{"id":"id-%s","name":%q}`, name, name))
+		}
+		header := http.Header{}
+		if page[1] != "" {
+			header.Set(continuationHeader, page[1])
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Request: req,
+			Body: io.NopCloser(strings.NewReader(`{"value":[` + strings.Join(value, ",") + `]}`))}, nil
+	})
+	t.Cleanup(func() { httpClient.Transport = prev })
+	return &queries
+}
+
+func TestGetReposPagination(t *testing.T) {
+	const second, third = "page 2/a+b=", "page3"
+	queries := servePages(t, map[string][2]string{
+		"":     {"Horse-Core Horse-Web", second},
+		second: {"Horse-Api", third},
+		third:  {"Horse-Docs", ""},
+	})
+	repos, err := getRepos(context.Background(), "contoso", "Horse", "pat", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range repos {
+		names = append(names, r.Name)
+	}
+	if got := strings.Join(names, ","); got != "Horse-Core,Horse-Web,Horse-Api,Horse-Docs" {
+		t.Errorf("repos = %s, want the four repositories of the three pages", got)
+	}
+	if len(*queries) != 3 || !strings.Contains((*queries)[1], "continuationToken="+url.QueryEscape(second)) {
+		t.Errorf("queries = %q, want three with the escaped token %q", *queries, url.QueryEscape(second))
+	}
+}
+
+func TestPaginateRepeatedToken(t *testing.T) {
+	// A server returning the token it was given would loop forever
+	queries := servePages(t, map[string][2]string{
+		"":      {"Horse-Core", "again"},
+		"again": {"Horse-Web", "again"},
+	})
+	repos, err := getRepos(context.Background(), "contoso", "Horse", "pat", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 || len(*queries) != 2 {
+		t.Errorf("%d repos in %d requests, want 2 in 2", len(repos), len(*queries))
+	}
+}
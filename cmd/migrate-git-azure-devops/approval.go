@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Decisions applied when an approval request times out (--approval-on-timeout).
+const (
+	ApprovalSkip    = "skip"
+	ApprovalProceed = "proceed"
+)
+
+// approvalGate asks a human, through a Slack/Teams incoming webhook, whether a force push
+// that was not requested on the command line may be performed. The message contains two
+// links served by a small local HTTP listener: each opens a confirmation page whose button
+// records the decision, the first confirmation decides. The links themselves change
+// nothing: chat link previews fetch them without anyone clicking.
+type approvalGate struct {
+	webhook   string
+	publicURL string
+	timeout   time.Duration
+	onTimeout string
+	trace     bool
+
+	mu      sync.Mutex
+	pending map[string]pendingApproval
+	server  *http.Server
+}
+
+// pendingApproval is an approval request waiting for its decision.
+type pendingApproval struct {
+	repo     string
+	decision chan bool
+}
+
+// approvalPage is the confirmation page opened by the links of the message.
+var approvalPage = template.Must(template.New("approval").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Migration approval</title></head>
+<body>
+<p>Force push over the destination repository <strong>{{ .Repo }}</strong>: {{ .Decision }}?</p>
+<form method="post" action="/approval">
+<input type="hidden" name="token" value="{{ .Token }}">
+<input type="hidden" name="decision" value="{{ .Decision }}">
+<button type="submit">Confirm: {{ .Decision }}</button>
+</form>
+</body></html>
+`))
+
+// newApprovalGate starts the callback listener and returns the gate.
+// Returns nil when no webhook is configured (gate disabled).
+func newApprovalGate(cfg Config) (*approvalGate, error) {
+	if cfg.ApprovalWebhook == "" {
+		return nil, nil
+	}
+	if cfg.ApprovalOnTimeout != ApprovalSkip && cfg.ApprovalOnTimeout != ApprovalProceed {
+		return nil, fmt.Errorf("unsupported --approval-on-timeout: %s (only skip, proceed are allowed)", cfg.ApprovalOnTimeout)
+	}
+	ln, err := net.Listen("tcp", cfg.ApprovalListen)
+	if err != nil {
+		return nil, fmt.Errorf("approval listener on %s: %w", cfg.ApprovalListen, err)
+	}
+	publicURL := strings.TrimRight(cfg.ApprovalURL, "/")
+	if addr := ln.Addr().(*net.TCPAddr); publicURL == "" && addr.IP.IsUnspecified() {
+		host, _ := os.Hostname()
+		publicURL = fmt.Sprintf("http://%s:%d", host, addr.Port)
+	} else if publicURL == "" {
+		publicURL = "http://" + addr.String()
+	}
+	g := &approvalGate{
+		webhook:   cfg.ApprovalWebhook,
+		publicURL: publicURL,
+		timeout:   cfg.ApprovalTimeout,
+		onTimeout: cfg.ApprovalOnTimeout,
+		trace:     cfg.Trace,
+		pending:   map[string]pendingApproval{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/approval", g.handle)
+	g.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := g.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintln(os.Stderr, "Approval listener error:", err)
+		}
+	}()
+	return g, nil
+}
+
+// Close stops the callback listener.
+func (g *approvalGate) Close() {
+	if g == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = g.server.Shutdown(ctx)
+}
+
+// handle serves the links of the message: GET /approval?token=...&decision=proceed|skip
+// returns the confirmation page, only its POST records the decision.
+func (g *approvalGate) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	token := r.Form.Get("token")
+	decision := r.Form.Get("decision")
+	if decision != ApprovalProceed && decision != ApprovalSkip {
+		http.Error(w, "unknown decision", http.StatusBadRequest)
+		return
+	}
+	g.mu.Lock()
+	p, ok := g.pending[token]
+	if ok && r.Method == http.MethodPost {
+		delete(g.pending, token)
+	}
+	g.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown or already answered approval request", http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = approvalPage.Execute(w, map[string]string{"Repo": p.repo, "Token": token, "Decision": decision})
+		return
+	}
+	p.decision <- decision == ApprovalProceed
+	_, _ = fmt.Fprintf(w, "Decision recorded: %s\n", decision)
+}
+
+// Request posts the approval message for the repository and waits for a decision,
+// falling back to the timeout policy. Returns true if the force push may proceed.
+func (g *approvalGate) Request(ctx context.Context, repo, reason string) (bool, string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return false, "", err
+	}
+	token := hex.EncodeToString(buf)
+	ch := make(chan bool, 1)
+	g.mu.Lock()
+	g.pending[token] = pendingApproval{repo: repo, decision: ch}
+	g.mu.Unlock()
+	defer func() {
+		g.mu.Lock()
+		delete(g.pending, token)
+		g.mu.Unlock()
+	}()
+
+	link := func(decision string) string {
+		return fmt.Sprintf("%s/approval?token=%s&decision=%s", g.publicURL, token, decision)
+	}
+	text := fmt.Sprintf("Migration approval required for repository *%s*: %s.\n"+
+		"Force push over the destination? Without an answer within %s the repo will be: %s.\n"+
+		"Proceed: %s\nSkip: %s", repo, reason, g.timeout, g.onTimeout, link(ApprovalProceed), link(ApprovalSkip))
+	if err := g.post(ctx, text); err != nil {
+		return false, "", err
+	}
+	fmt.Printf("  Waiting for approval (timeout %s)...\n", g.timeout)
+
+	select {
+	case ok := <-ch:
+		if ok {
+			return true, "approved", nil
+		}
+		return false, "denied", nil
+	case <-time.After(g.timeout):
+		return g.onTimeout == ApprovalProceed, "timeout", nil
+	case <-ctx.Done():
+		return false, "", ctx.Err()
+	}
+}
+
+// post sends a message to the approval webhook.
+func (g *approvalGate) post(ctx context.Context, text string) error {
+	return postWebhook(ctx, g.webhook, text, g.trace)
+}
+
+// postWebhook sends a plain text message; the {"text": ...} payload is accepted by both
+// Slack and Microsoft Teams incoming webhooks.
+func postWebhook(ctx context.Context, webhook, text string, trace bool) error {
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	if trace {
+		fmt.Fprintln(os.Stderr, "[TRACE] POST webhook")
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error closing HTTP response:", err)
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook error (HTTP %d)", resp.StatusCode)
+	}
+	return nil
+}
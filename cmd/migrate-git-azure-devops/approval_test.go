@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestApprovalHandle(t *testing.T) {
+	g := &approvalGate{pending: map[string]pendingApproval{}}
+	ch := make(chan bool, 1)
+	g.pending["tok"] = pendingApproval{repo: "horse-<core>", decision: ch}
+
+	// A link preview fetching the URL must not decide
+	rec := httptest.NewRecorder()
+	g.handle(rec, httptest.NewRequest(http.MethodGet, "/approval?token=tok&decision=proceed", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `method="post"`) {
+		t.Fatalf("GET: got %d %q, want the confirmation page", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "horse-<core>") {
+		t.Errorf("GET: repository name not escaped")
+	}
+	select {
+	case <-ch:
+		t.Fatal("GET recorded a decision")
+	default:
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		form     url.Values
+		wantCode int
+	}{
+		{"unknown decision", http.MethodPost, url.Values{"token": {"tok"}, "decision": {"maybe"}}, http.StatusBadRequest},
+		{"unknown token", http.MethodPost, url.Values{"token": {"other"}, "decision": {"proceed"}}, http.StatusNotFound},
+		{"method not allowed", http.MethodDelete, url.Values{"token": {"tok"}, "decision": {"proceed"}}, http.StatusMethodNotAllowed},
+		{"confirmation", http.MethodPost, url.Values{"token": {"tok"}, "decision": {"proceed"}}, http.StatusOK},
+		{"already answered", http.MethodPost, url.Values{"token": {"tok"}, "decision": {"skip"}}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/approval", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			g.handle(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Errorf("got HTTP %d, want %d", rec.Code, tt.wantCode)
+			}
+		})
+	}
+	select {
+	case ok := <-ch:
+		if !ok {
+			t.Error("decision recorded as skip, want proceed")
+		}
+	default:
+		t.Error("POST did not record the decision")
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// artifactStore is the remote location receiving the evidence of a run (--artifact-store):
+// azblob://{container}/{prefix} or s3://{bucket}/{prefix}.
+type artifactStore struct {
+	Scheme    string // azblob or s3
+	Container string // Blob container or S3 bucket
+	Prefix    string // Key prefix, without leading/trailing slashes
+}
+
+// parseArtifactStore parses --artifact-store and checks that the credentials of the
+// store are available in the environment.
+func parseArtifactStore(raw string) (artifactStore, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return artifactStore{}, fmt.Errorf("invalid --artifact-store: %w", err)
+	}
+	store := artifactStore{Scheme: u.Scheme, Container: u.Host, Prefix: strings.Trim(u.Path, "/")}
+	if store.Container == "" {
+		return store, fmt.Errorf("invalid --artifact-store %q: container/bucket missing", raw)
+	}
+	switch store.Scheme {
+	case "azblob":
+		if os.Getenv("AZURE_STORAGE_ACCOUNT") == "" || os.Getenv("AZURE_STORAGE_SAS_TOKEN") == "" {
+			return store, fmt.Errorf("--artifact-store azblob:// requires AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_SAS_TOKEN")
+		}
+	case "s3":
+		if os.Getenv("AWS_ACCESS_KEY_ID") == "" || os.Getenv("AWS_SECRET_ACCESS_KEY") == "" {
+			return store, fmt.Errorf("--artifact-store s3:// requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+	default:
+		return store, fmt.Errorf("unsupported --artifact-store scheme %q (only azblob, s3 are allowed)", store.Scheme)
+	}
+	return store, nil
+}
+
+// uploadArtifacts uploads the files of a run (file -> subdirectory, e.g. backups) under
+// {prefix}/{program}-{host}-{timestamp}/. Errors are reported per file and the upload
+// goes on with the next one.
+func uploadArtifacts(cfg Config, files map[string]string, hostname string) error {
+	store, err := parseArtifactStore(cfg.ArtifactStore)
+	if err != nil {
+		return err
+	}
+	run := fmt.Sprintf("%s-%s-%s", prog(), hostname, time.Now().UTC().Format("20060102T150405Z"))
+	failed := 0
+	for file, dir := range files {
+		key := path.Join(store.Prefix, run, dir, filepath.Base(file))
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+		err := store.put(ctx, key, file, cfg.Trace)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Upload of %s failed: %v\n", file, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Uploaded %s to %s://%s/%s\n", file, store.Scheme, store.Container, key)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d artifacts not uploaded", failed, len(files))
+	}
+	return nil
+}
+
+// put uploads a file to key with a single PUT request (up to 5 GiB).
+func (s artifactStore) put(ctx context.Context, key, file string, trace bool) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	var req *http.Request
+	switch s.Scheme {
+	case "azblob":
+		endpoint := fmt.Sprintf("https://%s.blob.core.windows.net/%s/%s?%s", os.Getenv("AZURE_STORAGE_ACCOUNT"),
+			s.Container, escapeKey(key), strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?"))
+		if req, err = http.NewRequestWithContext(ctx, "PUT", endpoint, f); err != nil {
+			return err
+		}
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+		req.Header.Set("x-ms-version", "2021-08-06")
+	case "s3":
+		if req, err = http.NewRequestWithContext(ctx, "PUT", s3ObjectURL(s.Container, key), f); err != nil {
+			return err
+		}
+		signS3(req, time.Now().UTC())
+	}
+	req.ContentLength = info.Size()
+	if trace {
+		fmt.Fprintf(os.Stderr, "[TRACE] PUT %s://%s/%s (%d bytes)\n", s.Scheme, s.Container, key, info.Size())
+	}
+
+	// No overall timeout: bundles can take long to upload, the context bounds the request
+	client := &http.Client{Transport: httpClient.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error closing HTTP response:", err)
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// escapeKey escapes every segment of an object key with the RFC 3986 rules required
+// by the S3 signature (only A-Z a-z 0-9 - _ . ~ are left as is).
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		var b strings.Builder
+		for _, c := range []byte(seg) {
+			if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+				b.WriteByte(c)
+			} else {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		}
+		segments[i] = b.String()
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3ObjectURL returns the URL of an object: virtual-hosted style on AWS (AWS_REGION,
+// default us-east-1), path style on AWS_ENDPOINT_URL for S3-compatible stores.
+func s3ObjectURL(bucket, key string) string {
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + escapeKey(key)
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, s3Region(), escapeKey(key))
+}
+
+// s3Region returns AWS_REGION, or us-east-1.
+func s3Region() string {
+	if r := os.Getenv("AWS_REGION"); r != "" {
+		return r
+	}
+	return "us-east-1"
+}
+
+// signS3 adds the AWS Signature Version 4 headers to an S3 request; the payload is not
+// hashed (UNSIGNED-PAYLOAD), the transport being TLS.
+func signS3(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", "UNSIGNED-PAYLOAD")
+	signed := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\nx-amz-content-sha256:UNSIGNED-PAYLOAD\nx-amz-date:" + amzDate + "\n"
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("x-amz-security-token", token)
+		signed += ";x-amz-security-token"
+		canonicalHeaders += "x-amz-security-token:" + token + "\n"
+	}
+	canonical := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery,
+		canonicalHeaders, signed, "UNSIGNED-PAYLOAD"}, "\n")
+	scope := day + "/" + s3Region() + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	key := mac([]byte("AWS4"+os.Getenv("AWS_SECRET_ACCESS_KEY")), day)
+	for _, part := range []string{s3Region(), "s3", "aws4_request"} {
+		key = mac(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		os.Getenv("AWS_ACCESS_KEY_ID"), scope, signed, hex.EncodeToString(mac(key, toSign))))
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Actions recorded in the audit log.
+const (
+	AuditCreateRepo    = "create-repo"
+	AuditPush          = "push"
+	AuditForcePush     = "force-push"
+	AuditDeleteRepo    = "delete-repo"
+	AuditDeleteSource  = "delete-source"
+	AuditPolicyDisable = "policy-disable"
+	AuditPolicyEnable  = "policy-enable"
+)
+
+// AuditEvent is a line of the audit log: a mutating action taken by the tool. Prev is the
+// hash of the previous line and Hash the SHA-256 of the line without it, so a line edited,
+// removed or inserted breaks the chain checked by "audit verify".
+type AuditEvent struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Org      string    `json:"org"`
+	Project  string    `json:"project"`
+	Repo     string    `json:"repo"`
+	RepoID   string    `json:"repoId,omitempty"`
+	Result   string    `json:"result,omitempty"`
+	Details  string    `json:"details,omitempty"`
+	Operator string    `json:"operator"`
+	Host     string    `json:"host"`
+	Version  string    `json:"version"`
+	Prev     string    `json:"prev,omitempty"`
+}
+
+// auditHashRe matches the hash closing a line of the audit log.
+var auditHashRe = regexp.MustCompile(`,"hash":"([0-9a-f]{64})"}$`)
+
+// auditMu serializes the appends of the parallel workers; auditPrev keeps the hash of the
+// last line of each audit log, read from the file on the first append.
+var (
+	auditMu   sync.Mutex
+	auditPrev = map[string]string{}
+)
+
+// operator returns the account running the tool, for the audit log.
+func operator() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// auditLineHash returns the hash chained by the next line: the one written in the line, or
+// the SHA-256 of the whole line for the lines written before the chain.
+func auditLineHash(line []byte) string {
+	if m := auditHashRe.FindSubmatch(line); m != nil {
+		return string(m[1])
+	}
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// lastAuditHash returns the hash of the last line of the audit log, "" if empty or missing.
+func lastAuditHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading the audit log: %w", err)
+	}
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return "", nil
+	}
+	return auditLineHash(data[bytes.LastIndexByte(data, '\n')+1:]), nil
+}
+
+// writeAudit appends an event to --audit-log (JSON lines, never truncated), chained to the
+// previous line.
+func writeAudit(cfg Config, e AuditEvent) error {
+	e.Time = time.Now().UTC()
+	e.Operator = operator()
+	e.Host, _ = os.Hostname()
+	e.Version = version
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	prev, ok := auditPrev[cfg.AuditLog]
+	if !ok {
+		var err error
+		if prev, err = lastAuditHash(cfg.AuditLog); err != nil {
+			return err
+		}
+	}
+	e.Prev = prev
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = []byte(scrub(string(line)))
+	sum := sha256.Sum256(line)
+	hash := hex.EncodeToString(sum[:])
+	line = append(line[:len(line)-1], `,"hash":"`+hash+`"}`...)
+
+	f, err := os.OpenFile(cfg.AuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening the audit log: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("writing the audit log: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	auditPrev[cfg.AuditLog] = hash
+	return nil
+}
+
+// recordAudit writes an event to the audit log, if enabled and not a dry-run. A failure
+// is reported without stopping the run: the action already happened.
+func recordAudit(cfg Config, e AuditEvent) {
+	if cfg.AuditLog == "" || cfg.DryRun {
+		return
+	}
+	if err := writeAudit(cfg, e); err != nil {
+		fmt.Fprintln(os.Stderr, "Audit log error:", err)
+	}
+}
+
+// auditResult returns the result of an audited action: OK, or the error.
+func auditResult(err error) string {
+	if err != nil {
+		return "ERROR: " + err.Error()
+	}
+	return "OK"
+}
+
+// pushAction returns the audit action of a push, force or not.
+func pushAction(force bool) string {
+	if force {
+		return AuditForcePush
+	}
+	return AuditPush
+}
+
+// verifyAuditLog checks the hash chain of an audit log and returns the number of lines and
+// the hash of the last one. Lines written before the chain are accepted only before the
+// first chained line.
+func verifyAuditLog(path string) (n int, last string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	prev, chained := "", false
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		n++
+		var e AuditEvent
+		if err := json.Unmarshal(line, &e); err != nil {
+			return n, "", fmt.Errorf("line %d: invalid JSON: %w", n, err)
+		}
+		m := auditHashRe.FindSubmatchIndex(line)
+		if m == nil {
+			if chained {
+				return n, "", fmt.Errorf("line %d: missing hash", n)
+			}
+			prev = auditLineHash(line)
+			continue
+		}
+		chained = true
+		if e.Prev != prev {
+			return n, "", fmt.Errorf("line %d: chain broken, a line was removed, inserted or modified before it", n)
+		}
+		body := append(slices.Clone(line[:m[0]]), '}')
+		sum := sha256.Sum256(body)
+		if hash := hex.EncodeToString(sum[:]); hash != string(line[m[2]:m[3]]) {
+			return n, "", fmt.Errorf("line %d: hash mismatch, the line was modified", n)
+		}
+		prev = string(line[m[2]:m[3]])
+	}
+	return n, prev, sc.Err()
+}
+
+// newAuditCmd returns the "audit" command, with "audit verify" checking the hash chain of
+// an audit log kept as migration evidence.
+func newAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Commands on the --audit-log file",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "verify <audit-log.jsonl>",
+		Short: "Check that no line of an audit log was modified, removed or inserted",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, last, err := verifyAuditLog(args[0])
+			if err != nil {
+				return fmt.Errorf("%s: %w", args[0], err)
+			}
+			fmt.Printf("%s: %d events, hash chain intact\n", args[0], n)
+			if last != "" {
+				fmt.Println("Last hash (keep it outside the file to detect a truncation):", last)
+			}
+			return nil
+		},
+	})
+	return cmd
+}
+
+// writableFile checks before the run that the audit log can be appended to.
+func writableFile(path string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
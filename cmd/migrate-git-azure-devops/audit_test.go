@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestAuditLog writes a chained audit log of three events and returns its lines.
+func writeTestAuditLog(t *testing.T, path string) [][]byte {
+	t.Helper()
+	cfg := Config{AuditLog: path}
+	for _, repo := range []string{"horse-core", "horse-web", "horse-api"} {
+		if err := writeAudit(cfg, AuditEvent{Action: AuditPush, Org: "dstorg", Project: "Dst", Repo: repo, Result: "OK"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(lines [][]byte) [][]byte
+		wantErr string
+	}{
+		{"intact", func(l [][]byte) [][]byte { return l }, ""},
+		{"modified line", func(l [][]byte) [][]byte {
+			l[1] = bytes.Replace(l[1], []byte("horse-web"), []byte("horse-xyz"), 1)
+			return l
+		}, "line 2: hash mismatch"},
+		{"removed line", func(l [][]byte) [][]byte { return append(l[:1], l[2:]...) }, "line 2: chain broken"},
+		{"swapped lines", func(l [][]byte) [][]byte {
+			l[1], l[2] = l[2], l[1]
+			return l
+		}, "line 2: chain broken"},
+		{"hash removed", func(l [][]byte) [][]byte {
+			l[2] = auditHashRe.ReplaceAll(l[2], []byte("}"))
+			return l
+		}, "line 3: missing hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			lines := tt.tamper(writeTestAuditLog(t, path))
+			if err := os.WriteFile(path, append(bytes.Join(lines, []byte("\n")), '\n'), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			n, last, err := verifyAuditLog(path)
+			if tt.wantErr == "" {
+				if err != nil || n != 3 || last != auditLineHash(lines[2]) {
+					t.Errorf("verifyAuditLog() = %d, %q, %v; want 3 events and the last hash", n, last, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("verifyAuditLog() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuditVerifyCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	lines := writeTestAuditLog(t, path)
+	lines[0] = bytes.Replace(lines[0], []byte(`"result":"OK"`), []byte(`"result":"ERROR"`), 1)
+	if err := os.WriteFile(path, append(bytes.Join(lines, []byte("\n")), '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := newAuditCmd()
+	cmd.SetArgs([]string{"verify", path})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "line 1: hash mismatch") {
+		t.Errorf("audit verify of a tampered log: error = %v, want a hash mismatch on line 1", err)
+	}
+}
+
+func TestAuditChainContinuesAcrossRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeTestAuditLog(t, path)
+	delete(auditPrev, path) // a new run reads the last hash from the file
+	writeTestAuditLog(t, path)
+	if n, _, err := verifyAuditLog(path); err != nil || n != 6 {
+		t.Errorf("verifyAuditLog() = %d, %v; want 6 chained events", n, err)
+	}
+}
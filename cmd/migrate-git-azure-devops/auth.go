@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// Supported git transport protocols (--protocol).
+const (
+	ProtocolHTTPS = "https"
+	ProtocolSSH   = "ssh"
+)
+
+// Ways of passing the credentials to git (--git-credentials).
+const (
+	GitCredsHeader = "header"
+	GitCredsHelper = "helper"
+	GitCredsSystem = "system"
+)
+
+// gitTokenEnv is the variable through which the temporary credential helper of
+// --git-credentials helper receives the token.
+const gitTokenEnv = "MIGRATE_GIT_TOKEN"
+
+// Supported authentication modes (--auth).
+const (
+	AuthModePAT        = "pat"
+	AuthModeAzCLI      = "azcli"
+	AuthModeDeviceCode = "devicecode"
+)
+
+const (
+	// azureDevOpsResource is the well-known Entra ID application ID of Azure DevOps.
+	azureDevOpsResource = "499b84ac-1321-427f-aa17-267ca6975798"
+	// deviceCodeClientID is the public client used for the device-code flow (Azure CLI).
+	deviceCodeClientID = "04b07795-8ddb-461a-bbee-02f9e1bf7b46"
+	// defaultTenant lets any work/school account sign in when no tenant is given.
+	defaultTenant = "organizations"
+)
+
+// resolveCredentials fills cfg.SrcPAT/cfg.DstPAT according to the selected auth mode.
+// In PAT mode the tokens are read from a file, a command or SRC_PAT/DST_PAT; in the
+// Entra ID modes a single access token is obtained and used for both source and destination.
+// The providers are kept in cfg so that long runs can obtain the tokens again.
+func resolveCredentials(ctx context.Context, cfg *Config) error {
+	creds := &credentials{obtained: time.Now()}
+	switch cfg.AuthMode {
+	case "", AuthModePAT:
+		cfg.AuthMode = AuthModePAT
+		var err error
+		if cfg.SrcPAT, err = readSecret(ctx, "SRC_PAT", cfg.SrcPATFile, cfg.SrcPATCmd); err != nil {
+			return err
+		}
+		if cfg.DstPAT, err = readSecret(ctx, "DST_PAT", cfg.DstPATFile, cfg.DstPATCmd); err != nil {
+			return err
+		}
+		if cfg.SrcPATFile != "" || cfg.SrcPATCmd != "" {
+			creds.src, creds.srcToken = secretCredential{"SRC_PAT", cfg.SrcPATFile, cfg.SrcPATCmd}, cfg.SrcPAT
+		}
+		if cfg.DstPATFile != "" || cfg.DstPATCmd != "" {
+			creds.dst, creds.dstToken = secretCredential{"DST_PAT", cfg.DstPATFile, cfg.DstPATCmd}, cfg.DstPAT
+		}
+	case AuthModeAzCLI:
+		token, err := azCLIToken(ctx, cfg.TenantID)
+		if err != nil {
+			return err
+		}
+		cfg.SrcPAT, cfg.DstPAT = token, token
+		creds.src = azCLICredential{cfg.TenantID}
+		creds.dst, creds.srcToken, creds.dstToken = creds.src, token, token
+	case AuthModeDeviceCode:
+		token, refresh, err := deviceCodeToken(ctx, cfg.TenantID, cfg.Trace)
+		if err != nil {
+			return err
+		}
+		cfg.SrcPAT, cfg.DstPAT = token, token
+		creds.src = &refreshTokenCredential{tenant: cfg.TenantID, refresh: refresh, trace: cfg.Trace}
+		creds.dst, creds.srcToken, creds.dstToken = creds.src, token, token
+	default:
+		return fmt.Errorf("unsupported auth mode: %s (only pat, azcli, devicecode are allowed)", cfg.AuthMode)
+	}
+	// The minted PAT of --mint-pat replaces the destination token for the whole run
+	if cfg.MintPAT {
+		creds.dst, creds.dstToken = nil, ""
+	}
+	cfg.creds = creds
+	registerSecret(cfg.SrcPAT)
+	registerSecret(cfg.DstPAT)
+	return nil
+}
+
+// readSecret reads a token from a file, from the stdout of a command (e.g. a Vault or
+// 1Password CLI invocation) or, when neither is given, from the environment variable.
+func readSecret(ctx context.Context, envName, file, command string) (string, error) {
+	switch {
+	case file != "" && command != "":
+		return "", fmt.Errorf("%s: use either a PAT file or a PAT command, not both", envName)
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("error reading %s file: %w", envName, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case command != "":
+		cmd := shellCommand(ctx, command)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%s command failed: %w", envName, err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	default:
+		return strings.TrimSpace(os.Getenv(envName)), nil
+	}
+}
+
+// promptSecret asks on the terminal for a token missing from the environment, with the
+// input hidden, so casual runs need no secret exported in the shell. Nothing is asked in
+// the Entra ID modes, when the token is already set or when stdin is not a terminal
+// (pipelines keep failing fast on the missing variable).
+func promptSecret(cfg Config, token *string, envName string) error {
+	if *token != "" || cfg.AuthMode != AuthModePAT || !term.IsTerminal(os.Stdin.Fd()) {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "%s is not set. Enter it (input hidden): ", envName)
+	data, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("error reading %s from the terminal: %w", envName, err)
+	}
+	*token = strings.TrimSpace(string(data))
+	registerSecret(*token)
+	return nil
+}
+
+// shellCommand runs a command line through the platform shell.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// azCLIToken obtains an Azure DevOps access token from the logged-in Azure CLI session.
+func azCLIToken(ctx context.Context, tenant string) (string, error) {
+	args := []string{"account", "get-access-token", "--resource", azureDevOpsResource, "--query", "accessToken", "-o", "tsv"}
+	if tenant != "" {
+		args = append(args, "--tenant", tenant)
+	}
+	cmd := exec.CommandContext(ctx, "az", args...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("az account get-access-token failed (run 'az login' first): %w", err)
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("az account get-access-token returned an empty token")
+	}
+	return token, nil
+}
+
+// deviceCodeResponse maps the response of the device authorization endpoint.
+type deviceCodeResponse struct {
+	DeviceCode string `json:"device_code"`
+	Message    string `json:"message"`
+	ExpiresIn  int    `json:"expires_in"`
+	Interval   int    `json:"interval"`
+}
+
+// tokenResponse maps the response (or error) of the token endpoint.
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// deviceCodeToken runs the OAuth2 device-code flow against Microsoft Entra ID:
+// prints the sign-in instructions and polls until the user completes the login. It returns
+// the access token and the refresh token used to renew it during long runs.
+func deviceCodeToken(ctx context.Context, tenant string, trace bool) (string, string, error) {
+	if tenant == "" {
+		tenant = defaultTenant
+	}
+	base := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0", url.PathEscape(tenant))
+
+	var dc deviceCodeResponse
+	if err := postForm(ctx, base+"/devicecode", url.Values{
+		"client_id": {deviceCodeClientID},
+		"scope":     {azureDevOpsResource + "/.default offline_access"},
+	}, &dc, trace); err != nil {
+		return "", "", fmt.Errorf("device code request failed: %w", err)
+	}
+	if dc.DeviceCode == "" {
+		return "", "", fmt.Errorf("device code request returned no device code")
+	}
+	fmt.Fprintln(os.Stderr, dc.Message)
+
+	interval := time.Duration(dc.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(dc.ExpiresIn) * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return "", "", ctx.Err()
+		case <-time.After(interval):
+		}
+		var tr tokenResponse
+		if err := postForm(ctx, base+"/token", url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"client_id":   {deviceCodeClientID},
+			"device_code": {dc.DeviceCode},
+		}, &tr, trace); err != nil {
+			return "", "", fmt.Errorf("token request failed: %w", err)
+		}
+		switch tr.Error {
+		case "":
+			if tr.AccessToken == "" {
+				return "", "", fmt.Errorf("token endpoint returned no access token")
+			}
+			return tr.AccessToken, tr.RefreshToken, nil
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			interval += 5 * time.Second
+			continue
+		default:
+			return "", "", fmt.Errorf("device code login failed: %s: %s", tr.Error, tr.ErrorDescription)
+		}
+	}
+	return "", "", fmt.Errorf("device code expired before login was completed")
+}
+
+// postForm sends a form-encoded POST and decodes the JSON response into out.
+// Error responses of the token endpoint are JSON too, so they are decoded as well.
+func postForm(ctx context.Context, endpoint string, form url.Values, out any, trace bool) error {
+	if trace {
+		fmt.Fprintln(os.Stderr, "[TRACE] POST", endpoint)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error closing HTTP response:", err)
+		}
+	}()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	return nil
+}
+
+// isBearerToken reports whether the token is an Entra ID access token (a JWT)
+// rather than a PAT. PATs never contain dots, JWTs always have three segments.
+func isBearerToken(token string) bool {
+	return strings.HasPrefix(token, "eyJ") && strings.Count(token, ".") == 2
+}
+
+// authHeader builds the Authorization header value for the given token:
+// Bearer for Entra ID access tokens, Basic for PATs.
+func authHeader(token string) string {
+	if isBearerToken(token) {
+		return "Bearer " + token
+	}
+	return basicAuth(token)
+}
+
+// gitRemote builds the remote of a repository (no credentials in the URL) and the extra
+// environment git needs to authenticate against it: HTTPS with the token as header, or
+// SSH (--protocol ssh) with the configured private key.
+func gitRemote(cfg Config, org, projectEnc, repoEnc, token string) (string, []string) {
+	if cfg.Protocol == ProtocolSSH {
+		remote := fmt.Sprintf("git@ssh.dev.azure.com:v3/%s/%s/%s", org, projectEnc, repoEnc)
+		return remote, gitSSHEnv(cfg.SSHKey)
+	}
+	remote := fmt.Sprintf("https://dev.azure.com/%s/%s/_git/%s", org, projectEnc, repoEnc)
+	return remote, gitAuthEnv(cfg.GitCreds, token)
+}
+
+// gitSSHEnv returns the environment making git use the given private key over SSH.
+// Without a key the user's SSH configuration (agent, ~/.ssh/config) is used as is.
+func gitSSHEnv(key string) []string {
+	env := []string{"GIT_TERMINAL_PROMPT=0"}
+	if key == "" {
+		return env
+	}
+	return append(env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -i %q -o IdentitiesOnly=yes -o BatchMode=yes", key))
+}
+
+// gitAuthEnv returns the environment that makes git authenticate with the token, by
+// default as an Authorization header (http.extraHeader). The header travels through
+// GIT_CONFIG_* variables, so it never shows up in process listings nor in git error
+// messages echoing the remote URL. With --git-credentials helper the token is answered by
+// a credential helper existing only for the run, with system git asks the helpers of the
+// user's configuration (e.g. Git Credential Manager). Interactive prompts are disabled to
+// fail fast on bad tokens.
+func gitAuthEnv(mode, token string) []string {
+	env := []string{"GIT_TERMINAL_PROMPT=0"}
+	switch {
+	case mode == GitCredsSystem:
+		return append(env, gitConfigEnv([][2]string{{"credential.useHttpPath", "true"}})...)
+	case token == "":
+		return env
+	case mode == GitCredsHelper:
+		// The empty helper drops the configured ones, so the token is never stored by them
+		env = append(env, gitTokenEnv+"="+token)
+		return append(env, gitConfigEnv([][2]string{
+			{"credential.helper", ""},
+			{"credential.helper", `!f() { test "$1" = get && printf 'username=pat\npassword=%s\n' "$` + gitTokenEnv + `"; }; f`},
+		})...)
+	}
+	return append(env, gitConfigEnv([][2]string{
+		{"http.extraHeader", "Authorization: " + authHeader(token)},
+	})...)
+}
+
+// gitConfigEnv converts git config entries into GIT_CONFIG_COUNT/KEY_n/VALUE_n
+// environment variables (git 2.31+), equivalent to "git -c key=value" without argv exposure.
+func gitConfigEnv(entries [][2]string) []string {
+	env := []string{fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(entries))}
+	for i, e := range entries {
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, e[0]),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, e[1]))
+	}
+	return env
+}
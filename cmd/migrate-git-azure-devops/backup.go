@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// backupPath returns the bundle file receiving the backup of a destination repository:
+// {backup-dir}/{org}/{project}/{repo}-{UTC timestamp}.bundle.
+func backupPath(cfg Config, dstRepoName string) string {
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	return filepath.Join(cfg.BackupDir, cfg.DstOrg, cfg.DstProject, dstRepoName+"-"+stamp+".bundle")
+}
+
+// backupDestination saves the current refs of a destination repository, with their
+// history, into a git bundle under --backup-dir before they are overwritten by a force
+// push. It returns the bundle path, or "" when the destination has no refs to save.
+func backupDestination(ctx context.Context, cfg Config, dstRepoName, dstURL string, dstEnv []string) (string, error) {
+	refs, err := lsRemote(ctx, dstEnv, dstURL)
+	if err != nil {
+		return "", fmt.Errorf("ls-remote destination: %w", err)
+	}
+	if len(refs) == 0 {
+		return "", nil
+	}
+
+	tmpDir, err := os.MkdirTemp(cfg.TmpDir, "tmp_backup_git_")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	repodir := filepath.Join(tmpDir, "backup.git")
+	if _, err := withRetry(ctx, cfg, "backup clone", func() error {
+		_ = os.RemoveAll(repodir)
+		return runCmd(ctx, transferEnv(dstEnv), "git", gitTransferArgs(cfg, 0, "clone", "--mirror", dstURL, repodir)...)
+	}); err != nil {
+		return "", fmt.Errorf("cloning the destination: %w", err)
+	}
+
+	bundle := backupPath(cfg, dstRepoName)
+	if err := os.MkdirAll(filepath.Dir(bundle), 0o755); err != nil {
+		return "", fmt.Errorf("creating the backup directory: %w", err)
+	}
+	if err := runCmd(ctx, nil, "git", "-C", repodir, "bundle", "create", bundle, "--all"); err != nil {
+		_ = os.Remove(bundle)
+		return "", fmt.Errorf("writing the bundle: %w", err)
+	}
+	return bundle, nil
+}
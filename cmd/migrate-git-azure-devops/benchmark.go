@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// benchLatencySamples is the number of round trips timed for the latency figures.
+const benchLatencySamples = 5
+
+// BenchResult is the outcome of the benchmark against one organization.
+type BenchResult struct {
+	Side       string        // source or destination
+	Org        string        // Organization/project benchmarked
+	PushMBps   float64       // Push throughput of the synthetic repo
+	CloneMBps  float64       // Mirror clone throughput of the synthetic repo
+	APILatency time.Duration // Median REST API round trip
+	GitLatency time.Duration // Median git ls-remote round trip (connection + ref advertisement)
+}
+
+// cmdBenchmark measures what the current network location achieves against the source and,
+// when configured, the destination: a synthetic repository of --bench-size-mb random
+// (incompressible) data is pushed to a temporary repository, mirror cloned back, then the
+// temporary repository is deleted. Requires permission to create repositories on each side.
+func cmdBenchmark(ctx context.Context, cfg Config) error {
+	if cfg.BenchSizeMB < 1 {
+		return fmt.Errorf("--bench-size-mb must be >= 1")
+	}
+	tmpDir, err := os.MkdirTemp(cfg.TmpDir, "tmp_benchmark_git_")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			fmt.Fprintln(os.Stderr, "Error removing temporary directory:", err)
+		}
+	}()
+
+	workDir := filepath.Join(tmpDir, "synthetic")
+	fmt.Printf("Creating a synthetic repository of %d MiB...\n", cfg.BenchSizeMB)
+	if err := syntheticRepo(ctx, workDir, cfg.BenchSizeMB); err != nil {
+		return fmt.Errorf("creating the synthetic repository: %w", err)
+	}
+	size, err := dirSize(filepath.Join(workDir, ".git"))
+	if err != nil {
+		return err
+	}
+
+	var results []BenchResult
+	sides := []struct{ side, org, project, pat string }{{"source", cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT}}
+	if cfg.DstOrg != "" {
+		sides = append(sides, struct{ side, org, project, pat string }{"destination", cfg.DstOrg, cfg.DstProject, cfg.DstPAT})
+	}
+	for _, s := range sides {
+		fmt.Printf("Benchmarking %s %s/%s...\n", s.side, s.org, s.project)
+		res, err := benchmarkSide(ctx, cfg, s.org, s.project, s.pat, workDir, tmpDir, size)
+		if err != nil {
+			return fmt.Errorf("benchmark of %s %s/%s: %w", s.side, s.org, s.project, err)
+		}
+		res.Side, res.Org = s.side, s.org+"/"+s.project
+		results = append(results, res)
+	}
+
+	fmt.Printf("\n===== BENCHMARK (%s over %s) =====\n", formatBytes(size), cfg.Protocol)
+	fmt.Printf("%-12s %-30s %10s %10s %12s %12s\n", "Side", "Org/Project", "Push MB/s", "Clone MB/s", "API latency", "Git latency")
+	for _, r := range results {
+		fmt.Printf("%-12s %-30s %10.1f %10.1f %12s %12s\n", r.Side, r.Org, r.PushMBps, r.CloneMBps,
+			r.APILatency.Round(time.Millisecond), r.GitLatency.Round(time.Millisecond))
+	}
+	fmt.Println("Run from every candidate network location and pick the one with the best figures;")
+	fmt.Println("real repositories compress better than random data, so these are lower bounds.")
+	return nil
+}
+
+// benchmarkSide runs push, clone and latency measurements against one organization,
+// always deleting the temporary repository it creates.
+func benchmarkSide(ctx context.Context, cfg Config, org, project, pat, workDir, tmpDir string, size int64) (BenchResult, error) {
+	var res BenchResult
+	name := fmt.Sprintf("migrate-benchmark-%d", time.Now().UnixNano())
+	repo, err := createRepo(ctx, org, project, pat, name, cfg.Trace)
+	recordAudit(cfg, AuditEvent{Action: AuditCreateRepo, Org: org, Project: project, Repo: name, RepoID: repo.ID,
+		Result: auditResult(err), Details: "benchmark repository"})
+	if err != nil {
+		return res, err
+	}
+	defer func() {
+		err := deleteRepo(context.Background(), org, project, pat, repo.ID, cfg.Trace)
+		recordAudit(cfg, AuditEvent{Action: AuditDeleteRepo, Org: org, Project: project, Repo: name, RepoID: repo.ID,
+			Result: auditResult(err), Details: "benchmark repository"})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: temporary repository %s not deleted: %v\n", name, err)
+		}
+	}()
+	remote, env := gitRemote(cfg, org, url.PathEscape(project), url.PathEscape(name), pat)
+
+	start := time.Now()
+	if err := runCmd(ctx, env, "git", "-C", workDir, "push", "--quiet", remote, "HEAD:refs/heads/main"); err != nil {
+		return res, fmt.Errorf("push: %w", err)
+	}
+	res.PushMBps = mbps(size, time.Since(start))
+
+	cloneDir := filepath.Join(tmpDir, name+".git")
+	start = time.Now()
+	if err := runCmd(ctx, env, "git", "clone", "--mirror", "--quiet", remote, cloneDir); err != nil {
+		return res, fmt.Errorf("clone: %w", err)
+	}
+	res.CloneMBps = mbps(size, time.Since(start))
+	_ = os.RemoveAll(cloneDir)
+
+	var api, git []time.Duration
+	for i := 0; i < benchLatencySamples; i++ {
+		start = time.Now()
+		if _, err := getRepo(ctx, org, project, pat, repo.ID, cfg.Trace); err != nil {
+			return res, fmt.Errorf("API latency: %w", err)
+		}
+		api = append(api, time.Since(start))
+		start = time.Now()
+		if _, err := lsRemote(ctx, env, remote); err != nil {
+			return res, fmt.Errorf("git latency: %w", err)
+		}
+		git = append(git, time.Since(start))
+	}
+	res.APILatency, res.GitLatency = median(api), median(git)
+	return res, nil
+}
+
+// syntheticRepo creates a repository with a single commit of sizeMB MiB of random data,
+// split in 8 MiB files.
+func syntheticRepo(ctx context.Context, dir string, sizeMB int) error {
+	if err := runCmd(ctx, nil, "git", "init", "--quiet", dir); err != nil {
+		return err
+	}
+	const chunkMB = 8
+	for i := 0; sizeMB > 0; i++ {
+		n := min(sizeMB, chunkMB)
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("blob-%03d.bin", i)))
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyN(f, rand.Reader, int64(n)<<20)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		sizeMB -= n
+	}
+	if err := runCmd(ctx, nil, "git", "-C", dir, "add", "-A"); err != nil {
+		return err
+	}
+	return runCmd(ctx, nil, "git", "-C", dir, "-c", "user.name=benchmark", "-c", "user.email=benchmark@localhost",
+		"commit", "--quiet", "-m", "Synthetic benchmark content")
+}
+
+// mbps returns the throughput in MB/s of size bytes transferred in d.
+func mbps(size int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(size) / (1 << 20) / d.Seconds()
+}
+
+// median returns the median of the samples.
+func median(samples []time.Duration) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples[len(samples)/2]
+}
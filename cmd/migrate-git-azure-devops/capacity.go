@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Migration phases timed for the capacity report.
+const (
+	PhaseList   = "list"
+	PhaseClone  = "clone"
+	PhaseCreate = "create"
+	PhasePush   = "push"
+	PhaseVerify = "verify"
+)
+
+// phaseClock accumulates the wall-clock time spent in each migration phase.
+type phaseClock map[string]time.Duration
+
+// phases is the clock of the current run.
+var phases = phaseClock{}
+
+// track starts timing a phase; call the returned function when the phase ends.
+func (p phaseClock) track(phase string) func() {
+	progress.phase(phase)
+	start := time.Now()
+	return func() {
+		p[phase] += time.Since(start)
+	}
+}
+
+// trackRepo is track also adding the time spent to a counter of the repository summary
+// (Summary.CloneSeconds, Summary.PushSeconds).
+func (p phaseClock) trackRepo(phase string, seconds *float64) func() {
+	stop := p.track(phase)
+	start := time.Now()
+	return func() {
+		stop()
+		*seconds += time.Since(start).Seconds()
+	}
+}
+
+// setThroughput computes the effective throughput of a repository: its size over the
+// time spent cloning and pushing it, in MiB/s.
+func setThroughput(sum *Summary) {
+	if secs := sum.CloneSeconds + sum.PushSeconds; secs > 0 && sum.Size > 0 {
+		sum.MiBPerSecond = float64(sum.Size) / (1 << 20) / secs
+	}
+}
+
+// Capacity summarizes the run for capacity planning of the next migration waves.
+type Capacity struct {
+	MigratedRepos       int                `json:"migratedRepos"`
+	MigratedBytes       int64              `json:"migratedBytes"`
+	ThroughputGBPerHour float64            `json:"throughputGBPerHour"`
+	PhaseSeconds        map[string]float64 `json:"phaseSeconds"`
+	RemainingRepos      int                `json:"remainingRepos"`
+	RemainingBytes      int64              `json:"remainingBytes"`
+	RemainingHours      float64            `json:"remainingHours"` // projection at the measured throughput
+
+	// Dry run: what would be cloned and pushed, and the duration estimated at --estimate-rate
+	EstimatedRepos int     `json:"estimatedRepos,omitempty"`
+	EstimatedBytes int64   `json:"estimatedBytes,omitempty"`
+	EstimatedHours float64 `json:"estimatedHours,omitempty"`
+}
+
+// computeCapacity measures the throughput of the run and projects the time needed for
+// the source repositories still to migrate: those failed in this run and those neither
+// selected nor already present at destination. Sizes of repos not cloned come from the API.
+func computeCapacity(cfg Config, results []Summary, srcRepos []Repo, dstExists map[string]bool, elapsed time.Duration) *Capacity {
+	c := &Capacity{PhaseSeconds: map[string]float64{}}
+	for phase, d := range phases {
+		c.PhaseSeconds[phase] = d.Seconds()
+	}
+
+	inRun := map[string]Summary{}
+	for _, s := range results {
+		inRun[s.Repo] = s
+		if s.EstimatedSeconds > 0 {
+			c.EstimatedRepos++
+			c.EstimatedBytes += s.Size
+			c.EstimatedHours += s.EstimatedSeconds / 3600
+		}
+		if s.Result == "OK" || s.Result == ResultStale || s.Result == ResultSynced {
+			c.MigratedRepos++
+			c.MigratedBytes += s.Size
+		}
+	}
+	if hours := elapsed.Hours(); hours > 0 {
+		c.ThroughputGBPerHour = float64(c.MigratedBytes) / (1 << 30) / hours
+	}
+
+	for _, r := range srcRepos {
+		s, ok := inRun[r.Name]
+		failed := ok && strings.HasPrefix(s.Result, "ERROR:")
+		missing := !ok && !dstExists[destinationName(cfg, r.Name)]
+		if failed || missing {
+			c.RemainingRepos++
+			c.RemainingBytes += r.Size
+		}
+	}
+	if c.ThroughputGBPerHour > 0 {
+		c.RemainingHours = float64(c.RemainingBytes) / (1 << 30) / c.ThroughputGBPerHour
+	}
+	return c
+}
+
+// printCapacity prints the capacity summary on the console.
+func printCapacity(c *Capacity) {
+	fmt.Printf("Throughput: %.2f GB/hour (%d repos, %d bytes)\n", c.ThroughputGBPerHour, c.MigratedRepos, c.MigratedBytes)
+	if c.RemainingRepos > 0 {
+		if c.RemainingHours > 0 {
+			fmt.Printf("Remaining: %d repos, %d bytes, ~%.1f hours at the measured throughput\n", c.RemainingRepos, c.RemainingBytes, c.RemainingHours)
+		} else {
+			fmt.Printf("Remaining: %d repos, %d bytes\n", c.RemainingRepos, c.RemainingBytes)
+		}
+	}
+	if c.EstimatedRepos > 0 {
+		fmt.Printf("Estimated: %d repos, %s, ~%.1f hours at --estimate-rate\n", c.EstimatedRepos, formatBytes(c.EstimatedBytes), c.EstimatedHours)
+	}
+}
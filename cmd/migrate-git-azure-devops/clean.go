@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// tempDirPrefixes are the prefixes of the temporary directories created by the tool.
+var tempDirPrefixes = []string{
+	"tmp_migrazione_git_",
+	"tmp_backup_git_",
+	"tmp_benchmark_git_",
+	"tmp_export_git_",
+	"tmp_import_git_",
+	"tmp_restore_git_",
+	"tmp_verify_lfs_",
+}
+
+// removeTempDir removes the temporary directory holding the mirrors of a run, unless
+// --keep-temp asks to keep them for debugging.
+func removeTempDir(cfg Config, dir string) {
+	if cfg.KeepTemp {
+		fmt.Printf("Temporary mirrors kept in %s (--keep-temp)\n", dir)
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		fmt.Fprintln(os.Stderr, "Error removing temporary directory:", err)
+	}
+}
+
+// newCleanCmd builds the "clean" subcommand removing the temporary directories left
+// by interrupted runs or kept with --keep-temp.
+func newCleanCmd() *cobra.Command {
+	var dir string
+	var olderThan time.Duration
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Remove the temporary directories (tmp_migrazione_git_* ...) left by previous runs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dir == "" {
+				dir = os.TempDir()
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", dir, err)
+			}
+			var removed int
+			var freed int64
+			for _, e := range entries {
+				if !e.IsDir() || !hasTempDirPrefix(e.Name()) {
+					continue
+				}
+				info, err := e.Info()
+				if err != nil || time.Since(info.ModTime()) < olderThan {
+					continue // possibly used by a running migration
+				}
+				path := filepath.Join(dir, e.Name())
+				size, _ := dirSize(path)
+				if dryRun {
+					fmt.Printf("[DRY] Would remove %s (%s)\n", path, formatBytes(size))
+					continue
+				}
+				if err := os.RemoveAll(path); err != nil {
+					fmt.Fprintf(os.Stderr, "Error removing %s: %v\n", path, err)
+					continue
+				}
+				fmt.Printf("Removed %s (%s)\n", path, formatBytes(size))
+				removed++
+				freed += size
+			}
+			if !dryRun {
+				fmt.Printf("%d directories removed, %s freed\n", removed, formatBytes(freed))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&dir, "tmp-dir", "", "Directory holding the temporary directories (default: system temp directory)")
+	cmd.Flags().DurationVar(&olderThan, "older-than", time.Hour, "Only remove directories not modified for this long, to spare running migrations")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only list the directories that would be removed")
+	return cmd
+}
+
+// hasTempDirPrefix reports whether name is a temporary directory of the tool.
+func hasTempDirPrefix(name string) bool {
+	for _, p := range tempDirPrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
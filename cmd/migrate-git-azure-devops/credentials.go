@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin is how long before its expiry an Entra ID token is obtained again:
+// enough for the clone and push of a repository to start with a valid one.
+const tokenExpiryMargin = 10 * time.Minute
+
+// CredentialProvider obtains the token of one side of the migration, again and again
+// during long runs.
+type CredentialProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// secretCredential reads the PAT again from its file or command (--src-pat-file, --dst-pat-cmd, ...),
+// picking up a PAT rotated by the secret manager during the run.
+type secretCredential struct {
+	envName, file, command string
+}
+
+func (c secretCredential) Token(ctx context.Context) (string, error) {
+	return readSecret(ctx, c.envName, c.file, c.command)
+}
+
+// azCLICredential asks the logged-in Azure CLI for a new access token.
+type azCLICredential struct {
+	tenant string
+}
+
+func (c azCLICredential) Token(ctx context.Context) (string, error) {
+	return azCLIToken(ctx, c.tenant)
+}
+
+// refreshTokenCredential redeems the refresh token of the device-code login for a new
+// access token, without signing in again. Entra ID rotates the refresh token at each use.
+type refreshTokenCredential struct {
+	mu      sync.Mutex
+	tenant  string
+	refresh string
+	trace   bool
+}
+
+func (c *refreshTokenCredential) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.refresh == "" {
+		return "", fmt.Errorf("the device code login returned no refresh token: sign in again")
+	}
+	access, refresh, err := refreshAccessToken(ctx, c.tenant, c.refresh, c.trace)
+	if err != nil {
+		return "", err
+	}
+	if refresh != "" {
+		c.refresh = refresh
+	}
+	return access, nil
+}
+
+// credentials keeps the providers of the run and the tokens they last returned. The
+// providers are nil for the tokens that can't be obtained again (environment, terminal).
+type credentials struct {
+	mu       sync.Mutex
+	src, dst CredentialProvider
+	srcToken string
+	dstToken string
+	obtained time.Time
+}
+
+// refreshCredentials brings cfg.SrcPAT/cfg.DstPAT up to date before a repository: Entra ID
+// tokens are obtained again shortly before their expiry, PATs of files and commands every
+// --token-refresh. A failed refresh is reported and the current tokens are kept, they may
+// still be valid. It reports whether the tokens of cfg changed, so the caller rebuilds
+// what holds them (providers, git environment).
+func refreshCredentials(ctx context.Context, cfg *Config) bool {
+	c := cfg.creds
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	due := cfg.TokenRefresh > 0 && time.Since(c.obtained) >= cfg.TokenRefresh
+	for _, token := range []string{c.srcToken, c.dstToken} {
+		if exp, ok := tokenExpiry(token); ok && time.Until(exp) < tokenExpiryMargin {
+			due = true
+		}
+	}
+	if due {
+		c.obtained = time.Now()
+		if c.src != nil {
+			if token, err := c.src.Token(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: refreshing the source credentials failed, keeping the current ones: %v\n", err)
+			} else if token != "" {
+				c.srcToken = token
+				registerSecret(token)
+			}
+		}
+		switch {
+		case c.dst == nil:
+		case c.dst == c.src: // Entra ID modes: one token for both sides
+			c.dstToken = c.srcToken
+		default:
+			if token, err := c.dst.Token(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: refreshing the destination credentials failed, keeping the current ones: %v\n", err)
+			} else if token != "" {
+				c.dstToken = token
+				registerSecret(token)
+			}
+		}
+	}
+
+	changed := false
+	if c.srcToken != "" && cfg.SrcPAT != c.srcToken {
+		cfg.SrcPAT, changed = c.srcToken, true
+	}
+	if c.dstToken != "" && cfg.DstPAT != c.dstToken {
+		cfg.DstPAT, changed = c.dstToken, true
+	}
+	if changed {
+		fmt.Println("[AUTH] Credentials refreshed")
+	}
+	return changed
+}
+
+// tokenExpiry returns the expiry (exp claim) of an Entra ID access token. PATs have no
+// expiry readable by the client.
+func tokenExpiry(token string) (time.Time, bool) {
+	if !isBearerToken(token) {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
+
+// refreshAccessToken redeems a refresh token at the token endpoint of Entra ID, returning
+// the new access token and the new refresh token.
+func refreshAccessToken(ctx context.Context, tenant, refresh string, trace bool) (string, string, error) {
+	if tenant == "" {
+		tenant = defaultTenant
+	}
+	var tr tokenResponse
+	if err := postForm(ctx, fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", url.PathEscape(tenant)), url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {deviceCodeClientID},
+		"refresh_token": {refresh},
+		"scope":         {azureDevOpsResource + "/.default offline_access"},
+	}, &tr, trace); err != nil {
+		return "", "", fmt.Errorf("token refresh failed: %w", err)
+	}
+	if tr.Error != "" {
+		return "", "", fmt.Errorf("token refresh failed: %s: %s", tr.Error, tr.ErrorDescription)
+	}
+	if tr.AccessToken == "" {
+		return "", "", fmt.Errorf("token endpoint returned no access token")
+	}
+	return tr.AccessToken, tr.RefreshToken, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// staticCredential always returns the same token.
+type staticCredential string
+
+func (c staticCredential) Token(context.Context) (string, error) {
+	return string(c), nil
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// recordAuthHeaders answers 401 to every API request of the test and returns the
+// Authorization headers received so far.
+func recordAuthHeaders(t *testing.T) func() []string {
+	t.Helper()
+	var mu sync.Mutex
+	var headers []string
+	prev := httpClient.Transport
+	httpClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		headers = append(headers, req.Header.Get("Authorization"))
+		mu.Unlock()
+		return &http.Response{StatusCode: http.StatusUnauthorized, Header: http.Header{},
+			Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	})
+	t.Cleanup(func() { httpClient.Transport = prev })
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), headers...)
+	}
+}
+
+func TestRefreshCredentials(t *testing.T) {
+	tests := []struct {
+		name             string
+		creds            *credentials
+		refresh          time.Duration
+		want             bool
+		wantSrc, wantDst string
+	}{
+		{"no providers", nil, time.Hour, false, "src-start", "dst-start"},
+		{"not due", &credentials{src: staticCredential("src-rotated-7f3a"), srcToken: "src-start", dstToken: "dst-start", obtained: time.Now()},
+			time.Hour, false, "src-start", "dst-start"},
+		{"refresh disabled", &credentials{src: staticCredential("src-rotated-7f3a"), srcToken: "src-start", dstToken: "dst-start"},
+			0, false, "src-start", "dst-start"},
+		{"due", &credentials{src: staticCredential("src-rotated-7f3a"), dst: staticCredential("dst-rotated-9c1e"), srcToken: "src-start", dstToken: "dst-start"},
+			time.Hour, true, "src-rotated-7f3a", "dst-rotated-9c1e"},
+		{"one provider for both sides", func() *credentials {
+			p := staticCredential("both-rotated-4d2b")
+			return &credentials{src: p, dst: p, srcToken: "src-start", dstToken: "dst-start"}
+		}(), time.Hour, true, "both-rotated-4d2b", "both-rotated-4d2b"},
+		{"terminal token kept", &credentials{dst: staticCredential("dst-rotated-9c1e"), srcToken: "src-start", dstToken: "dst-start"},
+			time.Hour, true, "src-start", "dst-rotated-9c1e"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{SrcPAT: "src-start", DstPAT: "dst-start", TokenRefresh: tt.refresh, creds: tt.creds}
+			if got := refreshCredentials(context.Background(), &cfg); got != tt.want {
+				t.Errorf("refreshCredentials() = %v, want %v", got, tt.want)
+			}
+			if cfg.SrcPAT != tt.wantSrc || cfg.DstPAT != tt.wantDst {
+				t.Errorf("tokens = %q/%q, want %q/%q", cfg.SrcPAT, cfg.DstPAT, tt.wantSrc, tt.wantDst)
+			}
+		})
+	}
+}
+
+func TestMirrorConfigUsesRefreshedToken(t *testing.T) {
+	cfg := Config{DstOrg: "fabrikam", DstProject: "Platform", DstPAT: "dst-rotated-9c1e"}
+	shared := mirrorConfig(cfg, Destination{Org: "fabrikam-dr", Project: "Platform"})
+	if shared.DstOrg != "fabrikam-dr" || shared.DstPAT != "dst-rotated-9c1e" {
+		t.Errorf("destination without patEnv: got %s with %q, want the refreshed token of the run", shared.DstOrg, shared.DstPAT)
+	}
+	own := mirrorConfig(cfg, Destination{Org: "fabrikam-dr", Project: "Platform", PATEnv: "DR_PAT", PAT: "dr-pat"})
+	if own.DstPAT != "dr-pat" {
+		t.Errorf("destination with patEnv: got %q, want its own PAT", own.DstPAT)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runDaemon keeps source and destination aligned during a gradual cutover: it runs an
+// incremental sync (--sync) of the selected repositories every --interval until it is
+// stopped with SIGINT/SIGTERM. A failed cycle is logged and retried at the next one;
+// a stop request lets the running cycle complete.
+func runDaemon(cfg Config) error {
+	cfg.Sync = true
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for cycle := 1; ; cycle++ {
+		start := time.Now()
+		fmt.Printf("===== DAEMON CYCLE %d (%s) =====\n", cycle, start.Format(time.RFC3339))
+		refreshCredentials(ctx, &cfg)
+		if err := runNonInteractive(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Cycle %d failed: %v\n", cycle, err)
+		}
+		next := start.Add(cfg.Interval)
+		fmt.Printf("Cycle %d completed in %s, next at %s\n\n", cycle, time.Since(start).Round(time.Second), next.Format(time.RFC3339))
+		select {
+		case <-ctx.Done():
+			fmt.Println("Stop requested, daemon exiting.")
+			return nil
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// verifySourceMigrated checks, right before the deletion, that every branch and tag of the
+// source repository is at destination with the same SHA (after --ref-rename). Branches left
+// out by the manifest filter count as missing: deleting the source would lose them.
+func verifySourceMigrated(ctx context.Context, cfg Config, s Summary) error {
+	if s.HistoryRewritten {
+		return fmt.Errorf("history rewritten, the refs cannot be verified")
+	}
+	dstOrg, dstProject, dstName, err := destinationFromWebURL(s.DstWebURL)
+	if err != nil {
+		return err
+	}
+	srcURL, srcEnv := gitRemote(cfg, cfg.SrcOrg, url.PathEscape(cfg.SrcProject), url.PathEscape(s.Repo), cfg.SrcPAT)
+	dstURL, dstEnv := gitRemote(cfg, dstOrg, url.PathEscape(dstProject), url.PathEscape(dstName), cfg.DstPAT)
+	srcRefs, err := lsRemote(ctx, srcEnv, srcURL)
+	if err != nil {
+		return fmt.Errorf("reading the source refs: %w", err)
+	}
+	dstRefs, err := lsRemote(ctx, dstEnv, dstURL)
+	if err != nil {
+		return fmt.Errorf("reading the destination refs: %w", err)
+	}
+	missing, divergent, _ := compareRefs(expectedRefs(cfg, RepoOverride{}, srcRefs), dstRefs)
+	if len(missing) > 0 || len(divergent) > 0 {
+		return fmt.Errorf("verification failed: %d refs missing and %d divergent at destination", len(missing), len(divergent))
+	}
+	return nil
+}
+
+// deleteVerifiedSources implements --delete-source-after: the source repositories migrated
+// successfully are verified ref by ref against the destination and deleted (Azure DevOps
+// keeps them in the recycle bin of the project). Every deletion, refused or failed ones
+// included, is written to the audit log; the repositories not deleted record the reason in
+// SourceKept.
+func deleteVerifiedSources(ctx context.Context, cfg Config, results []Summary) {
+	for i := range results {
+		s := &results[i]
+		switch s.Result {
+		case "OK", ResultSynced, ResultInSync:
+		default:
+			continue
+		}
+		audit := func(result, details string) {
+			recordAudit(cfg, AuditEvent{
+				Action:  AuditDeleteSource,
+				Org:     cfg.SrcOrg,
+				Project: cfg.SrcProject,
+				Repo:    s.Repo,
+				RepoID:  s.SrcRepoID,
+				Result:  result,
+				Details: details,
+			})
+		}
+		if s.SrcRepoID == "" {
+			s.SourceKept = "source repository ID unknown"
+			audit("REFUSED: "+s.SourceKept, "deletion not attempted")
+			continue
+		}
+		if err := verifySourceMigrated(ctx, cfg, *s); err != nil {
+			s.SourceKept = err.Error()
+			fmt.Printf("Source %s NOT deleted: %v\n", s.Repo, err)
+			audit("REFUSED: "+err.Error(), "deletion not attempted, refs verified against "+s.DstWebURL)
+			continue
+		}
+		if cfg.DryRun {
+			fmt.Printf("[DRY] Would delete the verified source repository %s/%s/%s\n", cfg.SrcOrg, cfg.SrcProject, s.Repo)
+			continue
+		}
+		err := deleteRepo(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, s.SrcRepoID, cfg.Trace)
+		audit(auditResult(err), "refs verified against "+s.DstWebURL)
+		if err != nil {
+			s.SourceKept = "deletion failed: " + err.Error()
+			fmt.Fprintf(os.Stderr, "Deletion of source %s failed: %v\n", s.Repo, err)
+			continue
+		}
+		s.SourceDeleted = true
+		fmt.Printf("Source repository %s deleted (verified against %s)\n", s.Repo, s.DstWebURL)
+	}
+}
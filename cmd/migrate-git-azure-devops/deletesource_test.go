@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDeleteVerifiedSources(t *testing.T) {
+	srcRefs := map[string]string{"refs/heads/main": "a1", "refs/heads/feature/login": "b2", "refs/tags/v1.0": "c3"}
+	tests := []struct {
+		name        string
+		sum         Summary
+		dstRefs     map[string]string
+		cfg         Config
+		deleteCode  int
+		wantDeleted bool
+		wantKept    string // substring of SourceKept
+		wantAudit   string // prefix of the audit result, "" for no event
+	}{
+		{"verified", Summary{}, srcRefs, Config{}, http.StatusNoContent, true, "", "OK"},
+		{"history rewritten", Summary{HistoryRewritten: true}, srcRefs, Config{}, 0, false, "history rewritten", "REFUSED"},
+		{"missing ref", Summary{}, map[string]string{"refs/heads/main": "a1", "refs/tags/v1.0": "c3"}, Config{}, 0, false, "1 refs missing", "REFUSED"},
+		{"divergent ref", Summary{}, map[string]string{"refs/heads/main": "a1", "refs/heads/feature/login": "ff", "refs/tags/v1.0": "c3"},
+			Config{}, 0, false, "1 divergent", "REFUSED"},
+		{"branch left out by the manifest", Summary{}, map[string]string{"refs/heads/main": "a1", "refs/tags/v1.0": "c3"},
+			Config{RepoOverrides: map[string]RepoOverride{"Horse-Core": {Branches: []string{"main"}}}}, 0, false, "1 refs missing", "REFUSED"},
+		{"source ID unknown", Summary{SrcRepoID: "-"}, srcRefs, Config{}, 0, false, "source repository ID unknown", "REFUSED"},
+		{"deletion failed", Summary{}, srcRefs, Config{}, http.StatusForbidden, false, "deletion failed", "ERROR"},
+		{"not migrated", Summary{Result: "ERROR: push"}, srcRefs, Config{}, 0, false, "", ""},
+		{"dry run", Summary{}, srcRefs, Config{DryRun: true}, 0, false, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prevLs := lsRemote
+			lsRemote = func(_ context.Context, _ []string, remote string) (map[string]string, error) {
+				if strings.Contains(remote, "/fabrikam/") {
+					return tt.dstRefs, nil
+				}
+				return srcRefs, nil
+			}
+			t.Cleanup(func() { lsRemote = prevLs })
+			var mu sync.Mutex
+			var deletes int
+			prevTransport := httpClient.Transport
+			httpClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				code := http.StatusNotFound
+				if req.Method == http.MethodDelete {
+					deletes++
+					code = tt.deleteCode
+				}
+				return &http.Response{StatusCode: code, Header: http.Header{},
+					Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+			})
+			t.Cleanup(func() { httpClient.Transport = prevTransport })
+
+			cfg := tt.cfg
+			cfg.SrcOrg, cfg.SrcProject, cfg.DstPAT, cfg.SrcPAT = "contoso", "Horse", "dst-pat", "src-pat"
+			cfg.AuditLog = filepath.Join(t.TempDir(), "audit.jsonl")
+			s := tt.sum
+			s.Repo, s.DstWebURL = "Horse-Core", "https://dev.azure.com/fabrikam/Platform/_git/horse-core"
+			if s.Result == "" {
+				s.Result = "OK"
+			}
+			switch s.SrcRepoID {
+			case "":
+				s.SrcRepoID = "0a1b2c3d-0000-0000-0000-000000000001"
+			case "-":
+				s.SrcRepoID = ""
+			}
+			results := []Summary{s}
+			deleteVerifiedSources(context.Background(), cfg, results)
+
+			got := results[0]
+			if got.SourceDeleted != tt.wantDeleted {
+				t.Errorf("SourceDeleted = %v, want %v", got.SourceDeleted, tt.wantDeleted)
+			}
+			if !strings.Contains(got.SourceKept, tt.wantKept) || (tt.wantKept == "") != (got.SourceKept == "") {
+				t.Errorf("SourceKept = %q, want %q", got.SourceKept, tt.wantKept)
+			}
+			wantDeletes := 0
+			if tt.deleteCode != 0 {
+				wantDeletes = 1
+			}
+			if deletes != wantDeletes {
+				t.Errorf("%d DELETE requests, want %d", deletes, wantDeletes)
+			}
+
+			data, err := os.ReadFile(cfg.AuditLog)
+			if tt.wantAudit == "" {
+				if err == nil && len(data) > 0 {
+					t.Errorf("audit log written: %s", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var e AuditEvent
+			if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 1 {
+				t.Fatalf("%d audit events, want 1", len(lines))
+			}
+			if err := json.Unmarshal(data, &e); err != nil {
+				t.Fatal(err)
+			}
+			if e.Action != AuditDeleteSource || e.Repo != "Horse-Core" || !strings.HasPrefix(e.Result, tt.wantAudit) {
+				t.Errorf("audit event %+v, want %s with result %s", e, AuditDeleteSource, tt.wantAudit)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// countRefs returns the number of branches and tags in a ref listing.
+func countRefs(refs map[string]string) int {
+	n := 0
+	for ref := range refs {
+		if strings.HasPrefix(ref, "refs/heads/") || strings.HasPrefix(ref, "refs/tags/") {
+			n++
+		}
+	}
+	return n
+}
+
+// cmdDiff prints what remains to be migrated: the selected source repositories (repo list
+// with its mapping, filter or globs) missing at destination, the destination repositories
+// without a source counterpart, and the repositories present on both sides whose number
+// of branches and tags differs. Refs are read with ls-remote, nothing is cloned.
+func cmdDiff(ctx context.Context, cfg Config) error {
+	srcRepos, err := getRepos(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, cfg.Trace)
+	if err != nil {
+		return fmt.Errorf("call failed for source %s/%s: %w", cfg.SrcOrg, cfg.SrcProject, err)
+	}
+	selected, preSummary, err := selectRepos(cfg, srcRepos)
+	if err != nil {
+		return err
+	}
+	dstRepos, err := getRepos(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, cfg.Trace)
+	if err != nil {
+		return fmt.Errorf("call failed for destination %s/%s: %w", cfg.DstOrg, cfg.DstProject, err)
+	}
+	sort.Slice(selected, func(i, j int) bool { return strings.ToLower(selected[i].Name) < strings.ToLower(selected[j].Name) })
+
+	dstByKey := map[string]string{}
+	for _, r := range dstRepos {
+		dstByKey[strings.ToLower(r.Name)] = r.Name
+	}
+	matched := map[string]bool{}
+	var onlySrc, mismatched []string
+	inSync := 0
+	fmt.Printf("Diff %s/%s -> %s/%s:\n\n", cfg.SrcOrg, cfg.SrcProject, cfg.DstOrg, cfg.DstProject)
+	for _, r := range selected {
+		dst := destinationName(cfg, r.Name)
+		name := r.Name
+		if dst != r.Name {
+			name += " -> " + dst
+		}
+		existing, ok := dstByKey[strings.ToLower(dst)]
+		if !ok {
+			onlySrc = append(onlySrc, name)
+			continue
+		}
+		matched[strings.ToLower(existing)] = true
+
+		srcURL, srcEnv := gitRemote(cfg, cfg.SrcOrg, url.PathEscape(cfg.SrcProject), url.PathEscape(r.Name), cfg.SrcPAT)
+		dstURL, dstEnv := gitRemote(cfg, cfg.DstOrg, url.PathEscape(cfg.DstProject), url.PathEscape(existing), cfg.DstPAT)
+		srcRefs, err := lsRemote(ctx, srcEnv, srcURL)
+		if err != nil {
+			mismatched = append(mismatched, fmt.Sprintf("%s (source refs not readable: %v)", name, err))
+			continue
+		}
+		dstRefs, err := lsRemote(ctx, dstEnv, dstURL)
+		if err != nil {
+			mismatched = append(mismatched, fmt.Sprintf("%s (destination refs not readable: %v)", name, err))
+			continue
+		}
+		if s, d := countRefs(srcRefs), countRefs(dstRefs); s != d {
+			mismatched = append(mismatched, fmt.Sprintf("%s (source %d refs, destination %d)", name, s, d))
+			continue
+		}
+		inSync++
+	}
+
+	var onlyDst []string
+	for _, r := range dstRepos {
+		if !matched[strings.ToLower(r.Name)] {
+			onlyDst = append(onlyDst, r.Name)
+		}
+	}
+	sort.Slice(onlyDst, func(i, j int) bool { return strings.ToLower(onlyDst[i]) < strings.ToLower(onlyDst[j]) })
+
+	printDiffSection("Only in source", onlySrc)
+	printDiffSection("Only in destination", onlyDst)
+	printDiffSection("Mismatched ref counts", mismatched)
+	var notFound []string
+	for _, s := range preSummary {
+		if !s.Skipped {
+			notFound = append(notFound, s.Repo)
+		}
+	}
+	printDiffSection("In the repo list but not in source", notFound)
+	fmt.Printf("%d source repositories compared: %d matching, %d only in source, %d with mismatched refs; %d only in destination\n",
+		len(selected), inSync, len(onlySrc), len(mismatched), len(onlyDst))
+	return nil
+}
+
+// printDiffSection prints a titled list of the diff, omitted when empty.
+func printDiffSection(title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Printf("%s (%d):\n", title, len(items))
+	for _, it := range items {
+		fmt.Printf("  - %s\n", it)
+	}
+	fmt.Println()
+}
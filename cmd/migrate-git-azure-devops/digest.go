@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// buildAdminDigest lists the repositories created at destination by the run, with size and
+// owner (from the repo list), for the governance team of the destination project.
+// Returns the digest text and the number of created repositories.
+func buildAdminDigest(cfg Config, results []Summary, hostname string) (string, int) {
+	var b strings.Builder
+	n := 0
+	for _, s := range results {
+		if !s.Created || s.RolledBack {
+			continue
+		}
+		n++
+		fmt.Fprintf(&b, "- %s (%s, owner: %s) %s\n", destinationName(cfg, s.Repo), formatBytes(s.Size), ownerOrUnknown(s.Owner), s.DstWebURL)
+	}
+	header := fmt.Sprintf("%d new repositories created in %s/%s by %s on %s at %s\n\n",
+		n, cfg.DstOrg, cfg.DstProject, prog(), hostname, time.Now().Format(time.RFC3339))
+	return header + b.String(), n
+}
+
+// sendAdminDigest writes the digest of created repositories to --admin-digest and/or posts
+// it to --admin-digest-webhook. Nothing is sent when no repository was created.
+func sendAdminDigest(ctx context.Context, cfg Config, results []Summary, hostname string) error {
+	if cfg.AdminDigest == "" && cfg.AdminDigestWebhook == "" {
+		return nil
+	}
+	text, n := buildAdminDigest(cfg, results, hostname)
+	if cfg.AdminDigest != "" {
+		if err := os.WriteFile(cfg.AdminDigest, []byte(text), 0644); err != nil {
+			return fmt.Errorf("writing admin digest: %w", err)
+		}
+		fmt.Printf("Admin digest saved to: %s\n", cfg.AdminDigest)
+	}
+	if cfg.AdminDigestWebhook != "" && n > 0 {
+		if err := postWebhook(ctx, cfg.AdminDigestWebhook, text, cfg.Trace); err != nil {
+			return fmt.Errorf("sending admin digest: %w", err)
+		}
+		fmt.Printf("Admin digest sent (%d new repositories)\n", n)
+	}
+	return nil
+}
+
+// formatBytes formats a size in bytes with a binary unit (e.g. 1.5 GiB).
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ResultDisabledSkip marks the disabled source repositories left out by --skip-disabled.
+const ResultDisabledSkip = "SKIPPED: disabled"
+
+// skipDisabledRepos removes from the selection the disabled source repositories
+// (--skip-disabled), returning them as SKIPPED summaries.
+func skipDisabledRepos(cfg Config, selected []Repo) ([]Repo, []Summary) {
+	if !cfg.SkipDisabled {
+		return selected, nil
+	}
+	var kept []Repo
+	var skipped []Summary
+	for _, r := range selected {
+		if r.IsDisabled {
+			fmt.Printf("[DISABLED] %s skipped: repository disabled at source\n", r.Name)
+			skipped = append(skipped, Summary{
+				Repo:       r.Name,
+				Result:     ResultDisabledSkip,
+				Skipped:    true,
+				SrcRepoID:  r.ID,
+				Size:       r.Size,
+				ErrDetails: "repository disabled at source (--skip-disabled)",
+			})
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept, skipped
+}
+
+// enableForClone enables a disabled source repository (--preserve-disabled), which can't be
+// cloned otherwise, and returns the function disabling it again, which runs only once.
+func enableForClone(ctx context.Context, cfg Config, r Repo) (func(), error) {
+	if err := setRepoDisabled(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, r.ID, false, cfg.Trace); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "[DISABLED] %s: source enabled for the clone\n", r.Name)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			// Use a fresh context: the source must be disabled again even if the run was cancelled
+			dctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := setRepoDisabled(dctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, r.ID, true, cfg.Trace); err != nil {
+				fmt.Fprintf(os.Stderr, "[DISABLED] %s: FAILED to disable the source again, disable it from the Repositories settings: %v\n", r.Name, err)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "[DISABLED] %s: source disabled again\n", r.Name)
+		})
+	}, nil
+}
+
+// disableDestination disables the destination repository of a disabled source after the
+// push (--preserve-disabled).
+func disableDestination(ctx context.Context, cfg Config, dstRepoName string, sum *Summary) error {
+	dstRepoID := sum.DstRepoID
+	if dstRepoID == "" {
+		dst, err := getRepo(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, dstRepoName, cfg.Trace)
+		if err != nil {
+			return err
+		}
+		dstRepoID = dst.ID
+	}
+	if err := setRepoDisabled(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, dstRepoID, true, cfg.Trace); err != nil {
+		return err
+	}
+	sum.Disabled = true
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ResultDiskSpace marks the repository whose migration was waiting for disk space when the
+// run was stopped.
+const ResultDiskSpace = "ERROR: disk space"
+
+// diskPollInterval is how often free space is checked again while a run is paused.
+const diskPollInterval = 30 * time.Second
+
+// waitForDiskSpace pauses the run while the free space of dir is below --min-free-gb,
+// alerting once (console and --disk-alert-webhook) and resuming when space is freed,
+// so that a full work disk doesn't turn every following clone into a failure.
+func waitForDiskSpace(ctx context.Context, cfg Config, dir string) error {
+	if cfg.MinFreeGB <= 0 {
+		return nil
+	}
+	threshold := uint64(cfg.MinFreeGB) << 30
+	alerted := false
+	for {
+		free, err := freeDiskSpace(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: cannot read free disk space of %s: %v\n", dir, err)
+			return nil
+		}
+		if free >= threshold {
+			if alerted {
+				fmt.Printf("[DISK] %s free on %s, resuming\n", formatBytes(int64(free)), dir)
+			}
+			return nil
+		}
+		if !alerted {
+			msg := fmt.Sprintf("%s paused: only %s free on %s (threshold %d GiB). Free some space to resume.",
+				prog(), formatBytes(int64(free)), dir, cfg.MinFreeGB)
+			fmt.Fprintln(os.Stderr, "[DISK] "+msg)
+			if cfg.DiskAlertWebhook != "" {
+				hostname, _ := os.Hostname()
+				if err := postWebhook(ctx, cfg.DiskAlertWebhook, hostname+": "+msg, cfg.Trace); err != nil {
+					fmt.Fprintln(os.Stderr, "Disk alert webhook error:", err)
+				}
+			}
+			alerted = true
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(diskPollInterval):
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMigrateReposStoppedWhileWaitingForDisk(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	prev := freeDiskSpace
+	defer func() { freeDiskSpace = prev }()
+	// The pause outlasts --repo-timeout, then the run is stopped
+	freeDiskSpace = func(string) (uint64, error) {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		return 0, nil
+	}
+
+	cfg := Config{TmpDir: t.TempDir(), MinFreeGB: 1, RepoTimeout: time.Millisecond}
+	repos := []Repo{{Name: "Horse-Core", ID: "1"}, {Name: "Horse-Web", ID: "2"}}
+	results, err := migrateRepos(ctx, cfg, repos, map[string]bool{}, false)
+	if err != nil {
+		t.Fatalf("migrateRepos() error = %v, want the results of the run", err)
+	}
+	if len(results) != 1 || results[0].Repo != "Horse-Core" || results[0].Result != ResultDiskSpace {
+		t.Fatalf("results = %+v, want Horse-Core with %q", results, ResultDiskSpace)
+	}
+}
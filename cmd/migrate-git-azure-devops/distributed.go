@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Distributed migrations use a shared state directory (NFS/SMB share, Azure Files mount, ...):
+// the coordinator writes one shard file per worker, each worker atomically claims shards
+// (claim file created with O_EXCL), migrates them and writes a shard report back; the
+// coordinator waits for every shard report and aggregates them into a single report.
+
+// Claims are leases: the worker touches the claim file every claimHeartbeat while it
+// migrates the shard. A claim not touched for claimLease belongs to a worker that crashed
+// or was killed, and is taken over by the next worker looking for a shard.
+const (
+	claimHeartbeat = 30 * time.Second
+	claimLease     = 5 * time.Minute
+)
+
+// Results of the repositories of a shard without a report from its worker.
+const (
+	ResultWorkerFailed = "ERROR: worker"
+	ResultShardTimeout = "ERROR: shard timeout"
+)
+
+// ShardEntry is a repository assigned to a shard with its destination name.
+type ShardEntry struct {
+	Src  string `json:"src"`
+	Dst  string `json:"dst"`
+	Size int64  `json:"size"`
+}
+
+// Shard is the unit of work claimed by a worker.
+type Shard struct {
+	Index int          `json:"index"`
+	Repos []ShardEntry `json:"repos"`
+}
+
+// shardPath returns the path of a shard file with the given suffix (json, claim, report.json).
+func shardPath(stateDir string, index int, suffix string) string {
+	return filepath.Join(stateDir, fmt.Sprintf("shard-%03d.%s", index, suffix))
+}
+
+// planShards distributes repositories on n shards balancing the total size:
+// largest repos first, each one to the currently lightest shard.
+func planShards(cfg Config, repos []Repo, n int) []Shard {
+	if n > len(repos) {
+		n = len(repos)
+	}
+	sorted := append([]Repo(nil), repos...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Size > sorted[j].Size })
+	shards := make([]Shard, n)
+	load := make([]int64, n)
+	for i := range shards {
+		shards[i].Index = i + 1
+	}
+	for _, r := range sorted {
+		lightest := 0
+		for i := range load {
+			if load[i] < load[lightest] {
+				lightest = i
+			}
+		}
+		shards[lightest].Repos = append(shards[lightest].Repos, ShardEntry{Src: r.Name, Dst: destinationName(cfg, r.Name), Size: r.Size})
+		load[lightest] += r.Size
+	}
+	return shards
+}
+
+// runCoordinator selects the repositories, writes the shards into the state directory,
+// then waits for all worker reports and aggregates them into one report.
+func runCoordinator(cfg Config) error {
+	startTime := time.Now()
+	hostname, _ := os.Hostname()
+
+	listCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	srcRepos, err := getRepos(listCtx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, cfg.Trace)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("call failed for source %s/%s: %w", cfg.SrcOrg, cfg.SrcProject, err)
+	}
+	selected, preSummary, err := selectRepos(cfg, srcRepos)
+	if err != nil {
+		return err
+	}
+	ignoreCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	selected, ignoredSummary, err := applyMigrateIgnore(ignoreCtx, cfg, selected)
+	cancel()
+	if err != nil {
+		return err
+	}
+	preSummary = append(preSummary, ignoredSummary...)
+	selected, largeSummary := skipLargeRepos(cfg, selected)
+	preSummary = append(preSummary, largeSummary...)
+	selected, disabledSummary := skipDisabledRepos(cfg, selected)
+	preSummary = append(preSummary, disabledSummary...)
+	if len(selected) == 0 {
+		fmt.Println("No repository to migrate.")
+		return nil
+	}
+	if err := failOnInvalidNames(cfg, selected); err != nil {
+		return err
+	}
+	if err := failOnNameCollisions(cfg, selected, nil); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cfg.StateDir, 0755); err != nil {
+		return fmt.Errorf("creating --state-dir: %w", err)
+	}
+	if existing, _ := filepath.Glob(filepath.Join(cfg.StateDir, "shard-*")); len(existing) > 0 {
+		return fmt.Errorf("--state-dir %s already contains shards: use an empty directory per run", cfg.StateDir)
+	}
+	shards := planShards(cfg, selected, cfg.Shards)
+	for _, sh := range shards {
+		data, err := json.MarshalIndent(sh, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(shardPath(cfg.StateDir, sh.Index, "json"), data, 0644); err != nil {
+			return fmt.Errorf("writing shard %d: %w", sh.Index, err)
+		}
+		fmt.Printf("Shard %d: %d repositories\n", sh.Index, len(sh.Repos))
+	}
+	fmt.Printf("\n%d shards written to %s. Start the workers with the same flags plus:\n  %s --worker --state-dir %s\n\n",
+		len(shards), cfg.StateDir, prog(), cfg.StateDir)
+
+	// Wait for the shard reports, up to --shard-timeout
+	all := preSummary
+	done := map[int]bool{}
+	var deadline time.Time
+	if cfg.ShardTimeout > 0 {
+		deadline = time.Now().Add(cfg.ShardTimeout)
+	}
+	for len(done) < len(shards) {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			for _, sh := range shards {
+				if !done[sh.Index] {
+					details := fmt.Sprintf("no report of shard %d within --shard-timeout %s (%s)", sh.Index, cfg.ShardTimeout, claimOwner(cfg.StateDir, sh.Index))
+					fmt.Println("ERROR:", details)
+					all = append(all, failedShard(sh, ResultShardTimeout, details)...)
+				}
+			}
+			break
+		}
+		for _, sh := range shards {
+			if done[sh.Index] {
+				continue
+			}
+			data, err := os.ReadFile(shardPath(cfg.StateDir, sh.Index, "report.json"))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			var rep Report
+			if err == nil {
+				err = json.Unmarshal(data, &rep)
+			}
+			if err != nil {
+				return fmt.Errorf("reading report of shard %d: %w", sh.Index, err)
+			}
+			done[sh.Index] = true
+			all = append(all, rep.Summaries...)
+			fmt.Printf("Shard %d completed on %s (%d/%d)\n", sh.Index, rep.Hostname, len(done), len(shards))
+		}
+		if len(done) < len(shards) {
+			time.Sleep(10 * time.Second)
+		}
+	}
+
+	endTime := time.Now()
+	classifyResults(all)
+	printSummary(all)
+	report := Report{
+		StartTime:   startTime,
+		EndTime:     endTime,
+		Duration:    endTime.Sub(startTime).Minutes(),
+		Hostname:    hostname,
+		Summaries:   all,
+		ProgramName: prog(),
+		Version:     version,
+		Commit:      commit,
+		BuildDate:   date,
+	}
+	if err := generateAndSaveReport(report, cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "Report generation error:", err)
+	}
+	digestCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := sendAdminDigest(digestCtx, cfg, all, hostname); err != nil {
+		fmt.Fprintln(os.Stderr, "Admin digest error:", err)
+	}
+	return nil
+}
+
+// claimShard atomically claims the first unclaimed shard of the state directory.
+// Returns false when no shard is left.
+func claimShard(stateDir string) (Shard, bool, error) {
+	files, err := filepath.Glob(filepath.Join(stateDir, "shard-*.json"))
+	if err != nil {
+		return Shard{}, false, err
+	}
+	sort.Strings(files)
+	hostname, _ := os.Hostname()
+	for _, f := range files {
+		if filepath.Ext(f[:len(f)-len(".json")]) == ".report" {
+			continue
+		}
+		var sh Shard
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return Shard{}, false, err
+		}
+		if err := json.Unmarshal(data, &sh); err != nil {
+			return Shard{}, false, fmt.Errorf("invalid shard %s: %w", f, err)
+		}
+		if _, err := os.Stat(shardPath(stateDir, sh.Index, "report.json")); err == nil {
+			continue // completed
+		}
+		claimPath := shardPath(stateDir, sh.Index, "claim")
+		claim, err := os.OpenFile(claimPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if errors.Is(err, os.ErrExist) && takeOverStaleClaim(claimPath) {
+			claim, err = os.OpenFile(claimPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		}
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return Shard{}, false, err
+		}
+		_, _ = fmt.Fprintf(claim, "%s pid %d at %s\n", hostname, os.Getpid(), time.Now().Format(time.RFC3339))
+		if err := claim.Close(); err != nil {
+			return Shard{}, false, err
+		}
+		return sh, true, nil
+	}
+	return Shard{}, false, nil
+}
+
+// runWorker claims and migrates shards until none is left; the migration of each shard
+// is a regular non-interactive run whose report is written back to the state directory.
+func runWorker(cfg Config) error {
+	for {
+		sh, ok, err := claimShard(cfg.StateDir)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("No shard left to claim.")
+			return nil
+		}
+		fmt.Printf("Claimed shard %d (%d repositories)\n\n", sh.Index, len(sh.Repos))
+		shardCfg := cfg
+		shardCfg.Filter = ""
+		shardCfg.Globs = nil
+		shardCfg.RepoList = nil
+		shardCfg.RepoMap = map[string]string{}
+		shardCfg.IgnoreRepo = ""                                   // already applied by the coordinator
+		shardCfg.AdminDigest, shardCfg.AdminDigestWebhook = "", "" // sent once by the coordinator
+		for _, e := range sh.Repos {
+			shardCfg.RepoList = append(shardCfg.RepoList, e.Src)
+			shardCfg.RepoMap[e.Src] = e.Dst
+		}
+		shardCfg.ShardReport = shardPath(cfg.StateDir, sh.Index, "report.json")
+		stopHeartbeat := keepClaim(shardPath(cfg.StateDir, sh.Index, "claim"))
+		err = runNonInteractive(shardCfg)
+		stopHeartbeat()
+		if err != nil {
+			// The coordinator waits for a report: hand over the failure instead
+			if _, statErr := os.Stat(shardCfg.ShardReport); errors.Is(statErr, os.ErrNotExist) {
+				hostname, _ := os.Hostname()
+				report := Report{Hostname: hostname, Summaries: failedShard(sh, ResultWorkerFailed, err.Error())}
+				if werr := writeShardReport(shardCfg.ShardReport, report); werr != nil {
+					fmt.Fprintf(os.Stderr, "Error writing the failure report of shard %d: %v\n", sh.Index, werr)
+				}
+			}
+			return fmt.Errorf("shard %d: %w", sh.Index, err)
+		}
+	}
+}
+
+// keepClaim renews the lease of a claim every claimHeartbeat until the returned function
+// is called.
+func keepClaim(path string) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(claimHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if err := os.Chtimes(path, now, now); err != nil {
+					fmt.Fprintln(os.Stderr, "WARNING: renewing the shard claim failed:", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// takeOverStaleClaim removes a claim whose lease expired, so that the shard can be claimed
+// again. The claim is renamed away first: of several workers finding it stale, only one
+// succeeds. Returns false when the claim is alive or was taken over by another worker.
+func takeOverStaleClaim(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) < claimLease {
+		return false
+	}
+	hostname, _ := os.Hostname()
+	stale := fmt.Sprintf("%s.stale-%s-%d", path, hostname, os.Getpid())
+	if err := os.Rename(path, stale); err != nil {
+		return false
+	}
+	// The claim renamed may be a new one, created by another worker after the check
+	if info, err := os.Stat(stale); err != nil || time.Since(info.ModTime()) < claimLease {
+		if os.Link(stale, path) == nil {
+			_ = os.Remove(stale)
+		}
+		return false
+	}
+	owner, _ := os.ReadFile(stale)
+	fmt.Printf("Taking over the stale claim %s (not renewed since %s): %s", filepath.Base(path),
+		info.ModTime().Format(time.RFC3339), owner)
+	_ = os.Remove(stale)
+	return true
+}
+
+// claimOwner describes the claim of a shard for the error rows of the coordinator.
+func claimOwner(stateDir string, index int) string {
+	path := shardPath(stateDir, index, "claim")
+	info, err := os.Stat(path)
+	if err != nil {
+		return "never claimed"
+	}
+	owner, _ := os.ReadFile(path)
+	return fmt.Sprintf("claimed by %s, last renewed %s", strings.TrimSpace(string(owner)), info.ModTime().Format(time.RFC3339))
+}
+
+// failedShard returns an error row for every repository of a shard without report.
+func failedShard(sh Shard, result, details string) []Summary {
+	rows := make([]Summary, 0, len(sh.Repos))
+	for _, e := range sh.Repos {
+		rows = append(rows, Summary{Repo: e.Src, Result: result, ErrDetails: details})
+	}
+	return rows
+}
+
+// writeShardReport writes the JSON report of a shard for the coordinator.
+// The file is written under a temporary name and renamed, so it appears complete.
+func writeShardReport(path string, report Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func writeTestShard(t *testing.T, dir string, index int) {
+	t.Helper()
+	data, err := json.Marshal(Shard{Index: index, Repos: []ShardEntry{{Src: "repo", Dst: "repo"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(shardPath(dir, index, "json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClaimShard(t *testing.T) {
+	tests := []struct {
+		name      string
+		claimAge  time.Duration // 0: no claim file
+		report    bool
+		wantClaim bool
+	}{
+		{"free shard", 0, false, true},
+		{"live claim", claimLease / 2, false, false},
+		{"stale claim taken over", 2 * claimLease, false, true},
+		{"completed shard", 2 * claimLease, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestShard(t, dir, 1)
+			claim := shardPath(dir, 1, "claim")
+			if tt.claimAge > 0 {
+				if err := os.WriteFile(claim, []byte("other-host pid 1\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				old := time.Now().Add(-tt.claimAge)
+				if err := os.Chtimes(claim, old, old); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.report {
+				if err := writeShardReport(shardPath(dir, 1, "report.json"), Report{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			sh, ok, err := claimShard(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantClaim {
+				t.Fatalf("claimShard() claimed = %v, want %v", ok, tt.wantClaim)
+			}
+			if ok {
+				if sh.Index != 1 {
+					t.Errorf("claimed shard %d, want 1", sh.Index)
+				}
+				info, err := os.Stat(claim)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if time.Since(info.ModTime()) > time.Minute {
+					t.Errorf("claim not renewed by the new owner")
+				}
+			}
+		})
+	}
+}
+
+func TestFailedShard(t *testing.T) {
+	sh := Shard{Index: 2, Repos: []ShardEntry{{Src: "a"}, {Src: "b"}}}
+	rows := failedShard(sh, ResultShardTimeout, "no report")
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	for _, r := range rows {
+		if r.Result != ResultShardTimeout || r.ErrDetails != "no report" || classifyResult(r) != "SHARD_TIMEOUT" {
+			t.Errorf("unexpected row %+v (code %s)", r, classifyResult(r))
+		}
+	}
+}
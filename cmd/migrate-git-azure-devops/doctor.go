@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+)
+
+// doctorCheck prints the outcome of a check of the doctor command and counts the failures.
+type doctorCheck struct {
+	failed int
+}
+
+func (d *doctorCheck) ok(format string, args ...any) {
+	fmt.Printf("[OK]   "+format+"\n", args...)
+}
+
+func (d *doctorCheck) warn(format string, args ...any) {
+	fmt.Printf("[WARN] "+format+"\n", args...)
+}
+
+func (d *doctorCheck) fail(format string, args ...any) {
+	d.failed++
+	fmt.Printf("[FAIL] "+format+"\n", args...)
+}
+
+// newDoctorCmd returns the "doctor" command: checks the environment of a run (git and the
+// optional tools, proxy, work directory, credentials and access to the organizations given)
+// and reports every problem at once instead of failing at the first one.
+func newDoctorCmd(rootCmd *cobra.Command, cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check git and optional tools, proxy, work directory, credentials and access to the organizations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var d doctorCheck
+			runDoctor(cmd.Context(), *cfg, &d)
+			if d.failed > 0 {
+				return fmt.Errorf("%d checks failed", d.failed)
+			}
+			fmt.Println("\nAll checks passed.")
+			return nil
+		},
+	}
+	cmd.Flags().AddFlagSet(rootCmd.Flags())
+	return cmd
+}
+
+// runDoctor performs the checks of the doctor command.
+func runDoctor(ctx context.Context, cfg Config, d *doctorCheck) {
+	// git, required
+	if err := checkGitTools(ctx, cfg); err != nil {
+		d.fail("%v", err)
+	} else {
+		_, raw, _ := toolVersion(ctx, "git", "--version")
+		d.ok("%s (minimum %s)", raw, cfg.MinGitVersion)
+	}
+
+	// Optional tools: only needed by some options
+	lfsWant, _ := parseVersion(minGitLFSVersion)
+	if v, raw, err := toolVersion(ctx, "git", "lfs", "version"); err != nil {
+		d.warn("git-lfs not found: needed by verify --lfs")
+	} else if olderThan(v, lfsWant) {
+		d.warn("%s: verify --lfs needs %s or newer", raw, minGitLFSVersion)
+	} else {
+		d.ok("%s", raw)
+	}
+	if err := checkFilterRepo(ctx); err != nil {
+		d.warn("git filter-repo not found: needed by --rewrite-config")
+	} else {
+		d.ok("git filter-repo available")
+	}
+	if _, err := exec.LookPath("ssh"); err != nil {
+		d.warn("ssh not found: needed by --protocol ssh")
+	} else {
+		d.ok("ssh available")
+	}
+
+	if err := configureProxy(cfg.Proxy, cfg.NoProxy); err != nil {
+		d.fail("proxy: %v", err)
+	} else if cfg.Proxy != "" {
+		d.ok("proxy %s", cfg.Proxy)
+	}
+
+	// Work directory: writable, with free space
+	dir := cfg.TmpDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if tmp, err := os.MkdirTemp(dir, "tmp_doctor_"); err != nil {
+		d.fail("work directory %s not writable: %v", dir, err)
+	} else {
+		_ = os.Remove(tmp)
+		if free, err := freeDiskSpace(dir); err != nil {
+			d.warn("work directory %s: free space unknown: %v", dir, err)
+		} else if cfg.MinFreeGB > 0 && free < uint64(cfg.MinFreeGB)<<30 {
+			d.fail("work directory %s: %s free, under --min-free-gb %d", dir, formatBytes(int64(free)), cfg.MinFreeGB)
+		} else {
+			d.ok("work directory %s: %s free", dir, formatBytes(int64(free)))
+		}
+	}
+
+	// Credentials and access to the projects given
+	if err := resolveCredentials(ctx, &cfg); err != nil {
+		d.fail("credentials: %v", err)
+		return
+	}
+	for _, side := range []struct{ name, org, project, pat, env string }{
+		{"source", cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, "SRC_PAT"},
+		{"destination", cfg.DstOrg, cfg.DstProject, cfg.DstPAT, "DST_PAT"},
+	} {
+		switch {
+		case side.org == "" || side.project == "":
+			d.warn("%s organization/project not given: access not checked", side.name)
+		case side.pat == "":
+			d.fail("%s: %s missing", side.name, side.env)
+		default:
+			if _, err := getProjectID(ctx, side.org, side.project, side.pat, cfg.Trace); err != nil {
+				d.fail("%s %s/%s: %v", side.name, side.org, side.project, err)
+			} else {
+				d.ok("%s %s/%s reachable with the %s credentials", side.name, side.org, side.project, cfg.AuthMode)
+			}
+		}
+	}
+}
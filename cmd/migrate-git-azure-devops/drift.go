@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DestAssumption records what was known about a destination repository when the
+// migration was planned: existence, emptiness and the blocking policies configured (the
+// project-wide ones for a repository still to be created).
+type DestAssumption struct {
+	Repo     string   `json:"repo"`
+	Exists   bool     `json:"exists"`
+	Empty    bool     `json:"empty"`
+	Policies []string `json:"policies,omitempty"`
+}
+
+// captureAssumptions snapshots the destination state for the given destination repo names.
+// The repositories still to be created record the blocking project-wide policies, the ones
+// they will be subject to when pushed.
+func captureAssumptions(ctx context.Context, cfg Config, names []string) (map[string]DestAssumption, error) {
+	dstRepos, err := getRepos(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, cfg.Trace)
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]Repo{}
+	for _, r := range dstRepos {
+		byName[r.Name] = r
+	}
+	var projectPolicies []string // read once, for the first repository to be created
+	projectRead := false
+	out := map[string]DestAssumption{}
+	for _, name := range names {
+		a := DestAssumption{Repo: name}
+		if r, ok := byName[name]; ok {
+			a.Exists = true
+			a.Empty = r.DefaultBranch == ""
+			policies, err := getPolicies(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, r.ID, cfg.Trace)
+			if err != nil {
+				return nil, fmt.Errorf("reading policies of %s: %w", name, err)
+			}
+			a.Policies = blockingPolicies(policies, false)
+		} else {
+			if !projectRead {
+				policies, err := getProjectPolicies(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, cfg.Trace)
+				if err != nil {
+					return nil, fmt.Errorf("reading the project policies: %w", err)
+				}
+				projectPolicies, projectRead = blockingPolicies(policies, true), true
+			}
+			a.Policies = projectPolicies
+		}
+		out[name] = a
+	}
+	return out, nil
+}
+
+// blockingPolicies returns the sorted "id:type" of the enabled blocking policies, only the
+// project-wide ones if projectOnly.
+func blockingPolicies(policies []PolicyConfiguration, projectOnly bool) []string {
+	var out []string
+	for _, p := range policies {
+		if p.IsEnabled && p.IsBlocking && (!projectOnly || projectWide(p)) {
+			out = append(out, fmt.Sprintf("%d:%s", p.ID, p.Type.DisplayName))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// diffAssumptions compares planned and actual destination state and returns
+// one human-readable line per drifted repository (empty if nothing changed).
+func diffAssumptions(planned, actual map[string]DestAssumption) []string {
+	var drift []string
+	for name, p := range planned {
+		a := actual[name]
+		var changes []string
+		if p.Exists != a.Exists {
+			changes = append(changes, fmt.Sprintf("exists %v -> %v", p.Exists, a.Exists))
+		}
+		if p.Exists && a.Exists && p.Empty != a.Empty {
+			changes = append(changes, fmt.Sprintf("empty %v -> %v", p.Empty, a.Empty))
+		}
+		if strings.Join(p.Policies, ",") != strings.Join(a.Policies, ",") {
+			changes = append(changes, fmt.Sprintf("blocking policies [%s] -> [%s]",
+				strings.Join(p.Policies, ", "), strings.Join(a.Policies, ", ")))
+		}
+		if len(changes) > 0 {
+			drift = append(drift, fmt.Sprintf("%s: %s", name, strings.Join(changes, "; ")))
+		}
+	}
+	sort.Strings(drift)
+	return drift
+}
+
+// printDriftReport prints the drift found between plan time and apply time.
+func printDriftReport(drift []string) {
+	fmt.Println("===== DESTINATION DRIFT DETECTED =====")
+	for _, d := range drift {
+		fmt.Println("- " + d)
+	}
+	fmt.Println("The destination changed since the plan was shown: review it and run again.")
+	fmt.Println(strings.Repeat("=", 38))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dryRunRepoOverhead is the time a repository takes besides the transfers: API calls,
+// repository creation, connection setup and the ref advertisement of clone and push.
+const dryRunRepoOverhead = 5 * time.Second
+
+// dryRunInventory fills the summary of a dry run with what the migration would transfer,
+// without cloning: the refs advertised by the source (git ls-remote), the size returned by
+// the API and the estimated clone and push time.
+func dryRunInventory(ctx context.Context, cfg Config, r Repo, srcEnv []string, srcURL string, sum *Summary) {
+	sum.Size = r.Size
+	sum.EstimatedSeconds = estimateSeconds(cfg, r.Size)
+	refs, err := lsRemote(ctx, srcEnv, srcURL)
+	if err != nil {
+		fmt.Printf("  [DRY] git ls-remote of the source failed: %v\n", err)
+	} else {
+		for ref := range refs {
+			if name, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+				sum.BranchNames = append(sum.BranchNames, name)
+			} else if name, ok := strings.CutPrefix(ref, "refs/tags/"); ok {
+				sum.TagNames = append(sum.TagNames, name)
+			}
+		}
+		sort.Strings(sum.BranchNames)
+		sort.Strings(sum.TagNames)
+		sum.NumBranches, sum.NumTags = len(sum.BranchNames), len(sum.TagNames)
+	}
+	fmt.Printf("  [DRY] Source: %d branches, %d tags, %s, estimated %s\n", sum.NumBranches, sum.NumTags,
+		formatBytes(sum.Size), (time.Duration(sum.EstimatedSeconds) * time.Second).Round(time.Second))
+}
+
+// estimateSeconds estimates the clone and push time of a repository of the given size: the
+// size transferred twice at --estimate-rate (or --max-bandwidth when lower), plus the
+// fixed overhead of a repository.
+func estimateSeconds(cfg Config, size int64) float64 {
+	rate := cfg.EstimateRate
+	if cfg.MaxBandwidth > 0 && (rate <= 0 || cfg.MaxBandwidth < rate) {
+		rate = cfg.MaxBandwidth
+	}
+	secs := dryRunRepoOverhead.Seconds()
+	if rate > 0 {
+		secs += 2 * float64(size) / float64(rate)
+	}
+	return secs
+}
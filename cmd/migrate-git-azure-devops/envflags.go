@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// envPrefix is the prefix of the environment variables setting the flags.
+const envPrefix = "MGAD_"
+
+// flagEnvName returns the environment variable of a flag: --dst-project -> MGAD_DST_PROJECT.
+func flagEnvName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// applyEnvFlags sets the flags of the command not given on the command line from their
+// MGAD_* environment variables: command line, then environment, then default. Lists are
+// comma separated, as on the command line; the repeatable flags whose values may hold
+// commas (--git-config, --ref-rename, --rename-regex) take one value per line. MGAD_*
+// variables matching no flag of any command are reported, to catch typos.
+func applyEnvFlags(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "help" {
+			return
+		}
+		value, ok := os.LookupEnv(flagEnvName(f.Name))
+		if !ok {
+			return
+		}
+		values := []string{value}
+		if f.Value.Type() == "stringArray" {
+			values = strings.FieldsFunc(value, func(r rune) bool { return r == '\n' || r == '\r' })
+		}
+		for _, v := range values {
+			if serr := cmd.Flags().Set(f.Name, v); serr != nil {
+				err = fmt.Errorf("%s: %w", flagEnvName(f.Name), serr)
+				return
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	known := map[string]bool{}
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		c.Flags().VisitAll(func(f *pflag.Flag) { known[flagEnvName(f.Name)] = true })
+		for _, sub := range c.Commands() {
+			walk(sub)
+		}
+	}
+	walk(cmd.Root())
+	var unknown []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, envPrefix) && !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		fmt.Fprintf(os.Stderr, "WARNING: %s matches no flag, ignored\n", name)
+	}
+	return nil
+}
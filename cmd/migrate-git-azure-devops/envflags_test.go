@@ -0,0 +1,27 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestApplyEnvFlagsLists(t *testing.T) {
+	var formats, gitConfig []string
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().StringSliceVar(&formats, "report-format", []string{"json"}, "")
+	cmd.Flags().StringArrayVar(&gitConfig, "git-config", nil, "")
+	t.Setenv("MGAD_REPORT_FORMAT", "json,html")
+	t.Setenv("MGAD_GIT_CONFIG", "pack.threads=4\nhttp.extraHeader=X-A: 1,2\n")
+
+	if err := applyEnvFlags(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"json", "html"}; !slices.Equal(formats, want) {
+		t.Errorf("--report-format = %q, want %q", formats, want)
+	}
+	if want := []string{"pack.threads=4", "http.extraHeader=X-A: 1,2"}; !slices.Equal(gitConfig, want) {
+		t.Errorf("--git-config = %q, want %q", gitConfig, want)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// excludePaths rewrites the whole history of the mirror removing the given paths
+// (e.g. third-party code that must not reach the destination tenant) and returns the
+// paths that were actually present. Rewriting changes the SHA of every affected commit.
+// git filter-repo is used when installed, git filter-branch otherwise.
+func excludePaths(ctx context.Context, repoDir string, paths []string) ([]string, error) {
+	var present []string
+	for _, p := range paths {
+		out, err := exec.CommandContext(ctx, "git", "-C", repoDir, "log", "--all", "--format=%H", "-1", "--", p).Output()
+		if err != nil {
+			return nil, fmt.Errorf("checking path %s: %w", p, err)
+		}
+		if strings.TrimSpace(string(out)) != "" {
+			present = append(present, p)
+		}
+	}
+	if len(present) == 0 {
+		return nil, nil
+	}
+
+	if exec.CommandContext(ctx, "git", "filter-repo", "--version").Run() == nil {
+		args := []string{"-C", repoDir, "filter-repo", "--force", "--invert-paths"}
+		for _, p := range present {
+			args = append(args, "--path", p)
+		}
+		return present, runCmd(ctx, nil, "git", args...)
+	}
+
+	fmt.Fprintln(os.Stderr, "  Warning: git filter-repo not found, falling back to the (slower) git filter-branch")
+	quoted := make([]string, len(present))
+	for i, p := range present {
+		quoted[i] = "'" + strings.ReplaceAll(p, "'", `'\''`) + "'"
+	}
+	indexFilter := "git rm -r -q --cached --ignore-unmatch -- " + strings.Join(quoted, " ")
+	err := runCmd(ctx, []string{"FILTER_BRANCH_SQUELCH_WARNING=1"}, "git", "-C", repoDir,
+		"filter-branch", "--force", "--index-filter", indexFilter, "--tag-name-filter", "cat", "--prune-empty", "--", "--all")
+	if err != nil {
+		return present, err
+	}
+	// Drop the refs/original/* backups, they would be pushed by --mirror
+	refs, err := localRefs(ctx, repoDir)
+	if err != nil {
+		return present, err
+	}
+	for ref := range refs {
+		if strings.HasPrefix(ref, "refs/original/") {
+			if err := runCmd(ctx, nil, "git", "-C", repoDir, "update-ref", "-d", ref); err != nil {
+				return present, err
+			}
+		}
+	}
+	return present, nil
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Explanation documents a classified result or error code for the explain command.
+type Explanation struct {
+	Title       string
+	Description string
+	Remediation []string
+}
+
+// explanations is the catalog of result/error codes shown by "explain <code>".
+var explanations = map[string]Explanation{
+	"OK": {
+		Title:       "Repository migrated",
+		Description: "The mirror clone and the mirror push completed successfully.",
+		Remediation: []string{"Nothing to do."},
+	},
+	"DRY_RUN": {
+		Title:       "Simulated only",
+		Description: "The run used --dry-run: no clone, creation or push was performed.",
+		Remediation: []string{"Run again without --dry-run to perform the migration."},
+	},
+	"SKIPPED_EXISTS": {
+		Title:       "Destination repository already present",
+		Description: "The destination repository exists and --force-push was not given, so clone and push were skipped.",
+		Remediation: []string{
+			"Use --final-sync to push only the changes of an already migrated repository.",
+			"Use --force-push to overwrite the destination with the source (irreversible).",
+		},
+	},
+	"ALREADY_MIGRATED": {
+		Title:       "Destination already identical to the source",
+		Description: "Before the clone, ls-remote showed the same branches and tags, at the same SHAs, on both sides: clone and push were skipped.",
+		Remediation: []string{"Nothing to do: re-runs skip the repositories already migrated."},
+	},
+	"SKIPPED_APPROVAL": {
+		Title:       "Force push not approved",
+		Description: "The destination had diverged and the approval request sent with --approval-webhook was denied or timed out.",
+		Remediation: []string{"Check with the approvers, then re-run the repository (optionally with --force-push)."},
+	},
+	"SKIPPED_IGNORED": {
+		Title:       "Excluded by .migrateignore",
+		Description: "The repository matches a pattern of the .migrateignore file in the config repository of the source project (--ignore-repo).",
+		Remediation: []string{"Remove the pattern, or add a '!name' line, in .migrateignore to migrate the repository."},
+	},
+	"SKIPPED_MANIFEST": {
+		Title:       "Skipped by the manifest",
+		Description: "The repository has 'skip: true' in the YAML manifest given with --repo-list.",
+		Remediation: []string{"Remove 'skip: true' from the manifest entry to migrate it."},
+	},
+	"SKIPPED_SIZE": {
+		Title:       "Over the size threshold",
+		Description: "The repository is larger than --skip-larger-than and was left out of the bulk migration.",
+		Remediation: []string{
+			"Migrate it on its own, e.g. with --repo-list, a larger --push-timeout and --git-config tuning.",
+			"For files over the Azure DevOps limits consider Git LFS before migrating.",
+		},
+	},
+	"SKIPPED_DISABLED": {
+		Title:       "Disabled at source",
+		Description: "The source repository is disabled and --skip-disabled left it out of the run.",
+		Remediation: []string{"Drop --skip-disabled and use --preserve-disabled to migrate it and disable it at destination."},
+	},
+	"DISABLED_SOURCE": {
+		Title:       "Disabled source not enabled",
+		Description: "--preserve-disabled could not enable the disabled source repository for the clone.",
+		Remediation: []string{
+			"SRC_PAT needs the Code (Read, write & manage) scope to change the state of a repository.",
+			"Enable it by hand from Project settings > Repositories, or use --skip-disabled.",
+		},
+	},
+	"OVERSIZED_FILES": {
+		Title:       "Files over the Azure DevOps push limits",
+		Description: "The history holds files larger than --max-file-size (Azure DevOps rejects files over 100 MB without LFS) or is larger than a single 5 GB push; the push was not attempted.",
+		Remediation: []string{
+			"Look at OversizedFiles in the JSON report for the size, SHA and path of each file.",
+			"Remove the files from the history with --exclude-path, or move them to Git LFS (git lfs migrate import) in the source.",
+			"Use --skip-oversized to leave these repositories out and go on with the others.",
+		},
+	},
+	"SKIPPED_OVERSIZED": {
+		Title:       "Skipped for oversized files",
+		Description: "The history holds files larger than --max-file-size and --skip-oversized was given.",
+		Remediation: []string{"Remove the files with --exclude-path or move them to Git LFS, then migrate the repository again."},
+	},
+	"SECRETS_FOUND": {
+		Title:       "Secrets found in the history",
+		Description: "The secret scan matched credentials in the files of the history and --block-on-secrets refused the push (or the scan itself failed).",
+		Remediation: []string{
+			"Look at SecretFindings in the JSON report for the rule, file, line and blob of each match.",
+			"Revoke the credentials, then remove them from the history with --exclude-path or git filter-repo in the source.",
+			"For false positives add the path to allowPaths in the --secret-rules file.",
+		},
+	},
+	"BRANCH_FILTER": {
+		Title:       "Branch filter failed",
+		Description: "The branches not matching the 'branches' globs of the manifest could not be removed from the clone.",
+		Remediation: []string{"Check the git message in the report (ErrDetails) and the branch patterns of the manifest."},
+	},
+	"SKIPPED_MISSING_DESTINATION": {
+		Title:       "Destination repository missing",
+		Description: "The destination repository does not exist and was not created (typically in dry-run).",
+		Remediation: []string{"Run without --dry-run so that the repository is created."},
+	},
+	"SOURCE_NOT_FOUND": {
+		Title:       "Source repository not found or not accessible",
+		Description: "The repository is missing from the source project, or git clone failed (name typo, deleted repo, PAT without Code Read scope, network).",
+		Remediation: []string{
+			"Check the name in --repo-list against the output of list.",
+			"Check that SRC_PAT has the Code (Read) scope and is not expired.",
+			"Look at the git message in the report (ErrDetails).",
+		},
+	},
+	"SOURCE_REMOVED": {
+		Title:       "Source repository deleted during the run",
+		Description: "The repository was selected at planning time but the API answers HTTP 404 at clone time: it was deleted (or renamed) in the meantime.",
+		Remediation: []string{
+			"Confirm with the owning team that the deletion is intended.",
+			"Use --on-source-removed error to count it as a failure instead of a skip.",
+		},
+	},
+	"SOURCE_ACCESS_DENIED": {
+		Title:       "Access denied to the source repository",
+		Description: "The API or git answered 401/403: the credentials can't read this repository.",
+		Remediation: []string{"Check the repository permissions of the SRC_PAT owner (Git repositories > Security > Read)."},
+	},
+	"NETWORK": {
+		Title:       "Network failure",
+		Description: "The source could not be reached (DNS, timeout, connection refused/reset).",
+		Remediation: []string{"Check connectivity and --proxy/--no-proxy, then re-run the repository; --retries retries transient failures."},
+	},
+	"CLONE_FAILED": {
+		Title:       "Clone failed",
+		Description: "The source repository exists and is readable but git clone failed.",
+		Remediation: []string{"Look at the git message in the report (ErrDetails), e.g. disk space in the temporary directory."},
+	},
+	"AUTH_FAILED": {
+		Title:       "Authentication failed",
+		Description: "Azure DevOps answered with a redirect to the sign-in page (HTTP 302) or git rejected the credentials: the PAT/token is invalid or expired.",
+		Remediation: []string{
+			"Create a new PAT with the required scopes, or run 'az login' again with --auth azcli.",
+			"Check that the PAT belongs to the right organization.",
+		},
+	},
+	"DESTINATION_CREATION": {
+		Title:       "Destination repository creation failed",
+		Description: "The API call creating the destination repository was rejected.",
+		Remediation: []string{
+			"Check that DST_PAT has the Code (Read, Write & Manage) scope.",
+			"Check the destination name against the naming rules (see the NAME WARNING lines).",
+		},
+	},
+	"PUSH_FAILED": {
+		Title:       "Mirror push failed",
+		Description: "git push --mirror to the destination failed.",
+		Remediation: []string{
+			"Look at the git message in the report (ErrDetails).",
+			"If refs were rejected by branch policies use --bypass-policies.",
+			"If the destination diverged use --force-push (irreversible).",
+		},
+	},
+	"PUSH_PACK_TOO_LARGE": {
+		Title:       "Push rejected: pack or file too large",
+		Description: "Azure DevOps rejects pushes larger than 5 GB and files over 100 MB outside of Git LFS.",
+		Remediation: []string{
+			"Remove large files from the history with --exclude-path, or migrate them to Git LFS before the migration.",
+			"Push the history in smaller chunks manually for very large repositories.",
+		},
+	},
+	"PUSH_REJECTED_POLICY": {
+		Title:       "Push rejected by branch policies",
+		Description: "The destination branch is protected by policies requiring pull requests.",
+		Remediation: []string{"Re-run with --bypass-policies using a PAT allowed to edit policies."},
+	},
+	"POLICY_BYPASS": {
+		Title:       "Policy bypass failed",
+		Description: "The policies of the destination repository could not be read or disabled with --bypass-policies.",
+		Remediation: []string{"Grant the destination PAT the permission to edit policies, or remove the policies manually."},
+	},
+	"PATH_EXCLUSION": {
+		Title:       "History rewrite failed",
+		Description: "Removing the --exclude-path paths from the history failed.",
+		Remediation: []string{"Install git filter-repo (recommended) and check the git message in the report."},
+	},
+	"HISTORY_REWRITE": {
+		Title:       "History cleanup failed",
+		Description: "git filter-repo failed applying the --rewrite-config cleanup to the mirror; nothing was pushed.",
+		Remediation: []string{
+			"Check the git filter-repo message in the report (ErrDetails).",
+			"Check the paths, globs and regexes of the rewrite config (Python regex syntax for replaceText).",
+		},
+	},
+	"SUBMODULE_REWRITE": {
+		Title:       "Submodule URL rewrite failed",
+		Description: "The commit updating .gitmodules with the destination URLs (--submodules commit) could not be created; nothing was pushed.",
+		Remediation: []string{
+			"Check the git message in the report (ErrDetails).",
+			"Use --submodules report to list the changes and apply them by hand after the migration.",
+		},
+	},
+	"SOURCE_LOCK": {
+		Title:       "Source branches could not be locked",
+		Description: "--lock-source could not lock the source branches before the clone, so the repository was not migrated; the branches locked so far were unlocked.",
+		Remediation: []string{
+			"Check that SRC_PAT has the Code (Read, write & manage) scope: locking a branch needs the Manage permissions.",
+			"Check the API message in the report (ErrDetails).",
+		},
+	},
+	"REF_RENAME": {
+		Title:       "Branch rename failed",
+		Description: "The --ref-rename rules map two branches to the same name, onto an existing branch or to an empty name.",
+		Remediation: []string{"Fix the rules (see the message in the report) or rename the conflicting branch in the source."},
+	},
+	"VERIFIED": {
+		Title:       "Verified identical",
+		Description: "verify found every branch and tag of the source at destination with the same SHA.",
+		Remediation: []string{"Nothing to do."},
+	},
+	"LFS_MISSING": {
+		Title:       "LFS objects missing at destination",
+		Description: "verify --lfs found LFS pointers in the destination history whose objects were never uploaded: a mirror push does not copy LFS content.",
+		Remediation: []string{
+			"Copy the objects: git lfs fetch --all from the source, then git lfs push --all to the destination.",
+			"The missing objects (oid and path) are listed in the report (MissingLFSObjects).",
+		},
+	},
+	"BACKUP_FAILED": {
+		Title:       "Destination backup failed",
+		Description: "The destination refs could not be saved to a bundle under --backup-dir, so the force push was not performed.",
+		Remediation: []string{"Check the message in the report, the free space and the permissions of --backup-dir, then retry."},
+	},
+	"EXPORTED": {
+		Title:       "Repository exported",
+		Description: "The mirror clone was written to a git bundle listed in the manifest of --bundle-dir.",
+		Remediation: []string{"Carry the bundle directory to the destination side and run import."},
+	},
+	"BUNDLE_FAILED": {
+		Title:       "Bundle not written",
+		Description: "The refs of the mirror clone could not be read or written to the bundle file.",
+		Remediation: []string{"Check the message in the report, the free space and the permissions of --bundle-dir."},
+	},
+	"TIMEOUT": {
+		Title:       "Timed out",
+		Description: "The clone, the push (--clone-timeout, --push-timeout) or the whole migration of the repository (--repo-timeout) took longer than allowed; the other repositories went on.",
+		Remediation: []string{"Raise or disable (0) the timeout for large repositories, then migrate the repository again."},
+	},
+	"LFS_VERIFY_FAILED": {
+		Title:       "LFS verification failed",
+		Description: "The LFS objects of the destination could not be checked (git-lfs missing or too old, clone or batch API error).",
+		Remediation: []string{"Install git-lfs 3.2 or newer and check the message in the report."},
+	},
+	"STALE": {
+		Title:       "Source changed during the run",
+		Description: "The source refs changed after the clone (e.g. a pull request completed): the destination is already behind.",
+		Remediation: []string{"Re-sync the repository, e.g. with --final-sync, before the cutover."},
+	},
+	"SYNCED": {
+		Title:       "Sync completed",
+		Description: "The changed refs were pushed by --final-sync (then verified identical) or by --sync.",
+		Remediation: []string{"Nothing to do."},
+	},
+	"IN_SYNC": {
+		Title:       "Already in sync",
+		Description: "Source and destination already had identical refs.",
+		Remediation: []string{"Nothing to do."},
+	},
+	"NOT_MIGRATED": {
+		Title:       "Final sync on a repository never migrated",
+		Description: "--final-sync requires the destination repository to exist from a previous full migration.",
+		Remediation: []string{"Run the full migration of the repository first."},
+	},
+	"FREEZE_FAILED": {
+		Title:       "Source branches could not be locked",
+		Description: "--final-sync could not lock the source branches.",
+		Remediation: []string{"Grant SRC_PAT Code (Read & Write) to allow branch locking."},
+	},
+	"VERIFY_FAILED": {
+		Title:       "Verification failed",
+		Description: "After the sync, or when checked by verify, source and destination refs differ (missing, divergent or extra refs).",
+		Remediation: []string{"Check the refs listed in the report (MissingRefs, DivergentRefs, ExtraRefs), then run --final-sync again."},
+	},
+	"DESTINATION_DIVERGED": {
+		Title:       "Destination refs diverged",
+		Description: "--final-sync found destination refs moved since the migration: they were left as is rather than overwritten.",
+		Remediation: []string{"Check the refs listed in the report (DivergentRefs), then run --final-sync again with --force-push (and --backup-dir) to overwrite them."},
+	},
+	"SYNC_FAILED": {
+		Title:       "Sync failed",
+		Description: "Fetching the changed refs from the source failed.",
+		Remediation: []string{"Look at the git message in the report (ErrDetails) and retry."},
+	},
+	"DESTINATION_ERROR": {
+		Title:       "Destination not reachable",
+		Description: "The destination refs could not be listed.",
+		Remediation: []string{"Check DST_PAT and the network path to the destination organization."},
+	},
+	"WORKER_FAILED": {
+		Title:       "Distributed worker failed",
+		Description: "The worker migrating the shard stopped on an error before migrating its repositories.",
+		Remediation: []string{"Look at the error in the report (ErrDetails) and the console of the worker.", "Migrate the repositories again with --retry-failed or a new distributed run."},
+	},
+	"SHARD_TIMEOUT": {
+		Title:       "Shard not completed in time",
+		Description: "No worker wrote the report of the shard within --shard-timeout: no worker was running, or it is still migrating.",
+		Remediation: []string{"Check the workers: the claim owner and its last renewal are in ErrDetails.", "Raise --shard-timeout for very large shards, then migrate the repositories again."},
+	},
+	"RESERVED_DEVICE_NAME": {
+		Title:       "Windows device name",
+		Description: "Names like CON, PRN, AUX, NUL, COM1..9, LPT1..9 can't be used as folders on Windows.",
+		Remediation: []string{"Map the repository to another destination name in the repo list (source,destination)."},
+	},
+	"RESERVED_ADO_NAME": {
+		Title:       "Name reserved by Azure DevOps",
+		Description: "Names like App_Data, bin or web.config are rejected by Azure DevOps.",
+		Remediation: []string{"Map the repository to another destination name in the repo list."},
+	},
+	"GIT_SUFFIX": {
+		Title:       "Name ending with .git",
+		Description: "A repository name ending with .git makes clone URLs ambiguous.",
+		Remediation: []string{"Drop the suffix in the destination name mapping."},
+	},
+	"LEADING_UNDERSCORE": {
+		Title:       "Name starting with an underscore",
+		Description: "Azure DevOps rejects repository names starting with an underscore.",
+		Remediation: []string{"Rename the destination in the repo list mapping."},
+	},
+	"LEADING_TRAILING_DOT": {
+		Title:       "Name starting or ending with a period",
+		Description: "Azure DevOps rejects repository names starting or ending with a period.",
+		Remediation: []string{"Rename the destination in the repo list mapping."},
+	},
+	"SURROUNDING_SPACES": {
+		Title:       "Name with leading/trailing spaces",
+		Description: "Spaces around the name lead to unexpected names at destination.",
+		Remediation: []string{"Trim the name in the repo list mapping."},
+	},
+}
+
+// classifyResult maps a repository outcome to a code of the explanations catalog.
+func classifyResult(s Summary) string {
+	details := strings.ToLower(s.ErrDetails)
+	switch {
+	case s.Result == "OK":
+		return "OK"
+	case s.Result == "DRY-RUN":
+		return "DRY_RUN"
+	case s.Result == ResultStale:
+		return "STALE"
+	case s.Result == ResultSynced:
+		return "SYNCED"
+	case s.Result == ResultVerified:
+		return "VERIFIED"
+	case s.Result == ResultExported:
+		return "EXPORTED"
+	case s.Result == ResultInSync:
+		return "IN_SYNC"
+	case strings.HasPrefix(s.Result, "SKIPPED: approval"):
+		return "SKIPPED_APPROVAL"
+	case s.Result == ResultAlreadyMigrated:
+		return "ALREADY_MIGRATED"
+	case s.Result == "SKIPPED: repo already present":
+		return "SKIPPED_EXISTS"
+	case s.Result == ResultIgnored:
+		return "SKIPPED_IGNORED"
+	case s.Result == ResultManifestSkip:
+		return "SKIPPED_MANIFEST"
+	case s.Result == ResultSizeSkip:
+		return "SKIPPED_SIZE"
+	case s.Result == ResultDisabledSkip:
+		return "SKIPPED_DISABLED"
+	case s.Result == "ERROR: disabled source":
+		return "DISABLED_SOURCE"
+	case s.Result == ResultOversized:
+		return "OVERSIZED_FILES"
+	case s.Result == ResultOversizedSkip:
+		return "SKIPPED_OVERSIZED"
+	case s.Result == ResultSecrets:
+		return "SECRETS_FOUND"
+	case s.Result == "SKIPPED: missing destination":
+		return "SKIPPED_MISSING_DESTINATION"
+	case strings.Contains(details, "http 302") || strings.Contains(details, "authentication failed"):
+		return "AUTH_FAILED"
+	case s.Result == ResultSourceRemoved || s.Result == ResultSourceRemovedError:
+		return "SOURCE_REMOVED"
+	case s.Result == ResultSourceDenied:
+		return "SOURCE_ACCESS_DENIED"
+	case s.Result == ResultNetwork:
+		return "NETWORK"
+	case s.Result == ResultClone:
+		return "CLONE_FAILED"
+	case s.Result == "ERROR: source not found":
+		return "SOURCE_NOT_FOUND"
+	case s.Result == "ERROR: destination creation":
+		return "DESTINATION_CREATION"
+	case s.Result == "ERROR: push":
+		switch {
+		case strings.Contains(details, "tf402455") || strings.Contains(details, "policy"):
+			return "PUSH_REJECTED_POLICY"
+		case strings.Contains(details, "too large") || strings.Contains(details, "exceeds") || strings.Contains(details, "tf401022"):
+			return "PUSH_PACK_TOO_LARGE"
+		}
+		return "PUSH_FAILED"
+	case s.Result == "ERROR: policy bypass":
+		return "POLICY_BYPASS"
+	case s.Result == "ERROR: branch filter":
+		return "BRANCH_FILTER"
+	case s.Result == "ERROR: path exclusion":
+		return "PATH_EXCLUSION"
+	case s.Result == "ERROR: history rewrite":
+		return "HISTORY_REWRITE"
+	case s.Result == "ERROR: submodule rewrite":
+		return "SUBMODULE_REWRITE"
+	case s.Result == "ERROR: source lock":
+		return "SOURCE_LOCK"
+	case s.Result == "ERROR: ref rename":
+		return "REF_RENAME"
+	case s.Result == ResultTimeout:
+		return "TIMEOUT"
+	case s.Result == "ERROR: bundle":
+		return "BUNDLE_FAILED"
+	case s.Result == "ERROR: backup":
+		return "BACKUP_FAILED"
+	case s.Result == "ERROR: lfs missing":
+		return "LFS_MISSING"
+	case s.Result == "ERROR: lfs verify":
+		return "LFS_VERIFY_FAILED"
+	case s.Result == "ERROR: not migrated":
+		return "NOT_MIGRATED"
+	case s.Result == "ERROR: freeze":
+		return "FREEZE_FAILED"
+	case s.Result == ResultDiverged:
+		return "DESTINATION_DIVERGED"
+	case s.Result == "ERROR: verify":
+		return "VERIFY_FAILED"
+	case s.Result == "ERROR: sync":
+		return "SYNC_FAILED"
+	case s.Result == "ERROR: destination":
+		return "DESTINATION_ERROR"
+	case s.Result == ResultWorkerFailed:
+		return "WORKER_FAILED"
+	case s.Result == ResultShardTimeout:
+		return "SHARD_TIMEOUT"
+	}
+	return ""
+}
+
+// classifyResults sets the explain code of every summary.
+func classifyResults(results []Summary) {
+	for i := range results {
+		results[i].Code = classifyResult(results[i])
+	}
+}
+
+// newExplainCmd builds the "explain [code]" subcommand.
+func newExplainCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain [code]",
+		Short: "Explain a result/error code of the summary and the report, with remediation steps",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				codes := make([]string, 0, len(explanations))
+				for c := range explanations {
+					codes = append(codes, c)
+				}
+				sort.Strings(codes)
+				fmt.Println("Available codes:")
+				for _, c := range codes {
+					fmt.Printf("  %-28s %s\n", c, explanations[c].Title)
+				}
+				return nil
+			}
+			code := strings.ToUpper(strings.ReplaceAll(args[0], "-", "_"))
+			e, ok := explanations[code]
+			if !ok {
+				return fmt.Errorf("unknown code: %s (run '%s explain' for the list)", args[0], prog())
+			}
+			fmt.Printf("%s - %s\n\n%s\n\nWhat to do:\n", code, e.Title, e.Description)
+			for _, r := range e.Remediation {
+				fmt.Printf("  - %s\n", r)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ResultExported is the result of a repository written to a bundle by export.
+const ResultExported = "EXPORTED"
+
+// bundleManifestFile is the manifest written by export in --bundle-dir and read by import.
+const bundleManifestFile = "manifest.json"
+
+// bundleManifestVersion is the version of the bundle manifest format.
+const bundleManifestVersion = 1
+
+// BundleEntry is a repository of the bundle manifest.
+type BundleEntry struct {
+	Repo        string `json:"repo"`
+	Destination string `json:"destination"`      // Destination name (repo list mapping, renames)
+	SrcRepoID   string `json:"srcRepoId"`        // Azure DevOps GUID of the source repository
+	Bundle      string `json:"bundle,omitempty"` // File in the bundle directory, empty for an empty repository
+	SHA256      string `json:"sha256,omitempty"` // Checksum of the bundle file
+	Size        int64  `json:"size"`             // Size of the bundle file in bytes
+	Refs        int    `json:"refs"`             // Refs saved in the bundle
+	Owner       string `json:"owner,omitempty"`
+}
+
+// BundleManifest describes the bundles of an air-gapped export.
+type BundleManifest struct {
+	Version    int           `json:"version"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	CreatedBy  string        `json:"createdBy"`
+	SrcOrg     string        `json:"srcOrg"`
+	SrcProject string        `json:"srcProject"`
+	Entries    []BundleEntry `json:"entries"`
+}
+
+// fileSHA256 returns the hex SHA-256 of a file.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// mergeManifest returns the entries of manifest plus those of a previous export of the
+// same project into the directory, for repositories not exported again by this run: a
+// partial export (e.g. of the failed repositories) completes the manifest.
+func mergeManifest(file string, manifest BundleManifest, exported []Repo) []BundleEntry {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return manifest.Entries
+	}
+	var prev BundleManifest
+	if json.Unmarshal(data, &prev) != nil || prev.SrcOrg != manifest.SrcOrg || prev.SrcProject != manifest.SrcProject {
+		return manifest.Entries
+	}
+	done := map[string]bool{}
+	for _, r := range exported {
+		done[r.Name] = true
+	}
+	entries := manifest.Entries
+	for _, e := range prev.Entries {
+		if !done[e.Repo] {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Repo < entries[j].Repo })
+	return entries
+}
+
+// cmdExport is the source half of a disconnected migration: every selected repository is
+// mirror cloned and written to {bundle-dir}/{repo}.bundle, then manifest.json lists the
+// bundles with their destination names and checksums. The directory is carried to a
+// machine reaching the destination and loaded with import.
+func cmdExport(ctx context.Context, cfg Config) error {
+	startTime := time.Now()
+	hostname, _ := os.Hostname()
+
+	srcRepos, err := getRepos(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, cfg.Trace)
+	if err != nil {
+		return fmt.Errorf("call failed for source %s/%s: %w", cfg.SrcOrg, cfg.SrcProject, err)
+	}
+	selected, preSummary, err := selectRepos(cfg, srcRepos)
+	if err != nil {
+		return err
+	}
+	selected, ignored, err := applyMigrateIgnore(ctx, cfg, selected)
+	if err != nil {
+		return err
+	}
+	preSummary = append(preSummary, ignored...)
+	selected, large := skipLargeRepos(cfg, selected)
+	preSummary = append(preSummary, large...)
+	selected, disabled := skipDisabledRepos(cfg, selected)
+	preSummary = append(preSummary, disabled...)
+	if err := failOnInvalidNames(cfg, selected); err != nil {
+		return err
+	}
+	if !cfg.DryRun {
+		if err := os.MkdirAll(cfg.BundleDir, 0o755); err != nil {
+			return fmt.Errorf("creating the bundle directory: %w", err)
+		}
+	}
+	tmpDir, err := os.MkdirTemp(cfg.TmpDir, "tmp_export_git_")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			fmt.Fprintln(os.Stderr, "Error removing temporary directory:", err)
+		}
+	}()
+
+	manifest := BundleManifest{
+		Version:    bundleManifestVersion,
+		CreatedAt:  time.Now().UTC(),
+		CreatedBy:  hostname,
+		SrcOrg:     cfg.SrcOrg,
+		SrcProject: cfg.SrcProject,
+	}
+	var results []Summary
+	for i, r := range selected {
+		fmt.Printf("[%d/%d] export %s\n", i+1, len(selected), r.Name)
+		progress.repo(r.Name, i+1, len(selected))
+		sum := Summary{Repo: r.Name, SrcWebURL: r.WebURL, SrcRepoID: r.ID, Owner: cfg.RepoOwners[r.Name]}
+		entry := BundleEntry{Repo: r.Name, Destination: destinationName(cfg, r.Name), SrcRepoID: r.ID, Owner: cfg.RepoOwners[r.Name]}
+		bundle := filepath.Join(cfg.BundleDir, r.Name+".bundle")
+		if cfg.DryRun {
+			fmt.Printf("  [DRY] Would write %s\n", bundle)
+			sum.Result = "DRY-RUN"
+			results = append(results, sum)
+			continue
+		}
+		if err := exportRepo(ctx, cfg, r, filepath.Join(tmpDir, r.Name+".git"), bundle, &entry, &sum); err != nil {
+			sum.ErrDetails = err.Error()
+			fmt.Println("  Error:", err)
+		} else {
+			manifest.Entries = append(manifest.Entries, entry)
+		}
+		results = append(results, sum)
+	}
+	fmt.Println()
+
+	if !cfg.DryRun {
+		manifest.Entries = mergeManifest(filepath.Join(cfg.BundleDir, bundleManifestFile), manifest, selected)
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(cfg.BundleDir, bundleManifestFile), data, 0o644); err != nil {
+			return fmt.Errorf("writing the manifest: %w", err)
+		}
+		fmt.Printf("%d bundles and %s written to %s\n\n", len(manifest.Entries), bundleManifestFile, cfg.BundleDir)
+	}
+
+	all := append(preSummary, results...)
+	classifyResults(all)
+	printSummary(all)
+	failed := 0
+	for _, s := range all {
+		if strings.HasPrefix(s.Result, "ERROR") {
+			failed++
+		}
+	}
+	endTime := time.Now()
+	if cfg.ReportFormats != nil {
+		report := Report{
+			StartTime:   startTime,
+			EndTime:     endTime,
+			Duration:    endTime.Sub(startTime).Minutes(),
+			Hostname:    hostname,
+			Summaries:   all,
+			ProgramName: prog(),
+			Version:     version,
+			Commit:      commit,
+			BuildDate:   date,
+		}
+		if err := generateAndSaveReport(report, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, "Report generation error:", err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("export failed for %d of %d repositories", failed, len(all))
+	}
+	return nil
+}
+
+// exportRepo mirror clones a source repository and writes its bundle, filling entry and sum.
+func exportRepo(ctx context.Context, cfg Config, r Repo, repodir, bundle string, entry *BundleEntry, sum *Summary) error {
+	srcURL, srcEnv := gitRemote(cfg, cfg.SrcOrg, url.PathEscape(cfg.SrcProject), url.PathEscape(r.Name), cfg.SrcPAT)
+	stopClone := phases.trackRepo(PhaseClone, &sum.CloneSeconds)
+	attempts, err := withRetry(ctx, cfg, "clone", func() error {
+		_ = os.RemoveAll(repodir)
+		return runCmd(ctx, transferEnv(srcEnv), "git", gitTransferArgs(cfg, r.Size, "clone", "--mirror", srcURL, repodir)...)
+	})
+	stopClone()
+	sum.CloneAttempts = attempts
+	if err != nil {
+		sum.Result, sum.Skipped = classifyCloneFailure(ctx, cfg, r.Name, err)
+		return fmt.Errorf("mirror clone: %w", err)
+	}
+	defer os.RemoveAll(repodir)
+
+	refs, err := localRefs(ctx, repodir)
+	if err != nil {
+		sum.Result = "ERROR: bundle"
+		return err
+	}
+	for ref := range refs {
+		switch {
+		case strings.HasPrefix(ref, "refs/heads/"):
+			sum.BranchNames = append(sum.BranchNames, strings.TrimPrefix(ref, "refs/heads/"))
+		case strings.HasPrefix(ref, "refs/tags/"):
+			sum.TagNames = append(sum.TagNames, strings.TrimPrefix(ref, "refs/tags/"))
+		}
+	}
+	sum.NumBranches, sum.NumTags = len(sum.BranchNames), len(sum.TagNames)
+	entry.Refs = len(refs)
+	if len(refs) == 0 {
+		// git refuses to write an empty bundle: import just creates the repository
+		fmt.Println("  Empty repository, no bundle.")
+		sum.Result = ResultExported
+		return nil
+	}
+
+	_ = os.Remove(bundle)
+	if err := runCmd(ctx, nil, "git", "-C", repodir, "bundle", "create", bundle, "--all"); err != nil {
+		_ = os.Remove(bundle)
+		sum.Result = "ERROR: bundle"
+		return fmt.Errorf("writing the bundle: %w", err)
+	}
+	info, err := os.Stat(bundle)
+	if err != nil {
+		sum.Result = "ERROR: bundle"
+		return err
+	}
+	if entry.SHA256, err = fileSHA256(bundle); err != nil {
+		sum.Result = "ERROR: bundle"
+		return err
+	}
+	entry.Bundle = filepath.Base(bundle)
+	entry.Size = info.Size()
+	sum.Size = info.Size()
+	sum.Bundle = bundle
+	setThroughput(sum)
+	fmt.Printf("  OK, %d refs in %s (%s).\n", len(refs), bundle, formatBytes(info.Size()))
+	sum.Result = ResultExported
+	return nil
+}
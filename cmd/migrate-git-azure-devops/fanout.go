@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Destination is an additional destination of the run, from the "destinations" of the YAML
+// manifest: every repository pushed to --dst-org/--dst-project is pushed there too, from
+// the same clone (e.g. a disaster recovery organization).
+type Destination struct {
+	Org     string
+	Project string
+	PATEnv  string // patEnv of the manifest; empty uses the destination token of the run
+	PAT     string // read from PATEnv at manifest load
+}
+
+// loadDestinations validates the additional destinations of the manifest.
+func loadDestinations(file string, cfg *Config, dests []ManifestDestination) error {
+	seen := map[string]bool{strings.ToLower(cfg.DstOrg + "/" + cfg.DstProject): true}
+	for i, d := range dests {
+		org, project := strings.TrimSpace(d.Org), strings.TrimSpace(d.Project)
+		if org == "" || project == "" {
+			return fmt.Errorf("invalid manifest %s: destination %d needs org and project", file, i+1)
+		}
+		key := strings.ToLower(org + "/" + project)
+		if seen[key] {
+			return fmt.Errorf("invalid manifest %s: destination %s/%s listed twice, or equal to --dst-org/--dst-project", file, org, project)
+		}
+		seen[key] = true
+		var pat string
+		if d.PATEnv != "" {
+			if pat = os.Getenv(d.PATEnv); pat == "" && !cfg.DryRun {
+				return fmt.Errorf("invalid manifest %s: destination %s/%s: environment variable %s missing", file, org, project, d.PATEnv)
+			}
+			registerSecret(pat)
+		}
+		cfg.Mirrors = append(cfg.Mirrors, Destination{Org: org, Project: project, PATEnv: d.PATEnv, PAT: pat})
+	}
+	return nil
+}
+
+// mirrorConfig returns the configuration of the run pointing at an additional destination.
+// Without a patEnv it keeps cfg.DstPAT, as refreshed before the repository.
+func mirrorConfig(cfg Config, d Destination) Config {
+	cfg.DstOrg, cfg.DstProject = d.Org, d.Project
+	if d.PATEnv != "" {
+		cfg.DstPAT = d.PAT
+	}
+	return cfg
+}
+
+// pushMirrors pushes the clone of a repository, just pushed to the primary destination, to
+// the additional destinations. Their results go to sum.Mirrors ("org/project: result"):
+// a failure there leaves the result of the primary destination unchanged.
+func pushMirrors(ctx context.Context, cfg Config, repodir, dstRepoName string, size int64, force bool, sum *Summary) {
+	for _, d := range cfg.Mirrors {
+		result, err := pushMirror(ctx, mirrorConfig(cfg, d), repodir, dstRepoName, size, force)
+		if err != nil {
+			result = "ERROR: " + err.Error()
+			fmt.Printf("  Warning: push to %s/%s failed: %v\n", d.Org, d.Project, err)
+		} else {
+			fmt.Printf("  %s/%s: %s\n", d.Org, d.Project, result)
+		}
+		sum.Mirrors = append(sum.Mirrors, d.Org+"/"+d.Project+": "+result)
+	}
+}
+
+// pushMirror pushes a clone to one additional destination, creating the repository when
+// missing; an existing repository is overwritten only with --force-push (or forcePush).
+func pushMirror(ctx context.Context, cfg Config, repodir, name string, size int64, force bool) (string, error) {
+	dst := newDestinationProvider(cfg)
+	exists, err := dst.RepoExists(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("reading the repositories: %w", err)
+	}
+	if exists && !force {
+		return "SKIPPED: repo already present", nil
+	}
+	if !exists {
+		created, err := dst.CreateRepo(ctx, name)
+		recordAudit(cfg, AuditEvent{Action: AuditCreateRepo, Org: cfg.DstOrg, Project: cfg.DstProject,
+			Repo: name, RepoID: created.ID, Result: auditResult(err)})
+		if err != nil {
+			return "", fmt.Errorf("creating the repository: %w", err)
+		}
+	}
+	dstURL, dstEnv := dst.CloneURL(name)
+	args := []string{"-C", repodir, "push", "--mirror"}
+	if exists {
+		args = append(args, "--force")
+	}
+	args = append(args, dstURL)
+	_, err = withRetry(ctx, cfg, "push", func() error {
+		return runCmdTimeout(ctx, cfg.PushTimeout, transferEnv(dstEnv), "git", gitTransferArgs(cfg, size, args...)...)
+	})
+	recordAudit(cfg, AuditEvent{Action: pushAction(exists), Org: cfg.DstOrg, Project: cfg.DstProject,
+		Repo: name, Result: auditResult(err), Details: "additional destination"})
+	if err != nil {
+		return "", fmt.Errorf("push: %w", err)
+	}
+	return "OK", nil
+}
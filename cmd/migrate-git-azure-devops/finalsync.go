@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Results of the final sync (--final-sync).
+const (
+	ResultSynced   = "SYNCED"
+	ResultInSync   = "IN SYNC"
+	ResultDiverged = "ERROR: destination diverged"
+)
+
+// finalSyncRepos is the cutover-night pass run after a previous full migration:
+// for every repository it freezes the source branches, pushes only the refs that differ
+// between source and destination (found with ls-remote, no full mirror clone) and
+// verifies that both sides now have identical refs. Destination refs that diverged since
+// the migration are overwritten only with --force-push (saved first to --backup-dir).
+func finalSyncRepos(ctx context.Context, cfg Config, repos []Repo, dstExists map[string]bool) ([]Summary, error) {
+	tmpDir, err := os.MkdirTemp(cfg.TmpDir, "tmp_migrazione_git_")
+	if err != nil {
+		return nil, err
+	}
+	defer removeTempDir(cfg, tmpDir)
+
+	src, dst := newSourceProvider(cfg), newDestinationProvider(cfg)
+	var results []Summary
+	for i, r := range repos {
+		if refreshCredentials(ctx, &cfg) {
+			src, dst = newSourceProvider(cfg), newDestinationProvider(cfg)
+		}
+		dstRepoName := destinationName(cfg, r.Name)
+		fmt.Printf("[%d/%d] final sync %s\n", i+1, len(repos), r.Name)
+		progress.repo(r.Name, i+1, len(repos))
+		sum := Summary{Repo: r.Name, SrcWebURL: r.WebURL, SrcRepoID: r.ID, Owner: cfg.RepoOwners[r.Name]}
+
+		srcURL, srcEnv := src.CloneURL(r.Name)
+		dstURL, dstEnv := dst.CloneURL(dstRepoName)
+		sum.DstClone = dstURL
+		sum.DstWebURL = dst.WebURL(dstRepoName)
+
+		if !dstExists[dstRepoName] {
+			sum.Result = "ERROR: not migrated"
+			sum.ErrDetails = "destination repository missing: run the full migration first"
+			fmt.Println("  Error: destination repository missing, run the full migration first")
+			results = append(results, sum)
+			continue
+		}
+
+		if err := finalSyncRepo(ctx, cfg, r, tmpDir, srcURL, srcEnv, dstURL, dstEnv, &sum); err != nil {
+			sum.ErrDetails = err.Error()
+			fmt.Println("  Error:", err)
+		}
+		results = append(results, sum)
+		fmt.Println()
+	}
+	return results, nil
+}
+
+// finalSyncRepo freezes, delta-pushes and verifies a single repository, filling sum.
+func finalSyncRepo(ctx context.Context, cfg Config, r Repo, tmpDir, srcURL string, srcEnv []string, dstURL string, dstEnv []string, sum *Summary) error {
+	// 1) Freeze: lock every source branch so nothing lands after the final sync
+	srcRefs, err := lsRemote(ctx, srcEnv, srcURL)
+	if err != nil {
+		sum.Result = "ERROR: source not found"
+		return fmt.Errorf("ls-remote source: %w", err)
+	}
+	for ref := range srcRefs {
+		if !strings.HasPrefix(ref, "refs/heads/") {
+			continue
+		}
+		if cfg.DryRun {
+			fmt.Printf("  [DRY] Would lock source branch %s\n", ref)
+			continue
+		}
+		if err := setRefLocked(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, r.ID, ref, true, cfg.Trace); err != nil {
+			sum.Result = "ERROR: freeze"
+			return err
+		}
+	}
+	// Re-read after the freeze: pushes completed before the lock must be included
+	if !cfg.DryRun {
+		if srcRefs, err = lsRemote(ctx, srcEnv, srcURL); err != nil {
+			sum.Result = "ERROR: source not found"
+			return fmt.Errorf("ls-remote source: %w", err)
+		}
+	}
+
+	// 2) Delta: refs changed, added or deleted since the previous migration, among the
+	// branches and tags migrated (manifest branch filter)
+	expected := expectedRefs(cfg, cfg.RepoOverrides[r.Name], srcRefs)
+	dstRefs, err := lsRemote(ctx, dstEnv, dstURL)
+	if err != nil {
+		sum.Result = "ERROR: destination"
+		return fmt.Errorf("ls-remote destination: %w", err)
+	}
+	dstRefs = branchesAndTags(dstRefs)
+	delta := changedRefs(dstRefs, expected)
+	sum.SyncedRefs = delta
+	for ref := range expected {
+		if strings.HasPrefix(ref, "refs/heads/") {
+			sum.BranchNames = append(sum.BranchNames, strings.TrimPrefix(ref, "refs/heads/"))
+		} else {
+			sum.TagNames = append(sum.TagNames, strings.TrimPrefix(ref, "refs/tags/"))
+		}
+	}
+	sort.Strings(sum.BranchNames)
+	sort.Strings(sum.TagNames)
+	sum.NumBranches, sum.NumTags = len(sum.BranchNames), len(sum.TagNames)
+	if len(delta) == 0 {
+		fmt.Println("  Already in sync.")
+		sum.Result = ResultInSync
+		return nil
+	}
+	fmt.Printf("  %d refs to sync\n", len(delta))
+	if cfg.DryRun {
+		for _, ref := range delta {
+			fmt.Printf("  [DRY] Would sync %s\n", ref)
+		}
+		sum.Result = "DRY-RUN"
+		return nil
+	}
+
+	var fetchSpecs []string
+	for _, ref := range delta {
+		if _, ok := expected[ref]; ok {
+			fetchSpecs = append(fetchSpecs, "+"+ref+":"+ref)
+		}
+	}
+	repodir := filepath.Join(tmpDir, r.Name+".git")
+	if err := runCmd(ctx, nil, "git", "init", "--bare", "--quiet", repodir); err != nil {
+		sum.Result = "ERROR: sync"
+		return err
+	}
+	if len(fetchSpecs) > 0 {
+		args := append([]string{"-C", repodir, "fetch", "--no-tags", srcURL}, fetchSpecs...)
+		if _, err := withRetry(ctx, cfg, "fetch", func() error { return runCmd(ctx, transferEnv(srcEnv), "git", gitTransferArgs(cfg, r.Size, args...)...) }); err != nil {
+			sum.Result = "ERROR: sync"
+			return fmt.Errorf("fetch changed refs: %w", err)
+		}
+	}
+
+	// Destination refs moved since the migration are not fast-forwards of the source: they
+	// are overwritten only with --force-push (or the manifest), after the backup
+	force := cfg.ForcePush
+	if o := cfg.RepoOverrides[r.Name].ForcePush; o != nil {
+		force = *o
+	}
+	diverged := divergedRefs(ctx, repodir, delta, dstRefs, expected)
+	sum.DivergentRefs = diverged
+	if len(diverged) > 0 {
+		fmt.Printf("  %d refs diverged at destination: %s\n", len(diverged), strings.Join(diverged, ", "))
+		if force && cfg.BackupDir != "" {
+			backup, err := backupDestination(ctx, cfg, destinationName(cfg, r.Name), dstURL, dstEnv)
+			if err != nil {
+				sum.Result = "ERROR: backup"
+				return fmt.Errorf("backing up the destination, diverged refs NOT overwritten: %w", err)
+			}
+			if backup != "" {
+				sum.Backup = backup
+				fmt.Println("  Destination refs saved to", backup)
+			}
+		}
+	}
+	var pushSpecs []string
+	for _, ref := range delta {
+		switch {
+		case expected[ref] == "":
+			pushSpecs = append(pushSpecs, ":"+ref) // deleted on source
+		case slices.Contains(diverged, ref) && force:
+			pushSpecs = append(pushSpecs, "+"+ref+":"+ref)
+		case slices.Contains(diverged, ref):
+			fmt.Printf("  %s left as is at destination (use --force-push to overwrite it)\n", ref)
+		default:
+			pushSpecs = append(pushSpecs, ref+":"+ref)
+		}
+	}
+	if len(pushSpecs) > 0 {
+		args := append([]string{"-C", repodir, "push", dstURL}, pushSpecs...)
+		stopPush := phases.trackRepo(PhasePush, &sum.PushSeconds)
+		attempts, err := withRetry(ctx, cfg, "push", func() error { return runCmd(ctx, transferEnv(dstEnv), "git", gitTransferArgs(cfg, r.Size, args...)...) })
+		stopPush()
+		sum.PushAttempts = attempts
+		recordAudit(cfg, AuditEvent{Action: pushAction(force && len(diverged) > 0), Org: cfg.DstOrg, Project: cfg.DstProject,
+			Repo: destinationName(cfg, r.Name), Result: auditResult(err), Details: fmt.Sprintf("%d refs synced from %s", len(pushSpecs), r.Name)})
+		if err != nil {
+			sum.Result = "ERROR: push"
+			return fmt.Errorf("push changed refs: %w", err)
+		}
+	}
+
+	// 3) Verify: both sides must now expose identical refs
+	dstRefs, err = lsRemote(ctx, dstEnv, dstURL)
+	if err != nil {
+		sum.Result = "ERROR: verify"
+		return fmt.Errorf("ls-remote destination: %w", err)
+	}
+	var diff []string
+	for _, ref := range changedRefs(branchesAndTags(dstRefs), expected) {
+		if force || !slices.Contains(diverged, ref) {
+			diff = append(diff, ref)
+		}
+	}
+	if len(diff) > 0 {
+		sum.Result = "ERROR: verify"
+		return fmt.Errorf("refs still differing after sync: %s", strings.Join(diff, ", "))
+	}
+	if len(diverged) > 0 && !force {
+		sum.Result = ResultDiverged
+		return fmt.Errorf("refs diverged at destination, not overwritten without --force-push: %s", strings.Join(diverged, ", "))
+	}
+	fmt.Println("  OK, synced and verified.")
+	sum.Result = ResultSynced
+	return nil
+}
+
+// divergedRefs returns the refs of delta existing at destination whose destination commit is
+// not an ancestor of the source one fetched into repodir: a push would drop destination
+// history. A moved tag always diverges.
+func divergedRefs(ctx context.Context, repodir string, delta []string, dstRefs, expected map[string]string) []string {
+	var out []string
+	for _, ref := range delta {
+		dstSHA, srcSHA := dstRefs[ref], expected[ref]
+		if dstSHA == "" || srcSHA == "" {
+			continue // created or deleted on source
+		}
+		// A destination commit missing from the fetched history is not an ancestor either
+		if strings.HasPrefix(ref, "refs/tags/") ||
+			exec.CommandContext(ctx, "git", "-C", repodir, "merge-base", "--is-ancestor", dstSHA, srcSHA).Run() != nil {
+			out = append(out, ref)
+		}
+	}
+	return out
+}
+
+// branchesAndTags keeps the branches and tags of a set of refs: the destination exposes
+// others (e.g. the pull request refs) that are not migrated.
+func branchesAndTags(refs map[string]string) map[string]string {
+	out := map[string]string{}
+	for ref, sha := range refs {
+		if strings.HasPrefix(ref, "refs/heads/") || strings.HasPrefix(ref, "refs/tags/") {
+			out[ref] = sha
+		}
+	}
+	return out
+}
+
+// printCutoverReport prints the cutover-completion summary of a final sync.
+func printCutoverReport(results []Summary) {
+	var synced, inSync, failed int
+	for _, s := range results {
+		switch s.Result {
+		case ResultSynced:
+			synced++
+		case ResultInSync:
+			inSync++
+		case "DRY-RUN":
+		default:
+			failed++
+		}
+	}
+	fmt.Println("===== CUTOVER COMPLETION =====")
+	fmt.Printf("Repositories: %d, synced: %d, already in sync: %d, failed: %d\n", len(results), synced, inSync, failed)
+	if failed == 0 {
+		fmt.Println("All repositories verified identical: source branches are locked, cutover can be completed.")
+	} else {
+		fmt.Println("Some repositories are NOT verified: fix them before completing the cutover.")
+	}
+	fmt.Println(strings.Repeat("=", 30))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFinalSyncRepoBranchFilter(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	work, src, dst := filepath.Join(dir, "work"), filepath.Join(dir, "src.git"), filepath.Join(dir, "dst.git")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Env = append(cmd.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "--quiet", "--bare", src)
+	git("init", "--quiet", "--bare", dst)
+	git("init", "--quiet", "-b", "main", work)
+	git("-C", work, "commit", "--quiet", "--allow-empty", "-m", "first")
+	git("-C", work, "push", "--quiet", dst, "main")
+	git("-C", work, "commit", "--quiet", "--allow-empty", "-m", "second")
+	git("-C", work, "branch", "scratch/wip")
+	git("-C", work, "tag", "v1.0")
+	git("-C", work, "push", "--quiet", src, "main", "scratch/wip", "v1.0")
+
+	// The manifest migrates main only: the filtered branch is neither synced nor listed
+	cfg := Config{DryRun: true, RepoOverrides: map[string]RepoOverride{"Horse-Core": {Branches: []string{"main"}}}}
+	var sum Summary
+	if err := finalSyncRepo(context.Background(), cfg, Repo{Name: "Horse-Core"}, dir, src, nil, dst, nil, &sum); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"refs/heads/main", "refs/tags/v1.0"}; !slices.Equal(sum.SyncedRefs, want) {
+		t.Errorf("SyncedRefs = %v, want %v", sum.SyncedRefs, want)
+	}
+	if !slices.Equal(sum.BranchNames, []string{"main"}) || !slices.Equal(sum.TagNames, []string{"v1.0"}) {
+		t.Errorf("branches %v, tags %v: want main and v1.0", sum.BranchNames, sum.TagNames)
+	}
+	if sum.Result != "DRY-RUN" {
+		t.Errorf("Result = %q, want DRY-RUN", sum.Result)
+	}
+}
+
+func TestDivergedRefs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	work := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", work}, args...)...)
+		cmd.Env = append(cmd.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--quiet", "-b", "main")
+	git("commit", "--quiet", "--allow-empty", "-m", "first")
+	first := git("rev-parse", "HEAD")
+	git("commit", "--quiet", "--allow-empty", "-m", "second")
+	second := git("rev-parse", "HEAD")
+	git("checkout", "--quiet", "-b", "hotfix", first)
+	git("commit", "--quiet", "--allow-empty", "-m", "destination only")
+	hotfix := git("rev-parse", "HEAD")
+
+	dstRefs := map[string]string{
+		"refs/heads/main":    first,  // fast-forward to second
+		"refs/heads/release": hotfix, // diverged from second
+		"refs/heads/gone":    first,  // deleted on source
+		"refs/tags/v1.0":     first,  // moved tag
+		"refs/heads/lost":    "0123456789abcdef0123456789abcdef01234567",
+	}
+	expected := map[string]string{
+		"refs/heads/main":    second,
+		"refs/heads/release": second,
+		"refs/heads/new":     second, // created on source
+		"refs/tags/v1.0":     second,
+		"refs/heads/lost":    second,
+	}
+	delta := []string{"refs/heads/gone", "refs/heads/lost", "refs/heads/main", "refs/heads/new", "refs/heads/release", "refs/tags/v1.0"}
+	got := divergedRefs(context.Background(), work, delta, dstRefs, expected)
+	if want := []string{"refs/heads/lost", "refs/heads/release", "refs/tags/v1.0"}; !slices.Equal(got, want) {
+		t.Errorf("divergedRefs() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+)
+
+// Limits of the bandwidth probe of --forecast: it stops at whichever comes first.
+const (
+	probeBytes    = 32 << 20
+	probeDuration = 10 * time.Second
+)
+
+// forecastShown is the number of largest repositories listed by the forecast.
+const forecastShown = 5
+
+// printForecast prints, before any clone, the volume the run would transfer (sizes from the
+// API), the largest repositories and the estimated duration at the rate measured by a
+// short download from the source, or at --estimate-rate when the probe fails.
+func printForecast(ctx context.Context, batches []*sourceBatch) {
+	type sized struct {
+		cfg  Config
+		repo Repo
+	}
+	var repos []sized
+	var total int64
+	for _, b := range batches {
+		for _, r := range b.selected {
+			repos = append(repos, sized{b.cfg, r})
+			total += r.Size
+		}
+	}
+	if len(repos) == 0 {
+		return
+	}
+	sort.SliceStable(repos, func(i, j int) bool { return repos[i].repo.Size > repos[j].repo.Size })
+
+	fmt.Println("===== FORECAST =====")
+	fmt.Printf("Volume: %d repos, %s\n", len(repos), formatBytes(total))
+	fmt.Println("Largest repositories:")
+	for _, s := range repos[:min(forecastShown, len(repos))] {
+		fmt.Printf("  %-40s %10s\n", s.repo.Name, formatBytes(s.repo.Size))
+	}
+
+	cfg := repos[0].cfg
+	rate, err := probeBandwidth(ctx, cfg, repos[0].repo)
+	if err != nil {
+		fmt.Printf("Bandwidth probe failed (%v): using --estimate-rate %s/s\n", err, formatBytes(cfg.EstimateRate))
+	} else {
+		fmt.Printf("Bandwidth probe: %s/s (download from %s)\n", formatBytes(rate), repos[0].repo.Name)
+		cfg.EstimateRate = rate
+	}
+	var secs float64
+	for _, s := range repos {
+		secs += estimateSeconds(cfg, s.repo.Size)
+	}
+	fmt.Printf("Estimated duration: ~%s (clone and push of each repository, one at a time)\n\n",
+		(time.Duration(secs) * time.Second).Round(time.Second))
+}
+
+// probeBandwidth measures the download rate from the source: the archive of the default
+// branch of a repository is read for up to probeBytes or probeDuration, timed from the
+// response headers so that the latency of the request does not count.
+func probeBandwidth(ctx context.Context, cfg Config, r Repo) (int64, error) {
+	if r.Size == 0 {
+		return 0, fmt.Errorf("no repository with content")
+	}
+	ctx, cancel := context.WithTimeout(ctx, probeDuration+30*time.Second)
+	defer cancel()
+	urlStr := fmt.Sprintf("https://dev.azure.com/%s/%s/_apis/git/repositories/%s/items?path=/&$format=zip&download=true&api-version=%s",
+		cfg.SrcOrg, url.PathEscape(cfg.SrcProject), url.PathEscape(r.Name), apiVersion)
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", authHeader(cfg.SrcPAT))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error closing HTTP response:", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	buf := make([]byte, 64<<10)
+	var n int64
+	start := time.Now()
+	for n < probeBytes && time.Since(start) < probeDuration {
+		read, err := resp.Body.Read(buf)
+		n += int64(read)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	elapsed := time.Since(start).Seconds()
+	if n < 1<<20 || elapsed <= 0 {
+		return 0, fmt.Errorf("only %s downloaded, too little to measure", formatBytes(n))
+	}
+	return int64(float64(n) / elapsed), nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+// newGenDocsCmd returns the "gen-docs" command: writes the man pages (section 1) and the
+// markdown reference of every command and flag from the cobra definitions, to be packaged
+// with the binary. The man pages of a release carry its build date, not the current one.
+func newGenDocsCmd(rootCmd *cobra.Command) *cobra.Command {
+	var dir string
+	var formats []string
+	cmd := &cobra.Command{
+		Use:   "gen-docs",
+		Short: "Write the man pages and the markdown reference of all commands and flags",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Release binaries are named after the platform (_linux_amd64): document the tool name
+			rootCmd.Use = "migrate-git-azure-devops"
+			rootCmd.DisableAutoGenTag = true
+			for _, f := range formats {
+				switch strings.ToLower(f) {
+				case "man":
+					out := filepath.Join(dir, "man1")
+					if err := os.MkdirAll(out, 0o755); err != nil {
+						return fmt.Errorf("creating %s: %w", out, err)
+					}
+					header := &doc.GenManHeader{
+						Title:   strings.ToUpper(rootCmd.Name()),
+						Section: "1",
+						Source:  rootCmd.Name() + " " + version,
+						Manual:  "Azure DevOps Git migration",
+					}
+					if built, err := time.Parse(time.RFC3339, date); err == nil {
+						header.Date = &built
+					}
+					if err := doc.GenManTree(rootCmd, header, out); err != nil {
+						return fmt.Errorf("writing the man pages: %w", err)
+					}
+					fmt.Println("Man pages written to", out)
+				case "markdown", "md":
+					out := filepath.Join(dir, "markdown")
+					if err := os.MkdirAll(out, 0o755); err != nil {
+						return fmt.Errorf("creating %s: %w", out, err)
+					}
+					if err := doc.GenMarkdownTree(rootCmd, out); err != nil {
+						return fmt.Errorf("writing the markdown reference: %w", err)
+					}
+					fmt.Println("Markdown reference written to", out)
+				default:
+					return fmt.Errorf("unsupported --format: %s (only man, markdown are allowed)", f)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&dir, "dir", "docs/reference", "Directory receiving man1/ (man pages) and markdown/ (reference)")
+	cmd.Flags().StringSliceVar(&formats, "format", []string{"man", "markdown"}, "Formats written: man, markdown (comma separated)")
+	return cmd
+}
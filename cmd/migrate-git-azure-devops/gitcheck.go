@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultMinGitVersion is the default of --min-git-version: credentials are passed to git
+// with GIT_CONFIG_COUNT, supported since git 2.31.
+const defaultMinGitVersion = "2.31.0"
+
+// minGitLFSVersion is the git-lfs release adding "git lfs ls-files --json", used by verify --lfs.
+const minGitLFSVersion = "3.2.0"
+
+var versionRe = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// parseVersion extracts the first major.minor[.patch] of a version string, such as
+// "git version 2.39.5.windows.1" or "git-lfs/3.4.0 (GitHub; linux amd64; go 1.21.1)".
+func parseVersion(s string) ([3]int, error) {
+	var v [3]int
+	m := versionRe.FindStringSubmatch(s)
+	if m == nil {
+		return v, fmt.Errorf("no version number in %q", strings.TrimSpace(s))
+	}
+	for i := range 3 {
+		if m[i+1] != "" {
+			v[i], _ = strconv.Atoi(m[i+1])
+		}
+	}
+	return v, nil
+}
+
+// olderThan reports whether version a is older than b.
+func olderThan(a, b [3]int) bool {
+	for i := range 3 {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// toolVersion runs "{name} {args}" and parses the version it prints.
+func toolVersion(ctx context.Context, name string, args ...string) ([3]int, string, error) {
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return [3]int{}, "", err
+	}
+	v, err := parseVersion(string(out))
+	return v, strings.TrimSpace(string(out)), err
+}
+
+// checkGitTools verifies, before any work, that the external tools needed by the run
+// are installed and recent enough: git (--min-git-version), git-lfs for verify --lfs,
+// ssh for the SSH protocol, a credential helper for --git-credentials system.
+func checkGitTools(ctx context.Context, cfg Config) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		if cfg.GitEngine != GitEngineNative {
+			return fmt.Errorf("git not found in PATH: install git %s or newer (or use --git-engine native)", cfg.MinGitVersion)
+		}
+		// The options needing git are checked once all of them are loaded (needsSystemGit)
+		fmt.Fprintln(os.Stderr, "WARNING: git not found in PATH: huge and Git LFS repositories will fail, the others are migrated with go-git")
+		systemGitMissing = true
+		return nil
+	}
+	want, err := parseVersion(cfg.MinGitVersion)
+	if err != nil {
+		return fmt.Errorf("invalid --min-git-version: %w", err)
+	}
+	have, raw, err := toolVersion(ctx, "git", "--version")
+	if err != nil {
+		return fmt.Errorf("reading the git version: %w", err)
+	}
+	if cfg.Trace {
+		fmt.Fprintf(os.Stderr, "[TRACE] %s\n", raw)
+	}
+	if olderThan(have, want) {
+		return fmt.Errorf("%s is older than the minimum %s (--min-git-version): upgrade git, or lower the minimum at your own risk", raw, cfg.MinGitVersion)
+	}
+
+	if cfg.VerifyLFS {
+		lfsWant, _ := parseVersion(minGitLFSVersion)
+		lfsHave, raw, err := toolVersion(ctx, "git", "lfs", "version")
+		if err != nil {
+			return fmt.Errorf("--lfs requires git-lfs %s or newer, not found: install it (https://git-lfs.com) and run 'git lfs install'", minGitLFSVersion)
+		}
+		if olderThan(lfsHave, lfsWant) {
+			return fmt.Errorf("--lfs requires git-lfs %s or newer, found %s: upgrade git-lfs", minGitLFSVersion, raw)
+		}
+	}
+	if cfg.Protocol == ProtocolSSH || cfg.Fallback == ProtocolSSH {
+		if _, err := exec.LookPath("ssh"); err != nil {
+			return fmt.Errorf("--protocol/--fallback-protocol ssh requires an ssh client in PATH (OpenSSH)")
+		}
+	}
+	if cfg.GitCreds == GitCredsSystem {
+		out, _ := exec.CommandContext(ctx, "git", "config", "--get-all", "credential.helper").Output()
+		if strings.TrimSpace(string(out)) == "" {
+			return fmt.Errorf("--git-credentials system requires a credential helper in the git configuration (e.g. Git Credential Manager: git credential-manager configure)")
+		}
+	}
+	return nil
+}
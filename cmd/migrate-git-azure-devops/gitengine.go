@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// Engines running the git operations (--git-engine).
+const (
+	GitEngineSystem = "system"
+	GitEngineNative = "native"
+)
+
+var (
+	// nativeGit is set by --git-engine native: the mirrors are cloned, pushed and read with
+	// go-git, the installed git is only used for the repositories it can't handle.
+	nativeGit bool
+	// systemGitMissing is set when --git-engine native runs without git installed.
+	systemGitMissing bool
+	nativeSetup      sync.Once
+)
+
+// setupNativeGit makes go-git use the shared HTTP transport (--proxy, --trace-http) and
+// accept the capabilities required by Azure DevOps, as documented by go-git: full clones
+// and pushes work, incremental fetches don't, so kept mirrors are cloned again.
+func setupNativeGit() {
+	nativeSetup.Do(func() {
+		transport.UnsupportedCapabilities = []capability.Capability{capability.ThinPack}
+		client.InstallProtocol("https", githttp.NewClient(&http.Client{
+			Transport: httpClient.Transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse // 302 to the sign-in page on a bad token
+			},
+		}))
+	})
+}
+
+// needsSystemGit returns the first option of the run only available with the installed
+// git, which --git-engine native without git can't honour.
+func needsSystemGit(cfg Config) string {
+	options := []struct {
+		set  bool
+		name string
+	}{
+		{cfg.Verify || cfg.Diff || cfg.Benchmark, "verify, diff and benchmark"},
+		{cfg.Sync || cfg.FinalSync || cfg.Daemon || cfg.HookListen != "", "--sync, --final-sync and the daemon"},
+		{cfg.Export || cfg.Import || cfg.Restore, "export, import and restore"},
+		{cfg.Protocol == ProtocolSSH || cfg.Fallback != "", "--protocol ssh and --fallback-protocol"},
+		{cfg.GitCreds != GitCredsHeader, "--git-credentials " + cfg.GitCreds},
+		{len(cfg.GitConfig) > 0, "--git-config"},
+		{cfg.MaxBandwidth > 0, "--max-bandwidth"},
+		{len(cfg.ExcludePaths) > 0 || cfg.Rewrite != nil, "--exclude-path and --rewrite-config"},
+		{len(cfg.RefRenameRules) > 0, "--ref-rename"},
+		{cfg.Submodules != nil, "--submodules"},
+		{cfg.SecretRules != nil, "--scan-secrets"},
+		{cfg.WorkItemRefs, "--work-item-refs"},
+		{cfg.Provenance != "", "--provenance"},
+		{cfg.BackupDir != "", "--backup-dir"},
+		{cfg.DeleteSource, "--delete-source"},
+		{len(cfg.Mirrors) > 0, "the destinations of the manifest"},
+	}
+	for _, o := range options {
+		if o.set {
+			return o.name
+		}
+	}
+	for name, o := range cfg.RepoOverrides {
+		if len(o.Branches) > 0 {
+			return "the branch filter of " + name + " in the manifest"
+		}
+	}
+	return ""
+}
+
+// repoGitEngine returns the engine migrating a repository: go-git with --git-engine native,
+// unless the repository is huge (--large-repo-size) or uses Git LFS, which go-git can't
+// push, or the run needs settings only the installed git has.
+func repoGitEngine(ctx context.Context, cfg Config, r Repo) (string, error) {
+	if !nativeGit {
+		return GitEngineSystem, nil
+	}
+	reason := ""
+	switch {
+	case cfg.Protocol != ProtocolHTTPS:
+		reason = "--protocol " + cfg.Protocol
+	case cfg.GitCreds != GitCredsHeader:
+		reason = "--git-credentials " + cfg.GitCreds
+	case throttleProxyURL != "" || len(cfg.GitConfig) > 0:
+		reason = "--max-bandwidth/--git-config"
+	case cfg.LargeRepoSize > 0 && r.Size >= cfg.LargeRepoSize:
+		reason = fmt.Sprintf("%s, over --large-repo-size", formatBytes(r.Size))
+	default:
+		if lfs, err := usesLFS(ctx, cfg, r.Name); err == nil && lfs {
+			reason = "Git LFS"
+		}
+	}
+	if reason == "" {
+		return GitEngineNative, nil
+	}
+	if systemGitMissing {
+		return "", fmt.Errorf("the repository needs the installed git (%s), not found in PATH", reason)
+	}
+	fmt.Printf("  Using the installed git (%s)\n", reason)
+	return GitEngineSystem, nil
+}
+
+// runNativeTimeout runs a go-git operation stopped after d (no limit when 0), with the
+// error of a stopped operation wrapping errOperationTimeout like runCmdTimeout.
+func runNativeTimeout(ctx context.Context, d time.Duration, op func(context.Context) error) error {
+	cctx, cancel := withTimeout(ctx, d)
+	defer cancel()
+	err := op(cctx)
+	if err != nil && ctx.Err() == nil && errors.Is(cctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %v", errOperationTimeout, d, err)
+	}
+	return err
+}
+
+// nativeAuth returns the go-git credentials of a token: Bearer for Entra ID access
+// tokens, Basic for PATs.
+func nativeAuth(token string) transport.AuthMethod {
+	if isBearerToken(token) {
+		return &githttp.TokenAuth{Token: token}
+	}
+	return &githttp.BasicAuth{Username: "pat", Password: token}
+}
+
+// nativeCloneMirror is git clone --mirror with go-git.
+func nativeCloneMirror(ctx context.Context, remote, token, repodir string) error {
+	setupNativeGit()
+	progress := newScrubWriter(os.Stderr)
+	defer progress.Flush()
+	_, err := git.PlainCloneContext(ctx, repodir, true, &git.CloneOptions{
+		URL:      remote,
+		Auth:     nativeAuth(token),
+		Mirror:   true,
+		Progress: progress,
+	})
+	if err != nil {
+		return fmt.Errorf("go-git clone: %w", err)
+	}
+	return nil
+}
+
+// nativePushMirror is git push --mirror with go-git: every ref is force pushed and the
+// destination refs missing from the mirror are deleted.
+func nativePushMirror(ctx context.Context, repodir, remote, token string) error {
+	setupNativeGit()
+	repo, err := git.PlainOpen(repodir)
+	if err != nil {
+		return err
+	}
+	progress := newScrubWriter(os.Stderr)
+	defer progress.Flush()
+	err = repo.PushContext(ctx, &git.PushOptions{
+		RemoteURL: remote,
+		RefSpecs:  []gitconfig.RefSpec{"+refs/*:refs/*"},
+		Auth:      nativeAuth(token),
+		Prune:     true,
+		Progress:  progress,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("go-git push: %w", err)
+	}
+	return nil
+}
+
+// nativeLocalRefs is localRefs with go-git.
+func nativeLocalRefs(repoDir string) (map[string]string, error) {
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	refs := map[string]string{}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && ref.Name() != plumbing.HEAD {
+			refs[ref.Name().String()] = ref.Hash().String()
+		}
+		return nil
+	})
+	return refs, err
+}
+
+// nativeRefNames is getGitRefNames with go-git: branch or tag names, sorted.
+func nativeRefNames(repoDir, prefix string) ([]string, error) {
+	refs, err := nativeLocalRefs(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for ref := range refs {
+		if name, ok := strings.CutPrefix(ref, prefix); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// nativeOversizedBlobs is findOversizedBlobs with go-git. The paths are looked up in the
+// trees of the history only when some blob is over the limit.
+func nativeOversizedBlobs(ctx context.Context, repoDir string, limit int64) ([]oversizedBlob, error) {
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	blobs, err := repo.BlobObjects()
+	if err != nil {
+		return nil, err
+	}
+	var found []oversizedBlob
+	index := map[plumbing.Hash]int{}
+	err = blobs.ForEach(func(b *object.Blob) error {
+		if b.Size > limit {
+			index[b.Hash] = len(found)
+			found = append(found, oversizedBlob{sha: b.Hash.String(), size: b.Size})
+		}
+		return ctx.Err()
+	})
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+
+	commits, err := repo.Log(&git.LogOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	missing := len(found)
+	err = commits.ForEach(func(c *object.Commit) error {
+		tree, err := c.Tree()
+		if err != nil {
+			return err
+		}
+		err = tree.Files().ForEach(func(f *object.File) error {
+			if i, ok := index[f.Hash]; ok && found[i].path == "" {
+				found[i].path = f.Name
+				if missing--; missing == 0 {
+					return storer.ErrStop
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if missing == 0 {
+			return storer.ErrStop
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].size > found[j].size })
+	return found, nil
+}
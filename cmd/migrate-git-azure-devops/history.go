@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// historyStats counts the commits reachable from the refs of a mirror and its distinct
+// contributors (author name and email, after .mailmap), for the management reporting.
+func historyStats(ctx context.Context, repoDir string) (commits, contributors int, err error) {
+	if nativeGit {
+		return nativeHistoryStats(ctx, repoDir)
+	}
+	out, err := exec.CommandContext(ctx, "git", "-C", repoDir, "rev-list", "--count", "--all").Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("git rev-list: %w", err)
+	}
+	if commits, err = strconv.Atoi(strings.TrimSpace(string(out))); err != nil {
+		return 0, 0, fmt.Errorf("git rev-list: %w", err)
+	}
+	if commits == 0 {
+		return 0, 0, nil
+	}
+	out, err = exec.CommandContext(ctx, "git", "-C", repoDir, "shortlog", "--summary", "--email", "--all").Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("git shortlog: %w", err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) != "" {
+			contributors++
+		}
+	}
+	return commits, contributors, nil
+}
+
+// nativeHistoryStats is historyStats with go-git (.mailmap is not applied).
+func nativeHistoryStats(ctx context.Context, repoDir string) (int, int, error) {
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return 0, 0, err
+	}
+	iter, err := repo.Log(&git.LogOptions{All: true})
+	if err != nil {
+		return 0, 0, err
+	}
+	commits := 0
+	authors := map[string]bool{}
+	err = iter.ForEach(func(c *object.Commit) error {
+		commits++
+		authors[c.Author.Name+" <"+c.Author.Email+">"] = true
+		return ctx.Err()
+	})
+	return commits, len(authors), err
+}
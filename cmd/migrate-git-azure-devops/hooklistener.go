@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// hookQueueSize is the number of repositories that can wait for a sync.
+const hookQueueSize = 256
+
+// PushEvent is the part of an Azure DevOps service hook "Code pushed" (git.push) payload
+// used by the listener.
+type PushEvent struct {
+	EventType string `json:"eventType"`
+	Resource  struct {
+		Repository struct {
+			ID      string `json:"id"`
+			Name    string `json:"name"`
+			Project struct {
+				Name string `json:"name"`
+			} `json:"project"`
+		} `json:"repository"`
+	} `json:"resource"`
+	ResourceContainers struct {
+		Account struct {
+			BaseURL string `json:"baseUrl"`
+		} `json:"account"`
+	} `json:"resourceContainers"`
+}
+
+// hookListener receives push events from the source organization and syncs the pushed
+// repositories one at a time; a repository pushed again while queued is synced once.
+type hookListener struct {
+	cfg     Config
+	queue   chan string // IDs of the source repositories to sync
+	mu      sync.Mutex
+	pending map[string]bool
+}
+
+// loopbackAddr reports whether a listen address only accepts local connections:
+// "localhost" or a loopback IP. An empty host (":8090") listens on every interface.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// runHookListener serves the service hook endpoint (POST /hooks/push on --hook-listen)
+// and runs an incremental sync (--sync) of each pushed repository, for near-real-time
+// mirroring during a freeze period. It stops on SIGINT/SIGTERM.
+func runHookListener(cfg Config) error {
+	cfg.Sync = true
+	cfg.ForceUnlock = false // each sync takes the locks: removing them would defeat them
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	l := &hookListener{cfg: cfg, queue: make(chan string, hookQueueSize), pending: map[string]bool{}}
+	ln, err := net.Listen("tcp", cfg.HookListen)
+	if err != nil {
+		return fmt.Errorf("hook listener on %s: %w", cfg.HookListen, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hooks/push", l.handle)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintln(os.Stderr, "Hook listener error:", err)
+		}
+	}()
+	fmt.Printf("Listening for push events of %s/%s on http://%s/hooks/push\n", cfg.SrcOrg, cfg.SrcProject, ln.Addr())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for id := range l.queue {
+			l.mu.Lock()
+			delete(l.pending, id)
+			l.mu.Unlock()
+			l.sync(context.Background(), id) // not canceled by the stop request
+		}
+	}()
+
+	<-ctx.Done()
+	fmt.Println("Stop requested, listener exiting after the running sync.")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = server.Shutdown(shutdownCtx)
+	close(l.queue)
+	<-done
+	return nil
+}
+
+// handle validates a service hook call and queues the pushed repository.
+func (l *hookListener) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if l.cfg.HookSecret != "" {
+		got := r.Header.Get("X-Hook-Secret")
+		if _, pass, ok := r.BasicAuth(); ok && got == "" {
+			got = pass
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(l.cfg.HookSecret)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	var ev PushEvent
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&ev); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	repo := ev.Resource.Repository
+	switch {
+	case ev.EventType != "git.push":
+		fmt.Fprintf(w, "ignored event %s\n", ev.EventType)
+		return
+	case !strings.EqualFold(repo.Project.Name, l.cfg.SrcProject):
+		fmt.Fprintf(w, "ignored project %s\n", repo.Project.Name)
+		return
+	case ev.ResourceContainers.Account.BaseURL != "" &&
+		!strings.Contains(strings.ToLower(ev.ResourceContainers.Account.BaseURL), "/"+strings.ToLower(l.cfg.SrcOrg)+"/"):
+		fmt.Fprintf(w, "ignored organization %s\n", ev.ResourceContainers.Account.BaseURL)
+		return
+	case repo.ID == "":
+		http.Error(w, "missing repository", http.StatusBadRequest)
+		return
+	}
+
+	l.mu.Lock()
+	queued := l.pending[repo.ID]
+	if !queued {
+		select {
+		case l.queue <- repo.ID:
+			l.pending[repo.ID] = true
+		default:
+			l.mu.Unlock()
+			http.Error(w, "sync queue full", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	l.mu.Unlock()
+	fmt.Printf("[%s] push on %s: sync queued\n", time.Now().Format(time.RFC3339), repo.Name)
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "sync of %s queued\n", repo.Name)
+}
+
+// sync runs the incremental sync of a source repository, if it is part of the selection
+// (repo list, filter, globs, .migrateignore).
+func (l *hookListener) sync(ctx context.Context, repoID string) {
+	// l.cfg holds the tokens of the start of the listener
+	cfg := l.cfg
+	refreshCredentials(ctx, &cfg)
+	r, err := getRepo(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, repoID, cfg.Trace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Source repository %s not readable: %v\n", repoID, err)
+		return
+	}
+	selected, _, err := selectRepos(cfg, []Repo{r})
+	if err == nil {
+		selected, _, err = applyMigrateIgnore(ctx, cfg, selected)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Selection error:", err)
+		return
+	}
+	if len(selected) == 0 {
+		fmt.Printf("%s is not selected for the migration: push ignored\n", r.Name)
+		return
+	}
+	if !cfg.DryRun {
+		release, err := acquireRunLocks(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Sync of %s not run: %v\n", r.Name, err)
+			return
+		}
+		defer release()
+	}
+	dstRepos, err := listDestination(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Call failed for destination %s/%s: %v\n", cfg.DstOrg, cfg.DstProject, err)
+		return
+	}
+	exists := map[string]bool{}
+	for _, d := range dstRepos {
+		exists[d.Name] = true
+	}
+	results, err := perProject(ctx, cfg, selected, exists, func(pcfg Config, repos []Repo, pexists map[string]bool) ([]Summary, error) {
+		return syncRepos(ctx, pcfg, repos, pexists)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Sync error:", err)
+	}
+	classifyResults(results)
+	printSummary(results)
+}
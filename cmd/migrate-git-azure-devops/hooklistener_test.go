@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoopbackAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1:8090", true},
+		{"127.1.2.3:8090", true},
+		{"[::1]:8090", true},
+		{"localhost:8090", true},
+		{"LOCALHOST:8090", true},
+		{":8090", false},
+		{"0.0.0.0:8090", false},
+		{"[::]:8090", false},
+		{"10.0.0.5:8090", false},
+		{"build-agent:8090", false},
+		{"8090", false},
+	}
+	for _, tt := range tests {
+		if got := loopbackAddr(tt.addr); got != tt.want {
+			t.Errorf("loopbackAddr(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestHookSyncRefreshesCredentials(t *testing.T) {
+	headers := recordAuthHeaders(t)
+	l := &hookListener{cfg: Config{SrcOrg: "contoso", SrcProject: "Horse", SrcPAT: "src-start", TokenRefresh: time.Hour,
+		creds: &credentials{src: staticCredential("src-rotated-7f3a"), srcToken: "src-start"}}}
+
+	l.sync(context.Background(), "0a7c2a4e")
+	got := headers()
+	if len(got) == 0 {
+		t.Fatal("no request to the source")
+	}
+	if want := authHeader("src-rotated-7f3a"); got[0] != want {
+		t.Errorf("Authorization = %q, want the refreshed token %q", got[0], want)
+	}
+}
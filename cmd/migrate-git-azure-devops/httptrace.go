@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// traceBodyLimit is how much of each request and response body --trace-http dumps.
+const traceBodyLimit = 4096
+
+// traceRedactedHeaders are the headers whose value is never dumped.
+var traceRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Amz-Security-Token"}
+
+// traceRedactedParams are the query parameters carrying signatures (SAS tokens, S3 presigned URLs).
+var traceRedactedParams = []string{"sig", "X-Amz-Signature", "X-Amz-Credential", "X-Amz-Security-Token"}
+
+// traceTransport dumps every HTTP exchange of the shared client to a file: request and
+// response headers and the start of the bodies, with credentials redacted.
+type traceTransport struct {
+	next http.RoundTripper
+	mu   sync.Mutex
+	out  io.Writer
+}
+
+// configureHTTPTrace wraps the shared HTTP client with the dump of --trace-http and
+// returns the function closing the dump file.
+func configureHTTPTrace(path string) (func(), error) {
+	if path == "" {
+		return func() {}, nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening --trace-http file: %w", err)
+	}
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	httpClient.Transport = &traceTransport{next: next, out: f}
+	fmt.Fprintln(os.Stderr, "[TRACE] HTTP dumps written to", path)
+	return func() {
+		httpClient.Transport = next
+		if err := f.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error closing --trace-http file:", err)
+		}
+	}, nil
+}
+
+// baseTransport returns the transport of the shared client below the --trace-http dump,
+// holding the proxy settings.
+func baseTransport() http.RoundTripper {
+	if t, ok := httpClient.Transport.(*traceTransport); ok {
+		return t.next
+	}
+	return httpClient.Transport
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "===== %s %s %s\n", time.Now().Format(time.RFC3339Nano), req.Method, traceURL(req.URL))
+	writeTraceHeaders(&b, req.Header)
+	if req.GetBody != nil && req.ContentLength != 0 {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, traceBodyLimit+1))
+			body.Close()
+			writeTraceBody(&b, data, req.ContentLength)
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		fmt.Fprintf(&b, "--- error after %s: %v\n\n", time.Since(start).Round(time.Millisecond), err)
+		t.write(b.String())
+		return resp, err
+	}
+	fmt.Fprintf(&b, "--- %s in %s\n", resp.Status, time.Since(start).Round(time.Millisecond))
+	writeTraceHeaders(&b, resp.Header)
+	// The start of the body is read now and given back in front of the rest
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, traceBodyLimit+1))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+	writeTraceBody(&b, data, resp.ContentLength)
+	b.WriteString("\n")
+	t.write(b.String())
+	return resp, nil
+}
+
+// write appends a dump to the file, scrubbed of the tokens of the run.
+func (t *traceTransport) write(s string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := io.WriteString(t.out, scrub(s)); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing --trace-http file:", err)
+	}
+}
+
+// traceURL returns the URL with the signatures of its query redacted.
+func traceURL(u *url.URL) string {
+	q := u.Query()
+	changed := false
+	for _, p := range traceRedactedParams {
+		if q.Has(p) {
+			q.Set(p, redacted)
+			changed = true
+		}
+	}
+	if !changed {
+		return u.String()
+	}
+	c := *u
+	c.RawQuery = strings.ReplaceAll(q.Encode(), url.QueryEscape(redacted), redacted)
+	return c.String()
+}
+
+// writeTraceHeaders writes the headers sorted by name, credentials redacted (the scheme of
+// an Authorization header is kept: Basic or Bearer tells which token was sent).
+func writeTraceHeaders(b *strings.Builder, h http.Header) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range h[name] {
+			for _, secret := range traceRedactedHeaders {
+				if strings.EqualFold(name, secret) {
+					scheme, _, ok := strings.Cut(v, " ")
+					if v = redacted; ok && strings.HasSuffix(name, "Authorization") {
+						v = scheme + " " + redacted
+					}
+				}
+			}
+			fmt.Fprintf(b, "%s: %s\n", name, v)
+		}
+	}
+}
+
+// writeTraceBody writes the start of a body, noting its full length when truncated.
+func writeTraceBody(b *strings.Builder, data []byte, length int64) {
+	if len(data) == 0 {
+		return
+	}
+	b.WriteString("\n")
+	if len(data) > traceBodyLimit {
+		b.Write(data[:traceBodyLimit])
+		if length > 0 {
+			fmt.Fprintf(b, "\n[... truncated, %d bytes in total]", length)
+		} else {
+			b.WriteString("\n[... truncated]")
+		}
+	} else {
+		b.Write(data)
+	}
+	b.WriteString("\n")
+}
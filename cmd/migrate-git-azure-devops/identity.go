@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// loadIdentityMap reads an --identity-map file: one "source,destination" pair per line,
+// blank lines, "#" comments and a "source,destination" header skipped. The source is a
+// descriptor (aad.…, vssgp.…) or a user name (UPN/email), the destination a user name or a
+// group ("[Project]\Group"). Keys are lower case.
+func loadIdentityMap(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading --identity-map: %w", err)
+	}
+	defer f.Close()
+	m := map[string]string{}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") || n == 1 && strings.EqualFold(line, "source,destination") {
+			continue
+		}
+		src, dst, ok := strings.Cut(line, ",")
+		src, dst = strings.TrimSpace(src), strings.TrimSpace(dst)
+		if !ok || src == "" || dst == "" {
+			return nil, fmt.Errorf("invalid --identity-map %s: line %d: expected source,destination", file, n)
+		}
+		if prev, dup := m[strings.ToLower(src)]; dup && prev != dst {
+			return nil, fmt.Errorf("invalid --identity-map %s: line %d: %s already mapped to %s", file, n, src, prev)
+		}
+		m[strings.ToLower(src)] = dst
+	}
+	return m, sc.Err()
+}
+
+// mapIdentity returns the destination name of a source identity, looked up in --identity-map
+// by descriptor then by user name; unchanged when not mapped (same Entra ID tenant on both
+// sides). Every feature referencing source users or groups goes through it.
+func mapIdentity(cfg Config, uniqueName, descriptor string) string {
+	if descriptor != "" {
+		if dst, ok := cfg.IdentityMap[strings.ToLower(descriptor)]; ok {
+			return dst
+		}
+	}
+	if dst, ok := cfg.IdentityMap[strings.ToLower(uniqueName)]; ok {
+		return dst
+	}
+	return uniqueName
+}
+
+// identityCache caches the destination identity IDs resolved during the run.
+var identityCache = struct {
+	sync.Mutex
+	ids map[string]string
+}{ids: map[string]string{}}
+
+// resolveIdentityID returns the ID of a user (user name, email) or group ("[Project]\Group")
+// of the destination organization, "" when it does not exist there.
+func resolveIdentityID(ctx context.Context, cfg Config, name string) (string, error) {
+	key := strings.ToLower(name)
+	identityCache.Lock()
+	id, ok := identityCache.ids[key]
+	identityCache.Unlock()
+	if ok {
+		return id, nil
+	}
+	urlStr := fmt.Sprintf("https://vssps.dev.azure.com/%s/_apis/identities?searchFilter=General&filterValue=%s&queryMembership=None&api-version=%s",
+		cfg.DstOrg, url.QueryEscape(name), apiVersion)
+	body, code, _, err := doHTTPReq(ctx, "GET", urlStr, cfg.DstPAT, nil)
+	if err != nil {
+		return "", err
+	}
+	if code < 200 || code >= 300 {
+		return "", fmt.Errorf("API error resolving %s (HTTP %d): %s", name, code, string(body))
+	}
+	var resp struct {
+		Value []struct {
+			ID string `json:"id"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("invalid response: %w", err)
+	}
+	if len(resp.Value) > 0 {
+		id = resp.Value[0].ID
+	}
+	identityCache.Lock()
+	identityCache.ids[key] = id
+	identityCache.Unlock()
+	return id, nil
+}
+
+// GraphSubject is a user or group of the Graph API.
+type GraphSubject struct {
+	SubjectKind   string `json:"subjectKind"` // user, group
+	PrincipalName string `json:"principalName"`
+	MailAddress   string `json:"mailAddress"`
+	DisplayName   string `json:"displayName"`
+	Descriptor    string `json:"descriptor"`
+}
+
+// findGraphSubjects returns the users and groups of an organization whose principal name
+// (UPN, "[Project]\Group") or email is name, case-insensitively.
+func findGraphSubjects(ctx context.Context, org, pat, name string) ([]GraphSubject, error) {
+	urlStr := fmt.Sprintf("https://vssps.dev.azure.com/%s/_apis/graph/subjectquery?api-version=%s-preview.1", org, apiVersion)
+	payload, err := json.Marshal(map[string]any{"query": name, "subjectKind": []string{"User", "Group"}})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding payload: %w", err)
+	}
+	body, code, _, err := doHTTPReq(ctx, "POST", urlStr, pat, payload)
+	if err != nil {
+		return nil, err
+	}
+	if code < 200 || code >= 300 {
+		return nil, fmt.Errorf("API error querying the Graph API for %s (HTTP %d): %s", name, code, string(body))
+	}
+	var resp struct {
+		Value []GraphSubject `json:"value"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	var found []GraphSubject
+	for _, s := range resp.Value {
+		if strings.EqualFold(s.PrincipalName, name) || strings.EqualFold(s.MailAddress, name) {
+			found = append(found, s)
+		}
+	}
+	return found, nil
+}
+
+// cmdValidateIdentityMap implements "identity-map validate": every destination identity of
+// --identity-map is resolved in the destination organization with the Graph API. Fails when
+// some are missing or ambiguous, before a run that would silently drop them.
+func cmdValidateIdentityMap(ctx context.Context, cfg Config) error {
+	sources := slices.Sorted(maps.Keys(cfg.IdentityMap))
+	resolved := map[string]string{} // destination -> problem ("" when resolved)
+	var failed int
+	for _, src := range sources {
+		dst := cfg.IdentityMap[src]
+		problem, seen := resolved[dst]
+		if !seen {
+			subjects, err := findGraphSubjects(ctx, cfg.DstOrg, cfg.DstPAT, dst)
+			switch {
+			case err != nil:
+				problem = err.Error()
+			case len(subjects) == 0:
+				problem = "not found in " + cfg.DstOrg
+			case len(subjects) > 1:
+				problem = fmt.Sprintf("ambiguous, %d matches", len(subjects))
+			default:
+				fmt.Printf("OK         %s -> %s (%s %s)\n", src, dst, subjects[0].SubjectKind, subjects[0].DisplayName)
+			}
+			resolved[dst] = problem
+		} else if problem == "" {
+			fmt.Printf("OK         %s -> %s\n", src, dst)
+		}
+		if problem != "" {
+			failed++
+			fmt.Printf("UNRESOLVED %s -> %s: %s\n", src, dst, problem)
+		}
+	}
+	fmt.Printf("\n%d identities checked, %d unresolved\n", len(sources), failed)
+	if failed > 0 {
+		return fmt.Errorf("%d destination identities of --identity-map not resolved", failed)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// loadBundleManifest reads the manifest written by export in a bundle directory.
+func loadBundleManifest(dir string) (BundleManifest, error) {
+	var manifest BundleManifest
+	data, err := os.ReadFile(filepath.Join(dir, bundleManifestFile))
+	if err != nil {
+		return manifest, fmt.Errorf("reading the manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.Version != bundleManifestVersion {
+		return manifest, fmt.Errorf("unsupported manifest version %d", manifest.Version)
+	}
+	return manifest, nil
+}
+
+// cmdImport is the destination half of a disconnected migration: for every repository of
+// the manifest written by export the bundle checksum is checked, the destination repository
+// is created when missing and the refs of the bundle are mirror pushed. Existing
+// repositories are skipped unless --force-push is given, as for a normal migration.
+func cmdImport(ctx context.Context, cfg Config) error {
+	startTime := time.Now()
+	hostname, _ := os.Hostname()
+
+	manifest, err := loadBundleManifest(cfg.BundleDir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Importing %d repositories exported from %s/%s on %s\n\n", len(manifest.Entries),
+		manifest.SrcOrg, manifest.SrcProject, manifest.CreatedAt.Format("2006-01-02 15:04"))
+
+	dst := newDestinationProvider(cfg)
+	dstRepos, err := dst.ListRepos(ctx)
+	if err != nil {
+		return fmt.Errorf("call failed for destination %s/%s: %w", cfg.DstOrg, cfg.DstProject, err)
+	}
+	dstExists := map[string]bool{}
+	for _, r := range dstRepos {
+		dstExists[r.Name] = true
+	}
+	tmpDir, err := os.MkdirTemp(cfg.TmpDir, "tmp_import_git_")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			fmt.Fprintln(os.Stderr, "Error removing temporary directory:", err)
+		}
+	}()
+
+	var results []Summary
+	for i, e := range manifest.Entries {
+		fmt.Printf("[%d/%d] import %s -> %s\n", i+1, len(manifest.Entries), e.Repo, e.Destination)
+		progress.repo(e.Repo, i+1, len(manifest.Entries))
+		sum := Summary{Repo: e.Repo, SrcRepoID: e.SrcRepoID, Owner: e.Owner, Size: e.Size}
+		sum.DstWebURL = dst.WebURL(e.Destination)
+		if err := importRepo(ctx, cfg, e, filepath.Join(tmpDir, e.Repo+".git"), dstExists, &sum); err != nil {
+			sum.ErrDetails = err.Error()
+			fmt.Println("  Error:", err)
+		}
+		results = append(results, sum)
+		fmt.Println()
+	}
+	if cfg.RollbackOnFailure {
+		rollbackCreated(ctx, cfg, results)
+	}
+
+	classifyResults(results)
+	printSummary(results)
+	failed := 0
+	for _, s := range results {
+		if strings.HasPrefix(s.Result, "ERROR") {
+			failed++
+		}
+	}
+	endTime := time.Now()
+	if cfg.ReportFormats != nil {
+		report := Report{
+			StartTime:   startTime,
+			EndTime:     endTime,
+			Duration:    endTime.Sub(startTime).Minutes(),
+			Hostname:    hostname,
+			Summaries:   results,
+			ProgramName: prog(),
+			Version:     version,
+			Commit:      commit,
+			BuildDate:   date,
+		}
+		if err := generateAndSaveReport(report, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, "Report generation error:", err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("import failed for %d of %d repositories", failed, len(results))
+	}
+	return nil
+}
+
+// importRepo checks the bundle of a manifest entry and pushes it to the destination,
+// filling sum.
+func importRepo(ctx context.Context, cfg Config, e BundleEntry, repodir string, dstExists map[string]bool, sum *Summary) error {
+	dstURL, dstEnv := newDestinationProvider(cfg).CloneURL(e.Destination)
+	sum.DstClone = dstURL
+	if e.Bundle != "" {
+		bundle := filepath.Join(cfg.BundleDir, e.Bundle)
+		sum.Bundle = bundle
+		checksum, err := fileSHA256(bundle)
+		if err != nil {
+			sum.Result = "ERROR: bundle"
+			return err
+		}
+		if checksum != e.SHA256 {
+			sum.Result = "ERROR: bundle"
+			return fmt.Errorf("checksum mismatch for %s: the file is corrupted or was modified", e.Bundle)
+		}
+	}
+
+	origExists := dstExists[e.Destination]
+	if origExists && !cfg.ForcePush {
+		fmt.Println("  Repo already present in destination. Push NOT performed (use --force-push to force).")
+		sum.Result = "SKIPPED: repo already present"
+		sum.Skipped = true
+		return nil
+	}
+	if cfg.DryRun {
+		if !origExists {
+			fmt.Printf("  [DRY] Would create repo in destination: %s\n", e.Destination)
+		}
+		if e.Bundle != "" {
+			fmt.Printf("  [DRY] Would push %d refs from %s\n", e.Refs, sum.Bundle)
+		}
+		sum.Result = "DRY-RUN"
+		return nil
+	}
+
+	if !origExists {
+		stopCreate := phases.track(PhaseCreate)
+		created, err := newDestinationProvider(cfg).CreateRepo(ctx, e.Destination)
+		stopCreate()
+		recordAudit(cfg, AuditEvent{Action: AuditCreateRepo, Org: cfg.DstOrg, Project: cfg.DstProject,
+			Repo: e.Destination, RepoID: created.ID, Result: auditResult(err)})
+		if err != nil {
+			sum.Result = "ERROR: destination creation"
+			return err
+		}
+		sum.DstRepoID = created.ID
+		sum.Created = true
+		dstExists[e.Destination] = true
+	}
+	if e.Bundle == "" {
+		fmt.Println("  OK, empty repository.")
+		sum.Result = "OK"
+		return nil
+	}
+
+	if err := runCmd(ctx, nil, "git", "clone", "--mirror", sum.Bundle, repodir); err != nil {
+		sum.Result = "ERROR: bundle"
+		return fmt.Errorf("reading the bundle: %w", err)
+	}
+	defer os.RemoveAll(repodir)
+	if branchNames, err := getGitRefNames(repodir, RefTypeBranches); err == nil {
+		sum.BranchNames, sum.NumBranches = branchNames, len(branchNames)
+	}
+	if tagNames, err := getGitRefNames(repodir, RefTypeTags); err == nil {
+		sum.TagNames, sum.NumTags = tagNames, len(tagNames)
+	}
+
+	args := []string{"-C", repodir, "push", "--mirror"}
+	if origExists {
+		args = append(args, "--force")
+		if cfg.BackupDir != "" {
+			backup, err := backupDestination(ctx, cfg, e.Destination, dstURL, dstEnv)
+			if err != nil {
+				sum.Result = "ERROR: backup"
+				return fmt.Errorf("backing up the destination, force push NOT performed: %w", err)
+			}
+			sum.Backup = backup
+		}
+	}
+	args = append(args, dstURL)
+	if cfg.BypassPolicies {
+		restorePolicies, err := bypassPolicies(ctx, cfg, e.Destination, sum)
+		if err != nil {
+			sum.Result = "ERROR: policy bypass"
+			return err
+		}
+		defer restorePolicies()
+	}
+	stopPush := phases.trackRepo(PhasePush, &sum.PushSeconds)
+	attempts, err := withRetry(ctx, cfg, "push", func() error { return runCmd(ctx, transferEnv(dstEnv), "git", gitTransferArgs(cfg, e.Size, args...)...) })
+	stopPush()
+	sum.PushAttempts = attempts
+	recordAudit(cfg, AuditEvent{Action: pushAction(origExists), Org: cfg.DstOrg, Project: cfg.DstProject,
+		Repo: e.Destination, RepoID: sum.DstRepoID, Result: auditResult(err), Details: "from bundle " + sum.Bundle})
+	if err != nil {
+		sum.Result = "ERROR: push"
+		return fmt.Errorf("push from the bundle: %w", err)
+	}
+	fmt.Println("  OK.")
+	sum.Result = "OK"
+	setThroughput(sum)
+	return nil
+}
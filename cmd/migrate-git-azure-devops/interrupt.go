@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// The undo functions registered with onInterrupt, run when SIGINT/SIGTERM stops the run
+// while a temporary change (a disabled policy, a locked source branch) is in place.
+var (
+	interruptMu    sync.Mutex
+	interruptHooks = map[int]func(){}
+	interruptNext  int
+	interrupted    bool
+	interruptOnce  sync.Once
+	interruptCh    = make(chan os.Signal, 1)
+)
+
+// onInterrupt registers fn to run if the process is stopped by SIGINT/SIGTERM before the
+// returned function removes it. The signals are caught only while a function is registered.
+func onInterrupt(fn func()) (remove func()) {
+	interruptMu.Lock()
+	defer interruptMu.Unlock()
+	interruptOnce.Do(func() { go handleInterrupt() })
+	if len(interruptHooks) == 0 && !interrupted {
+		signal.Notify(interruptCh, os.Interrupt, syscall.SIGTERM)
+	}
+	id := interruptNext
+	interruptNext++
+	interruptHooks[id] = fn
+	return func() {
+		interruptMu.Lock()
+		defer interruptMu.Unlock()
+		delete(interruptHooks, id)
+		if len(interruptHooks) == 0 {
+			signal.Stop(interruptCh)
+		}
+	}
+}
+
+// handleInterrupt runs the registered functions on the first signal and exits. A second
+// signal is no longer caught and stops the process at once.
+func handleInterrupt() {
+	sig := <-interruptCh
+	interruptMu.Lock()
+	interrupted = true
+	signal.Stop(interruptCh)
+	hooks := make([]func(), 0, len(interruptHooks))
+	for _, fn := range interruptHooks {
+		hooks = append(hooks, fn)
+	}
+	interruptMu.Unlock()
+
+	fmt.Fprintf(os.Stderr, "\nReceived %v: undoing the temporary changes of the run before exiting (again to exit now)\n", sig)
+	var wg sync.WaitGroup
+	for _, fn := range hooks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	}
+	wg.Wait()
+	os.Exit(130)
+}
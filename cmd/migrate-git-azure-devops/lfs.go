@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+// lfsBatchSize is the number of objects checked per LFS batch API request.
+const lfsBatchSize = 100
+
+// LFSPointer is an LFS object referenced by a pointer file in the history.
+type LFSPointer struct {
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+	Name string `json:"name"`
+}
+
+// usesLFS reports whether the .gitattributes at the root of the default branch of a
+// repository sends files to Git LFS, read through the API without cloning.
+func usesLFS(ctx context.Context, cfg Config, repo string) (bool, error) {
+	attrs, found, err := getItemContent(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, repo, "/.gitattributes", cfg.Trace)
+	if err != nil || !found {
+		return false, err
+	}
+	return bytes.Contains(attrs, []byte("filter=lfs")), nil
+}
+
+// lfsPointers lists the LFS pointers reachable from every ref of a repository
+// (git lfs ls-files --all --json, git-lfs 3.2 or newer), one entry per object.
+func lfsPointers(ctx context.Context, repoDir string) ([]LFSPointer, error) {
+	output, err := exec.CommandContext(ctx, "git", "-C", repoDir, "lfs", "ls-files", "--all", "--json").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git lfs ls-files (git-lfs 3.2+ required): %w", err)
+	}
+	var out struct {
+		Files []LFSPointer `json:"files"`
+	}
+	if err := json.Unmarshal(output, &out); err != nil {
+		return nil, fmt.Errorf("invalid git lfs ls-files output: %w", err)
+	}
+	seen := map[string]bool{}
+	var pointers []LFSPointer
+	for _, f := range out.Files {
+		if !seen[f.OID] {
+			seen[f.OID] = true
+			pointers = append(pointers, f)
+		}
+	}
+	return pointers, nil
+}
+
+// missingLFSObjects asks the LFS batch API of a repository whether the objects can be
+// downloaded and returns those the server does not have ("oid path").
+func missingLFSObjects(ctx context.Context, org, project, repo, token string, pointers []LFSPointer, trace bool) ([]string, error) {
+	endpoint := fmt.Sprintf("https://dev.azure.com/%s/%s/_git/%s/info/lfs/objects/batch",
+		org, url.PathEscape(project), url.PathEscape(repo))
+	var missing []string
+	for start := 0; start < len(pointers); start += lfsBatchSize {
+		chunk := pointers[start:min(start+lfsBatchSize, len(pointers))]
+		type object struct {
+			OID  string `json:"oid"`
+			Size int64  `json:"size"`
+		}
+		req := struct {
+			Operation string   `json:"operation"`
+			Transfers []string `json:"transfers"`
+			Objects   []object `json:"objects"`
+		}{Operation: "download", Transfers: []string{"basic"}}
+		names := map[string]string{}
+		for _, p := range chunk {
+			req.Objects = append(req.Objects, object{OID: p.OID, Size: p.Size})
+			names[p.OID] = p.Name
+		}
+		body, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		data, err := lfsBatch(ctx, endpoint, token, body, trace)
+		if err != nil {
+			return nil, err
+		}
+		var resp struct {
+			Objects []struct {
+				OID     string                     `json:"oid"`
+				Actions map[string]json.RawMessage `json:"actions"`
+				Error   *struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				} `json:"error"`
+			} `json:"objects"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, fmt.Errorf("invalid LFS batch response: %w", err)
+		}
+		found := map[string]bool{}
+		for _, o := range resp.Objects {
+			if o.Error == nil && o.Actions["download"] != nil {
+				found[o.OID] = true
+			}
+		}
+		for _, p := range chunk {
+			if !found[p.OID] {
+				missing = append(missing, p.OID+" "+names[p.OID])
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// lfsBatch posts a request to the LFS batch API with the LFS media type.
+func lfsBatch(ctx context.Context, endpoint, token string, body []byte, trace bool) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authHeader(token))
+	req.Header.Set("Accept", "application/vnd.git-lfs+json")
+	req.Header.Set("Content-Type", "application/vnd.git-lfs+json")
+	if trace {
+		fmt.Fprintf(os.Stderr, "[TRACE] POST %s\n", endpoint)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error closing HTTP response:", err)
+		}
+	}()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("LFS batch API error (HTTP %d): %s", resp.StatusCode, string(data))
+	}
+	return data, nil
+}
+
+// verifyLFS checks that every LFS pointer in the history of the destination repository
+// resolves to an object uploaded to the destination LFS store. The repository is cloned
+// bare (pointers only, no LFS content is downloaded).
+func verifyLFS(ctx context.Context, cfg Config, tmpDir, dstRepoName, dstURL string, dstEnv []string, sum *Summary) error {
+	repoDir := filepath.Join(tmpDir, dstRepoName+".git")
+	defer func() { _ = os.RemoveAll(repoDir) }()
+	env := append([]string{"GIT_LFS_SKIP_SMUDGE=1"}, dstEnv...)
+	if err := runCmd(ctx, env, "git", "clone", "--bare", "--quiet", dstURL, repoDir); err != nil {
+		return fmt.Errorf("clone destination: %w", err)
+	}
+	pointers, err := lfsPointers(ctx, repoDir)
+	if err != nil {
+		return err
+	}
+	sum.LFSObjects = len(pointers)
+	if len(pointers) == 0 {
+		return nil
+	}
+	sum.MissingLFSObjects, err = missingLFSObjects(ctx, cfg.DstOrg, cfg.DstProject, dstRepoName, cfg.DstPAT, pointers, cfg.Trace)
+	return err
+}
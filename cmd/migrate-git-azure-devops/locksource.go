@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Scopes of --lock-source.
+const (
+	LockDefault = "default" // the default branch only
+	LockAll     = "all"     // every branch
+)
+
+// lockSource locks the source branches of a repository (--lock-source) for the duration of
+// its migration, so that nothing lands between the clone and the push, and returns the
+// function unlocking them, which runs only once. Branches already locked before the run are
+// left alone.
+func lockSource(ctx context.Context, cfg Config, r Repo, sum *Summary) (func(), error) {
+	refs, err := getBranchRefs(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, r.ID, cfg.Trace)
+	if err != nil {
+		return nil, fmt.Errorf("reading source branches: %w", err)
+	}
+
+	var (
+		locked []string
+		once   sync.Once
+	)
+	unlock := func() {
+		once.Do(func() {
+			for _, ref := range locked {
+				// Use a fresh context: branches must be unlocked even if the run was cancelled
+				uctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				err := setRefLocked(uctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, r.ID, ref, false, cfg.Trace)
+				cancel()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "[LOCK] %s: FAILED to unlock %s, unlock it from the Branches page: %v\n", r.Name, ref, err)
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "[LOCK] %s: unlocked %s\n", r.Name, ref)
+			}
+		})
+	}
+
+	for _, ref := range refs {
+		if ref.IsLocked || cfg.LockSource == LockDefault && ref.Name != r.DefaultBranch {
+			continue
+		}
+		if err := setRefLocked(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, r.ID, ref.Name, true, cfg.Trace); err != nil {
+			unlock()
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "[LOCK] %s: locked %s during the migration\n", r.Name, ref.Name)
+		locked = append(locked, ref.Name)
+		sum.LockedRefs = append(sum.LockedRefs, ref.Name)
+	}
+	return unlock, nil
+}
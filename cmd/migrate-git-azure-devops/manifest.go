@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ResultManifestSkip marks repositories with "skip: true" in the YAML manifest.
+const ResultManifestSkip = "SKIPPED: manifest"
+
+// Manifest is the YAML alternative to the flat repo list, with per-repository overrides.
+type Manifest struct {
+	Destinations []ManifestDestination `yaml:"destinations"` // additional destinations receiving every repository
+	Repos        []ManifestRepo        `yaml:"repos"`
+}
+
+// ManifestDestination is an additional destination of the YAML manifest.
+type ManifestDestination struct {
+	Org     string `yaml:"org"`     // destination organization (required)
+	Project string `yaml:"project"` // destination project (required)
+	PATEnv  string `yaml:"patEnv"`  // environment variable holding its PAT (default: the DST_PAT of the run)
+}
+
+// ManifestRepo is a repository entry of the YAML manifest.
+type ManifestRepo struct {
+	Name               string   `yaml:"name"`               // source repository (required)
+	Destination        string   `yaml:"destination"`        // destination name (default: source name)
+	DestinationProject string   `yaml:"destinationProject"` // destination project (default: --dst-project)
+	Owner              string   `yaml:"owner"`              // owner, used by the admin digest
+	ForcePush          *bool    `yaml:"forcePush"`          // overrides --force-push
+	Branches           []string `yaml:"branches"`           // globs of the branches to migrate (default: all)
+	FallbackProtocol   string   `yaml:"fallbackProtocol"`   // overrides --fallback-protocol
+	Skip               bool     `yaml:"skip"`               // keep the entry but do not migrate it
+}
+
+// RepoOverride holds the per-repository settings of the manifest applied during migration.
+type RepoOverride struct {
+	DstProject string
+	ForcePush  *bool
+	Branches   []string
+	Fallback   string
+	Skip       bool
+}
+
+// isManifest reports whether a repo list file is a YAML manifest (by extension).
+func isManifest(file string) bool {
+	ext := strings.ToLower(path.Ext(file))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// loadManifest reads a YAML manifest into the repo list, mapping, owners and overrides of cfg.
+func loadManifest(file string, cfg *Config) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("error reading --repo-list: %w", err)
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("invalid manifest %s: %w", file, err)
+	}
+	if err := loadDestinations(file, cfg, m.Destinations); err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for i, r := range m.Repos {
+		name := strings.TrimSpace(r.Name)
+		if name == "" {
+			return fmt.Errorf("invalid manifest %s: entry %d has no name", file, i+1)
+		}
+		if seen[name] {
+			return fmt.Errorf("invalid manifest %s: repository %s listed twice", file, name)
+		}
+		seen[name] = true
+		if fb := r.FallbackProtocol; fb != "" && fb != ProtocolHTTPS && fb != ProtocolSSH {
+			return fmt.Errorf("invalid manifest %s: repository %s: unsupported fallbackProtocol %q", file, name, fb)
+		}
+		for _, b := range r.Branches {
+			if _, err := path.Match(b, ""); err != nil {
+				return fmt.Errorf("invalid manifest %s: repository %s: invalid branch pattern %q", file, name, b)
+			}
+		}
+		dst := strings.TrimSpace(r.Destination)
+		if dst == "" {
+			dst = name
+		}
+		cfg.RepoList = append(cfg.RepoList, name)
+		cfg.RepoMap[name] = dst
+		if r.Owner != "" {
+			cfg.RepoOwners[name] = r.Owner
+		}
+		cfg.RepoOverrides[name] = RepoOverride{
+			DstProject: strings.TrimSpace(r.DestinationProject),
+			ForcePush:  r.ForcePush,
+			Branches:   r.Branches,
+			Fallback:   r.FallbackProtocol,
+			Skip:       r.Skip,
+		}
+	}
+	return nil
+}
+
+// fallbackProtocol returns the protocol retried when the clone of a repository fails
+// on a transport error, empty when no fallback applies.
+func fallbackProtocol(cfg Config, src string) string {
+	if fb := cfg.RepoOverrides[src].Fallback; fb != "" {
+		return fb
+	}
+	return cfg.Fallback
+}
+
+// destinationProject returns the destination project of a source repository.
+func destinationProject(cfg Config, src string) string {
+	if p := cfg.RepoOverrides[src].DstProject; p != "" {
+		return p
+	}
+	return cfg.DstProject
+}
+
+// perProject runs fn once per destination project of the repositories: the default project
+// with the given existence map, the projects set in the manifest with their own listing.
+func perProject(ctx context.Context, cfg Config, repos []Repo, dstExists map[string]bool,
+	fn func(Config, []Repo, map[string]bool) ([]Summary, error)) ([]Summary, error) {
+	var projects []string
+	groups := map[string][]Repo{}
+	for _, r := range repos {
+		p := destinationProject(cfg, r.Name)
+		if groups[p] == nil {
+			projects = append(projects, p)
+		}
+		groups[p] = append(groups[p], r)
+	}
+	var results []Summary
+	for _, p := range projects {
+		pcfg := cfg
+		pcfg.DstProject = p
+		exists := dstExists
+		if p != cfg.DstProject {
+			dstRepos, err := listDestination(ctx, pcfg)
+			if err != nil {
+				return results, fmt.Errorf("call failed for destination %s/%s: %w", cfg.DstOrg, p, err)
+			}
+			exists = map[string]bool{}
+			for _, r := range dstRepos {
+				exists[r.Name] = true
+			}
+			fmt.Printf("Destination project %s:\n", p)
+		}
+		res, err := fn(pcfg, groups[p], exists)
+		results = append(results, res...)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// filterBranches deletes from a mirror clone the branches not matching any of the globs,
+// so that only the selected branches are pushed. Returns the deleted branches.
+func filterBranches(ctx context.Context, repoDir string, globs []string) ([]string, error) {
+	branches, err := getGitRefNames(repoDir, RefTypeBranches)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for _, b := range branches {
+		keep := false
+		for _, g := range globs {
+			if ok, _ := path.Match(g, b); ok {
+				keep = true
+				break
+			}
+		}
+		if keep {
+			continue
+		}
+		if err := runCmd(ctx, nil, "git", "-C", repoDir, "update-ref", "-d", "refs/heads/"+b); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, b)
+	}
+	return deleted, nil
+}
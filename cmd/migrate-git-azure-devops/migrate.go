@@ -0,0 +1,601 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// repoMigration is the state of the migration of one repository, shared by the steps of
+// migrateRepos. A step returning false has set the result of the repository in sum.
+type repoMigration struct {
+	cfg        Config
+	r          Repo
+	override   RepoOverride
+	dstName    string
+	srcURL     string
+	srcEnv     []string
+	dstURL     string
+	dstEnv     []string
+	tmpDir     string
+	repodir    string
+	engine     string
+	origExists bool        // destination present before the run
+	force      bool        // --force-push, the manifest or an approval
+	cloned     *staleCheck // source refs as cloned, to detect changes landing during the run
+	sum        Summary
+
+	// Undo of the changes made to the source for the clone, run when the repository is done
+	// or by onInterrupt if the run is stopped before (removed by removeUndo)
+	unlockSource, redisableSource func()
+	removeUndo                    func()
+}
+
+// guardSource registers with onInterrupt the undo of the changes made so far to the
+// source, replacing the previous registration, so that SIGINT/SIGTERM doesn't leave
+// branches locked nor a disabled source enabled.
+func (m *repoMigration) guardSource() {
+	unlock, redisable := m.unlockSource, m.redisableSource
+	remove := onInterrupt(func() {
+		// Locked branches are unlocked first, on an enabled repository
+		if unlock != nil {
+			unlock()
+		}
+		if redisable != nil {
+			redisable()
+		}
+	})
+	if m.removeUndo != nil {
+		m.removeUndo()
+	}
+	m.removeUndo = remove
+}
+
+// release unlocks the source branches locked by --lock-source and disables again the
+// source enabled for the clone, in this order.
+func (m *repoMigration) release() {
+	if m.unlockSource != nil {
+		m.unlockSource()
+		m.unlockSource = nil
+	}
+	if m.redisableSource != nil {
+		m.redisableSource()
+		m.redisableSource = nil
+	}
+	if m.removeUndo != nil {
+		m.removeUndo()
+		m.removeUndo = nil
+	}
+}
+
+// fail records an error result and prints it.
+func (m *repoMigration) fail(result, msg string, err error) bool {
+	m.sum.Result = result
+	m.sum.ErrDetails = err.Error()
+	fmt.Println("  "+msg+":", err)
+	return false
+}
+
+// checkDestination decides, before any clone, whether the repository is migrated: it is
+// skipped when the destination already holds the same refs, or exists and no force push
+// was requested or approved through the gate.
+func (m *repoMigration) checkDestination(ctx context.Context, gate *approvalGate) bool {
+	cfg, sum := m.cfg, &m.sum
+	if !m.origExists {
+		return true
+	}
+	// Identical refs on both sides: nothing to clone nor push, also with --force-push
+	if alreadyMigrated(ctx, cfg, m.override, m.srcURL, m.srcEnv, m.dstURL, m.dstEnv) {
+		fmt.Println("  Destination identical to the source (same branches and tags): clone and push skipped.")
+		sum.Result = ResultAlreadyMigrated
+		return false
+	}
+	// Destination diverged without --force-push: ask for approval if a gate is configured
+	if !m.force && gate != nil {
+		srcRefs, srcErr := lsRemote(ctx, m.srcEnv, m.srcURL)
+		dstRefs, dstErr := lsRemote(ctx, m.dstEnv, m.dstURL)
+		if srcErr == nil && dstErr == nil && refsDiverged(srcRefs, dstRefs) {
+			approved, why, err := gate.Request(ctx, m.dstName, "destination already exists and has diverged from source")
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "  Approval request error:", err)
+				why = "error"
+			}
+			if !approved {
+				fmt.Printf("  Force push not approved (%s): skipped.\n", why)
+				sum.Result = "SKIPPED: approval " + why
+				return false
+			}
+			fmt.Println("  Force push approved.")
+			m.force = true
+		}
+	}
+	// If it already exists and force is not wanted, skip clone and push immediately
+	if !m.force {
+		if cfg.DryRun {
+			fmt.Println("  [DRY] Repo already present: would skip clone and push (use --force-push to force).")
+			sum.Result = "DRY-RUN"
+		} else {
+			fmt.Println("  Repo already present in destination. Clone/Push NOT performed (use --force-push to force).")
+			sum.Result = "SKIPPED: repo already present"
+		}
+		return false
+	}
+	return true
+}
+
+// dryRunMirror takes the inventory of the source and prints what prepareMirror,
+// rewriteMirror and prePushChecks would do.
+func (m *repoMigration) dryRunMirror(ctx context.Context, reuse bool) {
+	cfg, r := m.cfg, m.r
+	m.sum.Action = "DRY-RUN"
+	dryRunInventory(ctx, cfg, r, m.srcEnv, m.srcURL, &m.sum)
+	if reuse {
+		fmt.Printf("  [DRY] git -C '%s' fetch --prune '%s' '+refs/*:refs/*'\n", m.repodir, m.srcURL)
+	} else {
+		fmt.Printf("  [DRY] git clone --mirror '%s' '%s'\n", m.srcURL, m.repodir)
+	}
+	if len(m.override.Branches) > 0 {
+		fmt.Printf("  [DRY] Would migrate only the branches matching: %s\n", strings.Join(m.override.Branches, ", "))
+	}
+	if len(cfg.RefRenameRules) > 0 {
+		fmt.Println("  [DRY] Would rename the branches matching the --ref-rename rules")
+	}
+	if len(cfg.ExcludePaths) > 0 {
+		fmt.Printf("  [DRY] Would rewrite history excluding: %s (commit SHAs will change)\n", strings.Join(cfg.ExcludePaths, ", "))
+	}
+	if cfg.Rewrite != nil {
+		fmt.Printf("  [DRY] Would rewrite history with git filter-repo: %s (commit SHAs will change)\n", cfg.Rewrite.describe())
+	}
+	if cfg.Submodules != nil && cfg.Submodules.Mode == SubmodulesCommit {
+		fmt.Println("  [DRY] Would commit the .gitmodules URLs mapped to the destination")
+	}
+	if cfg.KeepDisabled && r.IsDisabled {
+		fmt.Println("  [DRY] Would enable the disabled source for the time of the clone")
+	}
+	if cfg.LockSource != "" {
+		fmt.Printf("  [DRY] Would lock the source branches (%s) until the push is done\n", cfg.LockSource)
+	}
+	if cfg.WorkItemRefs {
+		fmt.Println("  [DRY] Would inventory the work item mentions of the commit messages")
+	}
+}
+
+// prepareMirror clones the source as a mirror (or updates the one kept in --work-dir),
+// over the fallback protocol on transport failures, keeps the branches selected by the
+// manifest, applies --ref-rename and counts refs, size, commits and contributors.
+func (m *repoMigration) prepareMirror(ctx context.Context, reuse bool) bool {
+	cfg, r, sum := m.cfg, m.r, &m.sum
+	// Disabled repositories can't be cloned: enabled for the time of the clone
+	if cfg.KeepDisabled && r.IsDisabled {
+		redisable, err := enableForClone(ctx, cfg, r)
+		if err != nil {
+			return m.fail("ERROR: disabled source", "Error enabling the disabled source for the clone", err)
+		}
+		m.redisableSource = redisable
+		m.guardSource()
+	}
+	// Nothing may land on the source between the clone and the push
+	if cfg.LockSource != "" {
+		unlock, err := lockSource(ctx, cfg, r, sum)
+		if err != nil {
+			return m.fail("ERROR: source lock", "Error locking the source branches", err)
+		}
+		m.unlockSource = unlock
+		m.guardSource()
+	}
+	var err error
+	if m.engine, err = repoGitEngine(ctx, cfg, r); err != nil {
+		return m.fail(ResultClone, "Error", err)
+	}
+	// go-git can't update a mirror from Azure DevOps: the kept one is cloned again
+	reuse = reuse && m.engine == GitEngineSystem
+	stopClone := phases.trackRepo(PhaseClone, &sum.CloneSeconds)
+	var attempts int
+	if reuse {
+		fmt.Println("  Updating the mirror kept in the work directory")
+		attempts, err = withRetry(ctx, cfg, "fetch", func() error {
+			return runCmdTimeout(ctx, cfg.CloneTimeout, transferEnv(m.srcEnv), "git", gitTransferArgs(cfg, r.Size, "-C", m.repodir, "fetch", "--prune", m.srcURL, "+refs/*:refs/*")...)
+		})
+		if err != nil {
+			fmt.Println("  Update of the kept mirror failed, cloning again:", err)
+		}
+	}
+	if !reuse || err != nil {
+		var n int
+		n, err = withRetry(ctx, cfg, "clone", func() error {
+			_ = os.RemoveAll(m.repodir) // leftovers of a failed attempt
+			if m.engine == GitEngineNative {
+				return runNativeTimeout(ctx, cfg.CloneTimeout, func(ctx context.Context) error {
+					return nativeCloneMirror(ctx, m.srcURL, cfg.SrcPAT, m.repodir)
+				})
+			}
+			return runCmdTimeout(ctx, cfg.CloneTimeout, transferEnv(m.srcEnv), "git", gitTransferArgs(cfg, r.Size, "clone", "--mirror", m.srcURL, m.repodir)...)
+		})
+		attempts += n
+	}
+	stopClone()
+	sum.CloneAttempts = attempts
+	sum.CloneProtocol = cfg.Protocol
+	if err != nil {
+		sum.Result, sum.Skipped = classifyCloneFailure(ctx, cfg, r.Name, err)
+	}
+	// Transport failures (e.g. proxy interference): retry over the fallback protocol
+	if fb := fallbackProtocol(cfg, r.Name); err != nil && fb != "" && fb != cfg.Protocol &&
+		(sum.Result == ResultNetwork || sum.Result == ResultClone) {
+		fbCfg := cfg
+		fbCfg.Protocol = fb
+		fbURL, fbEnv := newSourceProvider(fbCfg).CloneURL(r.Name)
+		fmt.Printf("  Clone over %s failed, retrying over %s\n", cfg.Protocol, fb)
+		stopClone = phases.trackRepo(PhaseClone, &sum.CloneSeconds)
+		attempts, err = withRetry(ctx, cfg, "clone", func() error {
+			_ = os.RemoveAll(m.repodir)
+			return runCmdTimeout(ctx, cfg.CloneTimeout, transferEnv(fbEnv), "git", gitTransferArgs(cfg, r.Size, "clone", "--mirror", fbURL, m.repodir)...)
+		})
+		stopClone()
+		sum.CloneAttempts += attempts
+		if err == nil {
+			m.srcURL, m.srcEnv = fbURL, fbEnv
+			sum.CloneProtocol = fb
+			sum.Result = ""
+		}
+	}
+	if errors.Is(err, errOperationTimeout) {
+		sum.Result = ResultTimeout
+	}
+	// Locked branches are unlocked first (release), on an enabled repository
+	if m.redisableSource != nil && m.unlockSource == nil {
+		m.release()
+	}
+	if err != nil {
+		sum.ErrDetails = err.Error()
+		switch sum.Result {
+		case ResultSourceRemoved, ResultSourceRemovedError:
+			fmt.Println("  Source repository removed after planning (HTTP 404)")
+		case ResultSourceDenied:
+			fmt.Println("  Error: access denied to the source repository")
+		case ResultNetwork:
+			fmt.Println("  Error: network failure reaching the source")
+		default:
+			fmt.Println("  Error: clone of the source repository failed")
+		}
+		return false
+	}
+	// Remember the cloned source refs to detect changes landing during the run
+	if refs, err := localRefs(ctx, m.repodir); err == nil {
+		m.cloned = &staleCheck{srcURL: m.srcURL, srcEnv: m.srcEnv, refs: refs}
+	}
+	// Keep only the branches selected in the manifest
+	if len(m.override.Branches) > 0 {
+		filtered, err := filterBranches(ctx, m.repodir, m.override.Branches)
+		if err != nil {
+			return m.fail("ERROR: branch filter", "Error filtering branches", err)
+		}
+		sum.FilteredBranches = filtered
+		if len(filtered) > 0 {
+			fmt.Printf("  Branches not migrated (manifest filter): %s\n", strings.Join(filtered, ", "))
+		}
+	}
+	// Normalize branch names to the destination convention
+	if len(cfg.RefRenameRules) > 0 {
+		renamed, err := normalizeBranches(ctx, m.repodir, cfg.RefRenameRules)
+		sum.RenamedRefs = renamed
+		if err != nil {
+			return m.fail("ERROR: ref rename", "Error renaming branches", err)
+		}
+		for _, r := range renamed {
+			fmt.Printf("  Branch renamed: %s\n", r)
+		}
+	}
+	// Get branch/tag names and count with len() to avoid double git execution
+	if branchNames, err := getGitRefNames(m.repodir, RefTypeBranches); err == nil {
+		sum.BranchNames = branchNames
+		sum.NumBranches = len(branchNames)
+	}
+	if tagNames, err := getGitRefNames(m.repodir, RefTypeTags); err == nil {
+		sum.TagNames = tagNames
+		sum.NumTags = len(tagNames)
+	}
+	if size, err := dirSize(m.repodir); err == nil {
+		sum.Size = size
+	}
+	if commits, contributors, err := historyStats(ctx, m.repodir); err == nil {
+		sum.Commits, sum.Contributors = commits, contributors
+	} else {
+		fmt.Println("  Warning: counting commits and contributors failed:", err)
+	}
+	return true
+}
+
+// rewriteMirror applies the history rewrites of the run to the mirror: --exclude-path,
+// --rewrite-config and the submodule URLs pointing at the source organization.
+func (m *repoMigration) rewriteMirror(ctx context.Context) bool {
+	cfg, sum := m.cfg, &m.sum
+	// Remove excluded paths from the whole history (SHAs change)
+	if len(cfg.ExcludePaths) > 0 {
+		fmt.Println("  WARNING: rewriting history to exclude paths, commit SHAs will change")
+		excluded, err := excludePaths(ctx, m.repodir, cfg.ExcludePaths)
+		sum.ExcludedPaths = excluded
+		if err != nil {
+			return m.fail("ERROR: path exclusion", "Error excluding paths", err)
+		}
+		if len(excluded) > 0 {
+			sum.HistoryRewritten = true
+			fmt.Printf("  Excluded paths: %s\n", strings.Join(excluded, ", "))
+		}
+	}
+	// History cleanup of --rewrite-config (SHAs change)
+	if cfg.Rewrite != nil {
+		fmt.Printf("  Rewriting history with git filter-repo: %s\n", cfg.Rewrite.describe())
+		sum.SizeBefore = sum.Size
+		changed, err := rewriteHistory(ctx, m.repodir, m.tmpDir, cfg.Rewrite)
+		if err != nil {
+			return m.fail("ERROR: history rewrite", "Error rewriting history", err)
+		}
+		_ = runCmd(ctx, nil, "git", "-C", m.repodir, "gc", "--prune=now", "--quiet")
+		if size, err := dirSize(m.repodir); err == nil {
+			sum.SizeAfter = size
+		}
+		sum.HistoryRewritten = sum.HistoryRewritten || changed
+		fmt.Printf("  Mirror size: %s -> %s\n", formatBytes(sum.SizeBefore), formatBytes(sum.SizeAfter))
+	}
+	// Submodules pointing at the source organization
+	if cfg.Submodules != nil {
+		changes, err := rewriteSubmodules(ctx, cfg, m.repodir, m.tmpDir, cfg.DstProject)
+		sum.SubmoduleChanges = changes
+		if err != nil {
+			return m.fail("ERROR: submodule rewrite", "Error rewriting submodule URLs", err)
+		}
+		for _, c := range changes {
+			if cfg.Submodules.Mode == SubmodulesCommit {
+				fmt.Println("  Submodule URL rewritten:", c)
+			} else {
+				fmt.Println("  Submodule URL to update:", c)
+			}
+		}
+	}
+	return true
+}
+
+// prePushChecks inspects the mirror before the push: files and history Azure DevOps would
+// reject (--max-file-size), credentials in the history and the work item mentions.
+func (m *repoMigration) prePushChecks(ctx context.Context) bool {
+	cfg, sum := m.cfg, &m.sum
+	// Files and history Azure DevOps would reject: fail (or skip) before the push
+	if cfg.MaxFileSize > 0 {
+		if err := checkPushLimits(ctx, cfg, m.repodir, sum); err != nil {
+			sum.ErrDetails = err.Error()
+			if cfg.SkipOversized && len(sum.OversizedFiles) > 0 {
+				sum.Result = ResultOversizedSkip
+				sum.Skipped = true
+				fmt.Println("  Skipped (--skip-oversized):", err)
+			} else {
+				sum.Result = ResultOversized
+				fmt.Println("  Error:", err)
+			}
+			for _, f := range sum.OversizedFiles {
+				fmt.Println("    " + f)
+			}
+			return false
+		}
+	}
+	// Credentials in the history: reported, or not pushed with --block-on-secrets
+	if cfg.SecretRules != nil {
+		findings, err := scanSecrets(ctx, m.repodir, cfg.SecretRules)
+		if err != nil {
+			sum.Result = ResultSecrets
+			sum.ErrDetails = "secret scan failed: " + err.Error()
+			fmt.Println("  Error scanning for secrets:", err)
+			return false
+		}
+		sum.SecretFindings = findings
+		if len(findings) > 0 {
+			fmt.Printf("  WARNING: %d possible secrets in the history\n", len(findings))
+			for _, f := range findings {
+				fmt.Println("    " + f)
+			}
+			if cfg.BlockOnSecrets {
+				sum.Result = ResultSecrets
+				sum.ErrDetails = fmt.Sprintf("%d possible secrets in the history (--block-on-secrets), push NOT performed", len(findings))
+				return false
+			}
+		}
+	}
+	// Work item mentions pointing at the source project: inventory only
+	if cfg.WorkItemRefs {
+		refs, err := inventoryWorkItemRefs(ctx, m.repodir)
+		if err == nil && cfg.WorkItemCSV != "" {
+			err = appendWorkItemCSV(cfg.WorkItemCSV, *sum, refs)
+		}
+		if err != nil {
+			sum.ErrDetails = "work item inventory: " + err.Error()
+			fmt.Println("  Warning: work item inventory failed:", err)
+		} else if len(refs) > 0 {
+			sum.WorkItemRefs = len(refs)
+			sum.WorkItems = workItemIDs(refs)
+			fmt.Printf("  %d work item mentions (%d work items) in the commit messages\n", len(refs), len(sum.WorkItems))
+		}
+	}
+	return true
+}
+
+// createDestination creates the destination repository if missing (in a dry run, records it
+// as existing).
+func (m *repoMigration) createDestination(ctx context.Context, dst DestinationProvider, dstExists map[string]bool) bool {
+	cfg, sum := m.cfg, &m.sum
+	if dstExists[m.dstName] {
+		return true
+	}
+	if cfg.DryRun {
+		fmt.Printf("  [DRY] Would create repo in destination: %s\n", m.dstName)
+		// Simulated as created, so that the dry run goes on with the push like the real one
+		dstExists[m.dstName] = true
+		return true
+	}
+	stopCreate := phases.track(PhaseCreate)
+	created, err := dst.CreateRepo(ctx, m.dstName)
+	stopCreate()
+	recordAudit(cfg, AuditEvent{Action: AuditCreateRepo, Org: cfg.DstOrg, Project: cfg.DstProject,
+		Repo: m.dstName, RepoID: created.ID, Result: auditResult(err)})
+	if err != nil {
+		sum.Result = "ERROR: destination creation"
+		sum.ErrDetails = err.Error()
+		fmt.Printf("  Error creating repo %s in destination: %v\n", m.dstName, err)
+		if cfg.Trace {
+			fmt.Fprintf(os.Stderr, "[TRACE] Error details creating repo: %v\n", err)
+		}
+		return false
+	}
+	sum.DstRepoID = created.ID
+	sum.Created = true
+	dstExists[m.dstName] = true
+	return true
+}
+
+// dryRunPush prints what push and postPush would do.
+func (m *repoMigration) dryRunPush(ctx context.Context) {
+	cfg, r, sum := m.cfg, m.r, &m.sum
+	if cfg.BypassPolicies {
+		fmt.Println("  [DRY] Would temporarily disable blocking policies during the push")
+	}
+	if cfg.Provenance != "" {
+		fmt.Printf("  [DRY] Would record the migration provenance as a %s on the default branch\n", cfg.Provenance)
+	}
+	if cfg.MigrateOpenPRs {
+		fmt.Println("  [DRY] Would recreate the active pull requests of the source repository")
+	}
+	if cfg.MigrateHooks {
+		fmt.Println("  [DRY] Would recreate the service hooks of the source repository")
+	}
+	if cfg.KeepDisabled && r.IsDisabled {
+		fmt.Println("  [DRY] Would disable the destination repository like the source")
+	}
+	if cfg.Pipelines != "" {
+		if _, err := inventoryPipelines(ctx, cfg, r, sum); err != nil {
+			fmt.Println("  [DRY] Pipeline inventory failed:", err)
+		}
+		for _, p := range sum.Pipelines {
+			if cfg.Pipelines == PipelinesMigrate {
+				fmt.Println("  [DRY] Would recreate pipeline", p)
+			} else {
+				fmt.Println("  [DRY] Pipeline building the repository:", p)
+			}
+		}
+	}
+	if m.origExists && m.force && cfg.BackupDir != "" {
+		fmt.Printf("  [DRY] Would save the destination refs to a bundle in %s\n", cfg.BackupDir)
+	}
+	if m.origExists && m.force {
+		fmt.Printf("  [DRY] (cd '%s' && git push --mirror --force '%s')\n", m.repodir, m.dstURL)
+	} else {
+		fmt.Printf("  [DRY] (cd '%s' && git push --mirror '%s')\n", m.repodir, m.dstURL)
+	}
+	for _, d := range cfg.Mirrors {
+		fmt.Printf("  [DRY] Would also push to %s/%s\n", d.Org, d.Project)
+	}
+	sum.Result = "DRY-RUN"
+}
+
+// push mirror pushes to the destination: force pushes save the destination refs first
+// (--backup-dir), and --bypass-policies disables the blocking policies for the push.
+func (m *repoMigration) push(ctx context.Context) bool {
+	cfg, r, sum := m.cfg, m.r, &m.sum
+	force := m.origExists && m.force
+	args := []string{"-C", m.repodir, "push", "--mirror"}
+	if force {
+		args = append(args, "--force")
+		if cfg.BackupDir != "" {
+			backup, err := backupDestination(ctx, cfg, m.dstName, m.dstURL, m.dstEnv)
+			if err != nil {
+				return m.fail("ERROR: backup", "Error backing up the destination, force push NOT performed", err)
+			}
+			if backup != "" {
+				sum.Backup = backup
+				fmt.Println("  Destination refs saved to", backup)
+			}
+		}
+	}
+	args = append(args, m.dstURL)
+	var restorePolicies func()
+	if cfg.BypassPolicies {
+		var err error
+		if restorePolicies, err = bypassPolicies(ctx, cfg, m.dstName, sum); err != nil {
+			return m.fail("ERROR: policy bypass", "Error bypassing destination policies", err)
+		}
+	}
+	stopPush := phases.trackRepo(PhasePush, &sum.PushSeconds)
+	attempts, pushErr := withRetry(ctx, cfg, "push", func() error {
+		if m.engine == GitEngineNative {
+			return runNativeTimeout(ctx, cfg.PushTimeout, func(ctx context.Context) error {
+				return nativePushMirror(ctx, m.repodir, m.dstURL, cfg.DstPAT)
+			})
+		}
+		return runCmdTimeout(ctx, cfg.PushTimeout, transferEnv(m.dstEnv), "git", gitTransferArgs(cfg, r.Size, args...)...)
+	})
+	stopPush()
+	sum.PushAttempts = attempts
+	recordAudit(cfg, AuditEvent{Action: pushAction(force), Org: cfg.DstOrg, Project: cfg.DstProject,
+		Repo: m.dstName, RepoID: sum.DstRepoID, Result: auditResult(pushErr), Details: "mirror of " + r.Name})
+	if restorePolicies != nil {
+		restorePolicies()
+	}
+	if pushErr != nil {
+		sum.Result = "ERROR: push"
+		if errors.Is(pushErr, errOperationTimeout) {
+			sum.Result = ResultTimeout
+		}
+		sum.ErrDetails = pushErr.Error()
+		fmt.Println("  Error pushing to destination")
+		return false
+	}
+	fmt.Println("  OK.")
+	sum.Result = "OK"
+	setThroughput(sum)
+	return true
+}
+
+// postPush completes a successful push: the additional destinations of the manifest, the
+// provenance, pull requests, service hooks and pipelines, and the disabled state of the
+// source. Their failures are reported without changing the result.
+func (m *repoMigration) postPush(ctx context.Context) {
+	cfg, r, sum := m.cfg, m.r, &m.sum
+	pushMirrors(ctx, cfg, m.repodir, m.dstName, r.Size, m.force, sum)
+	if cfg.Provenance != "" {
+		ref, err := stampProvenance(ctx, cfg, m.repodir, m.dstURL, m.dstEnv, *sum)
+		if err != nil {
+			// The migration itself succeeded: only reported
+			sum.ErrDetails = "provenance: " + err.Error()
+			fmt.Println("  Warning: provenance not recorded:", err)
+		} else if ref != "" {
+			sum.Provenance = ref
+			fmt.Println("  Provenance recorded in", ref)
+		}
+	}
+	if cfg.MigrateOpenPRs {
+		migrateOpenPRs(ctx, cfg, r, m.dstName, sum)
+	}
+	if cfg.MigrateHooks {
+		migrateServiceHooks(ctx, cfg, r, m.dstName, sum)
+	}
+	if cfg.Pipelines != "" {
+		defs, err := inventoryPipelines(ctx, cfg, r, sum)
+		if err != nil {
+			sum.PipelineIssues = append(sum.PipelineIssues, "pipeline inventory failed: "+err.Error())
+			fmt.Println("  Warning: pipeline inventory failed:", err)
+		} else if cfg.Pipelines == PipelinesMigrate && len(defs) > 0 {
+			migratePipelines(ctx, cfg, defs, m.dstName, sum)
+		} else if len(defs) > 0 {
+			fmt.Printf("  Pipelines building the repository: %s\n", strings.Join(sum.Pipelines, ", "))
+		}
+	}
+	// Last: nothing can be read nor created on a disabled repository
+	if cfg.KeepDisabled && r.IsDisabled {
+		if err := disableDestination(ctx, cfg, m.dstName, sum); err != nil {
+			sum.ErrDetails = "disable destination: " + err.Error()
+			fmt.Println("  Warning: destination not disabled like the source:", err)
+		} else {
+			fmt.Println("  Destination disabled like the source")
+		}
+	}
+}
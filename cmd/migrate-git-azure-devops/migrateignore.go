@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// ResultIgnored marks repositories excluded by the .migrateignore of the source project.
+const ResultIgnored = "SKIPPED: .migrateignore"
+
+// migrateIgnoreFile is the file read from the config repository of the source project.
+const migrateIgnoreFile = "/.migrateignore"
+
+// ignoreRule is a line of a .migrateignore file: a glob on repository names,
+// negated ("!pattern") to re-include repositories matched by earlier rules.
+type ignoreRule struct {
+	pattern string
+	negate  bool
+}
+
+// parseMigrateIgnore parses a .migrateignore file: one glob per line (* ? [..]),
+// blank lines and "#" comments are skipped, "!" negates, matching is case-insensitive.
+func parseMigrateIgnore(data []byte) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = strings.TrimSpace(line[1:])
+		}
+		r.pattern = strings.ToLower(strings.TrimSuffix(line, "/"))
+		if _, err := path.Match(r.pattern, ""); err != nil {
+			return nil, fmt.Errorf("line %d: invalid pattern %q", n, line)
+		}
+		rules = append(rules, r)
+	}
+	return rules, sc.Err()
+}
+
+// ignored reports whether a repository is excluded: like .gitignore the last matching rule wins.
+func ignored(rules []ignoreRule, name string) bool {
+	name = strings.ToLower(name)
+	out := false
+	for _, r := range rules {
+		if ok, _ := path.Match(r.pattern, name); ok {
+			out = !r.negate
+		}
+	}
+	return out
+}
+
+// applyMigrateIgnore fetches .migrateignore from the config repository of the source project
+// and removes the repositories it matches from the selection. A missing repository or file
+// means no exclusion. Excluded repositories are returned as SKIPPED summaries.
+func applyMigrateIgnore(ctx context.Context, cfg Config, selected []Repo) ([]Repo, []Summary, error) {
+	if cfg.IgnoreRepo == "" {
+		return selected, nil, nil
+	}
+	data, found, err := getItemContent(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, cfg.IgnoreRepo, migrateIgnoreFile, cfg.Trace)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading %s from %s: %w", migrateIgnoreFile, cfg.IgnoreRepo, err)
+	}
+	if !found {
+		if cfg.Trace {
+			fmt.Fprintf(os.Stderr, "[TRACE] No %s in %s, nothing excluded\n", migrateIgnoreFile, cfg.IgnoreRepo)
+		}
+		return selected, nil, nil
+	}
+	rules, err := parseMigrateIgnore(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s in %s: %w", migrateIgnoreFile, cfg.IgnoreRepo, err)
+	}
+	var kept []Repo
+	var skipped []Summary
+	for _, r := range selected {
+		if ignored(rules, r.Name) {
+			fmt.Printf("[IGNORED] %s excluded by %s in %s\n", r.Name, migrateIgnoreFile, cfg.IgnoreRepo)
+			skipped = append(skipped, Summary{Repo: r.Name, Result: ResultIgnored, Skipped: true, SrcRepoID: r.ID})
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept, skipped, nil
+}
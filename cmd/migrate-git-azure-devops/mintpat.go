@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// patLifecycleVersion is the api-version of the PAT lifecycle management API.
+const patLifecycleVersion = "7.1-preview.1"
+
+// MintedPAT is a short-lived PAT created for the pushes of a run and revoked at its end.
+type MintedPAT struct {
+	AuthorizationID string    `json:"authorizationId"`
+	DisplayName     string    `json:"displayName"`
+	Scope           string    `json:"scope"`
+	ValidTo         time.Time `json:"validTo"`
+	Token           string    `json:"token"`
+}
+
+// mintPAT creates a PAT in the destination organization through the PAT lifecycle API.
+// The API only accepts Microsoft Entra ID access tokens (--auth azcli/devicecode), not PATs.
+func mintPAT(ctx context.Context, org, adminToken, scope string, ttl time.Duration, trace bool) (MintedPAT, error) {
+	hostname, _ := os.Hostname()
+	payload, err := json.Marshal(map[string]any{
+		"displayName": fmt.Sprintf("%s %s %s", prog(), hostname, time.Now().UTC().Format("20060102T150405Z")),
+		"scope":       scope,
+		"validTo":     time.Now().Add(ttl).UTC().Format(time.RFC3339),
+		"allOrgs":     false,
+	})
+	if err != nil {
+		return MintedPAT{}, fmt.Errorf("error encoding payload: %w", err)
+	}
+	urlStr := fmt.Sprintf("https://vssps.dev.azure.com/%s/_apis/tokens/pats?api-version=%s", org, patLifecycleVersion)
+	if trace {
+		fmt.Fprintln(os.Stderr, "[TRACE] POST", urlStr)
+	}
+	body, code, _, err := doHTTPReq(ctx, "POST", urlStr, adminToken, payload)
+	if err != nil {
+		return MintedPAT{}, err
+	}
+	if code < 200 || code >= 300 {
+		return MintedPAT{}, fmt.Errorf("API error minting PAT (HTTP %d): %s", code, string(body))
+	}
+	var resp struct {
+		PatToken      MintedPAT `json:"patToken"`
+		PatTokenError string    `json:"patTokenError"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return MintedPAT{}, fmt.Errorf("invalid response: %w", err)
+	}
+	if resp.PatTokenError != "" && resp.PatTokenError != "none" {
+		return MintedPAT{}, fmt.Errorf("PAT not minted: %s", resp.PatTokenError)
+	}
+	if resp.PatToken.Token == "" {
+		return MintedPAT{}, fmt.Errorf("PAT not minted: empty token in response")
+	}
+	return resp.PatToken, nil
+}
+
+// revokePAT revokes a PAT minted by mintPAT.
+func revokePAT(ctx context.Context, org, adminToken, authorizationID string, trace bool) error {
+	urlStr := fmt.Sprintf("https://vssps.dev.azure.com/%s/_apis/tokens/pats?authorizationId=%s&api-version=%s",
+		org, url.QueryEscape(authorizationID), patLifecycleVersion)
+	if trace {
+		fmt.Fprintln(os.Stderr, "[TRACE] DELETE", urlStr)
+	}
+	body, code, _, err := doHTTPReq(ctx, "DELETE", urlStr, adminToken, nil)
+	if err != nil {
+		return err
+	}
+	if code < 200 || code >= 300 {
+		return fmt.Errorf("API error revoking PAT (HTTP %d): %s", code, string(body))
+	}
+	return nil
+}
+
+// withMintedPAT replaces the destination credentials with a freshly minted, tightly scoped
+// PAT for the duration of run, then revokes it, also on SIGINT/SIGTERM. The admin token is
+// used only for minting and revoking, obtained again for the revocation: the Entra ID token
+// of the start may have expired by then. A PAT left behind by a crash expires on its own
+// after --mint-pat-ttl.
+func withMintedPAT(ctx context.Context, cfg Config, run func(Config) error) error {
+	pat, err := mintPAT(ctx, cfg.DstOrg, cfg.DstPAT, cfg.MintPATScope, cfg.MintPATTTL, cfg.Trace)
+	if err != nil {
+		return fmt.Errorf("--mint-pat: %w", err)
+	}
+	fmt.Printf("Minted PAT %q (scope %s, valid to %s)\n", pat.DisplayName, pat.Scope, pat.ValidTo.Format(time.RFC3339))
+	admin := cfg // cfg gets the minted PAT
+	var once sync.Once
+	revoke := func() {
+		once.Do(func() {
+			revokeCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			if err := revokePAT(revokeCtx, admin.DstOrg, adminToken(revokeCtx, admin), pat.AuthorizationID, admin.Trace); err != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: revoking minted PAT %q failed, it expires at %s: %v\n", pat.DisplayName, pat.ValidTo.Format(time.RFC3339), err)
+				return
+			}
+			fmt.Printf("Revoked minted PAT %q\n", pat.DisplayName)
+		})
+	}
+	remove := onInterrupt(revoke)
+	defer func() {
+		revoke()
+		remove()
+	}()
+	cfg.DstPAT = pat.Token
+	registerSecret(pat.Token)
+	return run(cfg)
+}
+
+// adminToken returns a current token of the destination administrator: obtained again from
+// the credential provider of the run when there is one, cfg.DstPAT otherwise.
+func adminToken(ctx context.Context, cfg Config) string {
+	if cfg.creds == nil || cfg.creds.dst == nil {
+		return cfg.DstPAT
+	}
+	token, err := cfg.creds.dst.Token(ctx)
+	if err != nil || token == "" {
+		fmt.Fprintf(os.Stderr, "WARNING: obtaining a new admin token failed, revoking with the one of the start: %v\n", err)
+		return cfg.DstPAT
+	}
+	registerSecret(token)
+	return token
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithMintedPAT(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string // method, authorization and authorizationId of each request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls = append(calls, r.Method+" "+r.Header.Get("Authorization")+" "+r.URL.Query().Get("authorizationId"))
+		mu.Unlock()
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(map[string]any{"patToken": MintedPAT{AuthorizationID: "auth-1", DisplayName: "run",
+				Scope: "vso.code_manage", ValidTo: time.Now().Add(time.Hour), Token: "minted-pat-9c1e"}, "patTokenError": "none"})
+		}
+	}))
+	defer srv.Close()
+	target, _ := url.Parse(srv.URL)
+	prev := httpClient.Transport
+	httpClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	defer func() { httpClient.Transport = prev }()
+
+	// The admin token of the start has expired by the end of the run
+	cfg := Config{DstOrg: "fabrikam", DstPAT: "admin-start", MintPATScope: "vso.code_manage", MintPATTTL: time.Hour,
+		creds: &credentials{src: staticCredential("admin-fresh"), dst: staticCredential("admin-fresh")}}
+	var runPAT string
+	err := withMintedPAT(context.Background(), cfg, func(cfg Config) error {
+		runPAT = cfg.DstPAT
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runPAT != "minted-pat-9c1e" {
+		t.Errorf("run got DstPAT %q, want the minted PAT", runPAT)
+	}
+	want := []string{"POST " + authHeader("admin-start") + " ", "DELETE " + authHeader("admin-fresh") + " auth-1"}
+	if len(calls) != len(want) || calls[0] != want[0] || calls[1] != want[1] {
+		t.Errorf("requests = %q, want mint with the start token then revoke with a fresh one %q", calls, want)
+	}
+}
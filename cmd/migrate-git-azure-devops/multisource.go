@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// sourceBatch is the part of a run reading one source project: its configuration (source
+// project, destination names with the duplicates prefixed), repositories and selection.
+type sourceBatch struct {
+	cfg        Config
+	srcRepos   []Repo
+	selected   []Repo
+	preSummary []Summary
+	warnings   map[string][]NameWarning
+}
+
+// srcProjectsFlag is the value of --src-project: one or more projects, comma separated or
+// repeated. The first one is also kept in cfg.SrcProject, used by the single-project features.
+type srcProjectsFlag struct {
+	first *string
+	all   *[]string
+}
+
+func (f *srcProjectsFlag) String() string {
+	if f.all == nil {
+		return ""
+	}
+	return strings.Join(*f.all, ",")
+}
+
+func (f *srcProjectsFlag) Set(value string) error {
+	for _, p := range strings.Split(value, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" || slices.ContainsFunc(*f.all, func(q string) bool { return strings.EqualFold(p, q) }) {
+			continue
+		}
+		*f.all = append(*f.all, p)
+	}
+	if len(*f.all) > 0 {
+		*f.first = (*f.all)[0]
+	}
+	return nil
+}
+
+func (f *srcProjectsFlag) Type() string { return "strings" }
+
+// sourceProjects returns the source projects of the run: --src-project, repeatable.
+func sourceProjects(cfg Config) []string {
+	if len(cfg.SrcProjects) > 1 {
+		return cfg.SrcProjects
+	}
+	return []string{cfg.SrcProject}
+}
+
+// prepareSources lists and selects the repositories of every source project (repo list or
+// filters, .migrateignore, size and disabled checks, owners, name checks). With several
+// source projects a repo list name is an error only when missing from all of them, and
+// the destination names produced by more than one project are prefixed with the project.
+func prepareSources(ctx context.Context, cfg Config) ([]*sourceBatch, error) {
+	var batches []*sourceBatch
+	notFound := map[string]int{} // repo list names missing from a source project
+	for _, p := range sourceProjects(cfg) {
+		b := &sourceBatch{cfg: cfg}
+		b.cfg.SrcProject = p
+		if len(cfg.SrcProjects) > 1 {
+			b.cfg.RepoMap = maps.Clone(cfg.RepoMap)
+			if b.cfg.RepoMap == nil {
+				b.cfg.RepoMap = map[string]string{}
+			}
+			b.cfg.RepoOwners = maps.Clone(cfg.RepoOwners)
+		}
+		if err := b.prepare(ctx); err != nil {
+			return nil, err
+		}
+		for _, s := range b.preSummary {
+			if s.Result == "ERROR: source not found" {
+				notFound[s.Repo]++
+			}
+		}
+		batches = append(batches, b)
+	}
+	if len(batches) == 1 {
+		return batches, nil
+	}
+
+	for _, b := range batches {
+		var pre []Summary
+		for _, s := range b.preSummary {
+			if s.Result == "ERROR: source not found" {
+				if notFound[s.Repo] < len(batches) || b != batches[0] {
+					continue // found in another project, or reported once
+				}
+			}
+			pre = append(pre, s)
+		}
+		b.preSummary = pre
+	}
+	if err := prefixDuplicateNames(batches); err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
+
+// prepare lists and selects the repositories of the source project of the batch.
+func (b *sourceBatch) prepare(ctx context.Context) error {
+	cfg := &b.cfg
+	stopList := phases.track(PhaseList)
+	srcRepos, err := newSourceProvider(*cfg).ListRepos(ctx)
+	stopList()
+	if err != nil {
+		if cfg.Trace {
+			fmt.Fprintf(os.Stderr, "[TRACE] Error details: %v\n", err)
+		}
+		// Returned, not exited: a --daemon run survives a failed cycle
+		return fmt.Errorf("[API ERROR] call failed for source %s/%s: %w", cfg.SrcOrg, cfg.SrcProject, err)
+	}
+	b.srcRepos = srcRepos
+
+	selected, preSummary, err := selectRepos(*cfg, srcRepos)
+	if err != nil {
+		return err
+	}
+	selected, ignoredSummary, err := applyMigrateIgnore(ctx, *cfg, selected)
+	if err != nil {
+		return err
+	}
+	preSummary = append(preSummary, ignoredSummary...)
+	selected, largeSummary := skipLargeRepos(*cfg, selected)
+	preSummary = append(preSummary, largeSummary...)
+	selected, disabledSummary := skipDisabledRepos(*cfg, selected)
+	preSummary = append(preSummary, disabledSummary...)
+	if cfg.ResolveOwners {
+		resolveOwners(ctx, cfg, selected)
+	}
+	b.selected, b.preSummary = selected, preSummary
+	return nil
+}
+
+// projectPrefixRe matches the characters of a project name not kept in the name prefix.
+var projectPrefixRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// prefixDuplicateNames detects the destination names (case-insensitive, per destination
+// project) produced by more than one source project and maps each of those repositories
+// to "{source project}-{name}". Fails if the prefixed names still collide.
+func prefixDuplicateNames(batches []*sourceBatch) error {
+	key := func(b *sourceBatch, src string) string {
+		return destinationProject(b.cfg, src) + "/" + strings.ToLower(destinationName(b.cfg, src))
+	}
+	owners := map[string]map[*sourceBatch]bool{}
+	for _, b := range batches {
+		for _, r := range b.selected {
+			k := key(b, r.Name)
+			if owners[k] == nil {
+				owners[k] = map[*sourceBatch]bool{}
+			}
+			owners[k][b] = true
+		}
+	}
+	for _, b := range batches {
+		prefix := strings.Trim(projectPrefixRe.ReplaceAllString(b.cfg.SrcProject, "-"), "-._")
+		for _, r := range b.selected {
+			if len(owners[key(b, r.Name)]) < 2 {
+				continue
+			}
+			dst := prefix + "-" + destinationName(b.cfg, r.Name)
+			fmt.Fprintf(os.Stderr, "[NAME COLLISION] %s/%s -> %s (name used by several source projects)\n", b.cfg.SrcProject, r.Name, dst)
+			b.cfg.RepoMap[r.Name] = dst
+		}
+	}
+
+	seen := map[string]string{}
+	for _, b := range batches {
+		for _, r := range b.selected {
+			k := key(b, r.Name)
+			if other, ok := seen[k]; ok {
+				return fmt.Errorf("source repos %s and %s/%s map to the same destination %q even after prefixing: fix the repo list mapping",
+					other, b.cfg.SrcProject, r.Name, destinationName(b.cfg, r.Name))
+			}
+			seen[k] = b.cfg.SrcProject + "/" + r.Name
+		}
+	}
+	return nil
+}
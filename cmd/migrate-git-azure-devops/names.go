@@ -0,0 +1,249 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// NameWarning is a structured warning about a destination repository name that is
+// reserved or known to cause failures on creation or on Windows clones.
+type NameWarning struct {
+	Repo    string `json:"repo"`    // source repository
+	Dst     string `json:"dst"`     // destination name
+	Code    string `json:"code"`    // machine-readable warning code
+	Message string `json:"message"` // what is wrong
+	Hint    string `json:"hint"`    // how to fix it
+}
+
+// String formats the warning on one line for the console and the summary.
+func (w NameWarning) String() string {
+	return fmt.Sprintf("%s: %s (%s)", w.Code, w.Message, w.Hint)
+}
+
+// reservedDeviceNames are Windows device names: a clone into a folder with such a name fails.
+var reservedDeviceNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// reservedSegmentNames are names reserved by Azure DevOps (IIS segments).
+var reservedSegmentNames = map[string]bool{
+	"APP_BROWSERS": true, "APP_CODE": true, "APP_DATA": true, "APP_GLOBALRESOURCES": true,
+	"APP_LOCALRESOURCES": true, "APP_THEMES": true, "APP_WEBRESOURCES": true, "BIN": true, "WEB.CONFIG": true,
+}
+
+// checkReservedName returns the warnings for a destination repository name.
+func checkReservedName(src, dst string) []NameWarning {
+	var out []NameWarning
+	add := func(code, msg, hint string) {
+		out = append(out, NameWarning{Repo: src, Dst: dst, Code: code, Message: msg, Hint: hint})
+	}
+	upper := strings.ToUpper(dst)
+	base := upper
+	if i := strings.Index(base, "."); i >= 0 {
+		base = base[:i] // "CON.api" is reserved on Windows as well
+	}
+	if reservedDeviceNames[base] {
+		add("RESERVED_DEVICE_NAME", fmt.Sprintf("%q is a Windows device name, clones on Windows will fail", dst),
+			"map it to another name in the repo list, e.g. "+dst+"-repo")
+	}
+	if reservedSegmentNames[upper] {
+		add("RESERVED_ADO_NAME", fmt.Sprintf("%q is reserved by Azure DevOps, creation will be rejected", dst),
+			"map it to another name in the repo list")
+	}
+	if strings.HasSuffix(strings.ToLower(dst), ".git") {
+		add("GIT_SUFFIX", fmt.Sprintf("%q ends with .git, clone URLs become ambiguous", dst),
+			"drop the .git suffix in the repo list mapping")
+	}
+	if strings.HasPrefix(dst, "_") {
+		add("LEADING_UNDERSCORE", fmt.Sprintf("%q starts with an underscore, which Azure DevOps rejects", dst),
+			"remove the leading underscore in the repo list mapping")
+	}
+	if strings.HasPrefix(dst, ".") || strings.HasSuffix(dst, ".") {
+		add("LEADING_TRAILING_DOT", fmt.Sprintf("%q starts or ends with a period, which Azure DevOps rejects", dst),
+			"remove the period in the repo list mapping")
+	}
+	if strings.TrimSpace(dst) != dst {
+		add("SURROUNDING_SPACES", fmt.Sprintf("%q has leading or trailing spaces", dst),
+			"trim the name in the repo list mapping")
+	}
+	return out
+}
+
+// warnReservedNames checks the destination names of the selected repositories during
+// planning and prints one structured warning per problem. Returns the warnings by source repo.
+func warnReservedNames(cfg Config, repos []Repo) map[string][]NameWarning {
+	byRepo := map[string][]NameWarning{}
+	for _, r := range repos {
+		if cfg.SanitizeNames {
+			raw := cfg
+			raw.SanitizeNames = false
+			if before, after := destinationName(raw, r.Name), destinationName(cfg, r.Name); before != after {
+				fmt.Fprintf(os.Stderr, "[SANITIZED] repo=%q dst=%q -> %q\n", r.Name, before, after)
+			}
+		}
+		for _, w := range checkReservedName(r.Name, destinationName(cfg, r.Name)) {
+			fmt.Fprintf(os.Stderr, "[NAME WARNING] code=%s repo=%q dst=%q: %s. Hint: %s\n", w.Code, w.Repo, w.Dst, w.Message, w.Hint)
+			byRepo[r.Name] = append(byRepo[r.Name], w)
+		}
+	}
+	return byRepo
+}
+
+// attachNameWarnings records the planning warnings in the matching summaries.
+func attachNameWarnings(results []Summary, warnings map[string][]NameWarning) {
+	for i := range results {
+		results[i].NameWarnings = warnings[results[i].Repo]
+	}
+}
+
+// checkNameCollisions validates the whole plan before any clone: destination names are
+// compared case-insensitively, as Azure DevOps does, against each other and against the
+// existing destination repositories (an existing repo with the very same name is a
+// re-run target, not a collision). Returns one message per collision.
+func checkNameCollisions(cfg Config, repos []Repo, dstRepos []Repo) []string {
+	var out []string
+	bySrc := map[string][]string{}
+	var keys []string
+	for _, r := range repos {
+		key := destinationProject(cfg, r.Name) + "/" + strings.ToLower(destinationName(cfg, r.Name))
+		if bySrc[key] == nil {
+			keys = append(keys, key)
+		}
+		bySrc[key] = append(bySrc[key], r.Name)
+	}
+	existing := map[string]string{}
+	for _, r := range dstRepos {
+		existing[cfg.DstProject+"/"+strings.ToLower(r.Name)] = r.Name
+	}
+	for _, key := range keys {
+		srcs := bySrc[key]
+		dst := destinationName(cfg, srcs[0])
+		if len(srcs) > 1 {
+			out = append(out, fmt.Sprintf("source repos %s all map to destination %q", strings.Join(srcs, ", "), dst))
+		}
+		for _, src := range srcs {
+			d := destinationName(cfg, src)
+			if name, ok := existing[key]; ok && name != d {
+				out = append(out, fmt.Sprintf("destination %q of %s collides with existing repo %q", d, src, name))
+			}
+		}
+	}
+	return out
+}
+
+// failOnNameCollisions prints the collisions of the plan and returns an error if any.
+func failOnNameCollisions(cfg Config, repos []Repo, dstRepos []Repo) error {
+	collisions := checkNameCollisions(cfg, repos, dstRepos)
+	for _, c := range collisions {
+		fmt.Fprintf(os.Stderr, "[NAME COLLISION] %s\n", c)
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("%d destination name collisions: fix the repo list mapping before migrating", len(collisions))
+	}
+	return nil
+}
+
+// adoMaxNameLength is the maximum length of an Azure DevOps repository name (in characters).
+const adoMaxNameLength = 64
+
+// adoInvalidChars are the printable characters Azure DevOps rejects in repository names.
+const adoInvalidChars = `\/:*?"<>|;#${},+=[]`
+
+// validateRepoName checks a destination name against the Azure DevOps naming rules
+// and returns one message per violated rule.
+func validateRepoName(name string) []string {
+	var out []string
+	if strings.TrimSpace(name) == "" {
+		return []string{"name is empty"}
+	}
+	if n := len([]rune(name)); n > adoMaxNameLength {
+		out = append(out, fmt.Sprintf("name is %d characters long, the maximum is %d", n, adoMaxNameLength))
+	}
+	var bad []string
+	for _, r := range name {
+		if strings.ContainsRune(adoInvalidChars, r) || unicode.IsControl(r) || unicode.Is(unicode.Cs, r) {
+			bad = append(bad, fmt.Sprintf("%q", r))
+		}
+	}
+	if len(bad) > 0 {
+		out = append(out, "invalid characters "+strings.Join(bad, " "))
+	}
+	if strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") {
+		out = append(out, "name starts with '_' or '.'")
+	}
+	if strings.HasSuffix(name, ".") {
+		out = append(out, "name ends with '.'")
+	}
+	return out
+}
+
+// sanitizeRepoName deterministically turns a name into a valid Azure DevOps repository name:
+// invalid and control characters become '-', leading '_'/'.' and trailing '.' and spaces are
+// trimmed, reserved names get a "-repo" suffix and names too long are truncated keeping a short
+// hash of the original, so that distinct long names stay distinct.
+func sanitizeRepoName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(adoInvalidChars, r) || unicode.IsControl(r) || unicode.Is(unicode.Cs, r) {
+			r = '-'
+		}
+		b.WriteRune(r)
+	}
+	out := strings.TrimLeft(strings.TrimSpace(b.String()), "_. ")
+	out = strings.TrimRight(out, ". ")
+	hash := fmt.Sprintf("%x", sha1.Sum([]byte(name)))[:8]
+	if out == "" {
+		return "repo-" + hash
+	}
+	upper := strings.ToUpper(out)
+	base := upper
+	if i := strings.Index(base, "."); i >= 0 {
+		base = base[:i]
+	}
+	switch {
+	case reservedDeviceNames[base]:
+		out = out[:len(base)] + "-repo" + out[len(base):]
+	case reservedSegmentNames[upper]:
+		out += "-repo"
+	}
+	if r := []rune(out); len(r) > adoMaxNameLength {
+		out = strings.TrimRight(string(r[:adoMaxNameLength-len(hash)-1]), ". ") + "-" + hash
+	}
+	return out
+}
+
+// failOnInvalidNames validates the destination names of the plan against the Azure DevOps
+// naming rules and returns an error listing the invalid ones (not needed with --sanitize-names).
+func failOnInvalidNames(cfg Config, repos []Repo) error {
+	invalid := 0
+	for _, r := range repos {
+		dst := destinationName(cfg, r.Name)
+		if problems := validateRepoName(dst); len(problems) > 0 {
+			invalid++
+			fmt.Fprintf(os.Stderr, "[INVALID NAME] repo=%q dst=%q: %s\n", r.Name, dst, strings.Join(problems, "; "))
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d destination names violate the Azure DevOps naming rules: fix the mapping or use --sanitize-names", invalid)
+	}
+	return nil
+}
+
+// attachSanitizedNames records in the summaries the destination names changed by --sanitize-names.
+func attachSanitizedNames(cfg Config, results []Summary) {
+	if !cfg.SanitizeNames {
+		return
+	}
+	raw := cfg
+	raw.SanitizeNames = false
+	for i := range results {
+		if before := destinationName(raw, results[i].Repo); before != destinationName(cfg, results[i].Repo) {
+			results[i].SanitizedFrom = before
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Results of the repositories holding files over --max-file-size, or a history larger
+// than a single Azure DevOps push.
+const (
+	ResultOversized     = "ERROR: oversized files"
+	ResultOversizedSkip = "SKIPPED: oversized files"
+)
+
+// adoMaxPushSize is the largest push Azure DevOps accepts.
+const adoMaxPushSize = 5 << 30
+
+// oversizedBlob is a file of the history larger than --max-file-size.
+type oversizedBlob struct {
+	sha  string
+	size int64
+	path string
+}
+
+// findOversizedBlobs lists the files reachable from the refs of the mirror larger than
+// limit, largest first. The path is the first one the blob was found at.
+func findOversizedBlobs(ctx context.Context, repoDir string, limit int64) ([]oversizedBlob, error) {
+	if nativeGit {
+		return nativeOversizedBlobs(ctx, repoDir, limit)
+	}
+	revList := exec.CommandContext(ctx, "git", "-C", repoDir, "rev-list", "--objects", "--all")
+	objects, err := revList.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	catFile := exec.CommandContext(ctx, "git", "-C", repoDir, "cat-file",
+		"--batch-check=%(objecttype) %(objectname) %(objectsize) %(rest)")
+	catFile.Stdin = objects
+	out, err := catFile.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := revList.Start(); err != nil {
+		return nil, err
+	}
+	if err := catFile.Start(); err != nil {
+		_ = revList.Wait()
+		return nil, err
+	}
+
+	var found []oversizedBlob
+	sc := bufio.NewScanner(out)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		fields := strings.SplitN(sc.Text(), " ", 4)
+		if len(fields) < 3 || fields[0] != "blob" {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil || size <= limit {
+			continue
+		}
+		b := oversizedBlob{sha: fields[1], size: size}
+		if len(fields) == 4 {
+			b.path = fields[3]
+		}
+		found = append(found, b)
+	}
+	if err := revList.Wait(); err != nil {
+		return nil, fmt.Errorf("git rev-list: %w", err)
+	}
+	if err := catFile.Wait(); err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].size > found[j].size })
+	return found, sc.Err()
+}
+
+// checkPushLimits scans the mirror before the push for what Azure DevOps would reject:
+// files over --max-file-size and a history larger than one push. The offending files are
+// recorded in sum; a non-nil error describes the problem.
+func checkPushLimits(ctx context.Context, cfg Config, repoDir string, sum *Summary) error {
+	blobs, err := findOversizedBlobs(ctx, repoDir, cfg.MaxFileSize)
+	if err != nil {
+		return fmt.Errorf("scanning for oversized files: %w", err)
+	}
+	var problems []string
+	if len(blobs) > 0 {
+		for _, b := range blobs {
+			sum.OversizedFiles = append(sum.OversizedFiles, fmt.Sprintf("%s %s %s", formatBytes(b.size), b.sha, b.path))
+		}
+		problems = append(problems, fmt.Sprintf("%d files over %s (largest: %s, %s)",
+			len(blobs), formatBytes(cfg.MaxFileSize), blobs[0].path, formatBytes(blobs[0].size)))
+	}
+	if packSize, err := dirSize(filepath.Join(repoDir, "objects")); err == nil && packSize > adoMaxPushSize {
+		problems = append(problems, fmt.Sprintf("history of %s over the %s push limit", formatBytes(packSize), formatBytes(adoMaxPushSize)))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	if len(blobs) > 0 {
+		problems = append(problems, "move the files to Git LFS or remove them with --exclude-path")
+	}
+	return fmt.Errorf("%s", strings.Join(problems, "; "))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ownerCommitSample is the number of recent commits examined to find the owner of a repository.
+const ownerCommitSample = 200
+
+// mostFrequentAuthor returns the author with most commits ("Name <email>"), authors being
+// matched by email case-insensitively. Ties go to the most recent author (commits are newest first).
+func mostFrequentAuthor(authors []CommitAuthor) string {
+	counts := map[string]int{}
+	first := map[string]CommitAuthor{}
+	var order []string
+	for _, a := range authors {
+		key := strings.ToLower(a.Email)
+		if key == "" {
+			key = a.Name
+		}
+		if _, ok := first[key]; !ok {
+			first[key] = a
+			order = append(order, key)
+		}
+		counts[key]++
+	}
+	best := ""
+	for _, key := range order {
+		if best == "" || counts[key] > counts[best] {
+			best = key
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	a := first[best]
+	if a.Email == "" {
+		return a.Name
+	}
+	return fmt.Sprintf("%s <%s>", a.Name, a.Email)
+}
+
+// resolveOwners fills cfg.RepoOwners with the most frequent committer of the last
+// --owner-window for the repositories whose owner is not set in the repo list/manifest.
+// Lookup failures (e.g. empty repositories) leave the owner unknown.
+func resolveOwners(ctx context.Context, cfg *Config, repos []Repo) {
+	if cfg.RepoOwners == nil {
+		cfg.RepoOwners = map[string]string{}
+	}
+	since := time.Now().Add(-cfg.OwnerWindow)
+	for _, r := range repos {
+		if cfg.RepoOwners[r.Name] != "" || r.ID == "" {
+			continue
+		}
+		authors, err := getCommitAuthors(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, r.ID, since, ownerCommitSample, cfg.Trace)
+		if err != nil {
+			if cfg.Trace {
+				fmt.Fprintf(os.Stderr, "[TRACE] Owner lookup failed for %s: %v\n", r.Name, err)
+			}
+			continue
+		}
+		if owner := mostFrequentAuthor(authors); owner != "" {
+			cfg.RepoOwners[r.Name] = owner
+		}
+	}
+}
+
+// ownerOrUnknown returns the owner or "unknown" when not set.
+func ownerOrUnknown(owner string) string {
+	if owner == "" {
+		return "unknown"
+	}
+	return owner
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Modes of the pipeline definitions handling.
+const (
+	PipelinesReport  = "report"  // --inventory-pipelines: listed in the report
+	PipelinesMigrate = "migrate" // --migrate-pipelines: listed and recreated at destination
+)
+
+// BuildDefinitionRef is a build/YAML pipeline definition as listed by the API.
+type BuildDefinitionRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"` // folder, e.g. \ or \team
+}
+
+// fullName returns the folder and name of the definition.
+func (d BuildDefinitionRef) fullName() string {
+	return strings.TrimSuffix(d.Path, `\`) + `\` + d.Name
+}
+
+// getBuildDefinitions returns the pipeline definitions building a repository of the project.
+func getBuildDefinitions(ctx context.Context, org, project, pat, repoID string, trace bool) ([]BuildDefinitionRef, error) {
+	path := fmt.Sprintf("_apis/build/definitions?repositoryId=%s&repositoryType=TfsGit&api-version=%s", url.QueryEscape(repoID), apiVersion)
+	var defs []BuildDefinitionRef
+	err := paginate(ctx, org, project, path, pat, trace, func(body []byte) error {
+		var resp struct {
+			Value []BuildDefinitionRef `json:"value"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return fmt.Errorf("invalid response: %w", err)
+		}
+		defs = append(defs, resp.Value...)
+		return nil
+	})
+	return defs, err
+}
+
+// getBuildDefinition returns the full JSON of a pipeline definition.
+func getBuildDefinition(ctx context.Context, org, project, pat string, id int, trace bool) (map[string]any, error) {
+	path := fmt.Sprintf("_apis/build/definitions/%d?api-version=%s", id, apiVersion)
+	body, code, err := httpReq(ctx, "GET", org, project, path, pat, nil, trace)
+	if err != nil {
+		return nil, err
+	}
+	if code < 200 || code >= 300 {
+		return nil, fmt.Errorf("API error reading pipeline %d (HTTP %d): %s", id, code, string(body))
+	}
+	var def map[string]any
+	if err := json.Unmarshal(body, &def); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return def, nil
+}
+
+// createBuildDefinition creates a pipeline definition and returns its ID.
+func createBuildDefinition(ctx context.Context, org, project, pat string, def map[string]any, trace bool) (int, error) {
+	path := fmt.Sprintf("_apis/build/definitions?api-version=%s", apiVersion)
+	data, err := json.Marshal(def)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding payload: %w", err)
+	}
+	body, code, err := httpReq(ctx, "POST", org, project, path, pat, data, trace)
+	if err != nil {
+		return 0, err
+	}
+	if code != 200 && code != 201 {
+		return 0, fmt.Errorf("API error creating pipeline (HTTP %d): %s", code, string(body))
+	}
+	var created BuildDefinitionRef
+	if err := json.Unmarshal(body, &created); err != nil {
+		return 0, fmt.Errorf("invalid response: %w", err)
+	}
+	return created.ID, nil
+}
+
+// inventoryPipelines lists in sum.Pipelines the pipeline definitions of the source project
+// building the repository.
+func inventoryPipelines(ctx context.Context, cfg Config, r Repo, sum *Summary) ([]BuildDefinitionRef, error) {
+	defs, err := getBuildDefinitions(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, r.ID, cfg.Trace)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range defs {
+		sum.Pipelines = append(sum.Pipelines, fmt.Sprintf("%s (%d)", d.fullName(), d.ID))
+	}
+	return defs, nil
+}
+
+// pipelineServerFields are the fields of a definition set by the server, dropped before
+// the creation at destination.
+var pipelineServerFields = []string{"id", "url", "uri", "_links", "revision", "project", "authoredBy",
+	"createdDate", "queue", "draftOf", "drafts", "latestBuild", "latestCompletedBuild", "metrics"}
+
+// migratePipelines recreates in the destination project the pipeline definitions building
+// the source repository (--migrate-pipelines), pointed at the destination repository: same
+// name and folder, process (YAML file or classic steps), triggers, variables and options.
+// What can't be carried over is listed in sum.PipelineIssues: secret variables (the API
+// does not return their value), variable groups, and the agent queue of classic pipelines.
+// Definitions with the same name already building the destination repository are skipped.
+func migratePipelines(ctx context.Context, cfg Config, defs []BuildDefinitionRef, dstRepoName string, sum *Summary) {
+	dstRepoID := sum.DstRepoID
+	if dstRepoID == "" {
+		dst, err := getRepo(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, dstRepoName, cfg.Trace)
+		if err != nil {
+			sum.PipelineIssues = append(sum.PipelineIssues, "reading the destination repository failed: "+err.Error())
+			return
+		}
+		dstRepoID = dst.ID
+	}
+	existing, err := getBuildDefinitions(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, dstRepoID, cfg.Trace)
+	if err != nil {
+		sum.PipelineIssues = append(sum.PipelineIssues, "listing the destination pipelines failed: "+err.Error())
+		return
+	}
+
+	for _, d := range defs {
+		name := d.fullName()
+		if slices.ContainsFunc(existing, func(e BuildDefinitionRef) bool { return e.fullName() == name }) {
+			sum.PipelineIssues = append(sum.PipelineIssues, name+": already at destination")
+			continue
+		}
+		def, err := getBuildDefinition(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, d.ID, cfg.Trace)
+		if err != nil {
+			sum.PipelineIssues = append(sum.PipelineIssues, name+": "+err.Error())
+			continue
+		}
+		var issues []string
+		for _, f := range pipelineServerFields {
+			delete(def, f)
+		}
+		if repo, ok := def["repository"].(map[string]any); ok {
+			repo["id"] = dstRepoID
+			repo["name"] = dstRepoName
+			repo["url"] = sum.DstWebURL
+			if branch, ok := repo["defaultBranch"].(string); ok && branch != "" {
+				renamed, _ := migratedBranch(cfg, branch, sum.BranchNames)
+				repo["defaultBranch"] = renamed
+			}
+		}
+		if process, ok := def["process"].(map[string]any); ok && process["type"] != float64(2) {
+			issues = append(issues, "classic pipeline: select the agent pool, check the service connections of the tasks")
+		}
+		if vars, ok := def["variables"].(map[string]any); ok {
+			for _, k := range slices.Sorted(maps.Keys(vars)) {
+				if v, ok := vars[k].(map[string]any); ok && v["isSecret"] == true {
+					issues = append(issues, "secret variable "+k+" to enter again")
+				}
+			}
+		}
+		if groups, ok := def["variableGroups"].([]any); ok {
+			for _, g := range groups {
+				if g, ok := g.(map[string]any); ok {
+					issues = append(issues, fmt.Sprintf("variable group %v to link again", g["name"]))
+				}
+			}
+			delete(def, "variableGroups")
+		}
+
+		id, err := createBuildDefinition(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, def, cfg.Trace)
+		if err != nil {
+			sum.PipelineIssues = append(sum.PipelineIssues, name+": "+err.Error())
+			continue
+		}
+		sum.PipelinesCreated = append(sum.PipelinesCreated, fmt.Sprintf("%s (%d -> %d)", name, d.ID, id))
+		fmt.Printf("  Pipeline %s recreated (%d)\n", name, id)
+		for _, issue := range issues {
+			sum.PipelineIssues = append(sum.PipelineIssues, name+": "+issue)
+			fmt.Printf("    %s\n", issue)
+		}
+	}
+}
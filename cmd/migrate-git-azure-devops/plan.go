@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Actions of a plan entry.
+const (
+	PlanCreate = "create"     // Create the destination repository and mirror push
+	PlanForce  = "force-push" // Destination exists: mirror push with --force
+	PlanSkip   = "skip"       // Nothing done (destination exists without force push, manifest skip)
+)
+
+// planFileVersion is the version of the plan file format.
+const planFileVersion = 2
+
+// PlanEntry is the planned action for one source repository.
+type PlanEntry struct {
+	Repo        string   `json:"repo"`
+	Destination string   `json:"destination"`
+	Action      string   `json:"action"`
+	Reason      string   `json:"reason,omitempty"`
+	Size        int64    `json:"size"` // Estimated from the source repository size
+	Owner       string   `json:"owner,omitempty"`
+	Branches    []string `json:"branches,omitempty"` // Branch filter of the manifest
+}
+
+// PlanFile is the reviewed and signed description of a migration produced by plan
+// and executed by apply. The signature is an HMAC-SHA256 of the file with an empty
+// signature, keyed with --plan-key. The history rewrite is recorded by the path and
+// SHA-256 of its file, not by its content: the texts it replaces are secrets.
+type PlanFile struct {
+	Version       int                       `json:"version"`
+	CreatedAt     time.Time                 `json:"createdAt"`
+	CreatedBy     string                    `json:"createdBy"`
+	SrcOrg        string                    `json:"srcOrg"`
+	SrcProject    string                    `json:"srcProject"`
+	DstOrg        string                    `json:"dstOrg"`
+	DstProject    string                    `json:"dstProject"`
+	RefRenames    []RenameRule              `json:"refRenames,omitempty"`
+	ExcludePaths  []string                  `json:"excludePaths,omitempty"`
+	RewriteConfig string                    `json:"rewriteConfig,omitempty"`
+	RewriteSHA256 string                    `json:"rewriteSha256,omitempty"`
+	Submodules    *SubmoduleRewrite         `json:"submodules,omitempty"`
+	Entries       []PlanEntry               `json:"entries"`
+	TotalSize     int64                     `json:"totalSize"` // Estimated size of the repositories to push
+	Assumptions   map[string]DestAssumption `json:"assumptions"`
+	Signature     string                    `json:"signature,omitempty"`
+}
+
+// sign returns the HMAC-SHA256 of the plan without its signature.
+func (p PlanFile) sign(key string) (string, error) {
+	p.Signature = ""
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// cmdPlan computes what a migration would do, without touching the destination, and
+// writes it as a signed plan file for review: action per repository, estimated sizes
+// and the destination state the plan relies on.
+func cmdPlan(ctx context.Context, cfg Config) error {
+	srcRepos, err := getRepos(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, cfg.Trace)
+	if err != nil {
+		return fmt.Errorf("call failed for source %s/%s: %w", cfg.SrcOrg, cfg.SrcProject, err)
+	}
+	selected, preSummary, err := selectRepos(cfg, srcRepos)
+	if err != nil {
+		return err
+	}
+	for _, s := range preSummary {
+		if !s.Skipped {
+			return fmt.Errorf("%s: %s", s.Repo, s.Result)
+		}
+	}
+	selected, ignored, err := applyMigrateIgnore(ctx, cfg, selected)
+	if err != nil {
+		return err
+	}
+	selected, large := skipLargeRepos(cfg, selected)
+	ignored = append(ignored, large...)
+	selected, disabled := skipDisabledRepos(cfg, selected)
+	ignored = append(ignored, disabled...)
+	if cfg.ResolveOwners {
+		resolveOwners(ctx, &cfg, selected)
+	}
+	if err := failOnInvalidNames(cfg, selected); err != nil {
+		return err
+	}
+	for _, r := range selected {
+		if destinationProject(cfg, r.Name) != cfg.DstProject {
+			return fmt.Errorf("%s: plan does not support destinationProject overrides of the manifest", r.Name)
+		}
+	}
+	if len(cfg.Mirrors) > 0 {
+		return fmt.Errorf("plan does not support the additional destinations of the manifest")
+	}
+	dstRepos, err := getRepos(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, cfg.Trace)
+	if err != nil {
+		return fmt.Errorf("call failed for destination %s/%s: %w", cfg.DstOrg, cfg.DstProject, err)
+	}
+	if err := failOnNameCollisions(cfg, selected, dstRepos); err != nil {
+		return err
+	}
+	exists := map[string]bool{}
+	for _, r := range dstRepos {
+		exists[r.Name] = true
+	}
+
+	hostname, _ := os.Hostname()
+	plan := PlanFile{
+		Version:      planFileVersion,
+		CreatedAt:    time.Now().UTC(),
+		CreatedBy:    hostname,
+		SrcOrg:       cfg.SrcOrg,
+		SrcProject:   cfg.SrcProject,
+		DstOrg:       cfg.DstOrg,
+		DstProject:   cfg.DstProject,
+		RefRenames:   cfg.RefRenameRules,
+		ExcludePaths: cfg.ExcludePaths,
+		Submodules:   cfg.Submodules,
+	}
+	if cfg.Rewrite != nil {
+		plan.RewriteConfig, plan.RewriteSHA256 = cfg.Rewrite.file, cfg.Rewrite.sha256
+	}
+	var names []string
+	for _, r := range selected {
+		dst := destinationName(cfg, r.Name)
+		e := PlanEntry{Repo: r.Name, Destination: dst, Size: r.Size, Owner: cfg.RepoOwners[r.Name],
+			Branches: cfg.RepoOverrides[r.Name].Branches}
+		force := cfg.ForcePush
+		if o := cfg.RepoOverrides[r.Name].ForcePush; o != nil {
+			force = *o
+		}
+		switch {
+		case !exists[dst]:
+			e.Action = PlanCreate
+		case force:
+			e.Action = PlanForce
+		default:
+			e.Action, e.Reason = PlanSkip, "destination exists, no force push"
+		}
+		if e.Action != PlanSkip {
+			plan.TotalSize += r.Size
+		}
+		plan.Entries = append(plan.Entries, e)
+		names = append(names, dst)
+	}
+	for _, s := range append(preSummary, ignored...) {
+		plan.Entries = append(plan.Entries, PlanEntry{Repo: s.Repo, Action: PlanSkip, Reason: s.Result})
+	}
+	if plan.Assumptions, err = captureAssumptions(ctx, cfg, names); err != nil {
+		return fmt.Errorf("reading the destination state: %w", err)
+	}
+	if plan.Signature, err = plan.sign(cfg.PlanKey); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(cfg.PlanFile, data, 0o644); err != nil {
+		return fmt.Errorf("writing the plan: %w", err)
+	}
+	printPlan(plan)
+	fmt.Printf("\nPlan written to %s: review it, then run '%s apply --plan-file %s'\n", cfg.PlanFile, prog(), cfg.PlanFile)
+	return nil
+}
+
+// printPlan prints the entries of a plan and its totals.
+func printPlan(plan PlanFile) {
+	fmt.Printf("===== PLAN %s/%s -> %s/%s =====\n", plan.SrcOrg, plan.SrcProject, plan.DstOrg, plan.DstProject)
+	if len(plan.RefRenames) > 0 {
+		var rules []string
+		for _, r := range plan.RefRenames {
+			rules = append(rules, r.expr)
+		}
+		fmt.Printf("Branch renames: %s\n", strings.Join(rules, ", "))
+	}
+	if len(plan.ExcludePaths) > 0 {
+		fmt.Printf("History rewrite: excluding %s (commit SHAs will change)\n", strings.Join(plan.ExcludePaths, ", "))
+	}
+	if plan.RewriteConfig != "" {
+		fmt.Printf("History rewrite: %s, SHA-256 %s (commit SHAs will change)\n", plan.RewriteConfig, plan.RewriteSHA256)
+	}
+	if plan.Submodules != nil {
+		fmt.Printf("Submodule URLs mapped to the destination: %s mode\n", plan.Submodules.Mode)
+	}
+	counts := map[string]int{}
+	for _, e := range plan.Entries {
+		counts[e.Action]++
+		name := e.Repo
+		if e.Destination != "" && e.Destination != e.Repo {
+			name += " -> " + e.Destination
+		}
+		line := fmt.Sprintf("  %-10s %s (%s)", e.Action, name, formatBytes(e.Size))
+		if e.Reason != "" {
+			line += ": " + e.Reason
+		}
+		if len(e.Branches) > 0 {
+			line += "; branches " + strings.Join(e.Branches, ", ")
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("%d to create, %d to force push, %d skipped; about %s to push\n",
+		counts[PlanCreate], counts[PlanForce], counts[PlanSkip], formatBytes(plan.TotalSize))
+}
+
+// loadPlan reads a plan file and checks its signature.
+func loadPlan(file, key string) (PlanFile, error) {
+	var plan PlanFile
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return plan, fmt.Errorf("reading the plan: %w", err)
+	}
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return plan, fmt.Errorf("invalid plan file: %w", err)
+	}
+	if plan.Version != planFileVersion {
+		return plan, fmt.Errorf("unsupported plan version %d", plan.Version)
+	}
+	want, err := plan.sign(key)
+	if err != nil {
+		return plan, err
+	}
+	if !hmac.Equal([]byte(want), []byte(plan.Signature)) {
+		return plan, fmt.Errorf("plan signature not valid: the file was modified or signed with another key")
+	}
+	return plan, nil
+}
+
+// cmdApply executes exactly a signed plan: the repositories, destination names, force
+// pushes, branch filters, branch renames and history rewrites come from the plan, not from
+// the flags. If the destination or the --rewrite-config file changed since the plan was
+// made, nothing is done and the change is reported.
+func cmdApply(ctx context.Context, cfg Config) error {
+	plan, err := loadPlan(cfg.PlanFile, cfg.PlanKey)
+	if err != nil {
+		return err
+	}
+	printPlan(plan)
+	fmt.Println()
+
+	cfg, names, err := planConfig(ctx, cfg, plan)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Println("Nothing to apply.")
+		return nil
+	}
+	if !cfg.DryRun {
+		release, err := acquireRunLocks(cfg)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	actual, err := captureAssumptions(ctx, cfg, names)
+	if err != nil {
+		return fmt.Errorf("reading the destination state: %w", err)
+	}
+	planned := map[string]DestAssumption{}
+	for _, n := range names {
+		planned[n] = plan.Assumptions[n]
+	}
+	if drift := diffAssumptions(planned, actual); len(drift) > 0 {
+		printDriftReport(drift)
+		return fmt.Errorf("destination changed since the plan was made: run plan again")
+	}
+	return runNonInteractive(cfg)
+}
+
+// planConfig returns the configuration of the run executing plan, and the destination
+// names it writes. The settings recorded in the plan can't be given to apply as flags.
+func planConfig(ctx context.Context, cfg Config, plan PlanFile) (Config, []string, error) {
+	if len(cfg.RefRenameRules) > 0 || len(cfg.ExcludePaths) > 0 || cfg.Rewrite != nil || cfg.Submodules != nil {
+		return cfg, nil, fmt.Errorf("--ref-rename, --exclude-path, --rewrite-config and --submodules are recorded in the plan: pass them to plan")
+	}
+	// The plan records mirror pushes only: other modes would act beyond what was signed
+	// (deleted branches pruned, created repositories or sources deleted)
+	for _, mode := range []struct {
+		flag string
+		set  bool
+	}{
+		{"--sync", cfg.Sync},
+		{"--final-sync", cfg.FinalSync},
+		{"--daemon", cfg.Daemon},
+		{"--hook-listen", cfg.HookListen != ""},
+		{"--rollback-on-failure", cfg.RollbackOnFailure},
+		{"--delete-source-after", cfg.DeleteSource},
+		{"--retry-failed", cfg.RetryFailed != ""},
+		{"--coordinator", cfg.Coordinator},
+		{"--worker", cfg.Worker},
+		{"--wizard", cfg.Wizard},
+	} {
+		if mode.set {
+			return cfg, nil, fmt.Errorf("%s is not recorded in the plan and cannot be used with apply", mode.flag)
+		}
+	}
+	cfg.SrcOrg, cfg.SrcProject = plan.SrcOrg, plan.SrcProject
+	cfg.DstOrg, cfg.DstProject = plan.DstOrg, plan.DstProject
+	cfg.Filter, cfg.Globs, cfg.IgnoreRepo = "", nil, ""
+	cfg.RenameRules, cfg.RenameLowercase, cfg.RenamePrefix, cfg.RenameSuffix, cfg.SanitizeNames = nil, false, "", "", false
+	cfg.ForcePush = false
+	cfg.RepoList = nil
+	cfg.RepoMap = map[string]string{}
+	cfg.RepoOwners = map[string]string{}
+	cfg.RepoOverrides = map[string]RepoOverride{}
+	cfg.Mirrors = nil
+	cfg.RefRenameRules, cfg.ExcludePaths, cfg.Submodules = plan.RefRenames, plan.ExcludePaths, plan.Submodules
+	if plan.RewriteConfig != "" {
+		rw, err := loadRewriteConfig(plan.RewriteConfig)
+		if err != nil {
+			return cfg, nil, err
+		}
+		if rw.sha256 != plan.RewriteSHA256 {
+			return cfg, nil, fmt.Errorf("%s changed since the plan was made: run plan again", plan.RewriteConfig)
+		}
+		if !cfg.DryRun {
+			if err := checkFilterRepo(ctx); err != nil {
+				return cfg, nil, err
+			}
+		}
+		cfg.Rewrite = rw
+	}
+	var names []string
+	for _, e := range plan.Entries {
+		if e.Action == PlanSkip {
+			continue
+		}
+		force := e.Action == PlanForce
+		cfg.RepoList = append(cfg.RepoList, e.Repo)
+		cfg.RepoMap[e.Repo] = e.Destination
+		cfg.RepoOwners[e.Repo] = e.Owner
+		cfg.RepoOverrides[e.Repo] = RepoOverride{ForcePush: &force, Branches: e.Branches}
+		names = append(names, e.Destination)
+	}
+	return cfg, names, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestApplyRunLock(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{PlanFile: filepath.Join(dir, "plan.json"), PlanKey: "plan-key", LockDir: dir, DstPAT: "dst-start"}
+	plan := PlanFile{Version: planFileVersion, SrcOrg: "contoso", SrcProject: "Horse", DstOrg: "fabrikam", DstProject: "Platform",
+		Entries: []PlanEntry{{Repo: "Horse-Core", Destination: "Horse-Core", Action: PlanCreate}}}
+	var err error
+	if plan.Signature, err = plan.sign(cfg.PlanKey); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(plan)
+	if err := os.WriteFile(cfg.PlanFile, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lockPath := runLockPath(dir, "fabrikam", "Platform")
+
+	var requests int
+	held := true
+	prev := httpClient.Transport
+	httpClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		if _, err := os.Stat(lockPath); err != nil {
+			held = false
+		}
+		return &http.Response{StatusCode: http.StatusUnauthorized, Header: http.Header{},
+			Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	})
+	t.Cleanup(func() { httpClient.Transport = prev })
+
+	// The project of the plan is locked while the destination is read and released at the end
+	_ = cmdApply(context.Background(), cfg)
+	if requests == 0 || !held {
+		t.Errorf("destination read without the lock of fabrikam/Platform (%d requests)", requests)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("lock not released: %v", err)
+	}
+
+	// A project locked by another run stops apply before any request
+	if err := writeRunLock(lockPath, RunLock{Org: "fabrikam", Project: "Platform", Operator: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	requests = 0
+	err = cmdApply(context.Background(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "is locked by another migration") {
+		t.Errorf("cmdApply() error = %v, want the lock of another run", err)
+	}
+	if requests > 0 {
+		t.Errorf("%d requests to the destination of a locked project", requests)
+	}
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Errorf("lock of the other run removed: %v", err)
+	}
+}
+
+func TestPlanConfig(t *testing.T) {
+	dir := t.TempDir()
+	rewriteFile := filepath.Join(dir, "rewrite.yaml")
+	if err := os.WriteFile(rewriteFile, []byte("stripPaths: [secrets.txt]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rw, err := loadRewriteConfig(rewriteFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, err := parseRenameRegex("--ref-rename", "s#^Feature/#feature/#")
+	if err != nil {
+		t.Fatal(err)
+	}
+	made := PlanFile{Version: planFileVersion, SrcOrg: "contoso", SrcProject: "Horse", DstOrg: "fabrikam", DstProject: "Platform",
+		RefRenames: []RenameRule{rule}, ExcludePaths: []string{"build/"}, RewriteConfig: rw.file, RewriteSHA256: rw.sha256,
+		Entries: []PlanEntry{
+			{Repo: "Horse-Core", Destination: "horse-core", Action: PlanCreate, Branches: []string{"main", "release/*"}},
+			{Repo: "Horse-Web", Destination: "Horse-Web", Action: PlanForce},
+			{Repo: "Horse-Old", Action: PlanSkip, Reason: "SKIPPED: manifest"},
+		}}
+	// Read back from a signed plan file, as apply does
+	key := "plan-key"
+	if made.Signature, err = made.sign(key); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(made)
+	planFile := filepath.Join(dir, "plan.json")
+	if err := os.WriteFile(planFile, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := loadPlan(planFile, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := Config{DryRun: true, Filter: "Horse-.*", ForcePush: true, RepoOverrides: map[string]RepoOverride{"Horse-Web": {Branches: []string{"dev"}}},
+		Mirrors: []Destination{{Org: "fabrikam-dr", Project: "Platform"}}}
+	cfg, names, err := planConfig(context.Background(), flags, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"horse-core", "Horse-Web"}; !slices.Equal(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+	if got := cfg.RepoOverrides["Horse-Core"]; !slices.Equal(got.Branches, []string{"main", "release/*"}) || *got.ForcePush {
+		t.Errorf("Horse-Core override = %+v, want the branches of the plan without force push", got)
+	}
+	if got := cfg.RepoOverrides["Horse-Web"]; len(got.Branches) > 0 || !*got.ForcePush {
+		t.Errorf("Horse-Web override = %+v, want force push without the branch filter of the flags", got)
+	}
+	if cfg.Filter != "" || cfg.ForcePush || len(cfg.Mirrors) > 0 {
+		t.Errorf("flags of apply kept: filter %q, force push %v, %d destinations", cfg.Filter, cfg.ForcePush, len(cfg.Mirrors))
+	}
+	if len(cfg.RefRenameRules) != 1 || cfg.RefRenameRules[0].apply("Feature/x") != "feature/x" {
+		t.Errorf("ref renames of the plan not applied: %v", cfg.RefRenameRules)
+	}
+	if !slices.Equal(cfg.ExcludePaths, []string{"build/"}) || cfg.Rewrite == nil || !slices.Equal(cfg.Rewrite.StripPaths, []string{"secrets.txt"}) {
+		t.Errorf("history rewrite of the plan not applied: %v %+v", cfg.ExcludePaths, cfg.Rewrite)
+	}
+
+	// The settings of the plan can't be overridden by the flags of apply
+	if _, _, err := planConfig(context.Background(), Config{ExcludePaths: []string{"docs/"}}, plan); err == nil {
+		t.Error("--exclude-path given to apply accepted")
+	}
+	// Nor run in a mode the plan doesn't record
+	for _, flags := range []Config{{Sync: true}, {FinalSync: true}, {Daemon: true}, {RollbackOnFailure: true},
+		{DeleteSource: true}, {HookListen: ":8090"}, {RetryFailed: "report.json"}} {
+		if _, _, err := planConfig(context.Background(), flags, plan); err == nil || !strings.Contains(err.Error(), "not recorded in the plan") {
+			t.Errorf("planConfig(%+v) error = %v, want the mode refused", flags, err)
+		}
+	}
+	// Nor the rewrite config changed after the review
+	if err := os.WriteFile(rewriteFile, []byte("stripPaths: [other.txt]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := planConfig(context.Background(), Config{DryRun: true}, plan); err == nil || !strings.Contains(err.Error(), "changed since the plan") {
+		t.Errorf("planConfig() error = %v, want the rewrite config changed", err)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// repoScoped reports whether every entry of the scope of a policy names the repository:
+// a policy without scope, or with an entry without repository, applies to the whole project.
+func repoScoped(p PolicyConfiguration, repoID string) bool {
+	if len(p.Settings.Scope) == 0 {
+		return false
+	}
+	for _, s := range p.Settings.Scope {
+		if !strings.EqualFold(s.RepositoryID, repoID) {
+			return false
+		}
+	}
+	return true
+}
+
+// projectWide reports whether a policy applies to every repository of the project, and so
+// also to the repositories the migration creates: it has no scope or a scope without a
+// repository.
+func projectWide(p PolicyConfiguration) bool {
+	if len(p.Settings.Scope) == 0 {
+		return true
+	}
+	for _, s := range p.Settings.Scope {
+		if s.RepositoryID == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// bypassPolicies temporarily disables the enabled blocking policies scoped to the
+// destination repository so that the mirror push is not rejected, and returns the
+// function restoring them. Policies applying to other repositories too (project-wide or
+// cross-repository) are left enabled and reported, since disabling them would open every
+// repository they cover. Every disable/re-enable is logged with a [BYPASS] line and in the
+// audit log; the policies not re-enabled are listed in the report (PoliciesLeftOff) and
+// re-enabled by the rollback command. The restore also runs on SIGINT/SIGTERM.
+// The PAT must be allowed to edit policies of the destination project.
+func bypassPolicies(ctx context.Context, cfg Config, repoName string, sum *Summary) (func(), error) {
+	repo, err := getRepo(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, repoName, cfg.Trace)
+	if err != nil {
+		return nil, fmt.Errorf("reading destination repo: %w", err)
+	}
+	policies, err := getPolicies(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, repo.ID, cfg.Trace)
+	if err != nil {
+		return nil, fmt.Errorf("reading policies: %w", err)
+	}
+
+	var (
+		disabled []PolicyConfiguration
+		once     sync.Once
+		remove   = func() {}
+	)
+	restore := func() {
+		once.Do(func() {
+			defer remove()
+			for _, p := range disabled {
+				// Use a fresh context: policies must be restored even if the run was cancelled
+				rctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				err := setPolicyEnabled(rctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, p, true, cfg.Trace)
+				cancel()
+				recordAudit(cfg, AuditEvent{Action: AuditPolicyEnable, Org: cfg.DstOrg, Project: cfg.DstProject, Repo: repoName,
+					RepoID: repo.ID, Result: auditResult(err), Details: fmt.Sprintf("policy %d (%s)", p.ID, p.Type.DisplayName)})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "[BYPASS] %s: FAILED to re-enable policy %d (%s), run rollback on the report or re-enable it from the project settings: %v\n",
+						repoName, p.ID, p.Type.DisplayName, err)
+					sum.PoliciesLeftOff = append(sum.PoliciesLeftOff, fmt.Sprintf("%d:%s", p.ID, p.Type.DisplayName))
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "[BYPASS] %s: re-enabled policy %d (%s)\n", repoName, p.ID, p.Type.DisplayName)
+			}
+		})
+	}
+	remove = onInterrupt(restore)
+
+	for _, p := range policies {
+		if !p.IsEnabled || !p.IsBlocking {
+			continue
+		}
+		if !repoScoped(p, repo.ID) {
+			fmt.Fprintf(os.Stderr, "[BYPASS] %s: policy %d (%s) applies beyond this repository, left enabled: it may reject the push\n",
+				repoName, p.ID, p.Type.DisplayName)
+			sum.PoliciesKept = append(sum.PoliciesKept, fmt.Sprintf("%d:%s", p.ID, p.Type.DisplayName))
+			continue
+		}
+		err := setPolicyEnabled(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, p, false, cfg.Trace)
+		recordAudit(cfg, AuditEvent{Action: AuditPolicyDisable, Org: cfg.DstOrg, Project: cfg.DstProject, Repo: repoName,
+			RepoID: repo.ID, Result: auditResult(err), Details: fmt.Sprintf("policy %d (%s), for the push", p.ID, p.Type.DisplayName)})
+		if err != nil {
+			restore()
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "[BYPASS] %s: disabled policy %d (%s) for the push\n", repoName, p.ID, p.Type.DisplayName)
+		disabled = append(disabled, p)
+		sum.PoliciesBypassed = append(sum.PoliciesBypassed, fmt.Sprintf("%d:%s", p.ID, p.Type.DisplayName))
+	}
+	return restore, nil
+}
+
+// restoreReportPolicies re-enables the destination policies that a run disabled with
+// --bypass-policies and failed to re-enable (PoliciesLeftOff of its report), and
+// returns how many are still disabled.
+func restoreReportPolicies(ctx context.Context, cfg Config, results []Summary) int {
+	failed := 0
+	for i := range results {
+		s := &results[i]
+		if len(s.PoliciesLeftOff) == 0 {
+			continue
+		}
+		org, project, name, err := destinationFromWebURL(s.DstWebURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Re-enabling the policies of %s failed: %v\n", s.Repo, err)
+			failed += len(s.PoliciesLeftOff)
+			continue
+		}
+		var left []string
+		for _, entry := range s.PoliciesLeftOff {
+			idStr, _, _ := strings.Cut(entry, ":")
+			id, err := strconv.Atoi(idStr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid policy entry %q of %s\n", entry, s.Repo)
+				left = append(left, entry)
+				continue
+			}
+			if cfg.DryRun {
+				fmt.Printf("[DRY] Would re-enable policy %s of %s/%s/%s\n", entry, org, project, name)
+				left = append(left, entry)
+				continue
+			}
+			p, err := getPolicy(ctx, org, project, cfg.DstPAT, id, cfg.Trace)
+			if err == nil {
+				err = setPolicyEnabled(ctx, org, project, cfg.DstPAT, p, true, cfg.Trace)
+			}
+			recordAudit(cfg, AuditEvent{Action: AuditPolicyEnable, Org: org, Project: project, Repo: name, RepoID: s.DstRepoID,
+				Result: auditResult(err), Details: fmt.Sprintf("policy %s, left disabled by a previous run", entry)})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Re-enabling policy %s of %s failed: %v\n", entry, name, err)
+				left = append(left, entry)
+				continue
+			}
+			fmt.Printf("Re-enabled policy %s of %s\n", entry, name)
+		}
+		if !cfg.DryRun {
+			s.PoliciesLeftOff = left
+		}
+		failed += len(left)
+	}
+	return failed
+}
@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestRepoScoped(t *testing.T) {
+	const repoID = "0a1b2c3d-0000-0000-0000-000000000001"
+	tests := []struct {
+		name  string
+		scope []PolicyScope
+		want  bool
+	}{
+		{"no scope", nil, false},
+		{"repository branch", []PolicyScope{{RepositoryID: repoID, RefName: "refs/heads/main", MatchKind: "Exact"}}, true},
+		{"repository, other case", []PolicyScope{{RepositoryID: "0A1B2C3D-0000-0000-0000-000000000001"}}, true},
+		{"project-wide", []PolicyScope{{RefName: "refs/heads/main", MatchKind: "Exact"}}, false},
+		{"other repository", []PolicyScope{{RepositoryID: "ffffffff-0000-0000-0000-000000000002"}}, false},
+		{"cross-repository", []PolicyScope{{RepositoryID: repoID}, {RepositoryID: "ffffffff-0000-0000-0000-000000000002"}}, false},
+		{"repository and project-wide", []PolicyScope{{RepositoryID: repoID}, {RefName: "refs/heads/release"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p PolicyConfiguration
+			p.Settings.Scope = tt.scope
+			if got := repoScoped(p, repoID); got != tt.want {
+				t.Errorf("repoScoped() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectWide(t *testing.T) {
+	const repoID = "0a1b2c3d-0000-0000-0000-000000000001"
+	tests := []struct {
+		name  string
+		scope []PolicyScope
+		want  bool
+	}{
+		{"no scope", nil, true},
+		{"project branch", []PolicyScope{{RefName: "refs/heads/main", MatchKind: "Exact"}}, true},
+		{"repository branch", []PolicyScope{{RepositoryID: repoID, RefName: "refs/heads/main"}}, false},
+		{"repository and project-wide", []PolicyScope{{RepositoryID: repoID}, {RefName: "refs/heads/release"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p PolicyConfiguration
+			p.Settings.Scope = tt.scope
+			if got := projectWide(p); got != tt.want {
+				t.Errorf("projectWide() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// progressHeartbeat is how often the progress file is rewritten even when nothing changes.
+const progressHeartbeat = 30 * time.Second
+
+// Progress is the content of the --progress-file, read by external watchdogs:
+// a stale Heartbeat means the process is gone or stuck, a stale UpdatedAt with a
+// fresh Heartbeat means the current step (e.g. a clone) is taking long or hung.
+type Progress struct {
+	PID       int       `json:"pid"`
+	Hostname  string    `json:"hostname"`
+	StartedAt time.Time `json:"startedAt"`
+	UpdatedAt time.Time `json:"updatedAt"` // last progress: new repository or phase
+	Heartbeat time.Time `json:"heartbeat"` // refreshed every 30s while the process is alive
+	Repo      string    `json:"repo,omitempty"`
+	Index     int       `json:"index"` // 1-based index of the current repository
+	Total     int       `json:"total"`
+	Phase     string    `json:"phase,omitempty"`
+	Done      bool      `json:"done"`
+}
+
+// progressWriter keeps the progress file up to date; the zero/nil value does nothing.
+type progressWriter struct {
+	mu    sync.Mutex
+	path  string
+	state Progress
+	stop  chan struct{}
+}
+
+// progress is the progress file of the current run (nil without --progress-file).
+var progress *progressWriter
+
+// startProgress creates the progress file and starts the heartbeat.
+func startProgress(path string) *progressWriter {
+	hostname, _ := os.Hostname()
+	now := time.Now()
+	p := &progressWriter{
+		path:  path,
+		state: Progress{PID: os.Getpid(), Hostname: hostname, StartedAt: now, UpdatedAt: now},
+		stop:  make(chan struct{}),
+	}
+	p.write()
+	go func() {
+		t := time.NewTicker(progressHeartbeat)
+		defer t.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-t.C:
+				p.mu.Lock()
+				p.write()
+				p.mu.Unlock()
+			}
+		}
+	}()
+	return p
+}
+
+// repo records the repository being processed.
+func (p *progressWriter) repo(name string, index, total int) {
+	p.update(func(s *Progress) {
+		s.Repo, s.Index, s.Total, s.Phase = name, index, total, ""
+	})
+}
+
+// phase records the phase of the current repository.
+func (p *progressWriter) phase(phase string) {
+	p.update(func(s *Progress) { s.Phase = phase })
+}
+
+// finish marks the run as completed and stops the heartbeat.
+func (p *progressWriter) finish() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	p.update(func(s *Progress) {
+		s.Done, s.Phase = true, ""
+	})
+}
+
+// update changes the state and rewrites the file.
+func (p *progressWriter) update(fn func(*Progress)) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fn(&p.state)
+	p.state.UpdatedAt = time.Now()
+	p.write()
+}
+
+// write saves the state under a temporary name and renames it, so readers never see
+// a partial file. Must be called with p.mu held.
+func (p *progressWriter) write() {
+	p.state.Heartbeat = time.Now()
+	data, err := json.MarshalIndent(p.state, "", "  ")
+	if err != nil {
+		return
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err == nil {
+		err = os.Rename(tmp, p.path)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Progress file error:", err)
+	}
+}
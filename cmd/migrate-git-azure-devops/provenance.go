@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Modes of --provenance.
+const (
+	ProvenanceTag  = "tag"  // annotated tag on the default branch head
+	ProvenanceNote = "note" // git note on the default branch head, in provenanceNotesRef
+)
+
+// provenanceNotesRef is the notes ref holding the provenance notes.
+const provenanceNotesRef = "refs/notes/migration"
+
+// runID identifies the run in the provenance stamps and in the report.
+var runID = fmt.Sprintf("%s-%06x", time.Now().UTC().Format("20060102T150405Z"), rand.Uint32()&0xffffff)
+
+// provenanceMessage is the machine-readable provenance of a migrated repository, as git
+// trailers ("git interpret-trailers --parse").
+func provenanceMessage(cfg Config, sum Summary, now time.Time) string {
+	host, _ := os.Hostname()
+	var b strings.Builder
+	b.WriteString("Migration provenance\n\n")
+	fmt.Fprintf(&b, "Source: %s\n", sum.SrcWebURL)
+	fmt.Fprintf(&b, "Source-Repo-Id: %s\n", sum.SrcRepoID)
+	fmt.Fprintf(&b, "Destination: %s\n", sum.DstWebURL)
+	fmt.Fprintf(&b, "Run-Id: %s\n", runID)
+	fmt.Fprintf(&b, "Tool: %s %s (%s)\n", prog(), version, commit)
+	fmt.Fprintf(&b, "Operator: %s@%s\n", operator(), host)
+	fmt.Fprintf(&b, "Migrated-At: %s\n", now.UTC().Format(time.RFC3339))
+	return b.String()
+}
+
+// provenanceTagName expands the {date} and {run} placeholders of --provenance-tag.
+func provenanceTagName(pattern string, now time.Time) string {
+	return strings.NewReplacer("{date}", now.UTC().Format("2006-01-02"), "{run}", runID).Replace(pattern)
+}
+
+// stampProvenance records the provenance on the default branch head of the mirror, as an
+// annotated (optionally signed) tag or as a git note, and pushes it to the destination
+// after the mirror push. The history is not changed. Returns the ref pushed, or "" for
+// repositories without commits.
+func stampProvenance(ctx context.Context, cfg Config, repoDir, dstURL string, dstEnv []string, sum Summary) (string, error) {
+	head, err := exec.CommandContext(ctx, "git", "-C", repoDir, "rev-parse", "--verify", "-q", "HEAD^{commit}").Output()
+	if err != nil {
+		return "", nil // empty repository
+	}
+	target := strings.TrimSpace(string(head))
+	now := time.Now()
+	msg := provenanceMessage(cfg, sum, now)
+
+	// Signed tags use the signing identity of the operator, the rest the tool identity
+	var identity []string
+	if !cfg.ProvenanceSign {
+		identity = []string{
+			"GIT_AUTHOR_NAME=" + prog(), "GIT_AUTHOR_EMAIL=" + prog() + "@noreply",
+			"GIT_COMMITTER_NAME=" + prog(), "GIT_COMMITTER_EMAIL=" + prog() + "@noreply",
+		}
+	}
+
+	var ref string
+	switch cfg.Provenance {
+	case ProvenanceTag:
+		name := provenanceTagName(cfg.ProvenanceTag, now)
+		ref = "refs/tags/" + name
+		args := []string{"-C", repoDir, "tag", "-a", "-f", "-m", msg}
+		if cfg.ProvenanceSign {
+			args = append(args, "-s")
+		}
+		if err := runCmd(ctx, identity, "git", append(args, name, target)...); err != nil {
+			return "", fmt.Errorf("creating tag %s: %w", name, err)
+		}
+	case ProvenanceNote:
+		ref = provenanceNotesRef
+		if err := runCmd(ctx, identity, "git", "-C", repoDir, "notes", "--ref", provenanceNotesRef, "add", "-f", "-m", msg, target); err != nil {
+			return "", fmt.Errorf("adding the note: %w", err)
+		}
+	}
+	if err := runCmd(ctx, dstEnv, "git", "-C", repoDir, "push", dstURL, "+"+ref+":"+ref); err != nil {
+		return "", fmt.Errorf("pushing %s: %w", ref, err)
+	}
+	return ref, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// SourceProvider is the hosting service the repositories are migrated from.
+type SourceProvider interface {
+	// ListRepos returns the repositories to migrate.
+	ListRepos(ctx context.Context) ([]Repo, error)
+	// CloneURL returns the git remote of a repository and the environment git needs to
+	// authenticate against it (the credentials are never in the URL).
+	CloneURL(name string) (string, []string)
+}
+
+// DestinationProvider is the hosting service the repositories are migrated to.
+type DestinationProvider interface {
+	// ListRepos returns the repositories already present.
+	ListRepos(ctx context.Context) ([]Repo, error)
+	// RepoExists reports whether a repository exists.
+	RepoExists(ctx context.Context, name string) (bool, error)
+	// CreateRepo creates an empty repository.
+	CreateRepo(ctx context.Context, name string) (Repo, error)
+	// CloneURL returns the git remote of a repository and the environment of git, as for
+	// SourceProvider.
+	CloneURL(name string) (string, []string)
+	// WebURL returns the address of a repository in the browser.
+	WebURL(name string) string
+}
+
+// azureDevOps is a project of an Azure DevOps organization, as source or destination.
+// Protocol and SSH key of the remotes come from cfg.
+type azureDevOps struct {
+	cfg     Config
+	org     string
+	project string
+	token   string
+}
+
+// newSourceProvider returns the provider of the source project.
+func newSourceProvider(cfg Config) SourceProvider {
+	return azureDevOps{cfg: cfg, org: cfg.SrcOrg, project: cfg.SrcProject, token: cfg.SrcPAT}
+}
+
+// newDestinationProvider returns the provider of the destination project.
+func newDestinationProvider(cfg Config) DestinationProvider {
+	return azureDevOps{cfg: cfg, org: cfg.DstOrg, project: cfg.DstProject, token: cfg.DstPAT}
+}
+
+func (p azureDevOps) ListRepos(ctx context.Context) ([]Repo, error) {
+	return getRepos(ctx, p.org, p.project, p.token, p.cfg.Trace)
+}
+
+func (p azureDevOps) RepoExists(ctx context.Context, name string) (bool, error) {
+	path := fmt.Sprintf("_apis/git/repositories/%s?api-version=%s", url.PathEscape(name), apiVersion)
+	body, code, err := httpReq(ctx, "GET", p.org, p.project, path, p.token, nil, p.cfg.Trace)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case code == http.StatusNotFound:
+		return false, nil
+	case code < 200 || code >= 300:
+		return false, fmt.Errorf("API error (HTTP %d): %s", code, string(body))
+	}
+	return true, nil
+}
+
+func (p azureDevOps) CreateRepo(ctx context.Context, name string) (Repo, error) {
+	return createRepo(ctx, p.org, p.project, p.token, name, p.cfg.Trace)
+}
+
+func (p azureDevOps) CloneURL(name string) (string, []string) {
+	return gitRemote(p.cfg, p.org, url.PathEscape(p.project), url.PathEscape(name), p.token)
+}
+
+func (p azureDevOps) WebURL(name string) string {
+	return fmt.Sprintf("https://dev.azure.com/%s/%s/_git/%s", p.org, url.PathEscape(p.project), url.PathEscape(name))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// proxyEnv holds the proxy variables passed to git subprocesses when --proxy/--no-proxy
+// are given: --proxy overrides the proxy settings of the environment, --no-proxy alone
+// adds its entries to the NO_PROXY of the environment.
+var proxyEnv []string
+
+// configureProxy applies --proxy/--no-proxy to the shared HTTP client and to git.
+// Without flags nothing changes and the environment settings keep applying; with
+// --no-proxy only, the proxy of the environment keeps applying to the other hosts.
+func configureProxy(proxy, noProxy string) error {
+	if proxy == "" && noProxy == "" {
+		return nil
+	}
+	var proxyURL *url.URL
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid --proxy URL: %s", proxy)
+		}
+		proxyURL = u
+	}
+	bypass := splitNoProxy(noProxy)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if noProxyMatch(req.URL.Hostname(), bypass) {
+			return nil, nil
+		}
+		if proxyURL == nil {
+			return http.ProxyFromEnvironment(req)
+		}
+		return proxyURL, nil
+	}
+	httpClient.Transport = transport
+
+	if proxy == "" {
+		env := os.Getenv("NO_PROXY")
+		if env == "" {
+			env = os.Getenv("no_proxy")
+		}
+		noProxy = mergeNoProxy(env, noProxy)
+	} else {
+		for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+			proxyEnv = append(proxyEnv, name+"="+proxy)
+		}
+	}
+	proxyEnv = append(proxyEnv, "NO_PROXY="+noProxy, "no_proxy="+noProxy)
+	return nil
+}
+
+// mergeNoProxy appends to a no-proxy list the entries of extra it does not hold yet.
+func mergeNoProxy(list, extra string) string {
+	entries := splitNoProxy(list)
+	for _, p := range splitNoProxy(extra) {
+		if !slices.Contains(entries, p) {
+			entries = append(entries, p)
+		}
+	}
+	return strings.Join(entries, ",")
+}
+
+// splitNoProxy parses a comma separated no-proxy list.
+func splitNoProxy(list string) []string {
+	var out []string
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// noProxyMatch reports whether host must be reached directly, using the usual
+// NO_PROXY semantics: "*" matches everything, "example.com" and ".example.com"
+// match the domain and its subdomains, IPs and CIDR ranges match addresses.
+func noProxyMatch(host string, bypass []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, p := range bypass {
+		switch {
+		case p == "*":
+			return true
+		case strings.Contains(p, "/"):
+			if _, cidr, err := net.ParseCIDR(p); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		default:
+			p = strings.TrimPrefix(p, ".")
+			if host == p || strings.HasSuffix(host, "."+p) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestNoProxyMatch(t *testing.T) {
+	bypass := splitNoProxy(" .Corp.Local, dev.azure.com,10.0.0.0/8,,192.168.1.5/32 ")
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"corp.local", true},
+		{"git.corp.local", true},
+		{"GIT.CORP.LOCAL", true},
+		{"notcorp.local", false},
+		{"dev.azure.com", true},
+		{"ssh.dev.azure.com", true},
+		{"vssps.visualstudio.com", false},
+		{"10.1.2.3", true},
+		{"11.1.2.3", false},
+		{"192.168.1.5", true},
+		{"192.168.1.6", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := noProxyMatch(tt.host, bypass); got != tt.want {
+				t.Errorf("noProxyMatch(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+	if !noProxyMatch("anything.example", []string{"*"}) {
+		t.Error(`"*" does not match every host`)
+	}
+}
+
+func TestMergeNoProxy(t *testing.T) {
+	tests := []struct {
+		list, extra, want string
+	}{
+		{"", "", ""},
+		{"localhost,.corp.local", "", "localhost,.corp.local"},
+		{"", "10.0.0.0/8", "10.0.0.0/8"},
+		{"localhost, .Corp.local", ".corp.local,10.0.0.0/8", "localhost,.corp.local,10.0.0.0/8"},
+	}
+	for _, tt := range tests {
+		if got := mergeNoProxy(tt.list, tt.extra); got != tt.want {
+			t.Errorf("mergeNoProxy(%q, %q) = %q, want %q", tt.list, tt.extra, got, tt.want)
+		}
+	}
+}
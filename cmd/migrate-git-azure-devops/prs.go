@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// maxPRDescription is the longest pull request description accepted by Azure DevOps.
+const maxPRDescription = 4000
+
+// PullRequest is an active pull request of a repository.
+type PullRequest struct {
+	ID            int    `json:"pullRequestId"`
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+	SourceRefName string `json:"sourceRefName"`
+	TargetRefName string `json:"targetRefName"`
+	IsDraft       bool   `json:"isDraft"`
+	CreatedBy     struct {
+		DisplayName string `json:"displayName"`
+		UniqueName  string `json:"uniqueName"`
+	} `json:"createdBy"`
+	Reviewers []struct {
+		DisplayName string `json:"displayName"`
+		UniqueName  string `json:"uniqueName"`
+		Descriptor  string `json:"descriptor"`
+		IsRequired  bool   `json:"isRequired"`
+		IsContainer bool   `json:"isContainer"`
+	} `json:"reviewers"`
+}
+
+// getActivePullRequests returns the active pull requests of a repository (ID or name).
+func getActivePullRequests(ctx context.Context, org, project, pat, repo string, trace bool) ([]PullRequest, error) {
+	const top = 100
+	var prs []PullRequest
+	for skip := 0; ; skip += top {
+		path := fmt.Sprintf("_apis/git/repositories/%s/pullrequests?searchCriteria.status=active&$top=%d&$skip=%d&api-version=%s",
+			url.PathEscape(repo), top, skip, apiVersion)
+		body, code, err := httpReq(ctx, "GET", org, project, path, pat, nil, trace)
+		if err != nil {
+			return nil, err
+		}
+		if code < 200 || code >= 300 {
+			return nil, fmt.Errorf("API error listing pull requests (HTTP %d): %s", code, string(body))
+		}
+		var resp struct {
+			Value []PullRequest `json:"value"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("invalid response: %w", err)
+		}
+		prs = append(prs, resp.Value...)
+		if len(resp.Value) < top {
+			return prs, nil
+		}
+	}
+}
+
+// createPullRequest creates a pull request and returns its ID.
+func createPullRequest(ctx context.Context, org, project, pat, repo string, payload any, trace bool) (int, error) {
+	path := fmt.Sprintf("_apis/git/repositories/%s/pullrequests?api-version=%s", url.PathEscape(repo), apiVersion)
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding payload: %w", err)
+	}
+	body, code, err := httpReq(ctx, "POST", org, project, path, pat, data, trace)
+	if err != nil {
+		return 0, err
+	}
+	if code != 200 && code != 201 {
+		return 0, fmt.Errorf("API error creating pull request (HTTP %d): %s", code, string(body))
+	}
+	var created PullRequest
+	if err := json.Unmarshal(body, &created); err != nil {
+		return 0, fmt.Errorf("invalid response: %w", err)
+	}
+	return created.ID, nil
+}
+
+// migratedBranch returns the destination name of a source branch ref, after --ref-rename,
+// and whether it was pushed (it is among the branches of the mirror).
+func migratedBranch(cfg Config, ref string, pushed []string) (string, bool) {
+	branch := strings.TrimPrefix(ref, "refs/heads/")
+	for _, r := range cfg.RefRenameRules {
+		branch = r.apply(branch)
+	}
+	return "refs/heads/" + branch, slices.Contains(pushed, branch)
+}
+
+// migrateOpenPRs recreates the active pull requests of the source repository on the
+// destination one (--migrate-open-prs): title, description, branches (renamed like the
+// push), draft state and reviewers (users and groups) mapped with --identity-map. Pull requests whose
+// branches were not migrated, or already open at destination, are listed with the reason
+// in sum.PRsNotRecreated. Comments, votes and history are not migrated.
+func migrateOpenPRs(ctx context.Context, cfg Config, r Repo, dstRepoName string, sum *Summary) {
+	prs, err := getActivePullRequests(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, r.ID, cfg.Trace)
+	if err != nil {
+		sum.PRsNotRecreated = append(sum.PRsNotRecreated, "listing the source pull requests failed: "+err.Error())
+		return
+	}
+	if len(prs) == 0 {
+		return
+	}
+	existing, err := getActivePullRequests(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, dstRepoName, cfg.Trace)
+	if err != nil {
+		sum.PRsNotRecreated = append(sum.PRsNotRecreated, "listing the destination pull requests failed: "+err.Error())
+		return
+	}
+	open := map[string]int{}
+	for _, p := range existing {
+		open[p.SourceRefName+" "+p.TargetRefName] = p.ID
+	}
+
+	for _, pr := range prs {
+		label := fmt.Sprintf("#%d %s", pr.ID, pr.Title)
+		source, okSource := migratedBranch(cfg, pr.SourceRefName, sum.BranchNames)
+		target, okTarget := migratedBranch(cfg, pr.TargetRefName, sum.BranchNames)
+		if !okSource || !okTarget {
+			sum.PRsNotRecreated = append(sum.PRsNotRecreated, label+": branch not migrated (filtered out or deleted)")
+			continue
+		}
+		if id, ok := open[source+" "+target]; ok {
+			sum.PRsNotRecreated = append(sum.PRsNotRecreated, fmt.Sprintf("%s: already open at destination as #%d", label, id))
+			continue
+		}
+
+		type reviewer struct {
+			ID         string `json:"id"`
+			IsRequired bool   `json:"isRequired"`
+		}
+		reviewers := []reviewer{}
+		var unresolved []string
+		for _, rv := range pr.Reviewers {
+			id, err := resolveIdentityID(ctx, cfg, mapIdentity(cfg, rv.UniqueName, rv.Descriptor))
+			if err != nil || id == "" {
+				if rv.IsContainer {
+					unresolved = append(unresolved, rv.DisplayName+" (group)")
+				} else {
+					unresolved = append(unresolved, rv.UniqueName)
+				}
+				continue
+			}
+			reviewers = append(reviewers, reviewer{ID: id, IsRequired: rv.IsRequired})
+		}
+
+		srcURL := fmt.Sprintf("https://dev.azure.com/%s/%s/_git/%s/pullrequest/%d",
+			cfg.SrcOrg, url.PathEscape(cfg.SrcProject), url.PathEscape(r.Name), pr.ID)
+		footer := fmt.Sprintf("\n\n---\nMigrated from %s (opened by %s).", srcURL, pr.CreatedBy.DisplayName)
+		if len(unresolved) > 0 {
+			footer += "\nReviewers not found at destination: " + strings.Join(unresolved, ", ")
+		}
+		description := pr.Description
+		if len(description)+len(footer) > maxPRDescription {
+			description = description[:max(0, maxPRDescription-len(footer)-3)] + "..."
+		}
+		payload := map[string]any{
+			"title":         pr.Title,
+			"description":   description + footer,
+			"sourceRefName": source,
+			"targetRefName": target,
+			"isDraft":       pr.IsDraft,
+			"reviewers":     reviewers,
+		}
+		id, err := createPullRequest(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, dstRepoName, payload, cfg.Trace)
+		if err != nil {
+			sum.PRsNotRecreated = append(sum.PRsNotRecreated, label+": "+err.Error())
+			continue
+		}
+		sum.PullRequests = append(sum.PullRequests, fmt.Sprintf("#%d -> #%d %s", pr.ID, id, pr.Title))
+		fmt.Printf("  Pull request %s recreated as #%d\n", label, id)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RenameRule is a sed-like substitution applied to destination repository or branch names.
+type RenameRule struct {
+	expr   string // as given, in sed syntax
+	re     *regexp.Regexp
+	repl   string
+	global bool
+}
+
+// MarshalText writes the rule in sed syntax, for the effective configuration of the report
+// and the plan file.
+func (r RenameRule) MarshalText() ([]byte, error) {
+	return []byte(r.expr), nil
+}
+
+// UnmarshalText reads a rule written by MarshalText, e.g. the --ref-rename rules of a plan.
+func (r *RenameRule) UnmarshalText(text []byte) error {
+	rule, err := parseRenameRegex("rule", string(text))
+	if err != nil {
+		return err
+	}
+	*r = rule
+	return nil
+}
+
+// parseRenameRegex parses a substitution in sed syntax: s/regex/replacement/[gi].
+// Any character following "s" is the delimiter; it can be escaped with a backslash.
+// The replacement uses Go syntax for groups ($1, ${name}). flag names the option in errors.
+func parseRenameRegex(flag, expr string) (RenameRule, error) {
+	if len(expr) < 2 || expr[0] != 's' {
+		return RenameRule{}, fmt.Errorf("invalid %s %q: expected s/regex/replacement/[gi]", flag, expr)
+	}
+	delim := expr[1]
+	var parts []string
+	var cur strings.Builder
+	for i := 2; i < len(expr); i++ {
+		c := expr[i]
+		if c == '\\' && i+1 < len(expr) && expr[i+1] == delim {
+			if len(parts) == 0 {
+				// Literal in the regex too, also when the delimiter is a metacharacter (s|a\|b|c|)
+				cur.WriteString(regexp.QuoteMeta(string(delim)))
+			} else {
+				cur.WriteByte(delim)
+			}
+			i++
+			continue
+		}
+		if c == delim {
+			parts = append(parts, cur.String())
+			cur.Reset()
+			continue
+		}
+		cur.WriteByte(c)
+	}
+	parts = append(parts, cur.String())
+	if len(parts) != 3 {
+		return RenameRule{}, fmt.Errorf("invalid %s %q: expected s/regex/replacement/[gi]", flag, expr)
+	}
+	pattern, flags := parts[0], parts[2]
+	rule := RenameRule{expr: expr, repl: parts[1]}
+	for _, f := range flags {
+		switch f {
+		case 'g':
+			rule.global = true
+		case 'i':
+			pattern = "(?i)" + pattern
+		default:
+			return RenameRule{}, fmt.Errorf("invalid %s %q: unknown flag %q", flag, expr, f)
+		}
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return RenameRule{}, fmt.Errorf("invalid %s %q: %w", flag, expr, err)
+	}
+	rule.re = re
+	return rule, nil
+}
+
+// apply runs the substitution on a name: first match only, all matches with the g flag.
+func (r RenameRule) apply(name string) string {
+	if r.global {
+		return r.re.ReplaceAllString(name, r.repl)
+	}
+	loc := r.re.FindStringSubmatchIndex(name)
+	if loc == nil {
+		return name
+	}
+	out := r.re.ExpandString(nil, r.repl, name, loc)
+	return name[:loc[0]] + string(out) + name[loc[1]:]
+}
+
+// renameDestination applies the declarative transforms to a destination name, in order:
+// regex substitutions, lowercase, prefix, suffix.
+func renameDestination(cfg Config, name string) string {
+	for _, r := range cfg.RenameRules {
+		name = r.apply(name)
+	}
+	if cfg.RenameLowercase {
+		name = strings.ToLower(name)
+	}
+	return cfg.RenamePrefix + name + cfg.RenameSuffix
+}
+
+// normalizeBranches renames the branches of a local mirror with the --ref-rename rules,
+// so the mirror push creates them with the destination naming convention (e.g. Feature/x
+// -> feature/x). It returns the renames as "old -> new". Two branches mapped to the same
+// name, or a rename onto an existing branch, fail instead of overwriting a branch.
+func normalizeBranches(ctx context.Context, repoDir string, rules []RenameRule) ([]string, error) {
+	refs, err := localRefs(ctx, repoDir)
+	if err != nil {
+		return nil, err
+	}
+	var branches []string
+	for ref := range refs {
+		if strings.HasPrefix(ref, "refs/heads/") {
+			branches = append(branches, strings.TrimPrefix(ref, "refs/heads/"))
+		}
+	}
+	sort.Strings(branches)
+
+	renames := map[string]string{}
+	targets := map[string]string{}
+	for _, b := range branches {
+		name := b
+		for _, r := range rules {
+			name = r.apply(name)
+		}
+		if name == b {
+			continue
+		}
+		if name == "" {
+			return nil, fmt.Errorf("branch %s renamed to an empty name", b)
+		}
+		if prev, ok := targets[name]; ok {
+			return nil, fmt.Errorf("branches %s and %s both renamed to %s", prev, b, name)
+		}
+		targets[name] = b
+		renames[b] = name
+	}
+	for name, b := range targets {
+		if _, exists := refs["refs/heads/"+name]; exists && renames[name] == "" {
+			return nil, fmt.Errorf("branch %s renamed to %s, which already exists", b, name)
+		}
+	}
+
+	// Delete all the old names first: old and new names may clash as folders
+	// (Feature vs Feature/x) or swap between branches
+	var renamed []string
+	for _, b := range branches {
+		if _, ok := renames[b]; !ok {
+			continue
+		}
+		if err := runCmd(ctx, nil, "git", "-C", repoDir, "update-ref", "-d", "refs/heads/"+b); err != nil {
+			return nil, err
+		}
+	}
+	for _, b := range branches {
+		name, ok := renames[b]
+		if !ok {
+			continue
+		}
+		if err := runCmd(ctx, nil, "git", "-C", repoDir, "update-ref", "refs/heads/"+name, refs["refs/heads/"+b]); err != nil {
+			return renamed, err
+		}
+		renamed = append(renamed, b+" -> "+name)
+	}
+	return renamed, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestRefRenameRuleApply(t *testing.T) {
+	tests := []struct {
+		expr string
+		name string
+		want string
+	}{
+		{"s/^Feature\\//feature\\//", "Feature/login", "feature/login"},
+		{"s/^Feature\\//feature\\//", "bugfix/Feature/x", "bugfix/Feature/x"},
+		{"s#^feature/#feat/#", "feature/a/feature/b", "feat/a/feature/b"},
+		{"s#feature#feat#", "feature/feature", "feat/feature"},
+		{"s#feature#feat#g", "feature/feature", "feat/feat"},
+		{"s#^(bug|hot)fix/#fix/$1-#", "hotfix/crash", "fix/hot-crash"},
+		{"s#^(?P<kind>[a-z]+)_#${kind}/#", "release_1.2", "release/1.2"},
+		{"s#^master$#main#", "master", "main"},
+		{"s#^master$#main#", "master-old", "master-old"},
+		{"s#^FEATURE/#feature/#i", "Feature/x", "feature/x"},
+		{"s#_#-#g", "a_b_c", "a-b-c"},
+		{"s#^old/##", "old/thing", "thing"},
+		{"s|a\\|b|c|", "a|b", "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr+" "+tt.name, func(t *testing.T) {
+			rule, err := parseRenameRegex("--ref-rename", tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rule.apply(tt.name); got != tt.want {
+				t.Errorf("apply(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRenameRegexErrors(t *testing.T) {
+	for _, expr := range []string{"", "s", "x/a/b/", "s/a/b", "s/a/b/c/d", "s/a/b/x", "s/(/b/"} {
+		if _, err := parseRenameRegex("--ref-rename", expr); err == nil {
+			t.Errorf("parseRenameRegex(%q): no error", expr)
+		}
+	}
+}
+
+func TestRenameRuleText(t *testing.T) {
+	for _, expr := range []string{"s#^feature/#feat/#g", "s/^Feature\\//feature\\//", "s|a\\|b|c|i", "s#x\\#y#z#"} {
+		rule, err := parseRenameRegex("--ref-rename", expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, err := rule.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back RenameRule
+		if err := back.UnmarshalText(text); err != nil {
+			t.Fatalf("UnmarshalText(%q): %v", text, err)
+		}
+		for _, name := range []string{"feature/a", "Feature/a", "a|b", "x#y", "A|B"} {
+			if got, want := back.apply(name), rule.apply(name); got != want {
+				t.Errorf("%s read back: apply(%q) = %q, want %q", expr, name, got, want)
+			}
+		}
+	}
+}
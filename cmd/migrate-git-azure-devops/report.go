@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// newReportCmd returns the "report" command: prints the summary of a JSON report written
+// by a previous run and renders it again (e.g. as HTML), without contacting Azure DevOps.
+func newReportCmd() *cobra.Command {
+	var format, output string
+	cmd := &cobra.Command{
+		Use:   "report <report.json>",
+		Short: "Print the summary of a JSON report of a previous run and render it as HTML or JSON",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := loadReport(args[0])
+			if err != nil {
+				return err
+			}
+			printSummary(report.Summaries)
+			if format == "" {
+				return nil
+			}
+			format = strings.ToLower(format)
+			if format != "json" && format != "html" {
+				return fmt.Errorf("unsupported report format: %s (only json, html are allowed)", format)
+			}
+			if output == "" {
+				output = strings.TrimSuffix(args[0], filepath.Ext(args[0])) + "." + format
+			}
+			if filepath.Clean(output) == filepath.Clean(args[0]) {
+				return fmt.Errorf("--output would overwrite the report read: %s", output)
+			}
+			if err := generateReport(report, format, output); err != nil {
+				return err
+			}
+			fmt.Printf("Report (%s) written to %s\n", format, output)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "", "Render the report again: html or json (default: summary only)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File written with --format (default: the report name with the format extension)")
+	cmd.AddCommand(newReportSchemaCmd(), newReportMergeCmd(), newReportDiffCmd())
+	return cmd
+}
+
+// loadReport reads a JSON report written by a previous run, warning when its layout is newer
+// than the one known. Results are classified again for the reports written before the codes.
+func loadReport(file string) (Report, error) {
+	var report Report
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return report, fmt.Errorf("reading the report: %w", err)
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, fmt.Errorf("invalid report %s: %w", file, err)
+	}
+	if report.SchemaVersion > reportSchemaVersion {
+		fmt.Fprintf(os.Stderr, "WARNING: %s has schemaVersion %d, newer than the one known (%d): upgrade the tool, some fields may be ignored\n",
+			file, report.SchemaVersion, reportSchemaVersion)
+	}
+	classifyResults(report.Summaries)
+	return report, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// States of a repository compared by report diff.
+const (
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateSkipped   = "skipped"
+	StateDryRun    = "dry-run"
+)
+
+// resultState returns the state of a repository in a report: failed on errors, skipped when
+// left out of the run, succeeded otherwise (migrated, synced, verified, exported).
+func resultState(s Summary) string {
+	switch {
+	case strings.HasPrefix(s.Result, "ERROR"):
+		return StateFailed
+	case s.Result == "DRY-RUN":
+		return StateDryRun
+	case s.Skipped || strings.HasPrefix(s.Result, "SKIPPED") || s.Result == ResultSourceRemoved:
+		return StateSkipped
+	}
+	return StateSucceeded
+}
+
+// ReportDelta groups the repositories of two reports by how their state changed.
+type ReportDelta struct {
+	NewlySucceeded []string
+	NewlyFailed    []string
+	StillFailed    []string
+	NewlySkipped   []string
+	StillSkipped   []string
+	Other          []string // dry-runs and changes between succeeded states
+	OnlyInOld      []string
+	Unchanged      int // succeeded in both, same result
+}
+
+// diffReports compares the results of the repositories (matched by name) of two reports.
+// Every entry is "repo: old result -> new result", or "repo: result" when unchanged.
+func diffReports(oldRep, newRep Report) ReportDelta {
+	var d ReportDelta
+	before := map[string]Summary{}
+	for _, s := range oldRep.Summaries {
+		before[strings.ToLower(s.Repo)] = s
+	}
+	seen := map[string]bool{}
+	for _, s := range newRep.Summaries {
+		key := strings.ToLower(s.Repo)
+		seen[key] = true
+		o, existed := before[key]
+		oldState, newState := "", resultState(s)
+		entry := s.Repo + ": " + s.Result
+		if existed {
+			oldState = resultState(o)
+			if o.Result != s.Result {
+				entry = s.Repo + ": " + o.Result + " -> " + s.Result
+			}
+		} else {
+			entry = s.Repo + ": (new) -> " + s.Result
+		}
+		switch {
+		case newState == StateSucceeded && oldState == StateSucceeded && o.Result == s.Result:
+			d.Unchanged++
+		case newState == StateSucceeded && oldState != StateSucceeded:
+			d.NewlySucceeded = append(d.NewlySucceeded, entry)
+		case newState == StateFailed && oldState == StateFailed:
+			d.StillFailed = append(d.StillFailed, entry)
+		case newState == StateFailed:
+			d.NewlyFailed = append(d.NewlyFailed, entry)
+		case newState == StateSkipped && oldState == StateSkipped:
+			d.StillSkipped = append(d.StillSkipped, entry)
+		case newState == StateSkipped:
+			d.NewlySkipped = append(d.NewlySkipped, entry)
+		default:
+			d.Other = append(d.Other, entry)
+		}
+	}
+	for _, s := range oldRep.Summaries {
+		if !seen[strings.ToLower(s.Repo)] {
+			d.OnlyInOld = append(d.OnlyInOld, s.Repo+": "+s.Result)
+		}
+	}
+	for _, list := range [][]string{d.NewlySucceeded, d.NewlyFailed, d.StillFailed, d.NewlySkipped, d.StillSkipped, d.Other, d.OnlyInOld} {
+		sort.Slice(list, func(i, j int) bool { return strings.ToLower(list[i]) < strings.ToLower(list[j]) })
+	}
+	return d
+}
+
+// describeRun returns "file (run ID, end time)" for the header of report diff.
+func describeRun(file string, r Report) string {
+	var parts []string
+	if r.RunID != "" {
+		parts = append(parts, r.RunID)
+	}
+	if !r.EndTime.IsZero() {
+		parts = append(parts, r.EndTime.Local().Format("2006-01-02 15:04"))
+	}
+	if len(parts) == 0 {
+		return file
+	}
+	return file + " (" + strings.Join(parts, ", ") + ")"
+}
+
+// newReportDiffCmd returns the "report diff" command: the repositories whose state changed
+// between two runs, e.g. before and after fixing the failures of a wave.
+func newReportDiffCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff <old.json> <new.json>",
+		Short: "Show the repositories whose result changed between the JSON reports of two runs",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			oldRep, err := loadReport(args[0])
+			if err != nil {
+				return err
+			}
+			newRep, err := loadReport(args[1])
+			if err != nil {
+				return err
+			}
+			d := diffReports(oldRep, newRep)
+			fmt.Printf("%s -> %s\n", describeRun(args[0], oldRep), describeRun(args[1], newRep))
+			for _, g := range []struct {
+				title string
+				repos []string
+			}{
+				{"Newly succeeded", d.NewlySucceeded},
+				{"Newly failed", d.NewlyFailed},
+				{"Still failed", d.StillFailed},
+				{"Newly skipped", d.NewlySkipped},
+				{"Still skipped", d.StillSkipped},
+				{"Other changes", d.Other},
+				{"Not in the new report", d.OnlyInOld},
+			} {
+				if len(g.repos) == 0 {
+					continue
+				}
+				fmt.Printf("\n%s (%d):\n", g.title, len(g.repos))
+				for _, r := range g.repos {
+					fmt.Printf("  %s\n", r)
+				}
+			}
+			fmt.Printf("\nUnchanged: %d succeeded in both runs\n", d.Unchanged)
+			return nil
+		},
+	}
+}
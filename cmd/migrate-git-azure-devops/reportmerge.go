@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// newReportMergeCmd returns the "report merge" command: combines the JSON reports of the
+// waves of a migration program into a single JSON or HTML report.
+func newReportMergeCmd() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "merge <report.json>... -o <total.json|total.html>",
+		Short: "Combine the JSON reports of several runs, keeping the latest result of every repository",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format := strings.TrimPrefix(strings.ToLower(filepath.Ext(output)), ".")
+			if format != "json" && format != "html" {
+				return fmt.Errorf("--output must be a .json or .html file")
+			}
+			var reports []Report
+			for _, file := range args {
+				if filepath.Clean(file) == filepath.Clean(output) {
+					return fmt.Errorf("--output would overwrite the report read: %s", output)
+				}
+				report, err := loadReport(file)
+				if err != nil {
+					return err
+				}
+				if report.RunID == "" {
+					report.RunID = filepath.Base(file) // reports written before the run IDs
+				}
+				reports = append(reports, report)
+			}
+			merged := mergeReports(reports)
+			printSummary(merged.Summaries)
+			if err := generateReport(merged, format, output); err != nil {
+				return err
+			}
+			fmt.Printf("%d reports merged into %s (%d repositories)\n", len(reports), output, len(merged.Summaries))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Merged report written, .json or .html")
+	_ = cmd.MarkFlagRequired("output")
+	return cmd
+}
+
+// supersedes reports whether a later result replaces an earlier one of the same repository
+// in a merged report. Dry-runs and repositories skipped because already at destination
+// (or already identical) leave the repository as it was: they don't hide the outcome of
+// the run that migrated it.
+func supersedes(later Summary) bool {
+	return later.Result != "DRY-RUN" && later.Result != "SKIPPED: repo already present" && later.Result != ResultAlreadyMigrated
+}
+
+// mergeReports combines the reports of several runs: every repository (matched by name)
+// appears once, with the result of the latest run that processed it, by end time. The
+// merged report spans the runs; its capacity sums their phases and measures the
+// throughput over the repositories migrated, the remaining work is the latest projection.
+func mergeReports(reports []Report) Report {
+	reports = slices.Clone(reports)
+	sort.SliceStable(reports, func(i, j int) bool { return reports[i].EndTime.Before(reports[j].EndTime) })
+
+	merged := Report{
+		ProgramName: prog(),
+		Version:     version,
+		Commit:      commit,
+		BuildDate:   date,
+		RunID:       runID,
+		Capacity:    &Capacity{PhaseSeconds: map[string]float64{}},
+	}
+	latest := map[string]int{} // repository -> index in merged.Summaries
+	var hosts []string
+	for i, r := range reports {
+		if i == 0 || r.StartTime.Before(merged.StartTime) {
+			merged.StartTime = r.StartTime
+		}
+		if r.EndTime.After(merged.EndTime) {
+			merged.EndTime = r.EndTime
+		}
+		merged.Duration += r.Duration
+		if r.Hostname != "" && !slices.Contains(hosts, r.Hostname) {
+			hosts = append(hosts, r.Hostname)
+		}
+		merged.MergedRuns = append(merged.MergedRuns, r.RunID)
+		if r.Capacity != nil {
+			for phase, secs := range r.Capacity.PhaseSeconds {
+				merged.Capacity.PhaseSeconds[phase] += secs
+			}
+			merged.Capacity.RemainingRepos = r.Capacity.RemainingRepos
+			merged.Capacity.RemainingBytes = r.Capacity.RemainingBytes
+			merged.Capacity.RemainingHours = r.Capacity.RemainingHours
+		}
+		for _, s := range r.Summaries {
+			key := strings.ToLower(s.Repo)
+			if at, ok := latest[key]; !ok {
+				latest[key] = len(merged.Summaries)
+				merged.Summaries = append(merged.Summaries, s)
+			} else if supersedes(s) {
+				merged.Summaries[at] = s
+			}
+		}
+	}
+	merged.Hostname = strings.Join(hosts, ", ")
+	sort.SliceStable(merged.Summaries, func(i, j int) bool {
+		return strings.ToLower(merged.Summaries[i].Repo) < strings.ToLower(merged.Summaries[j].Repo)
+	})
+
+	c := merged.Capacity
+	for _, s := range merged.Summaries {
+		if s.Result == "OK" || s.Result == ResultStale || s.Result == ResultSynced {
+			c.MigratedRepos++
+			c.MigratedBytes += s.Size
+		}
+	}
+	if hours := merged.Duration / 60; hours > 0 {
+		c.ThroughputGBPerHour = float64(c.MigratedBytes) / (1 << 30) / hours
+	}
+	return merged
+}
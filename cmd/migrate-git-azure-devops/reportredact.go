@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// urlRe matches the URLs and SCP-like git remotes written in the reports.
+var urlRe = regexp.MustCompile(`(?:https?|ssh)://[^\s"'<>]+|git@[\w.-]+:[^\s"'<>]+`)
+
+// stableHash returns kind-xxxxxxxx, the same for the same value (case-insensitive) in every
+// report, so redacted reports can still be compared with each other.
+func stableHash(kind, value string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(value)))
+	return kind + "-" + hex.EncodeToString(sum[:4])
+}
+
+// redactNames returns the names hidden by --report-redact, by kind: the organizations and
+// projects of the run and the hostnames of the machines.
+func redactNames(cfg Config, report Report) map[string]string {
+	names := map[string]string{}
+	orgs := []string{cfg.SrcOrg, cfg.DstOrg}
+	projects := append([]string{cfg.SrcProject, cfg.DstProject}, cfg.SrcProjects...)
+	for _, d := range cfg.Mirrors {
+		orgs, projects = append(orgs, d.Org), append(projects, d.Project)
+	}
+	for _, org := range orgs {
+		names[org] = "org"
+	}
+	for _, o := range cfg.RepoOverrides {
+		projects = append(projects, o.DstProject)
+	}
+	for _, p := range projects {
+		names[p] = "project"
+	}
+	hosts := strings.Split(report.Hostname, ", ")
+	if h, err := os.Hostname(); err == nil {
+		hosts = append(hosts, h)
+	}
+	for _, h := range hosts {
+		names[h] = "host"
+		if short, _, ok := strings.Cut(h, "."); ok {
+			names[short] = "host"
+		}
+	}
+	delete(names, "")
+	return names
+}
+
+// redactReport returns the report with the URLs, then the organization, project and host
+// names, replaced by stable hashes wherever they appear (URLs, error details, paths,
+// configuration). Repository names are kept, unless they contain one of those names.
+func redactReport(report Report, names map[string]string) (Report, error) {
+	// Longest names first: "contoso-it" is replaced before "contoso"
+	keys := make([]string, 0, len(names))
+	for n := range names {
+		keys = append(keys, n)
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	var nameRes []*regexp.Regexp
+	for _, n := range keys {
+		nameRes = append(nameRes, regexp.MustCompile(`(?i)`+regexp.QuoteMeta(n)))
+	}
+	redact := func(s string) string {
+		s = urlRe.ReplaceAllStringFunc(s, func(u string) string { return stableHash("url", u) })
+		for i, re := range nameRes {
+			s = re.ReplaceAllString(s, stableHash(names[keys[i]], keys[i]))
+		}
+		return s
+	}
+
+	// Every string of the report, configuration included, through its JSON form
+	data, err := json.Marshal(report)
+	if err != nil {
+		return report, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return report, err
+	}
+	doc = redactJSON(doc, redact)
+	if data, err = json.Marshal(doc); err != nil {
+		return report, err
+	}
+	var out Report
+	if err := json.Unmarshal(data, &out); err != nil {
+		return report, fmt.Errorf("redacting the report: %w", err)
+	}
+	return out, nil
+}
+
+// redactJSON applies redact to the string values of a decoded JSON document. Object keys
+// are kept: they are field names or repository names.
+func redactJSON(v any, redact func(string) string) any {
+	switch v := v.(type) {
+	case string:
+		return redact(v)
+	case []any:
+		for i := range v {
+			v[i] = redactJSON(v[i], redact)
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = redactJSON(v[k], redact)
+		}
+	}
+	return v
+}
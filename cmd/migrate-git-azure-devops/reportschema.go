@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// reportSchemaVersion is the version of the JSON report layout, written as schemaVersion.
+// It is raised when a field is removed or changes meaning; new optional fields keep it.
+// Reports without schemaVersion predate the versioning.
+const reportSchemaVersion = 1
+
+// redacted replaces the secrets in the configuration written to the report.
+const redacted = "***REDACTED***"
+
+// secretConfigFields are the Config fields holding credentials, or URLs embedding them
+// (incoming webhooks): their value is never written to the report.
+var secretConfigFields = []string{"SrcPAT", "DstPAT", "ServeToken", "PlanKey", "HookSecret",
+	"ApprovalWebhook", "DiskAlertWebhook", "AdminDigestWebhook"}
+
+// reportConfig returns the effective configuration of the run for the report: the options
+// set (zero values are left out), durations as text, credentials redacted. The identity
+// mapping is summarized by its size, the texts scrubbed by --rewrite-config are redacted.
+func reportConfig(cfg Config) map[string]any {
+	out := map[string]any{}
+	v := reflect.ValueOf(cfg)
+	for _, f := range reflect.VisibleFields(v.Type()) {
+		fv := v.FieldByIndex(f.Index)
+		if !f.IsExported() || fv.IsZero() {
+			continue
+		}
+		switch {
+		case slices.Contains(secretConfigFields, f.Name):
+			out[f.Name] = redacted
+		case f.Type == reflect.TypeFor[time.Duration]():
+			out[f.Name] = time.Duration(fv.Int()).String()
+		case f.Name == "Proxy":
+			out[f.Name] = redactURL(cfg.Proxy)
+		case f.Name == "Mirrors":
+			var mirrors []string // without their PATs
+			for _, d := range cfg.Mirrors {
+				mirrors = append(mirrors, d.Org+"/"+d.Project)
+			}
+			out[f.Name] = mirrors
+		case f.Name == "IdentityMap":
+			out[f.Name] = fmt.Sprintf("%d identities", len(cfg.IdentityMap))
+		case f.Name == "SecretRules":
+			out[f.Name] = fmt.Sprintf("%d rules", len(cfg.SecretRules.rules))
+		case f.Name == "Rewrite":
+			rewrite := *cfg.Rewrite
+			rewrite.ReplaceText = slices.Clone(rewrite.ReplaceText)
+			for i := range rewrite.ReplaceText {
+				rewrite.ReplaceText[i].Find = redacted
+			}
+			out[f.Name] = rewrite
+		default:
+			out[f.Name] = fv.Interface()
+		}
+	}
+	return out
+}
+
+// redactURL removes the password of the user information of a URL.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	}
+	return u.String()
+}
+
+// jsonSchemaOf returns the JSON Schema of a Go type as encoding/json writes it: fields
+// named by their json tag, required unless omitempty, nil slices, maps and pointers as null.
+func jsonSchemaOf(t reflect.Type) map[string]any {
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	nullable := func(kind string) any { return []string{kind, "null"} }
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := jsonSchemaOf(t.Elem())
+		if kind, ok := s["type"].(string); ok {
+			s["type"] = nullable(kind)
+		}
+		return s
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": nullable("array"), "items": jsonSchemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": nullable("object"), "additionalProperties": jsonSchemaOf(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		required := []string{}
+		for _, f := range reflect.VisibleFields(t) {
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = jsonSchemaOf(f.Type)
+			if !slices.Contains(strings.Split(opts, ","), "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{"type": "object", "properties": props, "required": required}
+	}
+	return map[string]any{} // interfaces: any value
+}
+
+// reportSchema returns the JSON Schema of the JSON report.
+func reportSchema() map[string]any {
+	s := jsonSchemaOf(reflect.TypeFor[Report]())
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = "migrate-git-azure-devops migration report"
+	s["description"] = fmt.Sprintf("JSON report, schemaVersion %d, written by migrate-git-azure-devops %s", reportSchemaVersion, version)
+	s["properties"].(map[string]any)["schemaVersion"] = map[string]any{"const": reportSchemaVersion}
+	return s
+}
+
+// newReportSchemaCmd returns the "report schema" command: prints the JSON Schema of the
+// JSON report, for the consumers validating the reports they read.
+func newReportSchemaCmd() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the JSON report",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := json.MarshalIndent(reportSchema(), "", "  ")
+			if err != nil {
+				return err
+			}
+			data = append(data, '\n')
+			if output == "" {
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+			if err := os.WriteFile(output, data, 0o644); err != nil {
+				return fmt.Errorf("writing the schema: %w", err)
+			}
+			fmt.Println("Report schema written to", output)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File receiving the schema (default: standard output)")
+	return cmd
+}
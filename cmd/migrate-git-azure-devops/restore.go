@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// cmdRestore pushes the refs saved in a backup bundle (--backup-dir) back to a destination
+// repository: the repository gets exactly the refs of the bundle, so refs pushed after the
+// backup are deleted. It is the undo of a force push.
+func cmdRestore(ctx context.Context, cfg Config) error {
+	if _, err := os.Stat(cfg.RestoreBackup); err != nil {
+		return fmt.Errorf("backup bundle: %w", err)
+	}
+
+	found, err := newDestinationProvider(cfg).RepoExists(ctx, cfg.RestoreRepo)
+	if err != nil {
+		return fmt.Errorf("call failed for destination %s/%s: %w", cfg.DstOrg, cfg.DstProject, err)
+	}
+	if !found {
+		return fmt.Errorf("repository %s not found in %s/%s", cfg.RestoreRepo, cfg.DstOrg, cfg.DstProject)
+	}
+
+	tmpDir, err := os.MkdirTemp(cfg.TmpDir, "tmp_restore_git_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	repodir := filepath.Join(tmpDir, "restore.git")
+	if err := runCmd(ctx, nil, "git", "clone", "--mirror", cfg.RestoreBackup, repodir); err != nil {
+		return fmt.Errorf("invalid backup bundle %s: %w", cfg.RestoreBackup, err)
+	}
+	refs, err := localRefs(ctx, repodir)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(refs))
+	for ref := range refs {
+		names = append(names, ref)
+	}
+	sort.Strings(names)
+
+	dstURL, dstEnv := gitRemote(cfg, cfg.DstOrg, url.PathEscape(cfg.DstProject), url.PathEscape(cfg.RestoreRepo), cfg.DstPAT)
+	fmt.Printf("Restoring %s/%s/%s from %s (%d refs)\n", cfg.DstOrg, cfg.DstProject, cfg.RestoreRepo, cfg.RestoreBackup, len(names))
+	if cfg.DryRun {
+		for _, ref := range names {
+			fmt.Printf("  [DRY] Would restore %s %s\n", ref, refs[ref])
+		}
+		fmt.Printf("  [DRY] (cd '%s' && git push --mirror --force '%s')\n", repodir, dstURL)
+		return nil
+	}
+
+	sum := Summary{Repo: cfg.RestoreRepo}
+	if cfg.BypassPolicies {
+		restorePolicies, err := bypassPolicies(ctx, cfg, cfg.RestoreRepo, &sum)
+		if err != nil {
+			return fmt.Errorf("bypassing destination policies: %w", err)
+		}
+		defer restorePolicies()
+	}
+	_, err = withRetry(ctx, cfg, "push", func() error {
+		return runCmd(ctx, transferEnv(dstEnv), "git", gitTransferArgs(cfg, 0, "-C", repodir, "push", "--mirror", "--force", dstURL)...)
+	})
+	recordAudit(cfg, AuditEvent{Action: AuditForcePush, Org: cfg.DstOrg, Project: cfg.DstProject, Repo: cfg.RestoreRepo,
+		Result: auditResult(err), Details: "restore of " + cfg.RestoreBackup})
+	if err != nil {
+		return fmt.Errorf("pushing the backup: %w", err)
+	}
+	fmt.Printf("OK, %s restored.\n", cfg.RestoreRepo)
+	return nil
+}
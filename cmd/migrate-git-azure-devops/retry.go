@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// withRetry runs fn up to 1+cfg.Retries times, waiting an exponentially growing delay
+// (cfg.RetryDelay, 2x, 4x, ...) with random jitter between attempts.
+// Returns the number of attempts made and the last error. Only transient failures are
+// retried (see transientError): a missing repository, denied access, a rejected push or a
+// timed out operation (--clone-timeout, --push-timeout) fails at once.
+func withRetry(ctx context.Context, cfg Config, what string, fn func() error) (int, error) {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || attempt > cfg.Retries || !transientError(err) {
+			return attempt, err
+		}
+		delay := backoffDelay(cfg.RetryDelay, attempt)
+		fmt.Printf("  %s failed (attempt %d/%d): %v, retrying in %s\n", what, attempt, cfg.Retries+1, err, delay.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// gitHTTPStatusRe matches the HTTP status in git errors: "RPC failed; HTTP 502 curl 22",
+// "The requested URL returned error: 403".
+var gitHTTPStatusRe = regexp.MustCompile(`(?:\bhttp|returned error:) ([1-5]\d\d)\b`)
+
+// Messages of the git and go-git errors (lowercase) that a new attempt can't fix, and of
+// those caused by the network or an overloaded server.
+var (
+	permanentGitErrors = []string{"authentication failed", "authentication required", "authorization failed",
+		"could not read username", "permission denied", "access denied", "not found", "does not exist",
+		"tf401019", "tf401027", "tf402455", "pre-receive hook declined", "remote rejected", "[rejected]"}
+	transientGitErrors = []string{"connection reset", "connection refused", "connection timed out", "timed out",
+		"failed to connect", "couldn't connect to server", "could not resolve proxy", "rpc failed", "early eof", "unexpected eof", "unexpected disconnect", "remote end hung up",
+		"could not resolve host", "temporary failure in name resolution", "broken pipe", "tls handshake",
+		"gnutls", "ssl_read", "ssl_connect", "curl 18", "curl 56", "http/2 stream", "index-pack failed"}
+)
+
+// transientError reports whether a failed git operation may succeed if tried again. The
+// HTTP status decides when git reports one (5xx, 408 and 429 are transient); otherwise
+// the message: auth failures, missing repositories and rejected pushes are permanent,
+// network errors transient. Unknown failures, timed out operations and cancellations are
+// not retried.
+func transientError(err error) bool {
+	if errors.Is(err, errOperationTimeout) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	if m := gitHTTPStatusRe.FindStringSubmatch(msg); m != nil {
+		return m[1][0] == '5' || m[1] == "408" || m[1] == "429"
+	}
+	for _, p := range permanentGitErrors {
+		if strings.Contains(msg, p) {
+			return false
+		}
+	}
+	for _, t := range transientGitErrors {
+		if strings.Contains(msg, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// backoffDelay returns base*2^(attempt-1) with jitter in [50%, 100%] of that value,
+// so parallel agents hitting the same failure don't retry in lockstep.
+func backoffDelay(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	const maxWait = 10 * time.Minute
+	// Compared before shifting: a shifted value may overflow to any value, even a small positive one
+	d := maxWait
+	if shift := attempt - 1; shift < 63 && base <= maxWait>>shift {
+		d = base << shift
+	}
+	return d/2 + rand.N(d/2+1)
+}
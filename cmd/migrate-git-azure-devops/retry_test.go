@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	const maxWait = 10 * time.Minute
+	tests := []struct {
+		name    string
+		base    time.Duration
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{"no delay", 0, 3, 0, 0},
+		{"negative delay", -time.Second, 1, 0, 0},
+		{"first attempt", 2 * time.Second, 1, time.Second, 2 * time.Second},
+		{"third attempt", 2 * time.Second, 3, 4 * time.Second, 8 * time.Second},
+		{"below the cap", time.Second, 10, 256 * time.Second, 512 * time.Second},
+		{"capped", time.Second, 11, maxWait / 2, maxWait},
+		{"shift past the sign bit", 2 * time.Second, 40, maxWait / 2, maxWait},
+		{"shift wider than the type", time.Second, 64, maxWait / 2, maxWait},
+		{"wrap to a small positive value", 1<<30 + 1, 35, maxWait / 2, maxWait}, // (2^30+1)<<34 wraps to 2^34ns
+		{"huge attempt", time.Second, 1000, maxWait / 2, maxWait},
+		{"huge base", 24 * time.Hour, 1, maxWait / 2, maxWait},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 50 { // jitter
+				if got := backoffDelay(tt.base, tt.attempt); got < tt.min || got > tt.max {
+					t.Fatalf("backoffDelay(%s, %d) = %s, want in [%s, %s]", tt.base, tt.attempt, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection reset", errors.New("exit status 128: error: RPC failed; curl 56 Recv failure: Connection reset by peer"), true},
+		{"early EOF", errors.New("exit status 128: fatal: early EOF\nfatal: index-pack failed"), true},
+		{"hung up", errors.New("exit status 128: fatal: the remote end hung up unexpectedly"), true},
+		{"HTTP 502", errors.New("exit status 1: error: RPC failed; HTTP 502 curl 22 The requested URL returned error: 502"), true},
+		{"HTTP 503", errors.New("exit status 128: fatal: unable to access 'https://dev.azure.com/contoso/Horse/_git/Horse-Core/': The requested URL returned error: 503"), true},
+		{"throttled", errors.New("exit status 128: error: RPC failed; HTTP 429 curl 22 The requested URL returned error: 429"), true},
+		{"DNS", errors.New("exit status 128: fatal: unable to access 'https://dev.azure.com/': Could not resolve host: dev.azure.com"), true},
+		{"connect failed", errors.New("exit status 128: fatal: unable to access 'https://dev.azure.com/contoso/Horse/_git/Horse-Core/': Failed to connect to dev.azure.com port 443 after 21043 ms: Timeout was reached"), true},
+		{"no server", errors.New("exit status 128: fatal: unable to access 'https://dev.azure.com/contoso/Horse/_git/Horse-Core/': Couldn't connect to server"), true},
+		{"proxy DNS", errors.New("exit status 128: fatal: unable to access 'https://dev.azure.com/': Could not resolve proxy: proxy.example.com"), true},
+		{"TLS", errors.New("exit status 128: fatal: unable to access 'https://dev.azure.com/': gnutls_handshake() failed: Error in the pull function."), true},
+		{"go-git EOF", errors.New("unexpected EOF"), true},
+		{"not found", errors.New("exit status 128: remote: TF401019: The Git repository with name or identifier Horse-Core does not exist\nfatal: repository 'https://dev.azure.com/contoso/Horse/_git/Horse-Core/' not found"), false},
+		{"HTTP 404", errors.New("exit status 128: fatal: unable to access 'https://dev.azure.com/': The requested URL returned error: 404"), false},
+		{"HTTP 401", errors.New("exit status 128: fatal: Authentication failed for 'https://dev.azure.com/contoso/Horse/_git/Horse-Core/'"), false},
+		{"HTTP 403 with RPC failed", errors.New("exit status 1: error: RPC failed; HTTP 403 curl 22 The requested URL returned error: 403\nfatal: the remote end hung up unexpectedly"), false},
+		{"payload too large", errors.New("exit status 1: error: RPC failed; HTTP 413 curl 22 The requested URL returned error: 413"), false},
+		{"go-git auth", errors.New("authentication required"), false},
+		{"policy rejection", errors.New("exit status 1: ! [remote rejected] main -> main (TF402455: Pushes to this branch are not permitted; you must use a pull request to update this branch.)"), false},
+		{"pre-receive hook", errors.New("exit status 1: ! [remote rejected] main -> main (pre-receive hook declined)"), false},
+		{"unknown failure", errors.New("exit status 128: fatal: bad object HEAD"), false},
+		{"timed out operation", fmt.Errorf("push: %w", errOperationTimeout), false},
+		{"cancelled", fmt.Errorf("clone: %w", context.Canceled), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transientError(tt.err); got != tt.want {
+				t.Errorf("transientError(%q) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithRetryStopsOnPermanentError(t *testing.T) {
+	cfg := Config{Retries: 2}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"transient", errors.New("fatal: early EOF"), 3},
+		{"permanent", errors.New("fatal: Authentication failed"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			attempts, err := withRetry(context.Background(), cfg, "clone", func() error {
+				calls++
+				return tt.err
+			})
+			if attempts != tt.want || calls != tt.want || err != tt.err {
+				t.Errorf("withRetry() = %d attempts (%d calls), %v; want %d attempts and the error", attempts, calls, err, tt.want)
+			}
+		})
+	}
+}
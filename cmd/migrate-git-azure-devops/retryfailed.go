@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// loadRetryFailed restricts the run to the repositories failed in the JSON report of a
+// previous run (--retry-failed): they become the repo list, with the destination name and
+// project they had in that run. With a report of several source projects only the
+// repositories of --src-project are retried.
+func loadRetryFailed(file string, cfg *Config) error {
+	report, err := loadReport(file)
+	if err != nil {
+		return err
+	}
+	cfg.RepoMap = make(map[string]string)
+	cfg.RepoOwners = make(map[string]string)
+	cfg.RepoOverrides = make(map[string]RepoOverride)
+	other := 0
+	for _, s := range report.Summaries {
+		if resultState(s) != StateFailed {
+			continue
+		}
+		if s.SrcProject != "" && !strings.EqualFold(s.SrcProject, cfg.SrcProject) {
+			other++
+			continue
+		}
+		cfg.RepoList = append(cfg.RepoList, s.Repo)
+		if s.Owner != "" {
+			cfg.RepoOwners[s.Repo] = s.Owner
+		}
+		if s.DstWebURL == "" {
+			continue // failed before the destination was known: current mapping and transforms
+		}
+		org, project, name, err := destinationFromWebURL(s.DstWebURL)
+		if err != nil {
+			return fmt.Errorf("--retry-failed: %s: %w", s.Repo, err)
+		}
+		if !strings.EqualFold(org, cfg.DstOrg) {
+			return fmt.Errorf("--retry-failed: %s was migrated to organization %s, not --dst-org %s", s.Repo, org, cfg.DstOrg)
+		}
+		cfg.RepoMap[s.Repo] = name
+		if !strings.EqualFold(project, cfg.DstProject) {
+			cfg.RepoOverrides[s.Repo] = RepoOverride{DstProject: project}
+		}
+	}
+	if other > 0 {
+		fmt.Printf("%d failed repositories of other source projects left out (run again with their --src-project)\n", other)
+	}
+	if len(cfg.RepoList) == 0 {
+		return fmt.Errorf("--retry-failed: no failed repository of %s/%s in %s", cfg.SrcOrg, cfg.SrcProject, file)
+	}
+	fmt.Printf("Retrying the %d repositories failed in %s\n", len(cfg.RepoList), describeRun(file, report))
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HistoryRewrite is the --rewrite-config file: the history cleanup done by git filter-repo
+// on the mirror before the push.
+type HistoryRewrite struct {
+	StripPaths           []string      `yaml:"stripPaths"`           // files/directories removed from the history
+	StripPathGlobs       []string      `yaml:"stripPathGlobs"`       // globs of the files removed (e.g. "*.zip")
+	StripBlobsBiggerThan string        `yaml:"stripBlobsBiggerThan"` // files larger than this removed (e.g. 50M)
+	ReplaceText          []TextReplace `yaml:"replaceText"`          // text replaced in every file
+
+	file, sha256 string // absolute path and SHA-256 of the file, recorded by plan
+}
+
+// TextReplace replaces a literal text (or a regex) in every file of the history.
+type TextReplace struct {
+	Find    string `yaml:"find"`
+	Replace string `yaml:"replace"` // default ***REMOVED***
+	Regex   bool   `yaml:"regex"`
+}
+
+// loadRewriteConfig reads and checks a --rewrite-config file.
+func loadRewriteConfig(file string) (*HistoryRewrite, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading --rewrite-config: %w", err)
+	}
+	var rw HistoryRewrite
+	if err := yaml.Unmarshal(data, &rw); err != nil {
+		return nil, fmt.Errorf("invalid rewrite config %s: %w", file, err)
+	}
+	if rw.file, err = filepath.Abs(file); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	rw.sha256 = hex.EncodeToString(sum[:])
+	if rw.StripBlobsBiggerThan != "" {
+		if _, err := parseSize(rw.StripBlobsBiggerThan); err != nil {
+			return nil, fmt.Errorf("invalid rewrite config %s: stripBlobsBiggerThan: %w", file, err)
+		}
+	}
+	for i, r := range rw.ReplaceText {
+		if r.Find == "" {
+			return nil, fmt.Errorf("invalid rewrite config %s: replaceText entry %d has no find", file, i+1)
+		}
+		if strings.Contains(r.Find, "\n") || strings.Contains(r.Replace, "\n") {
+			return nil, fmt.Errorf("invalid rewrite config %s: replaceText entry %d spans lines", file, i+1)
+		}
+	}
+	if len(rw.StripPaths) == 0 && len(rw.StripPathGlobs) == 0 && rw.StripBlobsBiggerThan == "" && len(rw.ReplaceText) == 0 {
+		return nil, fmt.Errorf("invalid rewrite config %s: nothing to rewrite", file)
+	}
+	return &rw, nil
+}
+
+// describe summarizes the rewrite for the dry-run output.
+func (rw *HistoryRewrite) describe() string {
+	var parts []string
+	if n := len(rw.StripPaths) + len(rw.StripPathGlobs); n > 0 {
+		parts = append(parts, fmt.Sprintf("strip %d paths", n))
+	}
+	if rw.StripBlobsBiggerThan != "" {
+		parts = append(parts, "strip files over "+rw.StripBlobsBiggerThan)
+	}
+	if len(rw.ReplaceText) > 0 {
+		parts = append(parts, fmt.Sprintf("replace %d texts", len(rw.ReplaceText)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// rewriteHistory runs git filter-repo on the mirror as described by rw, in a single pass,
+// and reports whether any ref changed.
+func rewriteHistory(ctx context.Context, repoDir, tmpDir string, rw *HistoryRewrite) (bool, error) {
+	before, err := localRefs(ctx, repoDir)
+	if err != nil {
+		return false, err
+	}
+
+	args := []string{"-C", repoDir, "filter-repo", "--force"}
+	if len(rw.StripPaths) > 0 || len(rw.StripPathGlobs) > 0 {
+		args = append(args, "--invert-paths")
+		for _, p := range rw.StripPaths {
+			args = append(args, "--path", p)
+		}
+		for _, g := range rw.StripPathGlobs {
+			args = append(args, "--path-glob", g)
+		}
+	}
+	if rw.StripBlobsBiggerThan != "" {
+		size, _ := parseSize(rw.StripBlobsBiggerThan)
+		args = append(args, "--strip-blobs-bigger-than", fmt.Sprint(size))
+	}
+	if len(rw.ReplaceText) > 0 {
+		// filter-repo expressions file: "literal==>replacement" or "regex:expr==>replacement"
+		var lines []string
+		for _, r := range rw.ReplaceText {
+			repl := r.Replace
+			if repl == "" {
+				repl = "***REMOVED***"
+			}
+			find := r.Find
+			if r.Regex {
+				find = "regex:" + find
+			} else if strings.HasPrefix(find, "regex:") || strings.HasPrefix(find, "glob:") {
+				find = "literal:" + find
+			}
+			lines = append(lines, find+"==>"+repl)
+		}
+		exprFile := filepath.Join(tmpDir, filepath.Base(repoDir)+".replace-text")
+		if err := os.WriteFile(exprFile, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+			return false, err
+		}
+		defer os.Remove(exprFile)
+		args = append(args, "--replace-text", exprFile)
+	}
+	if err := runCmd(ctx, nil, "git", args...); err != nil {
+		return false, err
+	}
+
+	after, err := localRefs(ctx, repoDir)
+	if err != nil {
+		return false, err
+	}
+	return !maps.Equal(before, after), nil
+}
+
+// checkFilterRepo verifies that git filter-repo, needed by --rewrite-config, is installed.
+func checkFilterRepo(ctx context.Context) error {
+	if err := exec.CommandContext(ctx, "git", "filter-repo", "--version").Run(); err != nil {
+		return fmt.Errorf("--rewrite-config requires git filter-repo (https://github.com/newren/git-filter-repo): not found")
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// rollbackCandidate reports whether a destination repository was created by the run and
+// never reached a successful state, so that it only holds a partial push.
+func rollbackCandidate(s Summary) bool {
+	if !s.Created || s.RolledBack || s.DstRepoID == "" {
+		return false
+	}
+	switch s.Result {
+	case "OK", ResultStale, ResultSynced, ResultInSync:
+		return false
+	}
+	return true
+}
+
+// destinationFromWebURL returns organization, project and repository of a destination
+// web URL (https://dev.azure.com/{org}/{project}/_git/{repo}).
+func destinationFromWebURL(webURL string) (org, project, repo string, err error) {
+	u, err := url.Parse(webURL)
+	if err != nil {
+		return "", "", "", err
+	}
+	parts := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	if len(parts) != 4 || parts[2] != "_git" {
+		return "", "", "", fmt.Errorf("unexpected destination URL: %s", webURL)
+	}
+	if project, err = url.PathUnescape(parts[1]); err != nil {
+		return "", "", "", err
+	}
+	if repo, err = url.PathUnescape(parts[3]); err != nil {
+		return "", "", "", err
+	}
+	return parts[0], project, repo, nil
+}
+
+// rollbackCreated deletes the destination repositories created by the run that never
+// reached a successful state (--rollback-on-failure, rollback subcommand) and marks
+// them RolledBack. Deleted repositories stay in the project recycle bin.
+func rollbackCreated(ctx context.Context, cfg Config, results []Summary) {
+	for i := range results {
+		s := &results[i]
+		if !rollbackCandidate(*s) {
+			continue
+		}
+		org, project, name, err := destinationFromWebURL(s.DstWebURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Rollback of %s failed: %v\n", s.Repo, err)
+			continue
+		}
+		if cfg.DryRun {
+			fmt.Printf("[DRY] Would delete the partially migrated destination repository %s/%s/%s\n", org, project, name)
+			continue
+		}
+		err = deleteRepo(ctx, org, project, cfg.DstPAT, s.DstRepoID, cfg.Trace)
+		recordAudit(cfg, AuditEvent{Action: AuditDeleteRepo, Org: org, Project: project, Repo: name, RepoID: s.DstRepoID,
+			Result: auditResult(err), Details: "rollback of a partial migration (" + s.Result + ")"})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Rollback of %s failed: %v\n", name, err)
+			continue
+		}
+		s.RolledBack = true
+		fmt.Printf("Rolled back: destination repository %s deleted (%s)\n", name, s.Result)
+	}
+}
+
+// cmdRollback deletes the destination repositories of a previous run, read from its JSON
+// report, that were created by that run and never reached a successful state, and
+// re-enables the destination policies that run left disabled (PoliciesLeftOff).
+func cmdRollback(ctx context.Context, cfg Config) error {
+	data, err := os.ReadFile(cfg.RollbackReport)
+	if err != nil {
+		return fmt.Errorf("reading the report: %w", err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return fmt.Errorf("invalid JSON report: %w", err)
+	}
+	n, policies := 0, 0
+	for _, s := range report.Summaries {
+		if rollbackCandidate(s) {
+			n++
+		}
+		policies += len(s.PoliciesLeftOff)
+	}
+	if policies > 0 {
+		fmt.Printf("%d destination policies disabled by the run of %s were not re-enabled\n", policies, report.StartTime.Format("2006-01-02 15:04"))
+		if left := restoreReportPolicies(ctx, cfg, report.Summaries); left > 0 && !cfg.DryRun {
+			fmt.Fprintf(os.Stderr, "%d policies still disabled: re-enable them from the project settings\n", left)
+		}
+	}
+	if n == 0 {
+		fmt.Println("No partially migrated repository to roll back.")
+		return nil
+	}
+	fmt.Printf("%d destination repositories created by the run of %s never reached OK\n", n, report.StartTime.Format("2006-01-02 15:04"))
+	rollbackCreated(ctx, cfg, report.Summaries)
+	return nil
+}
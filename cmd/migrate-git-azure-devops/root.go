@@ -3,7 +3,9 @@ package main
 import (
 	"fmt"
 	"os"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -36,6 +38,21 @@ func Execute() {
 
 	var cfg Config
 	var repoListPath string
+	var renameRegex []string
+	var refRename []string
+	var largeRepoSize string
+	var maxBandwidth string
+	var estimateRate string
+	var skipLargerThan string
+	var maxFileSize string
+	var scanSecretsFlag bool
+	var secretRulesFile string
+	var rewriteConfig string
+	var submodulesMode string
+	var submoduleBranches []string
+	var yesIAmSure bool
+	var identityMapFile string
+	var pipelinesReport, pipelinesMigrate bool
 
 	rootCmd := &cobra.Command{
 		Use:   prog(),
@@ -44,7 +61,14 @@ func Execute() {
 			"\n\n" +
 			"Antonio Musarra <antonio.musarra@gmail.com>\n" +
 			"Blog: https://www.dontesta.it\n" +
-			"GitHub: https://github.com/amusarra",
+			"GitHub: https://github.com/amusarra" +
+			"\n\n" +
+			"Every flag can also be set with an MGAD_* environment variable (--dst-project: MGAD_DST_PROJECT); the command line wins.\n" +
+			"Lists are comma separated; --git-config, --ref-rename and --rename-regex take one value per line.",
+		// Flags not given on the command line from the MGAD_* environment variables
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return applyEnvFlags(cmd)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Version
 			if cfg.ShowVersion {
@@ -52,35 +76,349 @@ func Execute() {
 				return nil
 			}
 
-			// PAT from env
-			cfg.SrcPAT = strings.TrimSpace(os.Getenv("SRC_PAT"))
-			cfg.DstPAT = strings.TrimSpace(os.Getenv("DST_PAT"))
-
 			if cfg.Trace {
 				fmt.Fprintln(os.Stderr, "[TRACE] Trace enabled")
 			}
 
-			// Minimal validations
-			if cfg.SrcOrg == "" || cfg.SrcProject == "" {
+			if err := configureProxy(cfg.Proxy, cfg.NoProxy); err != nil {
+				return err
+			}
+			closeTrace, err := configureHTTPTrace(cfg.TraceHTTP)
+			if err != nil {
+				return err
+			}
+			defer closeTrace()
+
+			// Minimal validations (the API server takes them from each job, apply from the plan,
+			// rollback from the report, import from the manifest; restore has no source)
+			if (cfg.SrcOrg == "" || cfg.SrcProject == "") && !cfg.Serve && !cfg.Apply && !cfg.Rollback && !cfg.Restore && !cfg.Import && !cfg.ValidateIDs {
 				return fmt.Errorf("--src-org and --src-project are required")
 			}
-			if cfg.SrcPAT == "" {
-				return fmt.Errorf("SRC_PAT environment variable missing")
+			// Secrets of the environment are read at run time, not as flag defaults: --help
+			// and gen-docs would print them
+			if cfg.Serve && cfg.ServeToken == "" {
+				cfg.ServeToken = os.Getenv("MIGRATE_API_TOKEN")
+			}
+			if cfg.Serve && cfg.ServeToken == "" {
+				return fmt.Errorf("serve requires --token (or MIGRATE_API_TOKEN)")
+			}
+			if cfg.Rollback && cfg.RollbackReport == "" {
+				return fmt.Errorf("rollback requires --report")
+			}
+			if (cfg.Export || cfg.Import) && cfg.BundleDir == "" {
+				return fmt.Errorf("%s requires --bundle-dir", cmd.Name())
+			}
+			if cfg.ValidateIDs && identityMapFile == "" {
+				return fmt.Errorf("identity-map validate requires --identity-map")
+			}
+			if cfg.Restore && (cfg.RestoreBackup == "" || cfg.RestoreRepo == "") {
+				return fmt.Errorf("restore requires --backup and --repo")
+			}
+			if (cfg.Plan || cfg.Apply) && cfg.PlanKey == "" {
+				cfg.PlanKey = os.Getenv("MIGRATE_PLAN_KEY")
+			}
+			if (cfg.Plan || cfg.Apply) && (cfg.PlanFile == "" || cfg.PlanKey == "") {
+				return fmt.Errorf("%s requires --plan-file and --plan-key (or MIGRATE_PLAN_KEY)", cmd.Name())
+			}
+
+			// Credentials: PAT from env or Entra ID access token
+			if err := resolveCredentials(cmd.Context(), &cfg); err != nil {
+				return err
+			}
+			registerSecret(cfg.PlanKey)
+			needsSrc := !cfg.Rollback && !cfg.Restore && !cfg.Import && !cfg.ValidateIDs // these only touch the destination
+			if needsSrc {
+				if err := promptSecret(cfg, &cfg.SrcPAT, "SRC_PAT"); err != nil {
+					return err
+				}
+			}
+			if cfg.SrcPAT == "" && needsSrc {
+				return fmt.Errorf("SRC_PAT environment variable missing (or use --src-pat-file/--src-pat-cmd)")
+			}
+
+			if cfg.OnSourceRemoved != OnSourceRemovedSkip && cfg.OnSourceRemoved != OnSourceRemovedError {
+				return fmt.Errorf("--on-source-removed must be skip or error")
+			}
+
+			for _, g := range cfg.Globs {
+				if _, err := path.Match(g, ""); err != nil {
+					return fmt.Errorf("invalid --glob pattern: %s", g)
+				}
+			}
+
+			if cfg.Sort != SortName && cfg.Sort != SortSize && cfg.Sort != SortActivity {
+				return fmt.Errorf("unsupported --sort: %s (only name, size, activity are allowed)", cfg.Sort)
+			}
+
+			if cfg.MinFreeGB < 0 {
+				return fmt.Errorf("--min-free-gb must be >= 0")
+			}
+
+			if cfg.Retries < 0 {
+				return fmt.Errorf("--retries must be >= 0")
+			}
+
+			if cfg.Coordinator || cfg.Worker {
+				if cfg.Coordinator && cfg.Worker {
+					return fmt.Errorf("--coordinator and --worker are mutually exclusive")
+				}
+				if cfg.StateDir == "" {
+					return fmt.Errorf("--state-dir is required with --coordinator/--worker")
+				}
+				if cfg.Shards < 1 {
+					return fmt.Errorf("--shards must be >= 1")
+				}
+				if cfg.Wizard {
+					return fmt.Errorf("--coordinator/--worker are not available in wizard mode")
+				}
+			}
+
+			if len(cfg.ExcludePaths) > 0 {
+				if cfg.FinalSync || cfg.Sync || cfg.Verify {
+					return fmt.Errorf("--exclude-path cannot be used with --final-sync, --sync or verify: commit SHAs differ by design")
+				}
+				fmt.Fprintln(os.Stderr, "WARNING: --exclude-path rewrites the history: commit SHAs at destination will differ from the source")
+			}
+
+			if rewriteConfig != "" {
+				if cfg.FinalSync || cfg.Sync || cfg.Verify {
+					return fmt.Errorf("--rewrite-config cannot be used with --final-sync, --sync or verify: commit SHAs differ by design")
+				}
+				rw, err := loadRewriteConfig(rewriteConfig)
+				if err != nil {
+					return err
+				}
+				if !cfg.DryRun {
+					if err := checkFilterRepo(cmd.Context()); err != nil {
+						return err
+					}
+				}
+				cfg.Rewrite = rw
+				fmt.Fprintln(os.Stderr, "WARNING: --rewrite-config rewrites the history: commit SHAs at destination will differ from the source")
+			}
+
+			switch submodulesMode {
+			case "":
+			case SubmodulesReport, SubmodulesCommit:
+				if cfg.FinalSync || cfg.Sync {
+					return fmt.Errorf("--submodules cannot be used with --final-sync or --sync: use it on the migration run")
+				}
+				for _, g := range submoduleBranches {
+					if _, err := path.Match(g, ""); err != nil {
+						return fmt.Errorf("invalid --submodule-branches pattern %q", g)
+					}
+				}
+				cfg.Submodules = &SubmoduleRewrite{Mode: submodulesMode, Branches: submoduleBranches, DstProject: cfg.DstProject}
+			default:
+				return fmt.Errorf("--submodules must be report or commit")
 			}
 
-			isMigration := !cfg.ListOnly && !cfg.Wizard
-			if isMigration {
+			if cfg.FinalSync && cfg.Wizard {
+				return fmt.Errorf("--final-sync is not available in wizard mode")
+			}
+			if cfg.NoTUI && !cfg.Wizard {
+				return fmt.Errorf("--no-tui requires --wizard")
+			}
+			if cfg.Daemon && cfg.HookListen != "" {
+				return fmt.Errorf("--daemon and --hook-listen are mutually exclusive")
+			}
+			if cfg.HookListen != "" {
+				if cfg.Coordinator || cfg.Worker || cfg.MintPAT {
+					return fmt.Errorf("--hook-listen cannot be used with --coordinator/--worker/--mint-pat")
+				}
+				if cfg.HookSecret == "" && !loopbackAddr(cfg.HookListen) {
+					return fmt.Errorf("--hook-secret is required when --hook-listen is reachable from other hosts (%s); bind 127.0.0.1 to listen without it", cfg.HookListen)
+				}
+				cfg.Sync = true
+			}
+			if cfg.Daemon {
+				if cfg.Interval <= 0 {
+					return fmt.Errorf("--interval must be positive")
+				}
+				if cfg.Coordinator || cfg.Worker {
+					return fmt.Errorf("--daemon cannot be used with --coordinator/--worker")
+				}
+				if cfg.MintPAT {
+					return fmt.Errorf("--daemon cannot be used with --mint-pat: the minted PAT would expire while running")
+				}
+				cfg.Sync = true
+			}
+			if cfg.Sync && (cfg.Wizard || cfg.FinalSync) {
+				return fmt.Errorf("--sync/--daemon/--hook-listen cannot be used with --wizard or --final-sync")
+			}
+
+			// Destination credentials are required only by the operations contacting the
+			// destination: listing, dry-runs and the coordinator work without them.
+			isMigration := !cfg.ListOnly && !cfg.Wizard && !cfg.Diff && !cfg.Verify && !cfg.Benchmark && !cfg.Stats && !cfg.Serve && !cfg.Plan && !cfg.Apply && !cfg.Rollback && !cfg.Restore && !cfg.Export && !cfg.Import && !cfg.ValidateIDs
+			if isMigration && (cfg.DstOrg == "" || cfg.DstProject == "") {
+				return fmt.Errorf("specify destination (--dst-org, --dst-project) or use list/--wizard")
+			}
+			if len(cfg.SrcProjects) > 1 && (!isMigration || cfg.Coordinator || cfg.Worker || cfg.Daemon || cfg.HookListen != "") {
+				return fmt.Errorf("several --src-project are only supported by the non-interactive migration, --sync and --final-sync")
+			}
+			if cfg.ValidateIDs && cfg.DstOrg == "" {
+				return fmt.Errorf("%s requires --dst-org", cmd.CommandPath())
+			}
+			if (cfg.Diff || cfg.Verify || cfg.Plan || cfg.Restore || cfg.Import) && (cfg.DstOrg == "" || cfg.DstProject == "") {
+				return fmt.Errorf("%s requires --dst-org and --dst-project", cmd.Name())
+			}
+			if cfg.Benchmark && cfg.DstOrg != "" && cfg.DstProject == "" {
+				return fmt.Errorf("benchmark of the destination requires --dst-project")
+			}
+			if cfg.Stats && cfg.StatsFormat != StatsTable && cfg.StatsFormat != StatsJSON {
+				return fmt.Errorf("unsupported --format: %s (only table, json are allowed)", cfg.StatsFormat)
+			}
+			if cfg.ListOnly && cfg.Side != SideSrc {
+				if cfg.Side != SideDst && cfg.Side != SideBoth {
+					return fmt.Errorf("unsupported --side: %s (only src, dst, both are allowed)", cfg.Side)
+				}
 				if cfg.DstOrg == "" || cfg.DstProject == "" {
-					return fmt.Errorf("specify destination (--dst-org, --dst-project) or use --list-repos/--wizard")
+					return fmt.Errorf("--side %s requires --dst-org and --dst-project", cfg.Side)
+				}
+			}
+			needsDst := (isMigration || cfg.Wizard) && !cfg.Coordinator && (!cfg.DryRun || cfg.FinalSync || cfg.Sync) ||
+				cfg.ListOnly && cfg.Side != SideSrc || cfg.Diff || cfg.Verify || cfg.Serve || cfg.Plan || cfg.Apply || cfg.Rollback || cfg.Restore || cfg.Import || cfg.ValidateIDs || cfg.Benchmark && cfg.DstOrg != ""
+			if needsDst {
+				if err := promptSecret(cfg, &cfg.DstPAT, "DST_PAT"); err != nil {
+					return err
+				}
+			}
+			if needsDst && cfg.DstPAT == "" {
+				return fmt.Errorf("DST_PAT environment variable missing for destination (or use --dst-pat-file/--dst-pat-cmd, or --dry-run for a read-only plan)")
+			}
+
+			switch cfg.LockSource {
+			case "":
+			case LockDefault, LockAll:
+				if cfg.FinalSync || cfg.Sync || !isMigration {
+					return fmt.Errorf("--lock-source applies to non-interactive migrations (--final-sync already locks the source branches)")
+				}
+			default:
+				return fmt.Errorf("--lock-source must be default or all")
+			}
+
+			switch cfg.Provenance {
+			case "":
+			case ProvenanceTag, ProvenanceNote:
+				if cfg.FinalSync || cfg.Sync || !isMigration && !cfg.Wizard {
+					return fmt.Errorf("--provenance applies to the migration, not to --final-sync or --sync")
+				}
+				if cfg.Provenance == ProvenanceTag && provenanceTagName(cfg.ProvenanceTag, time.Now()) == "" {
+					return fmt.Errorf("--provenance-tag cannot be empty")
 				}
-				if cfg.DstPAT == "" {
-					return fmt.Errorf("DST_PAT environment variable missing for destination")
+			default:
+				return fmt.Errorf("--provenance must be tag or note")
+			}
+			if cfg.ProvenanceSign && cfg.Provenance != ProvenanceTag {
+				return fmt.Errorf("--provenance-sign requires --provenance tag")
+			}
+
+			if cfg.MigrateOpenPRs && (cfg.FinalSync || cfg.Sync || !isMigration && !cfg.Wizard) {
+				return fmt.Errorf("--migrate-open-prs applies to the migration, not to --final-sync or --sync")
+			}
+			if cfg.MigrateHooks && (cfg.FinalSync || cfg.Sync || !isMigration && !cfg.Wizard) {
+				return fmt.Errorf("--migrate-service-hooks applies to the migration, not to --final-sync or --sync")
+			}
+			switch {
+			case pipelinesMigrate:
+				cfg.Pipelines = PipelinesMigrate
+			case pipelinesReport:
+				cfg.Pipelines = PipelinesReport
+			}
+			if cfg.Pipelines != "" && (cfg.FinalSync || cfg.Sync || !isMigration && !cfg.Wizard) {
+				return fmt.Errorf("--inventory-pipelines and --migrate-pipelines apply to the migration, not to --final-sync or --sync")
+			}
+			if cfg.SkipDisabled && cfg.KeepDisabled {
+				return fmt.Errorf("--skip-disabled and --preserve-disabled are mutually exclusive")
+			}
+			if cfg.KeepDisabled && (cfg.FinalSync || cfg.Sync || !isMigration && !cfg.Wizard) {
+				return fmt.Errorf("--preserve-disabled applies to the migration, not to --final-sync or --sync")
+			}
+			if identityMapFile != "" {
+				m, err := loadIdentityMap(identityMapFile)
+				if err != nil {
+					return err
 				}
+				cfg.IdentityMap = m
 			}
 
-			// Load repo list from file if provided
+			if cfg.WorkItemCSV != "" {
+				cfg.WorkItemRefs = true
+			}
+			if cfg.WorkItemRefs {
+				if cfg.FinalSync || cfg.Sync || !isMigration && !cfg.Wizard {
+					return fmt.Errorf("--workitem-refs applies to the migration, not to --final-sync or --sync")
+				}
+				if cfg.WorkItemCSV != "" && !cfg.DryRun {
+					if err := initWorkItemCSV(cfg.WorkItemCSV); err != nil {
+						return err
+					}
+				}
+			}
+
+			if cfg.DeleteSource {
+				if !yesIAmSure {
+					return fmt.Errorf("--delete-source-after deletes the source repositories: confirm with --yes-i-am-sure")
+				}
+				if cfg.Wizard || cfg.Sync || !isMigration {
+					return fmt.Errorf("--delete-source-after is only available for non-interactive migrations and --final-sync")
+				}
+				if cfg.AuditLog == "" {
+					return fmt.Errorf("--delete-source-after requires --audit-log: the deletions must be recorded")
+				}
+			}
+			mutating := isMigration || cfg.Wizard || cfg.Apply || cfg.Rollback || cfg.Restore || cfg.Import || cfg.Benchmark
+			if mutating && !cfg.DryRun && cfg.AuditLog != "" {
+				if err := writableFile(cfg.AuditLog); err != nil {
+					return fmt.Errorf("--audit-log: %w", err)
+				}
+			}
+
+			if cfg.MintPAT {
+				if !isBearerToken(cfg.DstPAT) {
+					return fmt.Errorf("--mint-pat requires a Microsoft Entra ID token for the destination: use --auth azcli or --auth devicecode")
+				}
+				if cfg.MintPATTTL <= 0 {
+					return fmt.Errorf("--mint-pat-ttl must be positive")
+				}
+			}
+
+			if cfg.Protocol != ProtocolHTTPS && cfg.Protocol != ProtocolSSH {
+				return fmt.Errorf("unsupported protocol: %s (only https, ssh are allowed)", cfg.Protocol)
+			}
+			if cfg.Fallback != "" && cfg.Fallback != ProtocolHTTPS && cfg.Fallback != ProtocolSSH {
+				return fmt.Errorf("unsupported fallback protocol: %s (only https, ssh are allowed)", cfg.Fallback)
+			}
+			if cfg.GitEngine != GitEngineSystem && cfg.GitEngine != GitEngineNative {
+				return fmt.Errorf("unsupported --git-engine: %s (only system, native are allowed)", cfg.GitEngine)
+			}
+			nativeGit = cfg.GitEngine == GitEngineNative
+			if cfg.GitCreds != GitCredsHeader && cfg.GitCreds != GitCredsHelper && cfg.GitCreds != GitCredsSystem {
+				return fmt.Errorf("unsupported --git-credentials: %s (only header, helper, system are allowed)", cfg.GitCreds)
+			}
+			if cfg.SSHKey != "" {
+				if _, err := os.Stat(cfg.SSHKey); err != nil {
+					return fmt.Errorf("--ssh-key not readable: %w", err)
+				}
+			}
+			// Installed git tools (listing, stats, plan and rollback only call the REST API)
+			if !cfg.ListOnly && !cfg.Stats && !cfg.Plan && !cfg.Rollback {
+				if err := checkGitTools(cmd.Context(), cfg); err != nil {
+					return err
+				}
+			}
+
+			// Load repo list from file if provided: YAML manifest (.yaml/.yml) or CSV
 			if repoListPath != "" {
 				cfg.RepoMap = make(map[string]string)
+				cfg.RepoOwners = make(map[string]string)
+				cfg.RepoOverrides = make(map[string]RepoOverride)
+			}
+			if repoListPath != "" && isManifest(repoListPath) {
+				if err := loadManifest(repoListPath, &cfg); err != nil {
+					return err
+				}
+			} else if repoListPath != "" {
 				data, err := os.ReadFile(repoListPath)
 				if err != nil {
 					return fmt.Errorf("error reading --repo-list: %w", err)
@@ -88,20 +426,103 @@ func Execute() {
 				for _, ln := range strings.Split(string(data), "\n") {
 					ln = strings.TrimSpace(ln)
 					if ln != "" && !strings.HasPrefix(ln, "#") {
-						// Support CSV format: source,destination
-						// If no comma, destination = source
-						parts := strings.SplitN(ln, ",", 2)
+						// Support CSV format: source,destination,owner
+						// If no comma (or empty destination), destination = source
+						parts := strings.SplitN(ln, ",", 3)
 						srcName := strings.TrimSpace(parts[0])
 						dstName := srcName
-						if len(parts) == 2 {
+						if len(parts) >= 2 && strings.TrimSpace(parts[1]) != "" {
 							dstName = strings.TrimSpace(parts[1])
 						}
+						if len(parts) == 3 {
+							cfg.RepoOwners[srcName] = strings.TrimSpace(parts[2])
+						}
 						cfg.RepoList = append(cfg.RepoList, srcName)
 						cfg.RepoMap[srcName] = dstName
 					}
 				}
 			}
 
+			// Only the repositories failed in a previous run
+			if cfg.RetryFailed != "" {
+				if repoListPath != "" || cfg.Filter != "" || len(cfg.Globs) > 0 {
+					return fmt.Errorf("--retry-failed selects the repositories itself: don't use --repo-list, --filter or --glob")
+				}
+				if !isMigration || cfg.Coordinator || cfg.Worker || len(cfg.SrcProjects) > 1 {
+					return fmt.Errorf("--retry-failed applies to the non-interactive migration of one source project")
+				}
+				if err := loadRetryFailed(cfg.RetryFailed, &cfg); err != nil {
+					return err
+				}
+			}
+
+			for _, expr := range renameRegex {
+				rule, err := parseRenameRegex("--rename-regex", expr)
+				if err != nil {
+					return err
+				}
+				cfg.RenameRules = append(cfg.RenameRules, rule)
+			}
+			for _, expr := range refRename {
+				rule, err := parseRenameRegex("--ref-rename", expr)
+				if err != nil {
+					return err
+				}
+				cfg.RefRenameRules = append(cfg.RefRenameRules, rule)
+			}
+			if len(cfg.RefRenameRules) > 0 && (cfg.FinalSync || cfg.Sync) {
+				return fmt.Errorf("--ref-rename cannot be used with --final-sync or --sync")
+			}
+
+			if err := validateGitConfig(cfg.GitConfig); err != nil {
+				return err
+			}
+			size, err := parseSize(largeRepoSize)
+			if err != nil {
+				return fmt.Errorf("--large-repo-size: %w", err)
+			}
+			cfg.LargeRepoSize = size
+			if cfg.SkipLargerThan, err = parseSize(skipLargerThan); err != nil {
+				return fmt.Errorf("--skip-larger-than: %w", err)
+			}
+			if cfg.MaxFileSize, err = parseSize(maxFileSize); err != nil {
+				return fmt.Errorf("--max-file-size: %w", err)
+			}
+			if scanSecretsFlag || secretRulesFile != "" || cfg.BlockOnSecrets {
+				if cfg.SecretRules, err = loadSecretRules(secretRulesFile); err != nil {
+					return err
+				}
+			}
+			if cfg.MaxBandwidth, err = parseSize(maxBandwidth); err != nil {
+				return fmt.Errorf("--max-bandwidth: %w", err)
+			}
+			if cfg.EstimateRate, err = parseSize(estimateRate); err != nil {
+				return fmt.Errorf("--estimate-rate: %w", err)
+			}
+			if cfg.MaxBandwidth > 0 {
+				if cfg.Protocol == ProtocolSSH || cfg.Fallback == ProtocolSSH {
+					fmt.Fprintln(os.Stderr, "Warning: --max-bandwidth only limits the HTTPS transfers, not SSH")
+				}
+				if err := startThrottleProxy(cfg.MaxBandwidth, throttleHosts(cfg)); err != nil {
+					return err
+				}
+			}
+			if cfg.TmpDir != "" {
+				if info, err := os.Stat(cfg.TmpDir); err != nil || !info.IsDir() {
+					return fmt.Errorf("--tmp-dir must be an existing directory: %s", cfg.TmpDir)
+				}
+			}
+
+			// The artifact store keeps at least the JSON report
+			if cfg.ArtifactStore != "" {
+				if _, err := parseArtifactStore(cfg.ArtifactStore); err != nil {
+					return err
+				}
+				if len(cfg.ReportFormats) == 0 {
+					cfg.ReportFormats = []string{"json"}
+				}
+			}
+
 			// Report-path validation
 			if len(cfg.ReportFormats) > 0 {
 				// Check supported formats
@@ -119,36 +540,296 @@ func Execute() {
 					}
 				}
 			}
+			if cfg.ReportRedact && len(cfg.ReportFormats) == 0 {
+				return fmt.Errorf("--report-redact requires --report-format")
+			}
+			if systemGitMissing {
+				if opt := needsSystemGit(cfg); opt != "" {
+					return fmt.Errorf("git not found in PATH: %s need the installed git, also with --git-engine native", opt)
+				}
+			}
 
 			// Dispatch
 			if cfg.ListOnly {
 				return cmdListRepos(cfg)
 			}
-			if cfg.Wizard {
-				return runWizard(cfg)
+			if cfg.Stats {
+				return cmdStats(cmd.Context(), cfg)
+			}
+			if cfg.Diff {
+				return cmdDiff(cmd.Context(), cfg)
+			}
+			if cfg.Verify {
+				return cmdVerify(cmd.Context(), cfg)
+			}
+			if cfg.Benchmark {
+				return cmdBenchmark(cmd.Context(), cfg)
+			}
+			if cfg.Serve {
+				return runServer(cfg)
+			}
+			if cfg.Plan {
+				return cmdPlan(cmd.Context(), cfg)
 			}
-			return runNonInteractive(cfg)
+
+			// One migration at a time into a destination project. apply locks the project of
+			// the plan, --hook-listen locks for each sync, the coordinator for the whole
+			// distributed run (its workers take no lock).
+			writesDst := isMigration || cfg.Wizard || cfg.Rollback || cfg.Restore || cfg.Import
+			if writesDst && !cfg.DryRun && !cfg.Worker && cfg.HookListen == "" && cfg.DstOrg != "" && cfg.DstProject != "" {
+				release, err := acquireRunLocks(cfg)
+				if err != nil {
+					return err
+				}
+				defer release()
+			}
+			if cfg.Rollback {
+				return cmdRollback(cmd.Context(), cfg)
+			}
+			if cfg.Restore {
+				return cmdRestore(cmd.Context(), cfg)
+			}
+			if cfg.ValidateIDs {
+				return cmdValidateIdentityMap(cmd.Context(), cfg)
+			}
+			if cfg.Export {
+				return cmdExport(cmd.Context(), cfg)
+			}
+			if cfg.Import {
+				return cmdImport(cmd.Context(), cfg)
+			}
+			if cfg.Coordinator {
+				return runCoordinator(cfg)
+			}
+			if cfg.ProgressFile != "" {
+				progress = startProgress(cfg.ProgressFile)
+				defer progress.finish()
+			}
+			run := runNonInteractive
+			if cfg.Apply {
+				run = func(cfg Config) error { return cmdApply(cmd.Context(), cfg) }
+			} else if cfg.Daemon {
+				run = runDaemon
+			} else if cfg.HookListen != "" {
+				run = runHookListener
+			} else if cfg.Worker {
+				run = runWorker
+			} else if cfg.Wizard {
+				run = runWizard
+			}
+			if cfg.MintPAT && !cfg.DryRun {
+				return withMintedPAT(cmd.Context(), cfg, run)
+			}
+			return run(cfg)
 		},
 	}
 
 	// Flag definitions
 	rootCmd.Flags().StringVar(&cfg.SrcOrg, "src-org", "", "Source organization (required)")
-	rootCmd.Flags().StringVar(&cfg.SrcProject, "src-project", "", "Source project (required)")
+	rootCmd.Flags().Var(&srcProjectsFlag{first: &cfg.SrcProject, all: &cfg.SrcProjects}, "src-project", "Source project (required); several, comma separated or repeated, are merged into the destination project")
 	rootCmd.Flags().StringVar(&cfg.DstOrg, "dst-org", "", "Destination organization")
 	rootCmd.Flags().StringVar(&cfg.DstProject, "dst-project", "", "Destination project")
 	rootCmd.Flags().StringVarP(&cfg.Filter, "filter", "f", "", "Filter repositories with a regex")
-	rootCmd.Flags().StringVar(&repoListPath, "repo-list", "", "File with the list of repositories to migrate (one per line)")
+	rootCmd.Flags().StringSliceVar(&cfg.Globs, "glob", nil, "Filter repositories with glob patterns, comma separated (e.g. 'svc-*,api-?')")
+	rootCmd.Flags().StringVar(&cfg.IgnoreRepo, "ignore-repo", "", "Source repo whose /.migrateignore lists repo name patterns to exclude (e.g. migration-config)")
+	rootCmd.Flags().BoolVar(&cfg.ResolveOwners, "resolve-owners", false, "Look up the owner of each repo (most frequent recent committer) when not set in the repo list, shown in list, plan and reports")
+	rootCmd.Flags().DurationVar(&cfg.OwnerWindow, "owner-window", 180*24*time.Hour, "How far back commits are examined by --resolve-owners")
+	rootCmd.Flags().StringVar(&repoListPath, "repo-list", "", "File with the list of repositories to migrate (one per line), or a YAML manifest (.yaml/.yml) with per-repo overrides")
+	rootCmd.Flags().StringVar(&cfg.RetryFailed, "retry-failed", "", "JSON report of a previous run: migrate again only its failed repositories, with their destination names")
+	rootCmd.Flags().BoolVar(&cfg.RenameLowercase, "rename-lowercase", false, "Lowercase the destination repository names")
+	rootCmd.Flags().StringVar(&cfg.RenamePrefix, "rename-prefix", "", "Prefix added to the destination repository names")
+	rootCmd.Flags().StringVar(&cfg.RenameSuffix, "rename-suffix", "", "Suffix added to the destination repository names")
+	rootCmd.Flags().BoolVar(&cfg.SanitizeNames, "sanitize-names", false, "Replace characters and names rejected by Azure DevOps in destination names (recorded in the report)")
+	rootCmd.Flags().StringArrayVar(&renameRegex, "rename-regex", nil, "Sed-like substitution on destination names, e.g. 's/^Horse-/horse-/' (repeatable, flags g and i)")
+	rootCmd.Flags().StringArrayVar(&refRename, "ref-rename", nil, "Sed-like substitution on branch names pushed to destination, e.g. 's#^Feature/#feature/#' (repeatable, renames listed in the report)")
 	rootCmd.Flags().BoolVar(&cfg.DryRun, "dry-run", false, "Simulate execution without real changes")
 	rootCmd.Flags().BoolVar(&cfg.ForcePush, "force-push", false, "Force push if the repository exists in destination")
 	rootCmd.Flags().BoolVarP(&cfg.Trace, "trace", "t", false, "Enable detailed trace output")
-	rootCmd.Flags().BoolVarP(&cfg.ListOnly, "list-repos", "l", false, "List source repositories and exit")
+	rootCmd.Flags().StringVar(&cfg.TraceHTTP, "trace-http", "", "Append to this file every HTTP request and response (headers and the first 4 KB of the bodies, credentials redacted)")
+	rootCmd.Flags().BoolVarP(&cfg.ListOnly, "list-repos", "l", false, "List source repositories and exit (alias of the list command)")
+	_ = rootCmd.Flags().MarkHidden("list-repos")
+	rootCmd.Flags().StringVar(&cfg.Side, "side", SideSrc, "Side listed by list: src, dst or both (gap analysis of source repos already at destination)")
+	rootCmd.Flags().StringVar(&cfg.Sort, "sort", SortName, "Order of list: name, size or activity (last push)")
 	rootCmd.Flags().BoolVarP(&cfg.Wizard, "wizard", "w", false, "Start the interactive wizard procedure")
+	rootCmd.Flags().BoolVar(&cfg.NoTUI, "no-tui", false, "Line-based wizard instead of the full-screen one (always used when not on a terminal)")
 	rootCmd.Flags().BoolVarP(&cfg.ShowVersion, "version", "v", false, "Show program version")
 	rootCmd.Flags().StringSliceVar(&cfg.ReportFormats, "report-format", []string{}, "Migration report formats (json, html), comma separated")
 	rootCmd.Flags().StringVar(&cfg.ReportPath, "report-path", "", "Directory path to save the report (default: system temp directory)")
+	rootCmd.Flags().BoolVar(&cfg.ReportRedact, "report-redact", false, "Replace hostnames, organization and project names and URLs in the reports with stable hashes, to share them externally")
+	rootCmd.Flags().StringVar(&cfg.AuthMode, "auth", AuthModePAT, "Authentication mode: pat (SRC_PAT/DST_PAT), azcli (az account get-access-token), devicecode (Entra ID device login)")
+	rootCmd.Flags().StringVar(&cfg.TenantID, "tenant-id", "", "Entra ID tenant for --auth azcli/devicecode (default: account/organizations)")
+	rootCmd.Flags().DurationVar(&cfg.TokenRefresh, "token-refresh", time.Hour, "Read the PATs of --src-pat-file/--src-pat-cmd/--dst-pat-* again before the next repository after this interval (0 disables); Entra ID tokens are renewed before their expiry")
+	rootCmd.Flags().StringVar(&cfg.Protocol, "protocol", ProtocolHTTPS, "Git transport for clone and push: https or ssh")
+	rootCmd.Flags().StringVar(&cfg.Fallback, "fallback-protocol", "", "Protocol retried when a source clone fails on network/transport errors (e.g. ssh behind an interfering proxy)")
+	rootCmd.Flags().StringVar(&cfg.GitEngine, "git-engine", GitEngineSystem, "Engine of the mirror clones and pushes: system (installed git) or native (built-in go-git, the installed git only for huge and LFS repositories)")
+	rootCmd.Flags().StringVar(&cfg.GitCreds, "git-credentials", GitCredsHeader, "How git receives the credentials over HTTPS: header (Authorization header), helper (temporary credential helper), system (the configured helpers, e.g. Git Credential Manager)")
+	rootCmd.Flags().StringVar(&cfg.SSHKey, "ssh-key", "", "Private SSH key used with --protocol ssh (default: ssh agent/config)")
+	rootCmd.Flags().StringVar(&cfg.Proxy, "proxy", "", "HTTP(S) proxy URL used for API calls and git (overrides HTTPS_PROXY/HTTP_PROXY)")
+	rootCmd.Flags().StringVar(&cfg.NoProxy, "no-proxy", "", "Comma separated hosts/domains/CIDRs reached without proxy (added to NO_PROXY; replaces it with --proxy)")
+	rootCmd.Flags().BoolVar(&cfg.MintPAT, "mint-pat", false, "Use the Entra ID admin token only to mint a short-lived destination PAT for the pushes, revoked at the end of the run")
+	rootCmd.Flags().StringVar(&cfg.MintPATScope, "mint-pat-scope", "vso.code_manage", "Scopes of the minted PAT (space separated)")
+	rootCmd.Flags().DurationVar(&cfg.MintPATTTL, "mint-pat-ttl", 4*time.Hour, "Validity of the minted PAT; a PAT not revoked (e.g. after a crash) expires after it")
+	rootCmd.Flags().StringVar(&cfg.SrcPATFile, "src-pat-file", "", "Read the source PAT from a file instead of SRC_PAT")
+	rootCmd.Flags().StringVar(&cfg.SrcPATCmd, "src-pat-cmd", "", "Read the source PAT from the stdout of a command (e.g. 'vault kv get -field=pat secret/ado')")
+	rootCmd.Flags().StringVar(&cfg.DstPATFile, "dst-pat-file", "", "Read the destination PAT from a file instead of DST_PAT")
+	rootCmd.Flags().StringVar(&cfg.DstPATCmd, "dst-pat-cmd", "", "Read the destination PAT from the stdout of a command")
+	rootCmd.Flags().StringVar(&cfg.Provenance, "provenance", "", "Record source URL, run ID, tool version and operator on each migrated repository: tag (annotated tag) or note (git note in refs/notes/migration)")
+	rootCmd.Flags().StringVar(&cfg.ProvenanceTag, "provenance-tag", "migration/{date}", "Name of the provenance tag ({date}: UTC date, {run}: run ID)")
+	rootCmd.Flags().BoolVar(&cfg.ProvenanceSign, "provenance-sign", false, "Sign the provenance tag (git tag -s) with the git signing key configured for the operator")
+	rootCmd.Flags().BoolVar(&cfg.MigrateOpenPRs, "migrate-open-prs", false, "Recreate the active pull requests of each source repository at destination (title, description, branches, reviewers)")
+	rootCmd.Flags().BoolVar(&cfg.MigrateHooks, "migrate-service-hooks", false, "Recreate the service hooks (webhooks, Slack, Teams...) scoped to each source repository at destination; those with credentials are created disabled")
+	rootCmd.Flags().BoolVar(&pipelinesReport, "inventory-pipelines", false, "List in the report the build/YAML pipeline definitions of the source project building each migrated repository")
+	rootCmd.Flags().BoolVar(&pipelinesMigrate, "migrate-pipelines", false, "Recreate in the destination project the pipeline definitions building each migrated repository, pointed at the new repository")
+	rootCmd.Flags().BoolVar(&cfg.SkipDisabled, "skip-disabled", false, "Leave the disabled source repositories out of the run (SKIPPED: disabled)")
+	rootCmd.Flags().BoolVar(&cfg.KeepDisabled, "preserve-disabled", false, "Enable each disabled source repository for the time of its clone, then disable it again and disable the destination repository after the push")
+	rootCmd.Flags().StringVar(&identityMapFile, "identity-map", "", "CSV file of source,destination identities (source descriptor or user name -> destination UPN or [Project]\\Group) used by every feature referencing users")
+	rootCmd.Flags().BoolVar(&cfg.WorkItemRefs, "workitem-refs", false, "Inventory the work item mentions (#1234, AB#1234) of the commit messages of each repository in the report")
+	rootCmd.Flags().StringVar(&cfg.WorkItemCSV, "workitem-refs-csv", "", "CSV file receiving every work item mention with the commit URL at destination, to link the migrated work items again (implies --workitem-refs)")
+	rootCmd.Flags().StringVar(&cfg.LockSource, "lock-source", "", "Lock the source branches from the clone to the end of the push of each repository: default (default branch) or all")
+	rootCmd.Flags().BoolVar(&cfg.DeleteSource, "delete-source-after", false, "Delete each source repository once migrated and verified ref by ref at destination (requires --yes-i-am-sure)")
+	rootCmd.Flags().BoolVar(&yesIAmSure, "yes-i-am-sure", false, "Confirm --delete-source-after")
+	rootCmd.Flags().StringVar(&cfg.AuditLog, "audit-log", "", "File where the mutating actions (repository creations, pushes, force pushes, deletions, policy changes) are appended as hash-chained JSON lines (required by --delete-source-after)")
+	rootCmd.Flags().StringVar(&cfg.LockDir, "lock-dir", os.TempDir(), "Directory of the run locks preventing concurrent migrations into the same destination project; the default only covers this host: use a path shared between the operators' machines")
+	rootCmd.Flags().BoolVar(&cfg.ForceUnlock, "force-unlock", false, "Remove the run lock of the destination project left by an interrupted run, then take it")
+	rootCmd.Flags().StringVar(&submodulesMode, "submodules", "", "Map the .gitmodules URLs pointing at the source project to the destination: report (list the changes) or commit (new commit before the push)")
+	rootCmd.Flags().StringSliceVar(&submoduleBranches, "submodule-branches", nil, "Globs of the branches whose .gitmodules is updated (default: the default branch)")
+	rootCmd.Flags().StringVar(&rewriteConfig, "rewrite-config", "", "YAML file of history cleanup (paths, files over a size, text replacements) done with git filter-repo before pushing (rewrites commit SHAs)")
+	rootCmd.Flags().StringSliceVar(&cfg.ExcludePaths, "exclude-path", nil, "Path to remove from the whole history before pushing (repeatable, rewrites commit SHAs)")
+	rootCmd.Flags().StringVar(&cfg.OnSourceRemoved, "on-source-removed", OnSourceRemovedSkip, "Outcome of a source repo deleted between planning and clone: skip (SOURCE REMOVED) or error")
+	rootCmd.Flags().IntVar(&cfg.MinFreeGB, "min-free-gb", 0, "Pause before each clone while the work disk has less free space than this (GiB, 0 disables)")
+	rootCmd.Flags().StringVar(&cfg.DiskAlertWebhook, "disk-alert-webhook", "", "Slack/Teams incoming webhook alerted when the run pauses for low disk space")
+	rootCmd.Flags().StringVar(&cfg.ArtifactStore, "artifact-store", "", "Upload reports, backup bundles and progress file at the end of the run: azblob://container/path or s3://bucket/prefix")
+	rootCmd.Flags().StringVar(&cfg.ProgressFile, "progress-file", "", "JSON file refreshed with current repo, index/total, phase and timestamps, for external watchdogs")
+	rootCmd.Flags().IntVar(&cfg.Retries, "retries", 2, "Retries of a failed git clone/push (exponential backoff with jitter)")
+	rootCmd.Flags().DurationVar(&cfg.RetryDelay, "retry-delay", 10*time.Second, "Initial delay between retries, doubled at each attempt")
+	rootCmd.Flags().StringArrayVar(&cfg.GitConfig, "git-config", nil, "git setting key=value passed with 'git -c' to clone, fetch and push (repeatable, e.g. pack.threads=4)")
+	rootCmd.Flags().StringVar(&largeRepoSize, "large-repo-size", "1G", "Repositories from this size get larger HTTP buffers and bounded delta memory (0 to disable)")
+	rootCmd.Flags().StringVar(&skipLargerThan, "skip-larger-than", "0", "Skip the repositories larger than this size, e.g. 5G, reported as 'SKIPPED: size threshold' (0 for no limit)")
+	rootCmd.Flags().StringVar(&maxFileSize, "max-file-size", "100M", "Fail before the push when the history holds files larger than this (Azure DevOps limit without LFS; 0 to disable the scan)")
+	rootCmd.Flags().BoolVar(&cfg.SkipOversized, "skip-oversized", false, "Skip the repositories with files over --max-file-size instead of failing them")
+	rootCmd.Flags().BoolVar(&scanSecretsFlag, "scan-secrets", false, "Scan the history for credentials before the push and list the findings in the report")
+	rootCmd.Flags().StringVar(&secretRulesFile, "secret-rules", "", "YAML file of secret rules added to the built-in ones, with allowed paths (implies --scan-secrets)")
+	rootCmd.Flags().BoolVar(&cfg.BlockOnSecrets, "block-on-secrets", false, "Do not push the repositories where the secret scan finds credentials (implies --scan-secrets)")
+	rootCmd.Flags().StringVar(&maxBandwidth, "max-bandwidth", "0", "Limit of the git upload and of the download rate in bytes per second, e.g. 20M (0 for unlimited)")
+	rootCmd.Flags().StringVar(&estimateRate, "estimate-rate", "10M", "Transfer rate in bytes per second assumed by the duration estimate of --dry-run, e.g. the benchmark figure")
+	rootCmd.Flags().BoolVar(&cfg.Forecast, "forecast", false, "Before the run, print the volume to transfer, the largest repositories and the duration estimated with a bandwidth probe")
+	rootCmd.Flags().DurationVar(&cfg.RunTimeout, "run-timeout", 30*time.Minute, "Limit of the whole run (0 for unlimited)")
+	rootCmd.Flags().DurationVar(&cfg.RepoTimeout, "repo-timeout", 0, "Limit of the migration of one repository, marked as failed when exceeded (0 for unlimited)")
+	rootCmd.Flags().DurationVar(&cfg.CloneTimeout, "clone-timeout", 0, "Limit of one clone attempt (0 for unlimited)")
+	rootCmd.Flags().DurationVar(&cfg.PushTimeout, "push-timeout", 0, "Limit of one push attempt (0 for unlimited)")
+	rootCmd.Flags().BoolVar(&cfg.FinalSync, "final-sync", false, "Cutover pass after a full migration: lock source branches, push only changed refs and verify")
+	rootCmd.Flags().BoolVar(&cfg.RollbackOnFailure, "rollback-on-failure", false, "Delete the destination repositories created by this run that never reached OK (partial pushes)")
+	rootCmd.Flags().BoolVar(&cfg.Sync, "sync", false, "Incremental pass for repeated runs: update the cached source mirror and push only changed refs (deleted branches pruned)")
+	rootCmd.Flags().BoolVar(&cfg.Daemon, "daemon", false, "Keep running and repeat the --sync pass every --interval until stopped (SIGINT/SIGTERM)")
+	rootCmd.Flags().DurationVar(&cfg.Interval, "interval", 15*time.Minute, "Interval between the sync cycles of --daemon")
+	rootCmd.Flags().StringVar(&cfg.HookListen, "hook-listen", "", "Listen address (e.g. :8090) for source 'Code pushed' service hooks: each pushed repo gets an incremental sync")
+	rootCmd.Flags().StringVar(&cfg.HookSecret, "hook-secret", "", "Secret required in the service hook calls (X-Hook-Secret header or basic auth password); mandatory unless --hook-listen is a loopback address")
+	rootCmd.Flags().StringVar(&cfg.MinGitVersion, "min-git-version", defaultMinGitVersion, "Refuse to run with an older git")
+	rootCmd.Flags().StringVar(&cfg.TmpDir, "tmp-dir", "", "Directory receiving the temporary clones (default: system temp directory)")
+	rootCmd.Flags().BoolVar(&cfg.KeepTemp, "keep-temp", false, "Keep the temporary mirrors after the run, for debugging failed pushes")
+	rootCmd.Flags().StringVar(&cfg.WorkDir, "work-dir", "", "Keep the mirrors in this directory between runs and update them with a fetch instead of cloning again")
+	rootCmd.Flags().StringVar(&cfg.MirrorCache, "mirror-cache", "", "Directory keeping the source mirrors between --sync runs (default: user cache directory)")
+	rootCmd.Flags().StringVar(&cfg.BackupDir, "backup-dir", "", "Save the destination refs to a git bundle in this directory before every force push")
+	rootCmd.Flags().BoolVar(&cfg.BypassPolicies, "bypass-policies", false, "Temporarily disable blocking branch policies scoped to the destination repo during the push (requires policy edit permission)")
+	rootCmd.Flags().BoolVar(&cfg.Coordinator, "coordinator", false, "Distributed mode: split the selected repos in shards for workers and aggregate their reports")
+	rootCmd.Flags().BoolVar(&cfg.Worker, "worker", false, "Distributed mode: claim and migrate shards written by a coordinator")
+	rootCmd.Flags().StringVar(&cfg.StateDir, "state-dir", "", "Shared directory (NFS/SMB/Azure Files) holding shards and reports of a distributed run")
+	rootCmd.Flags().IntVar(&cfg.Shards, "shards", 4, "Number of shards written by --coordinator")
+	rootCmd.Flags().DurationVar(&cfg.ShardTimeout, "shard-timeout", 24*time.Hour, "How long --coordinator waits for the shard reports; the missing shards are reported as errors (0 for no limit)")
+	rootCmd.Flags().StringVar(&cfg.AdminDigest, "admin-digest", "", "File receiving the digest of the repositories created by the run (name, size, owner, URL) for the destination admins")
+	rootCmd.Flags().StringVar(&cfg.AdminDigestWebhook, "admin-digest-webhook", "", "Slack/Teams incoming webhook receiving the digest of the created repositories")
+	rootCmd.Flags().StringVar(&cfg.ApprovalWebhook, "approval-webhook", "", "Slack/Teams incoming webhook asking approval before unexpected force pushes (non-interactive runs)")
+	rootCmd.Flags().StringVar(&cfg.ApprovalListen, "approval-listen", "127.0.0.1:8089", "Listen address for approval callbacks (e.g. :8089 to let approvers on other machines reach it)")
+	rootCmd.Flags().StringVar(&cfg.ApprovalURL, "approval-url", "", "Public base URL of the approval listener used in the message links (default: http://<hostname>:<port>)")
+	rootCmd.Flags().DurationVar(&cfg.ApprovalTimeout, "approval-timeout", 15*time.Minute, "How long to wait for an approval decision")
+	rootCmd.Flags().StringVar(&cfg.ApprovalOnTimeout, "approval-on-timeout", ApprovalSkip, "Decision when the approval times out: skip or proceed")
+
+	rootCmd.AddCommand(newExplainCmd())
+	rootCmd.AddCommand(newCleanCmd())
+	rootCmd.AddCommand(newReportCmd())
+	rootCmd.AddCommand(newAuditCmd())
+	rootCmd.AddCommand(newDoctorCmd(rootCmd, &cfg))
+	rootCmd.AddCommand(newGenDocsCmd(rootCmd))
+	migrateCmd := newRunModeCmd(rootCmd, "migrate",
+		"Migrate the selected repositories (non-interactive, or interactive with --wizard)", nil)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(newRunModeCmd(rootCmd, "list",
+		"List the repositories of the source (--side dst/both: destination, gap analysis) with size and activity", &cfg.ListOnly))
+	rootCmd.AddCommand(newRunModeCmd(rootCmd, "diff",
+		"Compare source and destination: repos only in source, only in destination, or with mismatched ref counts", &cfg.Diff))
+	verifyCmd := newRunModeCmd(rootCmd, "verify",
+		"Prove the migration: compare the SHA of every ref of each mapped repo between source and destination", &cfg.Verify)
+	verifyCmd.Flags().BoolVar(&cfg.VerifyLFS, "lfs", false, "Also check that every LFS pointer at destination resolves to an uploaded object (requires git-lfs 3.2+)")
+	rootCmd.AddCommand(verifyCmd)
+	benchCmd := newRunModeCmd(rootCmd, "benchmark",
+		"Measure clone/push throughput and latency against the source and destination organizations", &cfg.Benchmark)
+	benchCmd.Flags().IntVar(&cfg.BenchSizeMB, "bench-size-mb", 50, "Size of the synthetic repository pushed and cloned (MiB)")
+	rootCmd.AddCommand(benchCmd)
+	statsCmd := newRunModeCmd(rootCmd, "stats",
+		"Aggregate the source project without migrating: repos, total size, branches, tags, largest repos, LFS usage", &cfg.Stats)
+	statsCmd.Flags().StringVar(&cfg.StatsFormat, "format", StatsTable, "Output: table or json")
+	rootCmd.AddCommand(statsCmd)
+	serveCmd := newRunModeCmd(rootCmd, "serve",
+		"Expose the migration engine behind a local HTTP API: submit jobs, poll their status, fetch their reports", &cfg.Serve)
+	serveCmd.Flags().StringVar(&cfg.ServeListen, "listen", "127.0.0.1:8088", "Listen address of the API")
+	serveCmd.Flags().StringVar(&cfg.ServeToken, "token", "", "Bearer token required by every API call (default: MIGRATE_API_TOKEN)")
+	serveCmd.Flags().StringVar(&cfg.ServeDir, "jobs-dir", "", "Directory keeping the job reports (default: system temp directory)")
+	rootCmd.AddCommand(serveCmd)
+	planCmd := newRunModeCmd(rootCmd, "plan",
+		"Write a signed plan file of the repos to create, force push or skip, with estimated sizes, for review", &cfg.Plan)
+	applyCmd := newRunModeCmd(rootCmd, "apply",
+		"Execute exactly a reviewed plan file (--plan-file), refusing it if the destination changed since", &cfg.Apply)
+	rollbackCmd := newRunModeCmd(rootCmd, "rollback",
+		"Delete the destination repositories created by a previous run (JSON report) that never reached OK and re-enable the policies it left disabled", &cfg.Rollback)
+	rollbackCmd.Flags().StringVar(&cfg.RollbackReport, "report", "", "JSON report of the run to roll back")
+	rootCmd.AddCommand(rollbackCmd)
+	restoreCmd := newRunModeCmd(rootCmd, "restore",
+		"Push the refs of a --backup-dir bundle back to a destination repository (undo of a force push)", &cfg.Restore)
+	restoreCmd.Flags().StringVar(&cfg.RestoreBackup, "backup", "", "Backup bundle to restore")
+	restoreCmd.Flags().StringVar(&cfg.RestoreRepo, "repo", "", "Destination repository receiving the backup")
+	rootCmd.AddCommand(restoreCmd)
+	identityCmd := &cobra.Command{
+		Use:   "identity-map",
+		Short: "Commands on the --identity-map file shared by the features referencing users and groups",
+	}
+	identityCmd.AddCommand(newRunModeCmd(rootCmd, "validate",
+		"Resolve every destination identity of --identity-map in the destination organization (Graph API)", &cfg.ValidateIDs))
+	rootCmd.AddCommand(identityCmd)
+	exportCmd := newRunModeCmd(rootCmd, "export",
+		"Write the selected source repositories to git bundles plus a manifest, for air-gapped migrations", &cfg.Export)
+	exportCmd.Flags().StringVar(&cfg.BundleDir, "bundle-dir", "", "Directory receiving the bundles and manifest.json")
+	rootCmd.AddCommand(exportCmd)
+	importCmd := newRunModeCmd(rootCmd, "import",
+		"Create the destination repositories and push them from the bundles written by export", &cfg.Import)
+	importCmd.Flags().StringVar(&cfg.BundleDir, "bundle-dir", "", "Directory holding the bundles and manifest.json written by export")
+	rootCmd.AddCommand(importCmd)
+	for _, c := range []*cobra.Command{planCmd, applyCmd} {
+		c.Flags().StringVar(&cfg.PlanFile, "plan-file", "", "Plan file written by plan and executed by apply")
+		c.Flags().StringVar(&cfg.PlanKey, "plan-key", "", "Key signing the plan file (HMAC-SHA256, default: MIGRATE_PLAN_KEY)")
+		rootCmd.AddCommand(c)
+	}
 
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
+		fmt.Fprintln(os.Stderr, "Error:", scrub(err.Error()))
 		os.Exit(1)
 	}
 }
+
+// newRunModeCmd returns a subcommand sharing the flags of the root command (organizations,
+// projects, credentials, repo list and renames) and its validations: it only turns on
+// the mode flag, dispatched by the root command instead of a migration (nil: the migration).
+func newRunModeCmd(rootCmd *cobra.Command, use, short string, mode *bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if mode != nil {
+				*mode = true
+			}
+			return rootCmd.RunE(cmd, args)
+		},
+	}
+	cmd.Flags().AddFlagSet(rootCmd.Flags())
+	return cmd
+}
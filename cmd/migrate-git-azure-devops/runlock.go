@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RunLock is the content of a lock file: who is migrating into a destination project.
+type RunLock struct {
+	Org      string    `json:"org"`
+	Project  string    `json:"project"`
+	Operator string    `json:"operator"`
+	Host     string    `json:"host"`
+	PID      int       `json:"pid"`
+	RunID    string    `json:"runId"`
+	Started  time.Time `json:"started"`
+}
+
+// lockNameRe matches the characters replaced in the lock file names.
+var lockNameRe = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// runLockPath returns the lock file of a destination organization/project in --lock-dir.
+func runLockPath(dir, org, project string) string {
+	name := lockNameRe.ReplaceAllString(strings.ToLower(org+"_"+project), "-")
+	return filepath.Join(dir, "migrate-git-azure-devops."+name+".lock")
+}
+
+// lockedProjects returns the destination organizations/projects written by the run:
+// --dst-org/--dst-project, the per-repo projects and the additional destinations of the
+// manifest.
+func lockedProjects(cfg Config) []Destination {
+	locked := []Destination{{Org: cfg.DstOrg, Project: cfg.DstProject}}
+	add := func(org, project string) {
+		if project == "" || slices.ContainsFunc(locked, func(d Destination) bool {
+			return strings.EqualFold(d.Org, org) && strings.EqualFold(d.Project, project)
+		}) {
+			return
+		}
+		locked = append(locked, Destination{Org: org, Project: project})
+	}
+	for _, o := range cfg.RepoOverrides {
+		add(cfg.DstOrg, o.DstProject)
+	}
+	for _, m := range cfg.Mirrors {
+		add(m.Org, m.Project)
+	}
+	sort.Slice(locked[1:], func(i, j int) bool {
+		return locked[1+i].Org+"/"+locked[1+i].Project < locked[1+j].Org+"/"+locked[1+j].Project
+	})
+	return locked
+}
+
+// localLockWarning prints, once per process, that the default --lock-dir only protects the
+// runs of this host.
+var localLockWarning sync.Once
+
+// acquireRunLocks takes the lock of every destination project written by the run, so that
+// two operators can't run overlapping migrations into the same project, and returns the
+// function releasing them, which also runs on SIGINT/SIGTERM while the locks are held. A lock
+// left by a killed run is removed by --force-unlock.
+func acquireRunLocks(cfg Config) (func(), error) {
+	if filepath.Clean(cfg.LockDir) == filepath.Clean(os.TempDir()) {
+		localLockWarning.Do(func() {
+			fmt.Fprintf(os.Stderr, "Warning: the run lock is in the local temp directory %s and doesn't stop migrations started from other machines: set --lock-dir to a shared path\n", cfg.LockDir)
+		})
+	}
+	var (
+		held   []string
+		once   sync.Once
+		remove = func() {}
+	)
+	release := func() {
+		once.Do(func() {
+			defer remove()
+			for _, path := range held {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					fmt.Fprintln(os.Stderr, "Error removing the run lock:", err)
+				}
+			}
+		})
+	}
+	remove = onInterrupt(release)
+	host, _ := os.Hostname()
+	for _, d := range lockedProjects(cfg) {
+		lock := RunLock{Org: d.Org, Project: d.Project, Operator: operator(), Host: host,
+			PID: os.Getpid(), RunID: runID, Started: time.Now().UTC()}
+		path := runLockPath(cfg.LockDir, d.Org, d.Project)
+		if cfg.ForceUnlock {
+			if owner, err := readRunLock(path); err == nil {
+				fmt.Printf("Removing the lock of %s/%s held by %s\n", d.Org, d.Project, owner)
+			}
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				release()
+				return nil, fmt.Errorf("removing the run lock: %w", err)
+			}
+		}
+		if err := writeRunLock(path, lock); err != nil {
+			release()
+			if !errors.Is(err, os.ErrExist) {
+				return nil, fmt.Errorf("creating the run lock: %w", err)
+			}
+			owner, rerr := readRunLock(path)
+			if rerr != nil {
+				owner = rerr.Error()
+			}
+			return nil, fmt.Errorf("%s/%s is locked by another migration (%s, lock %s): wait for it to end, or use --force-unlock if that run is no longer running",
+				d.Org, d.Project, owner, path)
+		}
+		held = append(held, path)
+	}
+	return release, nil
+}
+
+// writeRunLock creates the lock file, failing with os.ErrExist if already present.
+func writeRunLock(path string, lock RunLock) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// readRunLock describes the owner of a lock file: operator, host, PID, run ID and start.
+func readRunLock(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var lock RunLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return "", fmt.Errorf("unreadable lock file: %w", err)
+	}
+	return fmt.Sprintf("%s@%s, PID %d, run %s, since %s", lock.Operator, lock.Host, lock.PID, lock.RunID,
+		lock.Started.Local().Format("2006-01-02 15:04:05")), nil
+}
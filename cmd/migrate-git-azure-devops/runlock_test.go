@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestAcquireRunLocksInterruptHook(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{DstOrg: "fabrikam", DstProject: "Platform", LockDir: dir}
+	hooks := func() int {
+		interruptMu.Lock()
+		defer interruptMu.Unlock()
+		return len(interruptHooks)
+	}
+	before := hooks()
+
+	release, err := acquireRunLocks(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Held locks are released on SIGINT/SIGTERM too
+	if got := hooks(); got != before+1 {
+		t.Errorf("%d interrupt hooks while the lock is held, want %d", got, before+1)
+	}
+	if _, err := acquireRunLocks(cfg); err == nil {
+		t.Error("second lock of the same project acquired")
+	}
+	release()
+	release()
+	if got := hooks(); got != before {
+		t.Errorf("%d interrupt hooks after the release, want %d", got, before)
+	}
+	if _, err := os.Stat(runLockPath(dir, "fabrikam", "Platform")); !os.IsNotExist(err) {
+		t.Errorf("lock not removed: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ResultSecrets marks repositories not pushed because --block-on-secrets found credentials.
+const ResultSecrets = "ERROR: secrets found"
+
+// maxScannedBlob is the size above which blobs are not scanned (generated or binary content).
+const maxScannedBlob = 1 << 20
+
+// SecretRule is a pattern of credential, matched line by line on the files of the history.
+type SecretRule struct {
+	ID          string `yaml:"id"`
+	Description string `yaml:"description"`
+	Regex       string `yaml:"regex"`
+	re          *regexp.Regexp
+}
+
+// SecretRules is the format of the --secret-rules file: rules added to the built-in ones
+// and path regexes whose files are not scanned (fixtures, docs).
+type SecretRules struct {
+	Rules      []SecretRule `yaml:"rules"`
+	AllowPaths []string     `yaml:"allowPaths"`
+}
+
+// defaultSecretRules are the built-in rules, for the credentials most often leaked in
+// Azure DevOps repositories.
+var defaultSecretRules = []SecretRule{
+	{ID: "private-key", Description: "Private key", Regex: `-----BEGIN ((RSA|DSA|EC|OPENSSH|PGP) )?PRIVATE KEY( BLOCK)?-----`},
+	{ID: "aws-access-key", Description: "AWS access key ID", Regex: `\b(AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{ID: "azure-storage-key", Description: "Azure storage connection string", Regex: `AccountKey=[A-Za-z0-9+/]{86}==`},
+	{ID: "azure-sas", Description: "Azure shared access signature", Regex: `[?&]sig=[A-Za-z0-9%+/]{43,}(%3D|=)`},
+	{ID: "github-token", Description: "GitHub token", Regex: `\bgh[pousr]_[A-Za-z0-9]{36}\b`},
+	{ID: "slack-token", Description: "Slack token", Regex: `\bxox[baprs]-[0-9A-Za-z-]{10,}`},
+	{ID: "google-api-key", Description: "Google API key", Regex: `\bAIza[0-9A-Za-z_-]{35}\b`},
+	{ID: "password-in-url", Description: "Password in a URL", Regex: `[a-z][a-z0-9+.-]*://[^/\s:@]+:[^/\s:@]{3,}@[^/\s]+`},
+}
+
+// compiledSecretRules holds the rules of the run and the allowed paths, set by loadSecretRules.
+type compiledSecretRules struct {
+	rules []SecretRule
+	allow []*regexp.Regexp
+}
+
+// loadSecretRules compiles the built-in rules plus those of the optional rules file.
+func loadSecretRules(file string) (*compiledSecretRules, error) {
+	var extra SecretRules
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading --secret-rules: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &extra); err != nil {
+			return nil, fmt.Errorf("invalid secret rules %s: %w", file, err)
+		}
+	}
+	for i, r := range extra.Rules {
+		if r.ID == "" || r.Regex == "" {
+			return nil, fmt.Errorf("invalid secret rules %s: rule %d needs an id and a regex", file, i+1)
+		}
+	}
+	out := &compiledSecretRules{}
+	for _, r := range append(append([]SecretRule{}, defaultSecretRules...), extra.Rules...) {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret rules %s: rule %s: %w", file, r.ID, err)
+		}
+		r.re = re
+		out.rules = append(out.rules, r)
+	}
+	for _, p := range extra.AllowPaths {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret rules %s: allowPaths %q: %w", file, p, err)
+		}
+		out.allow = append(out.allow, re)
+	}
+	return out, nil
+}
+
+// allowed reports whether the file at path is excluded from the scan.
+func (c *compiledSecretRules) allowed(path string) bool {
+	for _, re := range c.allow {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanSecrets scans every file version reachable from the refs of the mirror and returns
+// the findings as "rule path:line (blob sha)". The matched values are never returned,
+// so that the report does not spread them further.
+func scanSecrets(ctx context.Context, repoDir string, rules *compiledSecretRules) ([]string, error) {
+	revList := exec.CommandContext(ctx, "git", "-C", repoDir, "rev-list", "--objects", "--all")
+	objects, err := revList.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	catFile := exec.CommandContext(ctx, "git", "-C", repoDir, "cat-file",
+		"--batch=%(objecttype) %(objectname) %(objectsize) %(rest)")
+	catFile.Stdin = objects
+	out, err := catFile.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := revList.Start(); err != nil {
+		return nil, err
+	}
+	if err := catFile.Start(); err != nil {
+		_ = revList.Wait()
+		return nil, err
+	}
+
+	findings, readErr := readSecretFindings(bufio.NewReaderSize(out, 64<<10), rules)
+	if readErr != nil {
+		_ = catFile.Process.Kill()
+	}
+	revErr, catErr := revList.Wait(), catFile.Wait()
+	switch {
+	case readErr != nil:
+		return nil, readErr
+	case revErr != nil:
+		return nil, fmt.Errorf("git rev-list: %w", revErr)
+	case catErr != nil:
+		return nil, fmt.Errorf("git cat-file: %w", catErr)
+	}
+	return findings, nil
+}
+
+// readSecretFindings reads the "git cat-file --batch" output of the history and scans the blobs.
+func readSecretFindings(br *bufio.Reader, rules *compiledSecretRules) ([]string, error) {
+	var findings []string
+	for {
+		header, err := br.ReadString('\n')
+		if err == io.EOF {
+			return findings, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.SplitN(strings.TrimSuffix(header, "\n"), " ", 4)
+		if len(fields) < 3 {
+			continue // "<sha> missing"
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected git cat-file output %q", header)
+		}
+		path := ""
+		if len(fields) == 4 {
+			path = fields[3]
+		}
+		// Each content is followed by a newline
+		if fields[0] != "blob" || size > maxScannedBlob || rules.allowed(path) {
+			if _, err := br.Discard(int(size) + 1); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		content := make([]byte, size+1)
+		if _, err := io.ReadFull(br, content); err != nil {
+			return nil, err
+		}
+		findings = append(findings, scanBlob(content[:size], fields[1], path, rules)...)
+	}
+}
+
+// scanBlob matches the rules on the lines of a text file; binary files are skipped.
+func scanBlob(content []byte, sha, path string, rules *compiledSecretRules) []string {
+	if bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0 {
+		return nil
+	}
+	var findings []string
+	for n, line := range bytes.Split(content, []byte("\n")) {
+		for _, r := range rules.rules {
+			if r.re.Match(line) {
+				findings = append(findings, fmt.Sprintf("%s %s:%d (blob %s)", r.ID, path, n+1, sha[:12]))
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Job states of the serve subcommand.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// JobRequest is the JSON body submitting a migration job. Empty organizations and projects
+// default to the flags the server was started with; credentials are always the server's.
+type JobRequest struct {
+	SrcOrg       string            `json:"srcOrg"`
+	SrcProject   string            `json:"srcProject"`
+	DstOrg       string            `json:"dstOrg"`
+	DstProject   string            `json:"dstProject"`
+	Repos        []string          `json:"repos"`        // Source repositories to migrate
+	Destinations map[string]string `json:"destinations"` // Optional destination name per source repository
+	Filter       string            `json:"filter"`       // Regex used when repos is empty
+	DryRun       bool              `json:"dryRun"`
+	ForcePush    bool              `json:"forcePush"`
+}
+
+// Job is a submitted migration and its status, as returned by the API.
+type Job struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Request    JobRequest `json:"request"`
+	Submitted  time.Time  `json:"submitted"`
+	Started    *time.Time `json:"started,omitempty"`
+	Finished   *time.Time `json:"finished,omitempty"`
+	Repos      int        `json:"repos,omitempty"`  // Repositories in the report
+	Failed     int        `json:"failed,omitempty"` // Repositories with an ERROR result
+	reportPath string
+}
+
+// jobServer runs the submitted jobs one at a time: the migration engine relies on
+// process-wide state (phase clock, progress file), so jobs never overlap.
+type jobServer struct {
+	cfg   Config
+	dir   string
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	queue chan *Job
+}
+
+// runServer exposes the migration engine behind a local HTTP API (serve subcommand):
+//
+//	POST /jobs              submit a job (JobRequest), 202 with the job
+//	GET  /jobs              list the jobs
+//	GET  /jobs/{id}         status of a job
+//	GET  /jobs/{id}/report  JSON report of a finished job
+//
+// Every call needs "Authorization: Bearer <--token>". It stops on SIGINT/SIGTERM
+// after the running job.
+func runServer(cfg Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	dir := cfg.ServeDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), prog()+"-jobs")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("creating the jobs directory: %w", err)
+	}
+	cfg.ForceUnlock = false // each job takes the locks: removing them would defeat them
+	s := &jobServer{cfg: cfg, dir: dir, jobs: map[string]*Job{}, queue: make(chan *Job, 100)}
+
+	ln, err := net.Listen("tcp", cfg.ServeListen)
+	if err != nil {
+		return fmt.Errorf("API listener on %s: %w", cfg.ServeListen, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.auth(s.submit))
+	mux.HandleFunc("GET /jobs", s.auth(s.list))
+	mux.HandleFunc("GET /jobs/{id}", s.auth(s.status))
+	mux.HandleFunc("GET /jobs/{id}/report", s.auth(s.report))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintln(os.Stderr, "API server error:", err)
+		}
+	}()
+	fmt.Printf("Migration API listening on http://%s (jobs in %s)\n", ln.Addr(), dir)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for job := range s.queue {
+			s.run(job)
+		}
+	}()
+
+	<-ctx.Done()
+	fmt.Println("Stop requested, API server exiting after the running job.")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = server.Shutdown(shutdownCtx)
+	close(s.queue)
+	<-done
+	return nil
+}
+
+// auth rejects the calls without the bearer token.
+func (s *jobServer) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.ServeToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// submit validates and queues a job.
+func (s *jobServer) submit(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid job: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.SrcOrg = defaultString(req.SrcOrg, s.cfg.SrcOrg)
+	req.SrcProject = defaultString(req.SrcProject, s.cfg.SrcProject)
+	req.DstOrg = defaultString(req.DstOrg, s.cfg.DstOrg)
+	req.DstProject = defaultString(req.DstProject, s.cfg.DstProject)
+	if req.SrcOrg == "" || req.SrcProject == "" || req.DstOrg == "" || req.DstProject == "" {
+		http.Error(w, "invalid job: srcOrg, srcProject, dstOrg and dstProject are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Repos) == 0 && req.Filter == "" {
+		http.Error(w, "invalid job: repos or filter is required", http.StatusBadRequest)
+		return
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	job := &Job{ID: hex.EncodeToString(id), Status: JobQueued, Request: req, Submitted: time.Now()}
+	job.reportPath = filepath.Join(s.dir, job.ID+".json")
+	s.mu.Lock()
+	out := *job
+	select {
+	case s.queue <- job:
+		s.jobs[job.ID] = job
+	default:
+		s.mu.Unlock()
+		http.Error(w, "job queue full", http.StatusServiceUnavailable)
+		return
+	}
+	s.mu.Unlock()
+	fmt.Printf("[%s] job %s queued\n", time.Now().Format(time.RFC3339), job.ID)
+	s.writeJSON(w, http.StatusAccepted, out)
+}
+
+// list returns all the jobs, most recent first.
+func (s *jobServer) list(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, *j)
+	}
+	s.mu.Unlock()
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Submitted.After(jobs[j].Submitted) })
+	s.writeJSON(w, http.StatusOK, jobs)
+}
+
+// status returns a job.
+func (s *jobServer) status(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	job, ok := s.jobs[r.PathValue("id")]
+	var out Job
+	if ok {
+		out = *job
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, out)
+}
+
+// report returns the JSON report of a finished job.
+func (s *jobServer) report(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	job, ok := s.jobs[r.PathValue("id")]
+	finished := ok && (job.Status == JobSucceeded || job.Status == JobFailed)
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	if !finished {
+		http.Error(w, "job not finished", http.StatusConflict)
+		return
+	}
+	data, err := os.ReadFile(job.reportPath)
+	if err != nil {
+		http.Error(w, "report not available", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// run executes a job with the non-interactive engine; the report is written like the
+// report of a distributed shard.
+func (s *jobServer) run(job *Job) {
+	now := time.Now()
+	s.mu.Lock()
+	job.Status, job.Started = JobRunning, &now
+	s.mu.Unlock()
+	fmt.Printf("[%s] job %s started\n", now.Format(time.RFC3339), job.ID)
+
+	req := job.Request
+	// s.cfg holds the tokens of the start of the server
+	cfg := s.cfg
+	refreshCredentials(context.Background(), &cfg)
+	cfg.SrcOrg, cfg.SrcProject = req.SrcOrg, req.SrcProject
+	cfg.DstOrg, cfg.DstProject = req.DstOrg, req.DstProject
+	cfg.Filter, cfg.Globs = req.Filter, nil
+	cfg.RepoList, cfg.RepoMap = req.Repos, map[string]string{}
+	for src, dst := range req.Destinations {
+		cfg.RepoMap[src] = dst
+	}
+	cfg.RepoOwners, cfg.RepoOverrides = nil, nil
+	cfg.DryRun, cfg.ForcePush = req.DryRun, req.ForcePush
+	cfg.ShardReport = job.reportPath
+	// A project locked by another run fails the job, the server goes on
+	var err error
+	release := func() {}
+	if !cfg.DryRun {
+		release, err = acquireRunLocks(cfg)
+	}
+	if err == nil {
+		err = runNonInteractive(cfg)
+		release()
+	}
+
+	var report Report
+	var failed int
+	if data, rerr := os.ReadFile(job.reportPath); rerr == nil && json.Unmarshal(data, &report) == nil {
+		for _, sum := range report.Summaries {
+			if strings.HasPrefix(sum.Result, "ERROR") {
+				failed++
+			}
+		}
+	}
+	end := time.Now()
+	s.mu.Lock()
+	job.Finished = &end
+	job.Repos, job.Failed = len(report.Summaries), failed
+	job.Status = JobSucceeded
+	if err != nil {
+		// Returned by GET /jobs: git and API errors may carry remote URLs and headers
+		job.Status, job.Error = JobFailed, scrub(err.Error())
+	} else if job.Failed > 0 {
+		job.Status = JobFailed
+		job.Error = fmt.Sprintf("%d repositories failed", job.Failed)
+	}
+	s.mu.Unlock()
+	fmt.Printf("[%s] job %s %s\n", end.Format(time.RFC3339), job.ID, job.Status)
+}
+
+// writeJSON writes v as the JSON response.
+func (s *jobServer) writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing API response:", err)
+	}
+}
+
+// defaultString returns v, or def when v is empty.
+func defaultString(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
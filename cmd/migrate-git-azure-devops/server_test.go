@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobRefreshesCredentials(t *testing.T) {
+	headers := recordAuthHeaders(t)
+	s := &jobServer{cfg: Config{SrcPAT: "src-start", DstPAT: "dst-start", TokenRefresh: time.Hour,
+		creds: &credentials{src: staticCredential("src-rotated-7f3a"), srcToken: "src-start", dstToken: "dst-start"}}}
+	job := &Job{ID: "job-1", reportPath: filepath.Join(t.TempDir(), "job-1.json"),
+		Request: JobRequest{SrcOrg: "contoso", SrcProject: "Horse", DstOrg: "fabrikam", DstProject: "Platform", Filter: ".*", DryRun: true}}
+
+	s.run(job)
+	if job.Status != JobFailed {
+		t.Errorf("status = %s, want %s with every request refused", job.Status, JobFailed)
+	}
+	got := headers()
+	if len(got) == 0 {
+		t.Fatal("no request to the source")
+	}
+	if want := authHeader("src-rotated-7f3a"); got[0] != want {
+		t.Errorf("Authorization = %q, want the refreshed token %q", got[0], want)
+	}
+}
+
+func TestJobFailsOnLockedProject(t *testing.T) {
+	headers := recordAuthHeaders(t)
+	dir := t.TempDir()
+	if err := writeRunLock(runLockPath(dir, "fabrikam", "Platform"), RunLock{Org: "fabrikam", Project: "Platform", Operator: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	s := &jobServer{cfg: Config{SrcPAT: "src-start", DstPAT: "dst-start", LockDir: dir}}
+	newJob := func(id, dstProject string) *Job {
+		return &Job{ID: id, reportPath: filepath.Join(dir, id+".json"),
+			Request: JobRequest{SrcOrg: "contoso", SrcProject: "Horse", DstOrg: "fabrikam", DstProject: dstProject, Filter: ".*"}}
+	}
+
+	locked := newJob("job-1", "Platform")
+	s.run(locked)
+	if locked.Status != JobFailed || !strings.Contains(locked.Error, "is locked by another migration") {
+		t.Errorf("job into a locked project: %s %q, want failed on the lock", locked.Status, locked.Error)
+	}
+	if n := len(headers()); n > 0 {
+		t.Errorf("%d requests for a job into a locked project", n)
+	}
+
+	// The next job runs, and releases its own lock
+	other := newJob("job-2", "Tools")
+	s.run(other)
+	if len(headers()) == 0 {
+		t.Error("job into an unlocked project not run")
+	}
+	if _, err := os.Stat(runLockPath(dir, "fabrikam", "Tools")); !os.IsNotExist(err) {
+		t.Errorf("lock of the job not released: %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// HookSubscription is a service hook subscription (webhook, Slack, Teams, Jenkins...).
+type HookSubscription struct {
+	ID               string            `json:"id,omitempty"`
+	PublisherID      string            `json:"publisherId"`
+	EventType        string            `json:"eventType"`
+	ResourceVersion  string            `json:"resourceVersion,omitempty"`
+	ConsumerID       string            `json:"consumerId"`
+	ConsumerActionID string            `json:"consumerActionId"`
+	PublisherInputs  map[string]string `json:"publisherInputs"`
+	ConsumerInputs   map[string]string `json:"consumerInputs"`
+	Status           string            `json:"status,omitempty"`
+}
+
+// describe returns "event -> consumer (url)" for the report.
+func (s HookSubscription) describe() string {
+	d := s.EventType + " -> " + s.ConsumerID
+	if u := s.ConsumerInputs["url"]; u != "" {
+		d += " (" + u + ")"
+	}
+	return d
+}
+
+// secretHookInputs are the consumer inputs holding credentials. Azure DevOps returns them
+// masked: they must be entered again on the recreated subscription.
+var secretHookInputs = []string{"basicAuthPassword", "password", "apiToken", "accessToken", "authToken", "httpHeaders", "secret"}
+
+// isSecretHookInput reports whether a consumer input set at source is a credential, known by
+// name or masked.
+func isSecretHookInput(key, value string) bool {
+	return value != "" && (slices.Contains(secretHookInputs, key) || strings.Trim(value, "*") == "")
+}
+
+// getHookSubscriptions returns the service hook subscriptions of the Azure Repos publisher
+// ("tfs") of an organization.
+func getHookSubscriptions(ctx context.Context, org, pat string, trace bool) ([]HookSubscription, error) {
+	path := fmt.Sprintf("_apis/hooks/subscriptions?publisherId=tfs&api-version=%s", apiVersion)
+	body, code, err := httpReq(ctx, "GET", org, "", path, pat, nil, trace)
+	if err != nil {
+		return nil, err
+	}
+	if code < 200 || code >= 300 {
+		return nil, fmt.Errorf("API error listing service hooks (HTTP %d): %s", code, string(body))
+	}
+	var resp struct {
+		Value []HookSubscription `json:"value"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return resp.Value, nil
+}
+
+// createHookSubscription creates a service hook subscription.
+func createHookSubscription(ctx context.Context, org, pat string, sub HookSubscription, trace bool) error {
+	path := fmt.Sprintf("_apis/hooks/subscriptions?api-version=%s", apiVersion)
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return fmt.Errorf("error encoding payload: %w", err)
+	}
+	body, code, err := httpReq(ctx, "POST", org, "", path, pat, data, trace)
+	if err != nil {
+		return err
+	}
+	if code != 200 && code != 201 {
+		return fmt.Errorf("API error creating service hook (HTTP %d): %s", code, string(body))
+	}
+	return nil
+}
+
+// srcHooks caches the source subscriptions, listed once per run.
+var srcHooks struct {
+	once sync.Once
+	subs []HookSubscription
+	err  error
+}
+
+// migrateServiceHooks recreates the service hook subscriptions scoped to the source
+// repository (--migrate-service-hooks) on the destination one, same event, filters and
+// consumer. Credentials can't be read back from the source: the subscriptions that had
+// some are created disabled, listed in sum.HookSecrets with the inputs to enter again.
+// Subscriptions already present at destination are not duplicated.
+func migrateServiceHooks(ctx context.Context, cfg Config, r Repo, dstRepoName string, sum *Summary) {
+	srcHooks.once.Do(func() {
+		srcHooks.subs, srcHooks.err = getHookSubscriptions(ctx, cfg.SrcOrg, cfg.SrcPAT, cfg.Trace)
+	})
+	if srcHooks.err != nil {
+		sum.HooksNotCreated = append(sum.HooksNotCreated, "listing the source service hooks failed: "+srcHooks.err.Error())
+		return
+	}
+	var subs []HookSubscription
+	for _, s := range srcHooks.subs {
+		if strings.EqualFold(s.PublisherInputs["repository"], r.ID) {
+			subs = append(subs, s)
+		}
+	}
+	if len(subs) == 0 {
+		return
+	}
+
+	dstRepoID := sum.DstRepoID
+	if dstRepoID == "" {
+		dst, err := getRepo(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, dstRepoName, cfg.Trace)
+		if err != nil {
+			sum.HooksNotCreated = append(sum.HooksNotCreated, "reading the destination repository failed: "+err.Error())
+			return
+		}
+		dstRepoID = dst.ID
+	}
+	projectID, err := getProjectID(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, cfg.Trace)
+	if err != nil {
+		sum.HooksNotCreated = append(sum.HooksNotCreated, err.Error())
+		return
+	}
+	existing, err := getHookSubscriptions(ctx, cfg.DstOrg, cfg.DstPAT, cfg.Trace)
+	if err != nil {
+		sum.HooksNotCreated = append(sum.HooksNotCreated, "listing the destination service hooks failed: "+err.Error())
+		return
+	}
+
+	for _, s := range subs {
+		sub := HookSubscription{
+			PublisherID:      s.PublisherID,
+			EventType:        s.EventType,
+			ResourceVersion:  s.ResourceVersion,
+			ConsumerID:       s.ConsumerID,
+			ConsumerActionID: s.ConsumerActionID,
+			PublisherInputs:  maps.Clone(s.PublisherInputs),
+			ConsumerInputs:   map[string]string{},
+			Status:           s.Status,
+		}
+		delete(sub.PublisherInputs, "tfsSubscriptionId")
+		sub.PublisherInputs["projectId"] = projectID
+		sub.PublisherInputs["repository"] = dstRepoID
+		var secrets []string
+		for k, v := range s.ConsumerInputs {
+			if isSecretHookInput(k, v) {
+				secrets = append(secrets, k)
+				continue
+			}
+			sub.ConsumerInputs[k] = v
+		}
+		slices.Sort(secrets)
+
+		if slices.ContainsFunc(existing, func(e HookSubscription) bool {
+			return e.EventType == sub.EventType && e.ConsumerID == sub.ConsumerID &&
+				e.ConsumerActionID == sub.ConsumerActionID &&
+				strings.EqualFold(e.PublisherInputs["repository"], dstRepoID) &&
+				e.ConsumerInputs["url"] == sub.ConsumerInputs["url"]
+		}) {
+			sum.HooksNotCreated = append(sum.HooksNotCreated, sub.describe()+": already at destination")
+			continue
+		}
+		// Without its credentials the subscription would call the consumer unauthenticated
+		if len(secrets) > 0 {
+			sub.Status = "disabledByUser"
+		}
+		if err := createHookSubscription(ctx, cfg.DstOrg, cfg.DstPAT, sub, cfg.Trace); err != nil {
+			sum.HooksNotCreated = append(sum.HooksNotCreated, sub.describe()+": "+err.Error())
+			continue
+		}
+		sum.ServiceHooks = append(sum.ServiceHooks, sub.describe())
+		fmt.Println("  Service hook recreated:", sub.describe())
+		if len(secrets) > 0 {
+			sum.HookSecrets = append(sum.HookSecrets, sub.describe()+": "+strings.Join(secrets, ", "))
+			fmt.Printf("  WARNING: service hook created disabled, enter again %s and enable it\n", strings.Join(secrets, ", "))
+		}
+	}
+}
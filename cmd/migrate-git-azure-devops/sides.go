@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// listBothSides prints a gap analysis between source and destination: for every source
+// repository whether its destination (after mapping and renames) already exists, then the
+// repositories present only at destination. Nothing is cloned or created.
+func listBothSides(ctx context.Context, cfg Config) error {
+	srcRepos, err := getRepos(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, cfg.Trace)
+	if err != nil {
+		return fmt.Errorf("call failed for source %s/%s: %w", cfg.SrcOrg, cfg.SrcProject, err)
+	}
+	dstRepos, err := getRepos(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, cfg.Trace)
+	if err != nil {
+		return fmt.Errorf("call failed for destination %s/%s: %w", cfg.DstOrg, cfg.DstProject, err)
+	}
+	sort.Slice(srcRepos, func(i, j int) bool { return strings.ToLower(srcRepos[i].Name) < strings.ToLower(srcRepos[j].Name) })
+
+	dstByKey := map[string]string{}
+	for _, r := range dstRepos {
+		dstByKey[strings.ToLower(r.Name)] = r.Name
+	}
+	matched := map[string]bool{}
+	present := 0
+	fmt.Printf("Source %s/%s -> destination %s/%s:\n\n", cfg.SrcOrg, cfg.SrcProject, cfg.DstOrg, cfg.DstProject)
+	for _, r := range srcRepos {
+		dst := destinationName(cfg, r.Name)
+		name := r.Name
+		if dst != r.Name {
+			name += " -> " + dst
+		}
+		existing, ok := dstByKey[strings.ToLower(dst)]
+		switch {
+		case ok && existing == dst:
+			present++
+			fmt.Printf("  [present] %s\n", name)
+		case ok:
+			present++
+			fmt.Printf("  [present] %s (as %q, case differs)\n", name, existing)
+		default:
+			fmt.Printf("  [missing] %s\n", name)
+		}
+		if ok {
+			matched[strings.ToLower(existing)] = true
+		}
+	}
+
+	var onlyDst []string
+	for _, r := range dstRepos {
+		if !matched[strings.ToLower(r.Name)] {
+			onlyDst = append(onlyDst, r.Name)
+		}
+	}
+	sort.Slice(onlyDst, func(i, j int) bool { return strings.ToLower(onlyDst[i]) < strings.ToLower(onlyDst[j]) })
+	if len(onlyDst) > 0 {
+		fmt.Println("\nOnly at destination:")
+		for _, n := range onlyDst {
+			fmt.Printf("  - %s\n", n)
+		}
+	}
+	fmt.Printf("\n%d source repositories: %d present at destination, %d missing; %d only at destination\n",
+		len(srcRepos), present, len(srcRepos)-present, len(onlyDst))
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import "fmt"
+
+// ResultSizeSkip marks repositories larger than --skip-larger-than, left to a manual migration.
+const ResultSizeSkip = "SKIPPED: size threshold"
+
+// skipLargeRepos removes from the selection the repositories whose size (as reported by
+// the API) exceeds --skip-larger-than, returning them as SKIPPED summaries.
+func skipLargeRepos(cfg Config, selected []Repo) ([]Repo, []Summary) {
+	if cfg.SkipLargerThan <= 0 {
+		return selected, nil
+	}
+	var kept []Repo
+	var skipped []Summary
+	for _, r := range selected {
+		if r.Size > cfg.SkipLargerThan {
+			fmt.Printf("[SIZE] %s skipped: %s over --skip-larger-than %s\n", r.Name, formatBytes(r.Size), formatBytes(cfg.SkipLargerThan))
+			skipped = append(skipped, Summary{
+				Repo:       r.Name,
+				Result:     ResultSizeSkip,
+				Skipped:    true,
+				SrcRepoID:  r.ID,
+				Size:       r.Size,
+				ErrDetails: fmt.Sprintf("size %s over the threshold of %s", formatBytes(r.Size), formatBytes(cfg.SkipLargerThan)),
+			})
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept, skipped
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Outcomes of a failed clone, told apart to avoid reporting a deleted repository as "access denied".
+const (
+	ResultSourceRemoved      = "SOURCE REMOVED"
+	ResultSourceRemovedError = "ERROR: source removed"
+	ResultSourceDenied       = "ERROR: source access denied"
+	ResultNetwork            = "ERROR: network"
+	ResultClone              = "ERROR: clone"
+)
+
+// Behaviours when the source repository disappears between planning and clone (--on-source-removed).
+const (
+	OnSourceRemovedSkip  = "skip"
+	OnSourceRemovedError = "error"
+)
+
+// classifyCloneFailure tells apart why a clone failed: it asks the API for the repository
+// (404 = deleted during the run, 401/403 = access denied, no answer = network) and falls
+// back to the git message. Returns the result and whether the repository counts as skipped.
+func classifyCloneFailure(ctx context.Context, cfg Config, repo string, cloneErr error) (string, bool) {
+	path := fmt.Sprintf("_apis/git/repositories/%s?api-version=%s", url.PathEscape(repo), apiVersion)
+	_, code, err := httpReq(ctx, "GET", cfg.SrcOrg, cfg.SrcProject, path, cfg.SrcPAT, nil, cfg.Trace)
+	switch {
+	case err == nil && code == http.StatusNotFound:
+		if cfg.OnSourceRemoved == OnSourceRemovedError {
+			return ResultSourceRemovedError, false
+		}
+		return ResultSourceRemoved, true
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ResultSourceDenied, false
+	case err != nil && ctx.Err() == nil:
+		return ResultNetwork, false
+	}
+
+	msg := strings.ToLower(cloneErr.Error())
+	switch {
+	case strings.Contains(msg, "could not resolve host"), strings.Contains(msg, "timed out"),
+		strings.Contains(msg, "connection refused"), strings.Contains(msg, "connection reset"):
+		return ResultNetwork, false
+	case strings.Contains(msg, "403"), strings.Contains(msg, "401"), strings.Contains(msg, "authentication failed"),
+		strings.Contains(msg, "tf401027"):
+		return ResultSourceDenied, false
+	case strings.Contains(msg, "tf401019"), strings.Contains(msg, "not found"):
+		return "ERROR: source not found", false
+	}
+	return ResultClone, false
+}
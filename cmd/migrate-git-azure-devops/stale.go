@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ResultStale marks repositories whose source changed while the migration was running.
+const ResultStale = "STALE: resync recommended"
+
+// staleCheck holds what is needed to re-read the source refs of a cloned repository.
+type staleCheck struct {
+	srcURL string
+	srcEnv []string
+	refs   map[string]string // refs as cloned
+}
+
+// markStaleRepos re-reads the source refs of every successfully pushed repository
+// (indexed by position in results) and flags those that changed after the clone,
+// e.g. a PR completed seconds after we cloned: the destination is already behind.
+func markStaleRepos(ctx context.Context, results []Summary, cloned map[int]staleCheck) {
+	for i, c := range cloned {
+		if results[i].Result != "OK" {
+			continue
+		}
+		current, err := lsRemote(ctx, c.srcEnv, c.srcURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot re-check source refs of %s: %v\n", results[i].Repo, err)
+			continue
+		}
+		changed := changedRefs(c.refs, current)
+		if len(changed) == 0 {
+			continue
+		}
+		results[i].Result = ResultStale
+		results[i].Stale = true
+		results[i].StaleRefs = changed
+		fmt.Printf("%s: source changed during the run (%d refs), resync recommended\n", results[i].Repo, len(changed))
+	}
+}
+
+// changedRefs lists refs added, removed or moved between two snapshots.
+func changedRefs(before, after map[string]string) []string {
+	var out []string
+	for ref, sha := range after {
+		if before[ref] != sha {
+			out = append(out, ref)
+		}
+	}
+	for ref := range before {
+		if _, ok := after[ref]; !ok {
+			out = append(out, ref)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
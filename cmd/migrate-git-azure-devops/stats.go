@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Output formats of the stats command (--format).
+const (
+	StatsTable = "table"
+	StatsJSON  = "json"
+)
+
+// statsLargestShown is the number of largest repositories listed by the stats table.
+const statsLargestShown = 10
+
+// RepoStats are the figures of one source repository, read through the API.
+type RepoStats struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	Branches int    `json:"branches"`
+	Tags     int    `json:"tags"`
+	LFS      bool   `json:"lfs"`
+	Disabled bool   `json:"disabled,omitempty"` // refs and LFS not readable
+	Error    string `json:"error,omitempty"`
+}
+
+// ProjectStats aggregates the repositories of a source project to size the migration.
+type ProjectStats struct {
+	Org           string      `json:"org"`
+	Project       string      `json:"project"`
+	Repos         int         `json:"repos"`
+	DisabledRepos int         `json:"disabledRepos"`
+	EmptyRepos    int         `json:"emptyRepos"`
+	TotalSize     int64       `json:"totalSize"`
+	TotalBranches int         `json:"totalBranches"`
+	TotalTags     int         `json:"totalTags"`
+	LFSRepos      int         `json:"lfsRepos"`
+	Repositories  []RepoStats `json:"repositories"` // largest first
+}
+
+// cmdStats aggregates the selected repositories of the source project (--filter, --glob,
+// --repo-list) without cloning anything: sizes from the repository list, branch and tag
+// counts from the refs API, Git LFS usage from the .gitattributes of the default branch.
+func cmdStats(ctx context.Context, cfg Config) error {
+	ctx, cancel := withTimeout(ctx, cfg.RunTimeout)
+	defer cancel()
+
+	repos, err := getRepos(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, cfg.Trace)
+	if err != nil {
+		return fmt.Errorf("[API ERROR] call failed for source %s/%s: %w", cfg.SrcOrg, cfg.SrcProject, err)
+	}
+	selected, missing, err := selectRepos(cfg, repos)
+	if err != nil {
+		return err
+	}
+	for _, s := range missing {
+		fmt.Fprintf(os.Stderr, "WARNING: %s: %s\n", s.Repo, s.Result)
+	}
+
+	stats := ProjectStats{Org: cfg.SrcOrg, Project: cfg.SrcProject, Repositories: make([]RepoStats, len(selected))}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, detailCalls)
+	for i, r := range selected {
+		stats.Repositories[i] = RepoStats{Name: r.Name, Size: r.Size, Disabled: r.IsDisabled}
+		if r.IsDisabled {
+			continue // refs and items APIs fail on disabled repositories
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			rs := &stats.Repositories[i]
+			var err error
+			var errs []error
+			if rs.Branches, err = getRefCount(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, r.ID, "heads/", cfg.Trace); err != nil {
+				errs = append(errs, err)
+			}
+			if rs.Tags, err = getRefCount(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, r.ID, "tags/", cfg.Trace); err != nil {
+				errs = append(errs, err)
+			}
+			if r.Size > 0 {
+				if rs.LFS, err = usesLFS(ctx, cfg, r.Name); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			if len(errs) > 0 {
+				rs.Error = errs[0].Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, rs := range stats.Repositories {
+		if rs.Error != "" {
+			fmt.Fprintf(os.Stderr, "WARNING: figures of %s incomplete: %s\n", rs.Name, rs.Error)
+		}
+		stats.Repos++
+		stats.TotalSize += rs.Size
+		stats.TotalBranches += rs.Branches
+		stats.TotalTags += rs.Tags
+		switch {
+		case rs.Disabled:
+			stats.DisabledRepos++
+		case rs.Size == 0:
+			stats.EmptyRepos++
+		}
+		if rs.LFS {
+			stats.LFSRepos++
+		}
+	}
+	sort.SliceStable(stats.Repositories, func(i, j int) bool { return stats.Repositories[i].Size > stats.Repositories[j].Size })
+
+	if cfg.StatsFormat == StatsJSON {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	printStats(stats)
+	return nil
+}
+
+// printStats prints the aggregates and the largest repositories as a table.
+func printStats(s ProjectStats) {
+	fmt.Printf("Statistics of %s/%s:\n\n", s.Org, s.Project)
+	fmt.Printf("Repositories:  %d (%d disabled, %d empty)\n", s.Repos, s.DisabledRepos, s.EmptyRepos)
+	fmt.Printf("Total size:    %s\n", formatBytes(s.TotalSize))
+	fmt.Printf("Branches:      %d\n", s.TotalBranches)
+	fmt.Printf("Tags:          %d\n", s.TotalTags)
+	fmt.Printf("Git LFS:       %d repos\n", s.LFSRepos)
+	if len(s.Repositories) == 0 {
+		return
+	}
+	fmt.Println("\nLargest repositories:")
+	fmt.Printf("  %-40s %10s %9s %6s %4s\n", "Repository", "Size", "Branches", "Tags", "LFS")
+	for _, r := range s.Repositories[:min(statsLargestShown, len(s.Repositories))] {
+		lfs := "-"
+		if r.LFS {
+			lfs = "yes"
+		}
+		fmt.Printf("  %-40s %10s %9d %6d %4s\n", r.Name, formatBytes(r.Size), r.Branches, r.Tags, lfs)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Modes of --submodules.
+const (
+	SubmodulesReport = "report" // only list the .gitmodules URLs to change
+	SubmodulesCommit = "commit" // commit the new URLs on the selected branches before the push
+)
+
+// SubmoduleRewrite holds the settings of the .gitmodules URL rewrite.
+type SubmoduleRewrite struct {
+	Mode       string   // report or commit
+	Branches   []string // globs of the branches to update (default: the default branch)
+	DstProject string   // default destination project of the run (repositories not in the manifest)
+}
+
+var (
+	// https://[user@]dev.azure.com/{org}/{project}/_git/{repo}
+	// https://{org}.visualstudio.com/[DefaultCollection/]{project}/_git/{repo}
+	adoHTTPSRe = regexp.MustCompile(`^https?://(?:[^@/]+@)?(?:dev\.azure\.com/([^/]+)|([^./]+)\.visualstudio\.com(?:/DefaultCollection)?)/([^/]+)/_git/([^/?#]+?)(?:\.git)?/?$`)
+	// git@ssh.dev.azure.com:v3/{org}/{project}/{repo}
+	adoSSHRe = regexp.MustCompile(`^(?:ssh://)?git@(?:ssh\.dev\.azure\.com|vs-ssh\.visualstudio\.com)[:/]v3/([^/]+)/([^/]+)/([^/]+?)/?$`)
+	// url = value of a .gitmodules line
+	gitmodulesURLRe = regexp.MustCompile(`^(\s*url\s*=\s*)(\S+)(\s*)$`)
+	// [submodule "name"] header of a .gitmodules section
+	gitmodulesSectionRe = regexp.MustCompile(`^\s*\[submodule\s+"(.*)"\]\s*$`)
+)
+
+// mapSubmoduleURL returns the URL of a submodule after the migration: URLs pointing at a
+// repository of the source project (absolute, or relative as "../repo") are mapped to its
+// destination, keeping the protocol. ok is false for URLs outside the migration.
+func mapSubmoduleURL(cfg Config, dstProject, u string) (string, bool) {
+	var org, project, repo string
+	ssh := false
+	if m := adoHTTPSRe.FindStringSubmatch(u); m != nil {
+		org, project, repo = m[1]+m[2], m[3], m[4]
+	} else if m := adoSSHRe.FindStringSubmatch(u); m != nil {
+		org, project, repo, ssh = m[1], m[2], m[3], true
+	} else if strings.HasPrefix(u, "../") && !strings.Contains(u[3:], "/") {
+		// Relative to the superproject: a repository of the same project
+		name, err := url.PathUnescape(u[3:])
+		if err != nil {
+			return "", false
+		}
+		dstName := destinationName(cfg, name)
+		if p := submoduleProject(cfg, name); p != dstProject {
+			return fmt.Sprintf("https://dev.azure.com/%s/%s/_git/%s", cfg.DstOrg, url.PathEscape(p), url.PathEscape(dstName)), true
+		}
+		return "../" + url.PathEscape(dstName), true
+	} else {
+		return "", false
+	}
+
+	project, _ = url.PathUnescape(project)
+	repo, _ = url.PathUnescape(repo)
+	if !strings.EqualFold(org, cfg.SrcOrg) || !strings.EqualFold(project, cfg.SrcProject) {
+		return "", false
+	}
+	dstName := destinationName(cfg, repo)
+	p := submoduleProject(cfg, repo)
+	if ssh {
+		return fmt.Sprintf("git@ssh.dev.azure.com:v3/%s/%s/%s", cfg.DstOrg, url.PathEscape(p), url.PathEscape(dstName)), true
+	}
+	return fmt.Sprintf("https://dev.azure.com/%s/%s/_git/%s", cfg.DstOrg, url.PathEscape(p), url.PathEscape(dstName)), true
+}
+
+// submoduleProject is the destination project of a source repository: from the manifest,
+// or the default destination project of the run.
+func submoduleProject(cfg Config, name string) string {
+	if p := cfg.RepoOverrides[name].DstProject; p != "" {
+		return p
+	}
+	return cfg.Submodules.DstProject
+}
+
+// rewriteGitmodules maps the URLs of a .gitmodules file, keeping its formatting, and
+// returns the new content with the changes as "name: old -> new".
+func rewriteGitmodules(cfg Config, dstProject string, content []byte) ([]byte, []string) {
+	lines := strings.Split(string(content), "\n")
+	var changes []string
+	section := ""
+	for i, line := range lines {
+		if m := gitmodulesSectionRe.FindStringSubmatch(line); m != nil {
+			section = m[1]
+			continue
+		}
+		m := gitmodulesURLRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		newURL, ok := mapSubmoduleURL(cfg, dstProject, m[2])
+		if !ok || newURL == m[2] {
+			continue
+		}
+		lines[i] = m[1] + newURL + m[3]
+		changes = append(changes, fmt.Sprintf("%s: %s -> %s", section, m[2], newURL))
+	}
+	return []byte(strings.Join(lines, "\n")), changes
+}
+
+// rewriteSubmodules updates the .gitmodules of the selected branches of the mirror so that
+// the submodules point at the migrated repositories. In report mode only the changes are
+// returned ("branch name: old -> new"); in commit mode a commit is added on each branch.
+func rewriteSubmodules(ctx context.Context, cfg Config, repoDir, tmpDir, dstProject string) ([]string, error) {
+	refs, err := localRefs(ctx, repoDir)
+	if err != nil {
+		return nil, err
+	}
+	var branches []string
+	if len(cfg.Submodules.Branches) == 0 {
+		head, err := exec.CommandContext(ctx, "git", "-C", repoDir, "symbolic-ref", "HEAD").Output()
+		if err != nil {
+			return nil, nil // no default branch (empty repository)
+		}
+		branches = append(branches, strings.TrimPrefix(strings.TrimSpace(string(head)), "refs/heads/"))
+	} else {
+		for ref := range refs {
+			name, ok := strings.CutPrefix(ref, "refs/heads/")
+			if ok && matchGlobs(cfg.Submodules.Branches, name) {
+				branches = append(branches, name)
+			}
+		}
+		sort.Strings(branches)
+	}
+
+	var changes []string
+	for _, b := range branches {
+		old, ok := refs["refs/heads/"+b]
+		if !ok {
+			continue
+		}
+		content, err := exec.CommandContext(ctx, "git", "-C", repoDir, "show", old+":.gitmodules").Output()
+		if err != nil {
+			continue // no submodules on this branch
+		}
+		updated, bchanges := rewriteGitmodules(cfg, dstProject, content)
+		if len(bchanges) == 0 {
+			continue
+		}
+		for _, c := range bchanges {
+			changes = append(changes, b+" "+c)
+		}
+		if cfg.Submodules.Mode == SubmodulesCommit {
+			if err := commitGitmodules(ctx, cfg, repoDir, tmpDir, b, old, updated); err != nil {
+				return changes, fmt.Errorf("committing .gitmodules on %s: %w", b, err)
+			}
+		}
+	}
+	return changes, nil
+}
+
+// commitGitmodules adds on branch (at commit old) a commit replacing .gitmodules with
+// content, working on a temporary index since the mirror has no working tree.
+func commitGitmodules(ctx context.Context, cfg Config, repoDir, tmpDir, branch, old string, content []byte) error {
+	git := func(env []string, stdin []byte, args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "git", append([]string{"-C", repoDir}, args...)...)
+		cmd.Env = append(os.Environ(), env...)
+		if stdin != nil {
+			cmd.Stdin = bytes.NewReader(stdin)
+		}
+		out, err := cmd.Output()
+		if ee, ok := err.(*exec.ExitError); ok {
+			err = fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(ee.Stderr)))
+		}
+		return strings.TrimSpace(string(out)), err
+	}
+	indexFile := filepath.Join(tmpDir, filepath.Base(repoDir)+".index")
+	defer os.Remove(indexFile)
+	index := []string{"GIT_INDEX_FILE=" + indexFile}
+
+	blob, err := git(nil, content, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return err
+	}
+	if _, err := git(index, nil, "read-tree", old); err != nil {
+		return err
+	}
+	if _, err := git(index, nil, "update-index", "--add", "--cacheinfo", "100644,"+blob+",.gitmodules"); err != nil {
+		return err
+	}
+	tree, err := git(index, nil, "write-tree")
+	if err != nil {
+		return err
+	}
+	author := []string{
+		"GIT_AUTHOR_NAME=" + prog(), "GIT_AUTHOR_EMAIL=" + prog() + "@noreply",
+		"GIT_COMMITTER_NAME=" + prog(), "GIT_COMMITTER_EMAIL=" + prog() + "@noreply",
+	}
+	msg := fmt.Sprintf("Point submodules at %s after the migration", cfg.DstOrg)
+	commit, err := git(author, nil, "commit-tree", tree, "-p", old, "-m", msg)
+	if err != nil {
+		return err
+	}
+	_, err = git(nil, nil, "update-ref", "refs/heads/"+branch, commit, old)
+	return err
+}
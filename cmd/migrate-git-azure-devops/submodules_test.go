@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func submoduleTestConfig() Config {
+	return Config{SrcOrg: "contoso", SrcProject: "Horse Team", DstOrg: "fabrikam",
+		RepoMap:       map[string]string{"Horse-Lib": "horse-lib"},
+		RepoOverrides: map[string]RepoOverride{"Horse-Tools": {DstProject: "Tools"}},
+		Submodules:    &SubmoduleRewrite{Mode: SubmodulesReport, DstProject: "Platform"}}
+}
+
+func TestMapSubmoduleURL(t *testing.T) {
+	cfg := submoduleTestConfig()
+	tests := []struct {
+		name string
+		url  string
+		want string // "" when not mapped
+	}{
+		{"https", "https://dev.azure.com/contoso/Horse%20Team/_git/Horse-Lib", "https://dev.azure.com/fabrikam/Platform/_git/horse-lib"},
+		{"https with user and .git", "https://contoso@dev.azure.com/contoso/Horse%20Team/_git/Horse-Lib.git", "https://dev.azure.com/fabrikam/Platform/_git/horse-lib"},
+		{"https, other case", "https://dev.azure.com/Contoso/horse%20team/_git/Horse-Web", "https://dev.azure.com/fabrikam/Platform/_git/Horse-Web"},
+		{"legacy host", "https://contoso.visualstudio.com/Horse%20Team/_git/Horse-Lib", "https://dev.azure.com/fabrikam/Platform/_git/horse-lib"},
+		{"legacy host with collection", "https://contoso.visualstudio.com/DefaultCollection/Horse%20Team/_git/Horse-Lib", "https://dev.azure.com/fabrikam/Platform/_git/horse-lib"},
+		{"ssh", "git@ssh.dev.azure.com:v3/contoso/Horse%20Team/Horse-Lib", "git@ssh.dev.azure.com:v3/fabrikam/Platform/horse-lib"},
+		{"legacy ssh", "ssh://git@vs-ssh.visualstudio.com/v3/contoso/Horse%20Team/Horse-Lib", "git@ssh.dev.azure.com:v3/fabrikam/Platform/horse-lib"},
+		{"per-repo project", "https://dev.azure.com/contoso/Horse%20Team/_git/Horse-Tools", "https://dev.azure.com/fabrikam/Tools/_git/Horse-Tools"},
+		{"relative", "../Horse-Lib", "../horse-lib"},
+		{"relative, per-repo project", "../Horse-Tools", "https://dev.azure.com/fabrikam/Tools/_git/Horse-Tools"},
+		{"other project", "https://dev.azure.com/contoso/Other/_git/Horse-Lib", ""},
+		{"other organization", "git@ssh.dev.azure.com:v3/northwind/Horse%20Team/Horse-Lib", ""},
+		{"other host", "https://github.com/contoso/horse-lib.git", ""},
+		{"relative to another project", "../../Other/_git/Horse-Lib", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mapSubmoduleURL(cfg, "Platform", tt.url)
+			if ok != (tt.want != "") || got != tt.want {
+				t.Errorf("mapSubmoduleURL(%q) = %q, %v, want %q", tt.url, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteGitmodules(t *testing.T) {
+	content := "[submodule \"lib\"]\n\tpath = lib\n\turl = ../Horse-Lib\n" +
+		"[submodule \"ext\"]\n\tpath = ext\n\turl = https://github.com/contoso/ext.git\n"
+	got, changes := rewriteGitmodules(submoduleTestConfig(), "Platform", []byte(content))
+	want := strings.Replace(content, "../Horse-Lib", "../horse-lib", 1)
+	if string(got) != want {
+		t.Errorf("content =\n%s\nwant\n%s", got, want)
+	}
+	if len(changes) != 1 || changes[0] != "lib: ../Horse-Lib -> ../horse-lib" {
+		t.Errorf("changes = %q", changes)
+	}
+}
+
+func TestCommitGitmodules(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo.git")
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		cmd.Env = append(cmd.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if out, err := exec.Command("git", "init", "--quiet", "--bare", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	// A first commit with .gitmodules and another file, written without a working tree
+	blob := func(content string) string {
+		t.Helper()
+		cmd := exec.Command("git", "-C", repo, "hash-object", "-w", "--stdin")
+		cmd.Stdin = strings.NewReader(content)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	tree := func(entries string) string {
+		t.Helper()
+		cmd := exec.Command("git", "-C", repo, "mktree")
+		cmd.Stdin = strings.NewReader(entries)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	readme := blob("horse\n")
+	root := tree("100644 blob " + blob("[submodule \"lib\"]\n\turl = ../Horse-Lib\n") + "\t.gitmodules\n100644 blob " + readme + "\tREADME.md\n")
+	old := git("commit-tree", root, "-m", "first")
+	git("update-ref", "refs/heads/main", old)
+
+	cfg := Config{DstOrg: "fabrikam"}
+	updated := []byte("[submodule \"lib\"]\n\turl = ../horse-lib\n")
+	if err := commitGitmodules(context.Background(), cfg, repo, dir, "main", old, updated); err != nil {
+		t.Fatal(err)
+	}
+	head := git("rev-parse", "refs/heads/main")
+	if head == old || git("rev-parse", head+"^") != old {
+		t.Fatalf("main = %s, want a new commit on top of %s", head, old)
+	}
+	if got := git("show", head+":.gitmodules"); got != strings.TrimSpace(string(updated)) {
+		t.Errorf(".gitmodules = %q, want %q", got, updated)
+	}
+	if got := git("rev-parse", head+":README.md"); got != readme {
+		t.Errorf("README.md = %s, want the file kept (%s)", got, readme)
+	}
+	// The update is refused if the branch moved meanwhile
+	if err := commitGitmodules(context.Background(), cfg, repo, dir, "main", old, updated); err == nil {
+		t.Error("commit on a branch moved since accepted")
+	}
+}
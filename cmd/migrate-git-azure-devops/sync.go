@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mirrorCacheDir returns the directory keeping the source mirrors between --sync runs:
+// --mirror-cache when set, otherwise a per-project directory in the user cache.
+func mirrorCacheDir(cfg Config) (string, error) {
+	if cfg.MirrorCache != "" {
+		return cfg.MirrorCache, nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("no user cache directory, use --mirror-cache: %w", err)
+	}
+	return filepath.Join(base, prog(), "mirrors", cfg.SrcOrg, cfg.SrcProject), nil
+}
+
+// reusableMirror reports whether the mirror kept in --work-dir from a previous run can be
+// updated with a fetch instead of a new clone. History rewritten by --exclude-path needs a
+// fresh clone.
+func reusableMirror(cfg Config, repodir string) bool {
+	if cfg.WorkDir == "" || len(cfg.ExcludePaths) > 0 || cfg.Rewrite != nil {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(repodir, "HEAD"))
+	return err == nil
+}
+
+// syncRepos is the incremental pass for repeated runs during a cutover window: the source
+// mirror kept in the cache is updated with a fetch (cloned only the first time), compared
+// with the destination refs, and only the changed refs are pushed; branches and tags
+// deleted on the source are deleted at destination. Nothing is locked.
+func syncRepos(ctx context.Context, cfg Config, repos []Repo, dstExists map[string]bool) ([]Summary, error) {
+	cacheDir, err := mirrorCacheDir(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating the mirror cache: %w", err)
+	}
+
+	src, dst := newSourceProvider(cfg), newDestinationProvider(cfg)
+	var results []Summary
+	for i, r := range repos {
+		if refreshCredentials(ctx, &cfg) {
+			src, dst = newSourceProvider(cfg), newDestinationProvider(cfg)
+		}
+		dstRepoName := destinationName(cfg, r.Name)
+		fmt.Printf("[%d/%d] sync %s\n", i+1, len(repos), r.Name)
+		progress.repo(r.Name, i+1, len(repos))
+		sum := Summary{Repo: r.Name, SrcWebURL: r.WebURL, SrcRepoID: r.ID, Owner: cfg.RepoOwners[r.Name]}
+
+		srcURL, srcEnv := src.CloneURL(r.Name)
+		dstURL, dstEnv := dst.CloneURL(dstRepoName)
+		sum.DstClone = dstURL
+		sum.DstWebURL = dst.WebURL(dstRepoName)
+
+		// Keyed by ID: a renamed source repository keeps its mirror
+		repodir := filepath.Join(cacheDir, r.ID+".git")
+		if err := syncRepo(ctx, cfg, r, repodir, srcURL, srcEnv, dstRepoName, dstURL, dstEnv, dstExists, &sum); err != nil {
+			sum.ErrDetails = err.Error()
+			fmt.Println("  Error:", err)
+		}
+		results = append(results, sum)
+		fmt.Println()
+	}
+	return results, nil
+}
+
+// syncRepo updates the cached mirror of a repository and pushes the refs differing from
+// the destination, filling sum.
+func syncRepo(ctx context.Context, cfg Config, r Repo, repodir, srcURL string, srcEnv []string, dstRepoName, dstURL string, dstEnv []string, dstExists map[string]bool, sum *Summary) error {
+	// 1) Update the mirror: fetch with prune when cached, full mirror clone otherwise
+	stopClone := phases.trackRepo(PhaseClone, &sum.CloneSeconds)
+	_, statErr := os.Stat(repodir)
+	var err error
+	if statErr == nil {
+		fmt.Println("  Updating the cached mirror")
+		sum.CloneAttempts, err = withRetry(ctx, cfg, "fetch", func() error {
+			return runCmd(ctx, transferEnv(srcEnv), "git", gitTransferArgs(cfg, r.Size, "-C", repodir, "fetch", "--prune", srcURL,
+				"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")...)
+		})
+	} else {
+		fmt.Println("  Mirror not cached, cloning")
+		sum.CloneAttempts, err = withRetry(ctx, cfg, "clone", func() error {
+			_ = os.RemoveAll(repodir)
+			return runCmd(ctx, transferEnv(srcEnv), "git", gitTransferArgs(cfg, r.Size, "clone", "--mirror", srcURL, repodir)...)
+		})
+	}
+	stopClone()
+	if err != nil {
+		sum.Result, sum.Skipped = classifyCloneFailure(ctx, cfg, r.Name, err)
+		return fmt.Errorf("updating the source mirror: %w", err)
+	}
+	srcRefs, err := localRefs(ctx, repodir)
+	if err != nil {
+		sum.Result = "ERROR: sync"
+		return err
+	}
+	expected := expectedRefs(cfg, cfg.RepoOverrides[r.Name], srcRefs)
+	for ref := range expected {
+		if strings.HasPrefix(ref, "refs/heads/") {
+			sum.BranchNames = append(sum.BranchNames, strings.TrimPrefix(ref, "refs/heads/"))
+		} else {
+			sum.TagNames = append(sum.TagNames, strings.TrimPrefix(ref, "refs/tags/"))
+		}
+	}
+	sort.Strings(sum.BranchNames)
+	sort.Strings(sum.TagNames)
+	sum.NumBranches, sum.NumTags = len(sum.BranchNames), len(sum.TagNames)
+
+	// 2) Destination: created when missing, then compared ref by ref
+	dstRefs := map[string]string{}
+	if !dstExists[dstRepoName] {
+		if cfg.DryRun {
+			fmt.Printf("  [DRY] Would create repo in destination: %s\n", dstRepoName)
+		} else {
+			stopCreate := phases.track(PhaseCreate)
+			created, err := newDestinationProvider(cfg).CreateRepo(ctx, dstRepoName)
+			stopCreate()
+			if err != nil {
+				sum.Result = "ERROR: destination creation"
+				return err
+			}
+			sum.DstRepoID = created.ID
+			sum.Created = true
+			dstExists[dstRepoName] = true
+		}
+	} else {
+		remote, err := lsRemote(ctx, dstEnv, dstURL)
+		if err != nil {
+			sum.Result = "ERROR: destination"
+			return fmt.Errorf("ls-remote destination: %w", err)
+		}
+		dstRefs = branchesAndTags(remote)
+	}
+
+	// 3) Push only the delta: changed/new refs and deletions
+	delta := changedRefs(dstRefs, expected)
+	sum.SyncedRefs = delta
+	if len(delta) == 0 {
+		fmt.Println("  Already in sync.")
+		sum.Result = ResultInSync
+		return nil
+	}
+	fmt.Printf("  %d refs to sync\n", len(delta))
+	if cfg.DryRun {
+		for _, ref := range delta {
+			fmt.Printf("  [DRY] Would sync %s\n", ref)
+		}
+		sum.Result = "DRY-RUN"
+		return nil
+	}
+	var pushSpecs []string
+	for _, ref := range delta {
+		if _, ok := expected[ref]; ok {
+			pushSpecs = append(pushSpecs, "+"+ref+":"+ref)
+		} else {
+			pushSpecs = append(pushSpecs, ":"+ref) // deleted on source
+		}
+	}
+	args := append([]string{"-C", repodir, "push", dstURL}, pushSpecs...)
+	stopPush := phases.trackRepo(PhasePush, &sum.PushSeconds)
+	attempts, err := withRetry(ctx, cfg, "push", func() error { return runCmd(ctx, transferEnv(dstEnv), "git", gitTransferArgs(cfg, r.Size, args...)...) })
+	stopPush()
+	sum.PushAttempts = attempts
+	recordAudit(cfg, AuditEvent{Action: AuditPush, Org: cfg.DstOrg, Project: cfg.DstProject, Repo: destinationName(cfg, r.Name),
+		Result: auditResult(err), Details: fmt.Sprintf("%d refs synced from %s", len(pushSpecs), r.Name)})
+	if err != nil {
+		sum.Result = "ERROR: push"
+		return fmt.Errorf("push changed refs: %w", err)
+	}
+	if size, err := dirSize(repodir); err == nil {
+		sum.Size = size
+	}
+	fmt.Println("  OK, synced.")
+	sum.Result = ResultSynced
+	setThroughput(sum)
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// throttleProxyURL is the address of the local proxy limiting the git transfers to
+// --max-bandwidth; empty when no limit is set.
+var throttleProxyURL string
+
+// rateLimiter paces the bytes going through it to rate bytes per second, shared by all
+// the connections (and so by all the parallel clones/pushes).
+type rateLimiter struct {
+	mu   sync.Mutex
+	rate float64
+	next time.Time
+}
+
+// wait blocks until n more bytes can be transferred within the rate.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// copy copies src to dst at the rate of the limiter, until either side is closed.
+func (l *rateLimiter) copy(dst io.Writer, src io.Reader) {
+	buf := make([]byte, 16<<10)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			l.wait(n)
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// throttleHosts returns the hosts the bandwidth limiting proxy tunnels to: the Azure DevOps
+// hosts of the run, including the legacy {org}.visualstudio.com of its organizations.
+func throttleHosts(cfg Config) map[string]bool {
+	hosts := map[string]bool{"dev.azure.com": true, "ssh.dev.azure.com": true}
+	orgs := []string{cfg.SrcOrg, cfg.DstOrg}
+	for _, m := range cfg.Mirrors {
+		orgs = append(orgs, m.Org)
+	}
+	for _, org := range orgs {
+		if org != "" {
+			hosts[strings.ToLower(org)+".visualstudio.com"] = true
+		}
+	}
+	return hosts
+}
+
+// startThrottleProxy starts a local CONNECT proxy forwarding the git HTTPS traffic
+// (directly or through --proxy / the environment proxy) with the upload and the download
+// each limited to rate bytes per second. git is pointed to it by gitTransferArgs and
+// transferEnv. Only tunnels to port 443 of hosts are accepted: any local process may
+// connect to it.
+func startThrottleProxy(rate int64, hosts map[string]bool) error {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("starting the bandwidth limiting proxy: %w", err)
+	}
+	up := &rateLimiter{rate: float64(rate)}
+	down := &rateLimiter{rate: float64(rate)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveThrottled(conn, up, down, hosts)
+		}
+	}()
+	throttleProxyURL = "http://" + ln.Addr().String()
+	return nil
+}
+
+// transferEnv returns the environment of a git clone/fetch/push given its extra variables.
+// With the bandwidth limiting proxy, NO_PROXY is cleared for the transfer alone, so that
+// git always goes through the local proxy, which decides itself which hosts go through the
+// upstream proxy; the other git commands keep the --no-proxy exclusions.
+func transferEnv(env []string) []string {
+	if throttleProxyURL == "" {
+		return env
+	}
+	return append(slices.Clip(env), "NO_PROXY=", "no_proxy=")
+}
+
+// serveThrottled handles one CONNECT request of git and relays the tunnel through the limiters.
+func serveThrottled(client net.Conn, up, down *rateLimiter, hosts map[string]bool) {
+	defer client.Close()
+	br := bufio.NewReader(client)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return
+	}
+	if req.Method != http.MethodConnect {
+		fmt.Fprint(client, "HTTP/1.1 405 Method Not Allowed\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+	if !throttleAllowed(req.Host, hosts) {
+		fmt.Fprintf(os.Stderr, "Bandwidth limiting proxy: CONNECT %s refused, not an Azure DevOps host of the run\n", req.Host)
+		fmt.Fprint(client, "HTTP/1.1 403 Forbidden\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+	server, err := dialUpstream(req.Host)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Bandwidth limiting proxy: %v\n", err)
+		fmt.Fprint(client, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+	defer server.Close()
+	if _, err := fmt.Fprint(client, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		up.copy(server, br)
+		if c, ok := server.(*net.TCPConn); ok {
+			c.CloseWrite()
+		}
+		close(done)
+	}()
+	down.copy(client, server)
+	client.Close()
+	<-done
+}
+
+// throttleAllowed reports whether the target of a CONNECT request is port 443 of one of hosts.
+func throttleAllowed(hostport string, hosts map[string]bool) bool {
+	host, port, err := net.SplitHostPort(hostport)
+	return err == nil && port == "443" && hosts[strings.ToLower(host)]
+}
+
+// dialUpstream connects to host:port directly or, when --proxy or the environment set a
+// proxy for it, through a CONNECT tunnel of that proxy.
+func dialUpstream(hostport string) (net.Conn, error) {
+	target := &http.Request{URL: &url.URL{Scheme: "https", Host: hostport}}
+	proxyFunc := http.ProxyFromEnvironment
+	if t, ok := baseTransport().(*http.Transport); ok && t.Proxy != nil {
+		proxyFunc = t.Proxy
+	}
+	proxyURL, err := proxyFunc(target)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL == nil {
+		return net.DialTimeout("tcp", hostport, 30*time.Second)
+	}
+
+	conn, err := net.DialTimeout("tcp", proxyURL.Host, 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to proxy %s: %w", proxyURL.Host, err)
+	}
+	connect := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", hostport, hostport)
+	if u := proxyURL.User; u != nil {
+		pass, _ := u.Password()
+		connect += "Proxy-Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+pass)) + "\r\n"
+	}
+	if _, err := io.WriteString(conn, connect+"\r\n"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %w", proxyURL.Host, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused CONNECT %s: %s", proxyURL.Host, hostport, resp.Status)
+	}
+	return conn, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestThrottleAllowed(t *testing.T) {
+	hosts := throttleHosts(Config{SrcOrg: "SrcOrg", DstOrg: "dstorg", Mirrors: []Destination{{Org: "backup"}}})
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"dev.azure.com:443", true},
+		{"DEV.AZURE.COM:443", true},
+		{"ssh.dev.azure.com:443", true},
+		{"srcorg.visualstudio.com:443", true},
+		{"backup.visualstudio.com:443", true},
+		{"other.visualstudio.com:443", false},
+		{"dev.azure.com:22", false},
+		{"dev.azure.com", false},
+		{"example.com:443", false},
+		{"127.0.0.1:443", false},
+	}
+	for _, tt := range tests {
+		if got := throttleAllowed(tt.target, hosts); got != tt.want {
+			t.Errorf("throttleAllowed(%q) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestServeThrottledRefusesOtherHosts(t *testing.T) {
+	client, proxy := net.Pipe()
+	defer client.Close()
+	go serveThrottled(proxy, &rateLimiter{rate: 1 << 20}, &rateLimiter{rate: 1 << 20}, throttleHosts(Config{}))
+
+	go fmt.Fprint(client, "CONNECT internal.example:443 HTTP/1.1\r\nHost: internal.example:443\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("got HTTP %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestTransferEnv(t *testing.T) {
+	defer func(u string) { throttleProxyURL = u }(throttleProxyURL)
+	env := []string{"GIT_TERMINAL_PROMPT=0"}
+
+	throttleProxyURL = ""
+	if got := transferEnv(env); len(got) != 1 {
+		t.Errorf("transferEnv() without throttling = %q, want the variables unchanged", got)
+	}
+	throttleProxyURL = "http://127.0.0.1:4321"
+	got := transferEnv(env)
+	if want := []string{"GIT_TERMINAL_PROMPT=0", "NO_PROXY=", "no_proxy="}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("transferEnv() = %q, want %q", got, want)
+	}
+	if len(env) != 1 {
+		t.Errorf("transferEnv() modified its argument: %q", env)
+	}
+}
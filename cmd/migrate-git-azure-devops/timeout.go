@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ResultTimeout is the result of a repository whose clone, push or whole migration took
+// longer than the configured timeout.
+const ResultTimeout = "ERROR: timeout"
+
+// errOperationTimeout marks the errors of commands stopped by --clone-timeout/--push-timeout.
+var errOperationTimeout = errors.New("timed out")
+
+// withTimeout returns ctx limited to d, or without an additional limit when d is 0.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// runCmdTimeout is runCmd stopped after d (no limit when 0); the error of a stopped
+// command wraps errOperationTimeout.
+func runCmdTimeout(ctx context.Context, d time.Duration, env []string, name string, args ...string) error {
+	cctx, cancel := withTimeout(ctx, d)
+	defer cancel()
+	err := runCmd(cctx, env, name, args...)
+	if err != nil && ctx.Err() == nil && errors.Is(cctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %v", errOperationTimeout, d, err)
+	}
+	return err
+}
+
+// markRepoTimeout turns the failure of the last repository of results into ResultTimeout
+// when its context (--repo-timeout) expired while the run was still going.
+func markRepoTimeout(runCtx, repoCtx context.Context, results []Summary, d time.Duration) {
+	if repoCtx == nil || len(results) == 0 || runCtx.Err() != nil || !errors.Is(repoCtx.Err(), context.DeadlineExceeded) {
+		return
+	}
+	s := &results[len(results)-1]
+	if strings.HasPrefix(s.Result, "ERROR") {
+		s.Result = ResultTimeout
+		s.ErrDetails = fmt.Sprintf("not completed within --repo-timeout %s: %s", d, s.ErrDetails)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// largeRepoGitConfig is added to the clone/fetch/push of the repositories larger than
+// --large-repo-size, before the --git-config settings (which win on the same key).
+var largeRepoGitConfig = []string{
+	"http.postBuffer=524288000", // send big packs in one request instead of chunked encoding
+	"http.version=HTTP/1.1",     // avoid HTTP/2 stream resets on transfers lasting minutes
+	"pack.windowMemory=256m",    // bound the memory used to compute deltas
+}
+
+// gitTransferArgs returns the git arguments of a clone/fetch/push with the transfer
+// settings passed as "-c key=value": the bandwidth limiting proxy of --max-bandwidth, the
+// large repository defaults when size exceeds --large-repo-size, then --git-config.
+func gitTransferArgs(cfg Config, size int64, args ...string) []string {
+	var out []string
+	if throttleProxyURL != "" {
+		out = append(out, "-c", "http.proxy="+throttleProxyURL)
+	}
+	if cfg.LargeRepoSize > 0 && size >= cfg.LargeRepoSize {
+		for _, kv := range largeRepoGitConfig {
+			out = append(out, "-c", kv)
+		}
+	}
+	for _, kv := range cfg.GitConfig {
+		out = append(out, "-c", kv)
+	}
+	return append(out, args...)
+}
+
+// validateGitConfig checks the key=value form of the --git-config settings.
+func validateGitConfig(settings []string) error {
+	for _, kv := range settings {
+		key, _, ok := strings.Cut(kv, "=")
+		if !ok || !strings.Contains(key, ".") || strings.TrimSpace(key) != key {
+			return fmt.Errorf("invalid --git-config %q: use section.key=value (e.g. pack.threads=4)", kv)
+		}
+	}
+	return nil
+}
+
+// parseSize parses a size with an optional binary unit suffix (K, M, G, T, with or
+// without a trailing B or iB): "500M", "5G", "1.5GiB", "1048576".
+func parseSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "B"), "I")
+	mult := int64(1)
+	if n := len(v); n > 0 {
+		switch v[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		case 'T':
+			mult = 1 << 40
+		}
+		if mult > 1 {
+			v = v[:n-1]
+		}
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	// NaN and Inf are accepted by ParseFloat, and the conversion of a value out of range is undefined
+	if err != nil || !(f >= 0) || f*float64(mult) >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q (e.g. 500M, 5G)", s)
+	}
+	return int64(f * float64(mult)), nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"1048576", 1 << 20, false},
+		{"500M", 500 << 20, false},
+		{"500m", 500 << 20, false},
+		{" 5G ", 5 << 30, false},
+		{"5GB", 5 << 30, false},
+		{"1.5GiB", 3 << 29, false},
+		{"64K", 64 << 10, false},
+		{"2T", 2 << 40, false},
+		{"10B", 10, false},
+		{"1e3", 1000, false},
+		{"", 0, true},
+		{"M", 0, true},
+		{"abc", 0, true},
+		{"-1M", 0, true},
+		{"5X", 0, true},
+		{"NaN", 0, true},
+		{"Inf", 0, true},
+		{"1e30", 0, true},
+		{"9000000T", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseSize(tt.in)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseSize(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -33,34 +34,111 @@ func printVersion() {
 
 // runCmd executes a system command propagating the current environment and optionally
 // adding extra variables; forwards stdout/stderr to the calling process.
+// On failure the last lines written on stderr are added to the error, so that reports
+// carry the actual git message instead of a bare exit status. The output and the error
+// are scrubbed of the tokens of the run, which git may echo with the remote URL.
 func runCmd(ctx context.Context, env []string, name string, args ...string) error {
 	cmd := exec.CommandContext(ctx, name, args...)
-	if env != nil {
-		cmd.Env = append(os.Environ(), env...)
+	cmd.Env = commandEnv(env)
+	var stderr tailBuffer
+	stdout, console := newScrubWriter(os.Stdout), newScrubWriter(os.Stderr)
+	defer stdout.Flush()
+	defer console.Flush()
+	cmd.Stdout = stdout
+	cmd.Stderr = io.MultiWriter(console, &stderr)
+	if err := cmd.Run(); err != nil {
+		if last := stderr.lastLines(3); last != "" {
+			return fmt.Errorf("%w: %s", err, scrub(last))
+		}
+		return err
+	}
+	return nil
+}
+
+// tailBuffer keeps only the last bytes written to it.
+type tailBuffer struct {
+	buf []byte
+}
+
+const tailBufferSize = 4096
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > tailBufferSize {
+		t.buf = t.buf[len(t.buf)-tailBufferSize:]
+	}
+	return len(p), nil
+}
+
+// lastLines returns the last n non-empty lines joined by " | "
+// (progress lines end with \r, not \n).
+func (t *tailBuffer) lastLines(n int) string {
+	var out []string
+	lines := strings.FieldsFunc(string(t.buf), func(r rune) bool { return r == '\n' || r == '\r' })
+	for i := len(lines) - 1; i >= 0 && len(out) < n; i-- {
+		if l := strings.TrimSpace(lines[i]); l != "" {
+			out = append([]string{l}, out...)
+		}
+	}
+	return strings.Join(out, " | ")
+}
+
+// commandEnv builds the environment of a subprocess: the current environment plus the
+// proxy settings from --proxy/--no-proxy and the given extra variables.
+// Returns nil (inherit the environment) when there is nothing to add.
+func commandEnv(env []string) []string {
+	if env == nil && proxyEnv == nil {
+		return nil
 	}
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	out := append(os.Environ(), proxyEnv...)
+	return append(out, env...)
 }
 
 // generateAndSaveReport generates and saves reports in the specified formats.
+// With --artifact-store the reports, the backup bundles of the run and the progress
+// file are then uploaded.
 func generateAndSaveReport(report Report, cfg Config) error {
+	artifacts := map[string]string{} // file -> directory in the store
+	report.Config = reportConfig(cfg)
+	report, err := scrubReport(report)
+	if err != nil {
+		return err
+	}
+	written := report // the artifact store gets the real backup paths and hostname
+	if cfg.ReportRedact {
+		if written, err = redactReport(report, redactNames(cfg, report)); err != nil {
+			return err
+		}
+	}
 	for _, format := range cfg.ReportFormats {
 		timestamp := time.Now().Format("20060102_150405")
 		filename := "migration_report_" + timestamp + "." + format
 		reportPath := filepath.Join(cfg.ReportPath, filename)
-		fmt.Printf("Report (%s) salvato in: %s\n", format, reportPath)
-		if err := generateReport(report, format, reportPath); err != nil {
+		fmt.Printf("Report (%s) saved to: %s\n", format, reportPath)
+		if err := generateReport(written, format, reportPath); err != nil {
 			return err
 		}
+		artifacts[reportPath] = ""
 	}
-	return nil
+	if cfg.ArtifactStore == "" {
+		return nil
+	}
+	for _, s := range report.Summaries {
+		if s.Backup != "" {
+			artifacts[s.Backup] = "backups"
+		}
+	}
+	if cfg.ProgressFile != "" {
+		artifacts[cfg.ProgressFile] = ""
+	}
+	return uploadArtifacts(cfg, artifacts, report.Hostname)
 }
 
 // generateReport generates the report in JSON or HTML and saves it to the specified path.
 func generateReport(report Report, format, path string) error {
 	switch format {
 	case "json":
+		report.SchemaVersion = reportSchemaVersion
 		data, err := json.MarshalIndent(report, "", "  ")
 		if err != nil {
 			return err
@@ -70,7 +148,7 @@ func generateReport(report Report, format, path string) error {
 		html := generateHTML(report)
 		return os.WriteFile(path, []byte(html), 0644)
 	default:
-		return fmt.Errorf("formato report non supportato: %s", format)
+		return fmt.Errorf("unsupported report format: %s", format)
 	}
 }
 
@@ -81,19 +159,27 @@ const (
 
 // getGitRefNames returns the list of branch/tag names.
 func getGitRefNames(repoDir, refType string) ([]string, error) {
+	if nativeGit {
+		switch refType {
+		case RefTypeBranches:
+			return nativeRefNames(repoDir, "refs/heads/")
+		case RefTypeTags:
+			return nativeRefNames(repoDir, "refs/tags/")
+		}
+	}
 	var cmd *exec.Cmd
 	switch refType {
 	case RefTypeBranches:
-		cmd = exec.Command("git", "ls-remote", "--heads", "origin")
+		cmd = exec.Command("git", "for-each-ref", "--format=%(objectname) %(refname)", "refs/heads")
 	case RefTypeTags:
 		cmd = exec.Command("git", "tag")
 	default:
-		return nil, fmt.Errorf("refType non supportato: %s", refType)
+		return nil, fmt.Errorf("unsupported refType: %s", refType)
 	}
 	cmd.Dir = repoDir
 	output, err := cmd.Output()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Errore comando git %s in %s: %v\n", refType, repoDir, err)
+		fmt.Fprintf(os.Stderr, "Error running git %s in %s: %v\n", refType, repoDir, err)
 		return nil, err
 	}
 	var names []string
@@ -118,11 +204,67 @@ func getGitRefNames(repoDir, refType string) ([]string, error) {
 	return names, nil
 }
 
+// lsRemote lists the refs of a remote repository without cloning it (ref name -> SHA).
+// HEAD and peeled tag entries (^{}) are omitted.
+var lsRemote = func(ctx context.Context, env []string, remote string) (map[string]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", remote)
+	cmd.Env = commandEnv(env)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	refs := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.Fields(line)
+		if len(parts) != 2 || parts[1] == "HEAD" || strings.HasSuffix(parts[1], "^{}") {
+			continue
+		}
+		refs[parts[1]] = parts[0]
+	}
+	return refs, nil
+}
+
+// localRefs lists all refs of a local (mirror) repository (ref name -> SHA).
+func localRefs(ctx context.Context, repoDir string) (map[string]string, error) {
+	if nativeGit {
+		return nativeLocalRefs(repoDir)
+	}
+	cmd := exec.CommandContext(ctx, "git", "-C", repoDir, "for-each-ref", "--format=%(objectname) %(refname)")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	refs := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.Fields(line)
+		if len(parts) == 2 {
+			refs[parts[1]] = parts[0]
+		}
+	}
+	return refs, nil
+}
+
+// refsDiverged reports whether a non-empty destination has refs that differ from the source.
+func refsDiverged(src, dst map[string]string) bool {
+	if len(dst) == 0 {
+		return false
+	}
+	if len(src) != len(dst) {
+		return true
+	}
+	for ref, sha := range src {
+		if dst[ref] != sha {
+			return true
+		}
+	}
+	return false
+}
+
 // generateHTML generates an HTML representation of the report as a table, using Bootstrap and the template engine.
 // Program/version/commit/build info is now shown in the footer, right-aligned.
 func generateHTML(report Report) string {
 	const tpl = `<!DOCTYPE html>
-<html lang="it">
+<html lang="en">
 <head>
   <meta charset="UTF-8">
   <title>Migration Report</title>
@@ -139,8 +281,20 @@ func generateHTML(report Report) string {
         <li class="list-group-item"><strong>End Time:</strong> {{ .EndTime.Format "2006-01-02 15:04:05" }}</li>
         <li class="list-group-item"><strong>Duration:</strong> {{ printf "%.2f" .Duration }} minutes</li>
         <li class="list-group-item"><strong>Hostname:</strong> {{ .Hostname }}</li>
+        {{ if .MergedRuns }}<li class="list-group-item"><strong>Merged runs:</strong> {{ range .MergedRuns }}<div class="small">{{ . }}</div>{{ end }}</li>{{ end }}
       </ul>
     </div>
+    {{ with .Capacity }}
+    <div class="col-md-6">
+      <ul class="list-group">
+        <li class="list-group-item"><strong>Throughput:</strong> {{ printf "%.2f" .ThroughputGBPerHour }} GB/hour ({{ .MigratedRepos }} repos, {{ .MigratedBytes }} bytes)</li>
+        <li class="list-group-item"><strong>Time per phase:</strong>
+          {{ range $phase, $secs := .PhaseSeconds }}{{ $phase }} {{ printf "%.0f" $secs }}s; {{ end }}</li>
+        <li class="list-group-item"><strong>Remaining:</strong> {{ .RemainingRepos }} repos, {{ .RemainingBytes }} bytes{{ if gt .RemainingHours 0.0 }}, ~{{ printf "%.1f" .RemainingHours }} hours{{ end }}</li>
+        {{ if .EstimatedRepos }}<li class="list-group-item"><strong>Estimated (dry run):</strong> {{ .EstimatedRepos }} repos, {{ .EstimatedBytes }} bytes, ~{{ printf "%.1f" .EstimatedHours }} hours</li>{{ end }}
+      </ul>
+    </div>
+    {{ end }}
   </div>
   <div class="table-responsive">
     <table class="table table-bordered table-hover align-middle">
@@ -152,14 +306,32 @@ func generateHTML(report Report) string {
           <th>Branches</th>
           <th>Tags</th>
           <th>Size (bytes)</th>
+          <th>Time</th>
           <th>Destination URL</th>
         </tr>
       </thead>
       <tbody>
         {{ range .Summaries }}
         <tr>
-          <td>{{ .Repo }}</td>
-          <td>{{ .Result }}</td>
+          <td>
+            {{ .Repo }}
+            {{ if .SrcProject }}<div class="small">source project: {{ .SrcProject }}</div>{{ end }}
+            {{ if .Owner }}<div class="small">owner: {{ .Owner }}</div>{{ end }}
+            {{ if .SrcRepoID }}<div class="small text-muted">src id: {{ .SrcRepoID }}</div>{{ end }}
+            {{ if .DstRepoID }}<div class="small text-muted">dst id: {{ .DstRepoID }}</div>{{ end }}
+            {{ if .Backup }}<div class="small text-muted">backup: {{ .Backup }}</div>{{ end }}
+            {{ if .Bundle }}<div class="small text-muted">bundle: {{ .Bundle }}</div>{{ end }}
+            {{ if .RolledBack }}<div class="small text-danger">rolled back (destination deleted)</div>{{ end }}
+            {{ if .SourceDeleted }}<div class="small text-warning">source deleted after verification</div>{{ else if .SourceKept }}<div class="small text-muted">source kept: {{ .SourceKept }}</div>{{ end }}
+          </td>
+          <td>
+            {{ .Result }}
+            {{ range .MissingRefs }}<div class="small text-danger">missing: {{ . }}</div>{{ end }}
+            {{ range .DivergentRefs }}<div class="small text-danger">divergent: {{ . }}</div>{{ end }}
+            {{ range .ExtraRefs }}<div class="small text-warning">extra: {{ . }}</div>{{ end }}
+            {{ range .MissingLFSObjects }}<div class="small text-danger">missing LFS: {{ . }}</div>{{ end }}
+            {{ range .Mirrors }}<div class="small">also pushed to {{ . }}</div>{{ end }}
+          </td>
           <td><a href="{{ .SrcWebURL }}" target="_blank">{{ .SrcWebURL }}</a></td>
           <td>
             {{ if .BranchNames }}
@@ -167,6 +339,7 @@ func generateHTML(report Report) string {
                 {{ range .BranchNames }}<li>{{ . }}</li>{{ end }}
               </ul>
             {{ else }}-{{ end }}
+            {{ if .RenamedRefs }}<div class="small">renamed: {{ range .RenamedRefs }}<div>{{ . }}</div>{{ end }}</div>{{ end }}
           </td>
           <td>
             {{ if .TagNames }}
@@ -175,7 +348,15 @@ func generateHTML(report Report) string {
               </ul>
             {{ else }}-{{ end }}
           </td>
-          <td>{{ .Size }}</td>
+          <td>
+            {{ .Size }}
+            {{ if .Commits }}<div class="small">{{ .Commits }} commits, {{ .Contributors }} contributors</div>{{ end }}
+          </td>
+          <td class="small text-nowrap">
+            {{ if .CloneSeconds }}<div>clone {{ printf "%.1f" .CloneSeconds }}s</div>{{ end }}
+            {{ if .PushSeconds }}<div>push {{ printf "%.1f" .PushSeconds }}s</div>{{ end }}
+            {{ if .MiBPerSecond }}<div>{{ printf "%.2f" .MiBPerSecond }} MiB/s</div>{{ end }}
+          </td>
           <td><a href="{{ .DstWebURL }}" target="_blank">{{ .DstWebURL }}</a></td>
         </tr>
         {{ end }}
@@ -198,11 +379,11 @@ func generateHTML(report Report) string {
 `
 	tmpl, err := template.New("report").Parse(tpl)
 	if err != nil {
-		return fmt.Sprintf("Errore template HTML: %v", err)
+		return fmt.Sprintf("HTML template error: %v", err)
 	}
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, report); err != nil {
-		return fmt.Sprintf("Errore rendering HTML: %v", err)
+		return fmt.Sprintf("HTML rendering error: %v", err)
 	}
 	return buf.String()
 }
@@ -243,6 +424,45 @@ func printSummary(results []Summary) {
 	}
 	fmt.Println(sep)
 	fmt.Println(strings.Repeat("=", 32))
+	printSlowest(results)
+
+	// Point to the explanation of every problem found
+	seen := map[string]bool{}
+	for _, s := range results {
+		if s.Code == "" || seen[s.Code] || s.Code == "OK" || s.Code == "DRY_RUN" || s.Code == "SYNCED" || s.Code == "IN_SYNC" || s.Code == "VERIFIED" || s.Code == "EXPORTED" {
+			continue
+		}
+		seen[s.Code] = true
+		fmt.Printf("%s: %s (run '%s explain %s')\n", s.Code, explanations[s.Code].Title, prog(), s.Code)
+	}
+}
+
+// slowestShown is the number of repositories listed by printSlowest.
+const slowestShown = 5
+
+// printSlowest lists the repositories that took the longest to clone and push, with their
+// effective throughput, to spot the pathological ones.
+func printSlowest(results []Summary) {
+	var timed []Summary
+	for _, s := range results {
+		if s.CloneSeconds+s.PushSeconds > 0 {
+			timed = append(timed, s)
+		}
+	}
+	if len(timed) < 2 {
+		return
+	}
+	sort.SliceStable(timed, func(i, j int) bool {
+		return timed[i].CloneSeconds+timed[i].PushSeconds > timed[j].CloneSeconds+timed[j].PushSeconds
+	})
+	fmt.Println("Slowest repositories:")
+	for _, s := range timed[:min(slowestShown, len(timed))] {
+		line := fmt.Sprintf("  %s: clone %.1fs, push %.1fs", s.Repo, s.CloneSeconds, s.PushSeconds)
+		if s.MiBPerSecond > 0 {
+			line += fmt.Sprintf(", %s at %.2f MiB/s", formatBytes(s.Size), s.MiBPerSecond)
+		}
+		fmt.Println(line)
+	}
 }
 
 // parseElement parses a single element (number or range) and adds
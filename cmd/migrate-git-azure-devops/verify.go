@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ResultVerified is the result of a repository whose refs are identical on both sides.
+const ResultVerified = "VERIFIED"
+
+// ResultAlreadyMigrated is the result of a repository whose destination already exposes
+// exactly the branches and tags the migration would push: clone and push are skipped.
+const ResultAlreadyMigrated = "ALREADY MIGRATED"
+
+// alreadyMigrated compares, before any clone, the refs of source and destination with
+// ls-remote: it reports whether the destination holds every branch and tag of the source
+// (manifest branch filter and --ref-rename applied) at the same SHA, and no other. The
+// provenance tags of previous runs are not counted as differences. Rewritten histories
+// (--exclude-paths, --rewrite-config, --submodules commit) never match and are not compared.
+func alreadyMigrated(ctx context.Context, cfg Config, override RepoOverride, srcURL string, srcEnv []string, dstURL string, dstEnv []string) bool {
+	if len(cfg.ExcludePaths) > 0 || cfg.Rewrite != nil || cfg.Submodules != nil && cfg.Submodules.Mode == SubmodulesCommit {
+		return false
+	}
+	srcRefs, err := lsRemote(ctx, srcEnv, srcURL)
+	if err != nil {
+		return false
+	}
+	dstRefs, err := lsRemote(ctx, dstEnv, dstURL)
+	if err != nil {
+		return false
+	}
+	expected := expectedRefs(cfg, override, srcRefs)
+	if len(expected) == 0 {
+		return false // empty source: nothing proves the migration
+	}
+	if cfg.Provenance == ProvenanceTag {
+		tag := "refs/tags/" + strings.NewReplacer("{date}", "*", "{run}", "*").Replace(cfg.ProvenanceTag)
+		for ref := range dstRefs {
+			if ok, _ := path.Match(tag, ref); ok {
+				delete(dstRefs, ref)
+			}
+		}
+	}
+	missing, divergent, extra := compareRefs(expected, dstRefs)
+	return len(missing) == 0 && len(divergent) == 0 && len(extra) == 0
+}
+
+// expectedRefs returns the branches and tags the destination must expose after the
+// migration of a source repository: the manifest branch filter and the --ref-rename
+// rules are applied, as the migration does before the push.
+func expectedRefs(cfg Config, override RepoOverride, srcRefs map[string]string) map[string]string {
+	out := map[string]string{}
+	for ref, sha := range srcRefs {
+		switch {
+		case strings.HasPrefix(ref, "refs/tags/"):
+			out[ref] = sha
+		case strings.HasPrefix(ref, "refs/heads/"):
+			branch := strings.TrimPrefix(ref, "refs/heads/")
+			if len(override.Branches) > 0 {
+				keep := false
+				for _, g := range override.Branches {
+					if ok, _ := path.Match(g, branch); ok {
+						keep = true
+						break
+					}
+				}
+				if !keep {
+					continue
+				}
+			}
+			for _, r := range cfg.RefRenameRules {
+				branch = r.apply(branch)
+			}
+			out["refs/heads/"+branch] = sha
+		}
+	}
+	return out
+}
+
+// compareRefs compares the expected refs with the destination ones, returning the refs
+// missing at destination, those pointing to another SHA and those only at destination.
+// Only branches and tags are compared.
+func compareRefs(expected, dst map[string]string) (missing, divergent, extra []string) {
+	for ref, sha := range expected {
+		got, ok := dst[ref]
+		switch {
+		case !ok:
+			missing = append(missing, ref)
+		case got != sha:
+			divergent = append(divergent, fmt.Sprintf("%s (source %.10s, destination %.10s)", ref, sha, got))
+		}
+	}
+	for ref := range dst {
+		if _, ok := expected[ref]; !ok && (strings.HasPrefix(ref, "refs/heads/") || strings.HasPrefix(ref, "refs/tags/")) {
+			extra = append(extra, ref)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(divergent)
+	sort.Strings(extra)
+	return missing, divergent, extra
+}
+
+// cmdVerify proves the equivalence of the migrated repositories: for every selected
+// repository (repo list with its mapping, filter or globs) the refs of source and
+// destination are read with ls-remote and compared SHA by SHA. The outcome is printed
+// and saved in the usual report formats; the command fails if any repository differs.
+// With --lfs the LFS pointers of the destination history must also resolve to uploaded
+// objects, since a mirror push succeeds even when the LFS content was never copied.
+func cmdVerify(ctx context.Context, cfg Config) error {
+	startTime := time.Now()
+	hostname, _ := os.Hostname()
+
+	srcRepos, err := getRepos(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, cfg.Trace)
+	if err != nil {
+		return fmt.Errorf("call failed for source %s/%s: %w", cfg.SrcOrg, cfg.SrcProject, err)
+	}
+	selected, preSummary, err := selectRepos(cfg, srcRepos)
+	if err != nil {
+		return err
+	}
+	dstRepos, err := getRepos(ctx, cfg.DstOrg, cfg.DstProject, cfg.DstPAT, cfg.Trace)
+	if err != nil {
+		return fmt.Errorf("call failed for destination %s/%s: %w", cfg.DstOrg, cfg.DstProject, err)
+	}
+	dstByName := map[string]Repo{}
+	for _, r := range dstRepos {
+		dstByName[r.Name] = r
+	}
+
+	var tmpDir string
+	if cfg.VerifyLFS {
+		if tmpDir, err = os.MkdirTemp(cfg.TmpDir, "tmp_verify_lfs_"); err != nil {
+			return err
+		}
+		defer func() {
+			if err := os.RemoveAll(tmpDir); err != nil {
+				fmt.Fprintln(os.Stderr, "Error removing temporary directory:", err)
+			}
+		}()
+	}
+
+	var results []Summary
+	for i, r := range selected {
+		dstRepoName := destinationName(cfg, r.Name)
+		fmt.Printf("[%d/%d] verify %s\n", i+1, len(selected), r.Name)
+		dstProjectEnc := url.PathEscape(cfg.DstProject)
+		sum := Summary{Repo: r.Name, SrcWebURL: r.WebURL, SrcRepoID: r.ID, Owner: cfg.RepoOwners[r.Name]}
+		sum.DstWebURL = fmt.Sprintf("https://dev.azure.com/%s/%s/_git/%s", cfg.DstOrg, dstProjectEnc, url.PathEscape(dstRepoName))
+
+		dst, ok := dstByName[dstRepoName]
+		if !ok {
+			sum.Result = "ERROR: not migrated"
+			sum.ErrDetails = "destination repository missing"
+			fmt.Println("  Error: destination repository missing")
+			results = append(results, sum)
+			continue
+		}
+		sum.DstRepoID = dst.ID
+		srcURL, srcEnv := gitRemote(cfg, cfg.SrcOrg, url.PathEscape(cfg.SrcProject), url.PathEscape(r.Name), cfg.SrcPAT)
+		dstURL, dstEnv := gitRemote(cfg, cfg.DstOrg, dstProjectEnc, url.PathEscape(dstRepoName), cfg.DstPAT)
+		sum.DstClone = dstURL
+		srcRefs, err := lsRemote(ctx, srcEnv, srcURL)
+		if err != nil {
+			sum.Result = "ERROR: source not found"
+			sum.ErrDetails = fmt.Sprintf("ls-remote source: %v", err)
+			fmt.Println("  Error reading the source refs:", err)
+			results = append(results, sum)
+			continue
+		}
+		dstRefs, err := lsRemote(ctx, dstEnv, dstURL)
+		if err != nil {
+			sum.Result = "ERROR: destination"
+			sum.ErrDetails = fmt.Sprintf("ls-remote destination: %v", err)
+			fmt.Println("  Error reading the destination refs:", err)
+			results = append(results, sum)
+			continue
+		}
+
+		expected := expectedRefs(cfg, cfg.RepoOverrides[r.Name], srcRefs)
+		sum.MissingRefs, sum.DivergentRefs, sum.ExtraRefs = compareRefs(expected, dstRefs)
+		for ref := range expected {
+			if strings.HasPrefix(ref, "refs/heads/") {
+				sum.NumBranches++
+			} else {
+				sum.NumTags++
+			}
+		}
+		if cfg.VerifyLFS {
+			if err := verifyLFS(ctx, cfg, tmpDir, dstRepoName, dstURL, dstEnv, &sum); err != nil {
+				sum.Result = "ERROR: lfs verify"
+				sum.ErrDetails = err.Error()
+				fmt.Println("  Error verifying LFS objects:", err)
+				results = append(results, sum)
+				continue
+			}
+			for _, obj := range sum.MissingLFSObjects {
+				fmt.Printf("  missing LFS object: %s\n", obj)
+			}
+		}
+		switch {
+		case len(sum.MissingRefs)+len(sum.DivergentRefs)+len(sum.ExtraRefs) == 0 && len(sum.MissingLFSObjects) == 0:
+			if cfg.VerifyLFS {
+				fmt.Printf("  OK, %d refs identical, %d LFS objects present.\n", len(expected), sum.LFSObjects)
+			} else {
+				fmt.Printf("  OK, %d refs identical.\n", len(expected))
+			}
+			sum.Result = ResultVerified
+		case len(sum.MissingRefs)+len(sum.DivergentRefs)+len(sum.ExtraRefs) == 0:
+			sum.Result = "ERROR: lfs missing"
+			sum.ErrDetails = fmt.Sprintf("%d of %d LFS objects missing at destination", len(sum.MissingLFSObjects), sum.LFSObjects)
+		default:
+			sum.Result = "ERROR: verify"
+			sum.ErrDetails = fmt.Sprintf("%d missing, %d divergent, %d only at destination",
+				len(sum.MissingRefs), len(sum.DivergentRefs), len(sum.ExtraRefs))
+			if len(sum.MissingLFSObjects) > 0 {
+				sum.ErrDetails += fmt.Sprintf("; %d LFS objects missing", len(sum.MissingLFSObjects))
+			}
+			for _, ref := range sum.MissingRefs {
+				fmt.Printf("  missing:   %s\n", ref)
+			}
+			for _, ref := range sum.DivergentRefs {
+				fmt.Printf("  divergent: %s\n", ref)
+			}
+			for _, ref := range sum.ExtraRefs {
+				fmt.Printf("  extra:     %s\n", ref)
+			}
+		}
+		results = append(results, sum)
+	}
+	fmt.Println()
+
+	all := append(preSummary, results...)
+	classifyResults(all)
+	printSummary(all)
+	failed := 0
+	for _, s := range all {
+		if s.Result != ResultVerified && !s.Skipped {
+			failed++
+		}
+	}
+	endTime := time.Now()
+	if cfg.ReportFormats != nil {
+		report := Report{
+			StartTime:   startTime,
+			EndTime:     endTime,
+			Duration:    endTime.Sub(startTime).Minutes(),
+			Hostname:    hostname,
+			Summaries:   all,
+			ProgramName: prog(),
+			Version:     version,
+			Commit:      commit,
+			BuildDate:   date,
+		}
+		if err := generateAndSaveReport(report, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, "Report generation error:", err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("verification failed for %d of %d repositories", failed, len(all))
+	}
+	fmt.Printf("All %d repositories verified identical.\n", len(all))
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpectedRefs(t *testing.T) {
+	rule, err := parseRenameRegex("--ref-rename", "s#^Feature/#feature/#")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := map[string]string{
+		"refs/heads/main":          "a1",
+		"refs/heads/Feature/login": "b2",
+		"refs/heads/release/1.0":   "c3",
+		"refs/heads/sandbox":       "d4",
+		"refs/tags/v1.0":           "e5",
+		"refs/pull/7/merge":        "f6",
+		"HEAD":                     "a1",
+	}
+	tests := []struct {
+		name     string
+		cfg      Config
+		override RepoOverride
+		want     map[string]string
+	}{
+		{"branches and tags only", Config{}, RepoOverride{}, map[string]string{
+			"refs/heads/main": "a1", "refs/heads/Feature/login": "b2", "refs/heads/release/1.0": "c3",
+			"refs/heads/sandbox": "d4", "refs/tags/v1.0": "e5",
+		}},
+		{"manifest branch filter", Config{}, RepoOverride{Branches: []string{"main", "release/*"}}, map[string]string{
+			"refs/heads/main": "a1", "refs/heads/release/1.0": "c3", "refs/tags/v1.0": "e5",
+		}},
+		{"ref rename", Config{RefRenameRules: []RenameRule{rule}}, RepoOverride{}, map[string]string{
+			"refs/heads/main": "a1", "refs/heads/feature/login": "b2", "refs/heads/release/1.0": "c3",
+			"refs/heads/sandbox": "d4", "refs/tags/v1.0": "e5",
+		}},
+		{"filter on the source names, then rename", Config{RefRenameRules: []RenameRule{rule}}, RepoOverride{Branches: []string{"Feature/*"}}, map[string]string{
+			"refs/heads/feature/login": "b2", "refs/tags/v1.0": "e5",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expectedRefs(tt.cfg, tt.override, src); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expectedRefs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareRefs(t *testing.T) {
+	tests := []struct {
+		name                      string
+		expected, dst             map[string]string
+		missing, divergent, extra []string
+	}{
+		{"identical",
+			map[string]string{"refs/heads/main": "a1", "refs/tags/v1": "b2"},
+			map[string]string{"refs/heads/main": "a1", "refs/tags/v1": "b2", "HEAD": "a1", "refs/pull/1/merge": "c3"},
+			nil, nil, nil},
+		{"missing",
+			map[string]string{"refs/heads/main": "a1", "refs/heads/dev": "b2", "refs/tags/v1": "c3"},
+			map[string]string{"refs/heads/main": "a1"},
+			[]string{"refs/heads/dev", "refs/tags/v1"}, nil, nil},
+		{"divergent",
+			map[string]string{"refs/heads/main": "1111111111aaaa"},
+			map[string]string{"refs/heads/main": "2222222222bbbb"},
+			nil, []string{"refs/heads/main (source 1111111111, destination 2222222222)"}, nil},
+		{"extra",
+			map[string]string{"refs/heads/main": "a1"},
+			map[string]string{"refs/heads/main": "a1", "refs/heads/old": "b2", "refs/tags/x": "c3"},
+			nil, nil, []string{"refs/heads/old", "refs/tags/x"}},
+		{"empty destination", map[string]string{"refs/heads/main": "a1"}, nil, []string{"refs/heads/main"}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, divergent, extra := compareRefs(tt.expected, tt.dst)
+			if !reflect.DeepEqual(missing, tt.missing) || !reflect.DeepEqual(divergent, tt.divergent) || !reflect.DeepEqual(extra, tt.extra) {
+				t.Errorf("compareRefs() = %v, %v, %v; want %v, %v, %v", missing, divergent, extra, tt.missing, tt.divergent, tt.extra)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// editDestinationNames lets the wizard user override the destination name of the selected
+// repositories, one by one: the proposed name (mapping and --rename-* transforms applied)
+// is kept with Enter. The overrides go into cfg.RepoMap, as from a --repo-list CSV, and
+// the selection can be saved as such a CSV to be reused non-interactively.
+func editDestinationNames(in *bufio.Reader, cfg *Config, selected []Repo) error {
+	fmt.Print("\nEdit the destination names? [y/N]: ")
+	ans, _ := in.ReadString('\n')
+	ans = strings.TrimSpace(strings.ToLower(ans))
+	if ans != "s" && ans != "si" && ans != "y" && ans != "yes" {
+		return nil
+	}
+	if cfg.RepoMap == nil {
+		cfg.RepoMap = make(map[string]string)
+	}
+
+	fmt.Println("Enter the destination name of each repository, Enter keeps the proposed one:")
+	for _, r := range selected {
+		proposed := destinationName(*cfg, r.Name)
+		for {
+			fmt.Printf("  %s [%s]: ", r.Name, proposed)
+			line, err := in.ReadString('\n')
+			name := strings.TrimSpace(line)
+			if name == "" || name == proposed {
+				if err != nil {
+					return fmt.Errorf("reading the destination name of %s: %w", r.Name, err)
+				}
+				break
+			}
+			if problems := validateRepoName(name); len(problems) > 0 {
+				fmt.Printf("    invalid name: %s\n", strings.Join(problems, "; "))
+				continue
+			}
+			// A mapping to the source name itself is not an override: the transforms would apply
+			if name == r.Name && renameDestination(*cfg, r.Name) != r.Name {
+				fmt.Println("    the source name can't override the --rename-* transforms, run without them")
+				continue
+			}
+			cfg.RepoMap[r.Name] = name
+			break
+		}
+	}
+
+	fmt.Print("\nSave the selection as a --repo-list CSV (file name, Enter to skip): ")
+	file, _ := in.ReadString('\n')
+	if file = strings.TrimSpace(file); file == "" {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString("# source,destination,owner\n")
+	for _, r := range selected {
+		fmt.Fprintf(&b, "%s,%s", r.Name, destinationName(*cfg, r.Name))
+		if owner := cfg.RepoOwners[r.Name]; owner != "" {
+			fmt.Fprintf(&b, ",%s", owner)
+		}
+		b.WriteString("\n")
+	}
+	if err := os.WriteFile(file, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("saving the repo list: %w", err)
+	}
+	fmt.Println("Repo list saved to", file)
+	return nil
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// wizardRow is a source repository listed by the full-screen wizard.
+type wizardRow struct {
+	repo    Repo
+	dst     string       // destination name, after mapping and renames
+	exists  bool         // the destination repository already exists
+	details *RepoDetails // nil while loading
+	failed  bool         // the details could not be read
+	checked bool
+	force   bool // push --force on the existing destination
+}
+
+// wizardDetailsMsg carries the activity details of a row, loaded in background.
+type wizardDetailsMsg struct {
+	row     int
+	details RepoDetails
+	err     error
+}
+
+// wizardModel is the bubbletea model of the full-screen wizard: a search box filtering the
+// repositories, a checkbox list with size, default branch, branches, last push and
+// destination state, and the force push toggle of the repositories already at destination.
+type wizardModel struct {
+	title     string
+	rows      []*wizardRow
+	visible   []int // rows matching the search
+	cursor    int   // position in visible
+	offset    int   // first line of visible shown
+	height    int   // lines available for the list
+	search    string
+	searching bool
+	confirmed bool
+	load      func(r Repo) (RepoDetails, error)
+	sem       chan struct{} // limits the detail calls in flight
+}
+
+// fuzzyMatch reports whether the characters of pattern appear in s in order,
+// case-insensitively ("mgapi" matches "migration-api").
+func fuzzyMatch(pattern, s string) bool {
+	s = strings.ToLower(s)
+	for _, c := range strings.ToLower(pattern) {
+		i := strings.IndexRune(s, c)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(c)):]
+	}
+	return true
+}
+
+// truncate shortens s to n characters, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func (m *wizardModel) filter() {
+	m.visible = m.visible[:0]
+	for i, row := range m.rows {
+		if fuzzyMatch(m.search, row.repo.Name) || fuzzyMatch(m.search, row.dst) {
+			m.visible = append(m.visible, i)
+		}
+	}
+	m.cursor, m.offset = 0, 0
+}
+
+func (m *wizardModel) loadDetails(i int) tea.Cmd {
+	r := m.rows[i].repo
+	return func() tea.Msg {
+		m.sem <- struct{}{}
+		defer func() { <-m.sem }()
+		d, err := m.load(r)
+		return wizardDetailsMsg{row: i, details: d, err: err}
+	}
+}
+
+func (m *wizardModel) Init() tea.Cmd {
+	var cmds []tea.Cmd
+	for i, row := range m.rows {
+		if row.repo.IsDisabled {
+			row.details = &RepoDetails{} // activity APIs fail on disabled repositories
+			continue
+		}
+		cmds = append(cmds, m.loadDetails(i))
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m *wizardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = max(msg.Height-6, 1) // title, search, header, blank, help
+	case wizardDetailsMsg:
+		m.rows[msg.row].details = &msg.details
+		m.rows[msg.row].failed = msg.err != nil
+	case tea.KeyMsg:
+		if m.searching {
+			switch msg.Type {
+			case tea.KeyCtrlC:
+				return m, tea.Quit
+			case tea.KeyEnter:
+				m.searching = false
+			case tea.KeyEsc:
+				m.searching, m.search = false, ""
+				m.filter()
+			case tea.KeyBackspace:
+				if r := []rune(m.search); len(r) > 0 {
+					m.search = string(r[:len(r)-1])
+					m.filter()
+				}
+			case tea.KeyRunes, tea.KeySpace:
+				m.search += string(msg.Runes)
+				m.filter()
+			}
+			return m, nil
+		}
+		switch msg.String() {
+		case "esc":
+			if m.search == "" {
+				return m, tea.Quit
+			}
+			m.search = ""
+			m.filter()
+		case "ctrl+c", "q":
+			return m, tea.Quit
+		case "enter":
+			m.confirmed = true
+			return m, tea.Quit
+		case "/":
+			m.searching = true
+		case "up", "k":
+			m.cursor = max(m.cursor-1, 0)
+		case "down", "j":
+			m.cursor = min(m.cursor+1, max(len(m.visible)-1, 0))
+		case "pgup":
+			m.cursor = max(m.cursor-m.height, 0)
+		case "pgdown":
+			m.cursor = min(m.cursor+m.height, max(len(m.visible)-1, 0))
+		case "home", "g":
+			m.cursor = 0
+		case "end", "G":
+			m.cursor = max(len(m.visible)-1, 0)
+		case " ", "x":
+			if row := m.current(); row != nil {
+				row.checked = !row.checked
+			}
+		case "a":
+			// Toggles the repositories matching the search: all checked unless they already are
+			all := true
+			for _, i := range m.visible {
+				all = all && m.rows[i].checked
+			}
+			for _, i := range m.visible {
+				m.rows[i].checked = !all
+			}
+		case "f":
+			if row := m.current(); row != nil && row.exists {
+				row.force = !row.force
+			}
+		}
+	}
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	} else if m.height > 0 && m.cursor >= m.offset+m.height {
+		m.offset = m.cursor - m.height + 1
+	}
+	return m, nil
+}
+
+// current returns the row under the cursor, nil when the search matches nothing.
+func (m *wizardModel) current() *wizardRow {
+	if len(m.visible) == 0 {
+		return nil
+	}
+	return m.rows[m.visible[m.cursor]]
+}
+
+func (m *wizardModel) View() string {
+	var b strings.Builder
+	checked := 0
+	for _, row := range m.rows {
+		if row.checked {
+			checked++
+		}
+	}
+	fmt.Fprintf(&b, "%s: %d selected of %d\n", m.title, checked, len(m.rows))
+	switch {
+	case m.searching:
+		fmt.Fprintf(&b, "Search: %s█\n", m.search)
+	case m.search != "":
+		fmt.Fprintf(&b, "Search: %s (%d matching)\n", m.search, len(m.visible))
+	default:
+		b.WriteString("Search: press / to filter\n")
+	}
+
+	nameWidth, branchWidth := 20, len("BRANCH")
+	for _, row := range m.rows {
+		nameWidth = max(nameWidth, min(len([]rune(row.label())), 50))
+		branchWidth = max(branchWidth, min(len([]rune(defaultBranchLabel(row.repo))), 20))
+	}
+	fmt.Fprintf(&b, "       %-*s %10s  %-*s %8s  %-16s  %s\n", nameWidth, "REPOSITORY", "SIZE", branchWidth, "BRANCH",
+		"BRANCHES", "LAST PUSH", "DESTINATION")
+	end := min(m.offset+m.height, len(m.visible))
+	for pos := m.offset; pos < end; pos++ {
+		row := m.rows[m.visible[pos]]
+		cursor := " "
+		if pos == m.cursor {
+			cursor = ">"
+		}
+		box := "[ ]"
+		if row.checked {
+			box = "[x]"
+		}
+		branches, lastPush := "…", "…"
+		switch {
+		case row.repo.IsDisabled:
+			branches, lastPush = "-", "-"
+		case row.failed:
+			branches, lastPush = "?", "?"
+		case row.details != nil:
+			branches, lastPush = fmt.Sprint(row.details.NumBranches), lastPushLabel(*row.details)
+		}
+		dest := "new"
+		if row.exists {
+			dest = "exists, skipped"
+			if row.force {
+				dest = "exists, force push"
+			}
+		}
+		if row.repo.IsDisabled {
+			dest += " (disabled)"
+		}
+		fmt.Fprintf(&b, "%s %s  %-*s %10s  %-*s %8s  %-16s  %s\n", cursor, box, nameWidth, truncate(row.label(), nameWidth),
+			formatBytes(row.repo.Size), branchWidth, truncate(defaultBranchLabel(row.repo), branchWidth), branches, lastPush, dest)
+	}
+	if len(m.visible) == 0 {
+		b.WriteString("  no repository matches the search\n")
+	}
+	b.WriteString("\n↑/↓ move  space select  a select all  f force push  / search  esc clear  enter continue  q cancel")
+	return b.String()
+}
+
+// label is the name shown in the list: "source -> destination" when renamed.
+func (row *wizardRow) label() string {
+	if row.dst != row.repo.Name {
+		return row.repo.Name + " -> " + row.dst
+	}
+	return row.repo.Name
+}
+
+// selectReposTUI runs the full-screen wizard over the source repositories and returns the
+// ones checked, with the force push chosen for each of those already at destination.
+// ok is false when the user cancels.
+func selectReposTUI(ctx context.Context, cfg Config, repos []Repo, exists map[string]bool) (selected []Repo, force map[string]bool, ok bool, err error) {
+	m := &wizardModel{
+		title: fmt.Sprintf("Repositories of %s/%s -> %s/%s", cfg.SrcOrg, cfg.SrcProject, cfg.DstOrg, cfg.DstProject),
+		load: func(r Repo) (RepoDetails, error) {
+			return getRepoDetails(ctx, cfg.SrcOrg, cfg.SrcProject, cfg.SrcPAT, r.ID, cfg.Trace)
+		},
+		sem:    make(chan struct{}, detailCalls),
+		height: 20,
+	}
+	for _, r := range repos {
+		dst := destinationName(cfg, r.Name)
+		m.rows = append(m.rows, &wizardRow{repo: r, dst: dst, exists: exists[dst], force: cfg.ForcePush})
+	}
+	m.filter()
+
+	if _, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx)).Run(); err != nil {
+		return nil, nil, false, fmt.Errorf("wizard: %w", err)
+	}
+	if !m.confirmed {
+		return nil, nil, false, nil
+	}
+	force = map[string]bool{}
+	for _, row := range m.rows {
+		if row.checked {
+			selected = append(selected, row.repo)
+			if row.exists {
+				force[row.repo.Name] = row.force
+			}
+		}
+	}
+	return selected, force, true, nil
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	st, err := f.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
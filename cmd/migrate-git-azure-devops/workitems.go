@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// workItemRefRe matches the work item mentions of a commit message: "#1234" (Azure Repos)
+// and "AB#1234" (Azure Boards from GitHub). HTML entities (&#39;) and URL fragments are not.
+var workItemRefRe = regexp.MustCompile(`(?i)(?:^|[^\w&#/])((?:AB)?#(\d+))\b`)
+
+// WorkItemRef is a work item mentioned by a commit message.
+type WorkItemRef struct {
+	Commit string // SHA of the commit (as pushed to destination)
+	Ref    string // Mention as written ("#1234", "AB#1234")
+	ID     int    // Work item ID in the source project
+}
+
+// inventoryWorkItemRefs lists the work item mentions in the commit messages of every ref of
+// the mirror, one entry per commit and work item.
+func inventoryWorkItemRefs(ctx context.Context, repoDir string) ([]WorkItemRef, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", repoDir, "log", "--all", "--format=%H%x00%B%x1e").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+	var refs []WorkItemRef
+	for _, rec := range bytes.Split(out, []byte{0x1e}) {
+		sha, msg, ok := bytes.Cut(bytes.TrimLeft(rec, "\n"), []byte{0})
+		if !ok {
+			continue
+		}
+		seen := map[int]bool{}
+		for _, m := range workItemRefRe.FindAllSubmatch(msg, -1) {
+			id, err := strconv.Atoi(string(m[2]))
+			if err != nil || id == 0 || seen[id] {
+				continue
+			}
+			seen[id] = true
+			refs = append(refs, WorkItemRef{Commit: string(sha), Ref: strings.ToUpper(string(m[1])), ID: id})
+		}
+	}
+	return refs, nil
+}
+
+// workItemIDs returns the distinct work item IDs of the references, sorted.
+func workItemIDs(refs []WorkItemRef) []int {
+	var ids []int
+	for _, r := range refs {
+		ids = append(ids, r.ID)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// workItemCSVHeader is the header of the --workitem-refs-csv file.
+var workItemCSVHeader = []string{"repository", "destination", "commit", "reference", "workItemId", "commitUrl"}
+
+// initWorkItemCSV truncates the --workitem-refs-csv file and writes its header.
+func initWorkItemCSV(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("--workitem-refs-csv: %w", err)
+	}
+	w := csv.NewWriter(f)
+	_ = w.Write(workItemCSVHeader)
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("--workitem-refs-csv: %w", err)
+	}
+	return f.Close()
+}
+
+// appendWorkItemCSV appends the references of a repository to the --workitem-refs-csv file,
+// with the URL of each commit at destination: joined with the old -> new work item ID
+// mapping of the work item migration, it is what is needed to link them again.
+func appendWorkItemCSV(file string, sum Summary, refs []WorkItemRef) error {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	for _, r := range refs {
+		commitURL := ""
+		if sum.DstWebURL != "" {
+			commitURL = sum.DstWebURL + "/commit/" + r.Commit
+		}
+		_ = w.Write([]string{sum.Repo, sum.DstWebURL, r.Commit, r.Ref, strconv.Itoa(r.ID), commitURL})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}